@@ -0,0 +1,576 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+	"github.com/neutrinoguy/timehammer/internal/control"
+	"github.com/neutrinoguy/timehammer/internal/fingerprint"
+	"github.com/neutrinoguy/timehammer/internal/logger"
+	"github.com/neutrinoguy/timehammer/internal/probe"
+	"github.com/neutrinoguy/timehammer/internal/report"
+	"github.com/neutrinoguy/timehammer/internal/session"
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// runCommand executes a CLI subcommand and returns the exit code
+func runCommand(name string, args []string) int {
+	switch name {
+	case "status":
+		return cmdStatus()
+	case "replay":
+		return cmdReplay(args)
+	case "validate":
+		return cmdValidate(args)
+	case "export":
+		return cmdExport(args)
+	case "fingerprint":
+		return cmdFingerprint(args)
+	case "report":
+		return cmdReport(args)
+	case "config-keys":
+		return cmdConfigKeys()
+	case "capabilities":
+		return cmdCapabilities(args)
+	case "schema":
+		return cmdSchema(args)
+	case "selftest":
+		return cmdSelftest(args)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s (see --help)\n", name)
+		return 2
+	}
+}
+
+// parseArgs parses flags that may appear before, between or after the
+// positional arguments, and returns the positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// newFlagSet creates the flag set of a subcommand
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: timehammer %s %s\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// cmdStatus prints the one-line status of the running instance
+func cmdStatus() int {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		return 1
+	}
+
+	line, err := control.FetchStatus(cfg.Control.Address)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Println(line)
+	return 0
+}
+
+// cmdReplay replays a saved session or vector file against a target
+func cmdReplay(args []string) int {
+	fs := newFlagSet("replay", "[OPTIONS] SESSION_ID|VECTOR_FILE HOST[:PORT]")
+	speed := fs.Float64("speed", 1, "Timing multiplier (2 = twice as fast)")
+	dryRun := fs.Bool("dry-run", false, "Log what would be sent without sending")
+	noTiming := fs.Bool("no-timing", false, "Send back to back instead of keeping the recorded gaps")
+	upstream := fs.Bool("upstream", false, "Also replay upstream traffic")
+	rewrite := fs.Bool("rewrite-timestamps", false, "Move the recorded timestamps to the present, keeping their offsets")
+
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(pos) != 2 || *speed <= 0 {
+		fs.Usage()
+		return 2
+	}
+
+	sess, err := session.LoadSource(pos[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	stop := printLogs(logger.GetLogger(), os.Stderr)
+	err = session.NewReplayer().Replay(sess, pos[1], session.ReplayOptions{
+		PreserveTiming:    !*noTiming,
+		Speed:             *speed,
+		DryRun:            *dryRun,
+		SkipUpstream:      !*upstream,
+		RewriteTimestamps: *rewrite,
+	})
+	stop()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// cmdValidate checks a configuration file without starting anything
+func cmdValidate(args []string) int {
+	fs := newFlagSet("validate", "[CONFIG_FILE]")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(pos) > 1 {
+		fs.Usage()
+		return 2
+	}
+
+	path := ""
+	if len(pos) == 1 {
+		path = pos[0]
+	} else if path, err = config.GetConfigPath(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if _, err := config.LoadFile(path); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("%s: OK\n", path)
+	return 0
+}
+
+// cmdConfigKeys lists the config keys with the environment variables that
+// override them
+func cmdConfigKeys() int {
+	for _, key := range config.Keys() {
+		fmt.Printf("%-55s %s\n", key, config.EnvName(key))
+	}
+	return 0
+}
+
+// cmdCapabilities prints the version, attacks and features of this build,
+// as the control API and the REPL describe them
+func cmdCapabilities(args []string) int {
+	fs := newFlagSet("capabilities", "[-json]")
+	asJSON := fs.Bool("json", false, "Print the JSON that GET /capabilities returns")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(pos) != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	out, err := control.DescribeCapabilities(*asJSON)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Println(out)
+	return 0
+}
+
+// cmdSchema writes the JSON Schema of the config file
+func cmdSchema(args []string) int {
+	fs := newFlagSet("schema", "[-o FILE]")
+	out := fs.String("o", "", "Output file (default: stdout)")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(pos) != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	schema, err := config.JSONSchema()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	schema = append(schema, '\n')
+	if *out == "" {
+		os.Stdout.Write(schema)
+		return 0
+	}
+	if err := os.WriteFile(*out, schema, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Schema written to %s\n", *out)
+	return 0
+}
+
+// cmdSelftest runs each attack against the built-in probe client and scores
+// whether it does what it claims
+func cmdSelftest(args []string) int {
+	fs := newFlagSet("selftest", "[OPTIONS] [ATTACK...]")
+	port := fs.Int("port", 0, "Loopback port of the test server (default: any free port)")
+	threshold := fs.Float64("threshold", probe.DefaultThreshold, "Score an attack needs to pass (0-1)")
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return 2
+	}
+	if *threshold <= 0 || *threshold > 1 {
+		fs.Usage()
+		return 2
+	}
+
+	if !*asJSON {
+		fmt.Println("🧪 Running attack self-test...")
+	}
+	res, err := probe.Run(probe.Options{Port: *port, Attacks: pos, Threshold: *threshold})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if *asJSON {
+		data, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		fmt.Println(string(data))
+	} else {
+		writeSelftestReport(os.Stdout, res)
+	}
+	if !res.Passed() {
+		return 1
+	}
+	return 0
+}
+
+// writeSelftestReport prints one line per attack with its score
+func writeSelftestReport(w io.Writer, res *probe.Report) {
+	fmt.Fprintf(w, "\n📋 Self-test against %s (%v, baseline offset %+v)\n",
+		res.Address, res.Duration.Round(time.Millisecond), res.Baseline.Offset.Round(time.Microsecond))
+	failed := 0
+	for _, s := range res.Scores {
+		mark := "✅"
+		if !s.Passed {
+			mark = "❌"
+			failed++
+		}
+		fmt.Fprintf(w, "  %s %-15s %3.0f%%  %s\n", mark, s.Attack, 100*s.Score, s.Observed)
+		if !s.Passed {
+			fmt.Fprintf(w, "     %-15s       expected %s\n", "", s.Expected)
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(w, "💥 %d of %d attacks below %.0f%%\n", failed, len(res.Scores), 100*res.Threshold)
+		return
+	}
+	fmt.Fprintf(w, "🏁 All %d attacks effective\n", len(res.Scores))
+}
+
+// cmdExport exports a saved session as pcap or test vectors
+func cmdExport(args []string) int {
+	fs := newFlagSet("export", "[OPTIONS] SESSION_ID")
+	format := fs.String("format", "pcap", "Export format: pcap, vectors")
+	out := fs.String("o", "", "Output file (default: the exports directory)")
+
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(pos) != 1 {
+		fs.Usage()
+		return 2
+	}
+	id := pos[0]
+
+	var path string
+	switch *format {
+	case "pcap":
+		if *out == "" {
+			path, err = session.ExportSessionPCAP(id)
+			break
+		}
+		var sess *session.Session
+		if sess, err = session.LoadSession(id); err == nil {
+			path, err = *out, session.ExportPCAP(sess, *out)
+		}
+	case "vectors":
+		if *out == "" {
+			path, err = session.ExportSessionVectors(id)
+			break
+		}
+		var sess *session.Session
+		if sess, err = session.LoadSession(id); err == nil {
+			path, err = *out, session.ExportVectors(sess, *out)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown export format: %s\n", *format)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Exported to %s\n", path)
+	return 0
+}
+
+// cmdReport writes a test report from the config, the log file and
+// optionally a saved session
+func cmdReport(args []string) int {
+	fs := newFlagSet("report", "[OPTIONS] [SESSION_ID]")
+	format := fs.String("format", report.FormatMarkdown, "Report format: md, html")
+	out := fs.String("o", "", "Output file (default: the exports directory)")
+
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(pos) > 1 {
+		fs.Usage()
+		return 2
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		return 1
+	}
+
+	opts := report.Options{Config: cfg, Version: AppVersion}
+	if len(pos) == 1 {
+		if opts.Session, err = session.LoadSession(pos[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+	if opts.Entries, err = readLogFile(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: attack log unavailable: %v\n", err)
+	}
+
+	path, err := report.Write(opts, *format, *out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Report written to %s\n", path)
+	return 0
+}
+
+// readLogFile loads the entries of the log file, if logging to file is on
+func readLogFile() ([]logger.LogEntry, error) {
+	dataDir, err := config.GetDataDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := logger.ReadNDJSON(filepath.Join(dataDir, config.LogFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return entries, err
+}
+
+// clientReport tallies the identifications of one source IP
+type clientReport struct {
+	ip         string
+	requests   int
+	versions   map[uint8]bool
+	guesses    map[string]int     // Best match name -> requests
+	conf       map[string]float64 // Best match name -> highest confidence
+	candidates map[string]bool    // Runner-up names seen in any request
+}
+
+// fingerprintEntry is one client of the fingerprint report
+type fingerprintEntry struct {
+	Client         string   `json:"client"`
+	Requests       int      `json:"requests"`
+	Versions       []int    `json:"versions"`
+	Implementation string   `json:"implementation,omitempty"`
+	Confidence     float64  `json:"confidence,omitempty"`
+	Matched        int      `json:"matched,omitempty"` // Requests identified as Implementation
+	Candidates     []string `json:"candidates,omitempty"`
+}
+
+// cmdFingerprint identifies the NTP clients in a capture file
+func cmdFingerprint(args []string) int {
+	fs := newFlagSet("fingerprint", "[OPTIONS] PCAP_FILE")
+	db := fs.String("db", "", "Additional client signature file")
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(pos) != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	if *db != "" {
+		if _, err := fingerprint.GetDatabase().LoadFile(*db); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading signatures: %v\n", err)
+			return 1
+		}
+	}
+
+	packets, err := ntpcore.ReadPCAP(pos[0])
+	if err != nil && len(packets) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v (reporting what was read)\n", err)
+	}
+
+	clients := make(map[string]*clientReport)
+	for _, cp := range packets {
+		p, perr := ntpcore.ParsePacket(cp.Payload)
+		if perr != nil || cp.DstPort != 123 {
+			continue
+		}
+		// Peers polling in symmetric active mode are clients too
+		if p.Mode != ntpcore.ModeClient && p.Mode != ntpcore.ModeSymmetricActive {
+			continue
+		}
+
+		ip := cp.SrcIP.String()
+		r := clients[ip]
+		if r == nil {
+			r = &clientReport{ip: ip, versions: make(map[uint8]bool), guesses: make(map[string]int),
+				conf: make(map[string]float64), candidates: make(map[string]bool)}
+			clients[ip] = r
+		}
+		r.requests++
+		r.versions[p.Version] = true
+
+		matches := fingerprint.GetDatabase().Identify(p, cp.Time)
+		if len(matches) == 0 {
+			continue
+		}
+		m := matches[0]
+		r.guesses[m.Name]++
+		if m.Confidence > r.conf[m.Name] {
+			r.conf[m.Name] = m.Confidence
+		}
+		for _, other := range matches[1:] {
+			r.candidates[other.Name] = true
+		}
+	}
+
+	entries := fingerprintEntries(clients)
+	if *asJSON {
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		fmt.Println(string(data))
+		return 0
+	}
+	if len(entries) == 0 {
+		fmt.Printf("No NTP client requests in %s (%d NTP packets)\n", pos[0], len(packets))
+		return 0
+	}
+	writeFingerprintReport(os.Stdout, entries)
+	return 0
+}
+
+// topGuess returns the most frequent identification of a client
+func (r *clientReport) topGuess() string {
+	best, n := "", 0
+	for name, count := range r.guesses {
+		if count > n || (count == n && name < best) {
+			best, n = name, count
+		}
+	}
+	return best
+}
+
+// fingerprintEntries summarizes each client, sorted by IP
+func fingerprintEntries(clients map[string]*clientReport) []fingerprintEntry {
+	entries := make([]fingerprintEntry, 0, len(clients))
+	for _, r := range clients {
+		e := fingerprintEntry{Client: r.ip, Requests: r.requests}
+		for v := range r.versions {
+			e.Versions = append(e.Versions, int(v))
+		}
+		sort.Ints(e.Versions)
+
+		if name := r.topGuess(); name != "" {
+			e.Implementation, e.Confidence, e.Matched = name, r.conf[name], r.guesses[name]
+		}
+		// Other best guesses count as candidates too
+		for name := range r.guesses {
+			r.candidates[name] = true
+		}
+		for name := range r.candidates {
+			if name != e.Implementation {
+				e.Candidates = append(e.Candidates, name)
+			}
+		}
+		sort.Strings(e.Candidates)
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Client < entries[j].Client })
+	return entries
+}
+
+// writeFingerprintReport prints one line per client
+func writeFingerprintReport(w io.Writer, entries []fingerprintEntry) {
+	fmt.Fprintf(w, "%-40s %8s  %-8s %s\n", "CLIENT", "REQUESTS", "VERSION", "IDENTIFIED AS")
+	for _, e := range entries {
+		versions := make([]string, len(e.Versions))
+		for i, v := range e.Versions {
+			versions[i] = fmt.Sprintf("v%d", v)
+		}
+
+		guess := "unknown"
+		if e.Implementation != "" {
+			guess = fmt.Sprintf("%s (%.0f%%, %d/%d requests)", e.Implementation, e.Confidence*100, e.Matched, e.Requests)
+		}
+		if len(e.Candidates) > 0 {
+			guess += "; also " + strings.Join(e.Candidates, ", ")
+		}
+		fmt.Fprintf(w, "%-40s %8d  %-8s %s\n", e.Client, e.Requests, strings.Join(versions, ","), guess)
+	}
+}
+
+// printLogs prints new log entries as plain lines until stop is called
+func printLogs(log *logger.Logger, w io.Writer) (stop func()) {
+	ch := log.Subscribe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for entry := range ch {
+			fmt.Fprintln(w, logger.FormatEntryPlain(entry))
+		}
+	}()
+	return func() {
+		log.Unsubscribe(ch)
+		close(ch)
+		<-done
+	}
+}
@@ -6,14 +6,21 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/neutrinoguy/timehammer/internal/config"
+	"github.com/neutrinoguy/timehammer/internal/control"
 	"github.com/neutrinoguy/timehammer/internal/logger"
+	"github.com/neutrinoguy/timehammer/internal/scenario"
 	"github.com/neutrinoguy/timehammer/internal/server"
 	"github.com/neutrinoguy/timehammer/internal/tui"
 )
@@ -28,11 +35,34 @@ var (
 	showVersion = flag.Bool("version", false, "Show version information")
 	showHelp    = flag.Bool("help", false, "Show help information")
 	headless    = flag.Bool("headless", false, "Run in headless mode (no TUI)")
+	repl        = flag.Bool("repl", false, "Run headless with an interactive command prompt on stdin")
 	configPath  = flag.String("config", "", "Path to configuration file")
+	sequence    = flag.String("sequence", "", "Run the named attack sequence after the server starts (headless/repl)")
+	logStream   = flag.String("log-stream", "", "Stream log entries as NDJSON to a file, or - for stdout (headless/repl)")
+	scenarioArg = flag.String("scenario", "", "Run a scenario file unattended and exit with its result")
+	sets        stringList
 )
 
+// stringList is a flag that may be given several times
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
 func main() {
-	flag.Parse()
+	control.BuildVersion = AppVersion
+
+	// A leading word names a subcommand; plain flags (or nothing) mean serve
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name != "serve" {
+		os.Exit(runCommand(name, args))
+	}
+
+	flag.Var(&sets, "set", "Override a config key, e.g. server.port=1123 (repeatable)")
+	flag.CommandLine.Parse(args)
 
 	// Handle version flag
 	if *showVersion {
@@ -46,6 +76,22 @@ func main() {
 		os.Exit(0)
 	}
 
+	if err := config.SetFlagOverrides(sets); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	// Older invocations put the subcommand after the flags
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(flag.Arg(0), flag.Args()[1:]))
+	}
+
+	os.Exit(serve())
+}
+
+// serve runs the server with the TUI, headless, with a prompt or through a
+// scenario, and returns the exit code
+func serve() int {
 	// Print banner
 	printBanner()
 
@@ -73,22 +119,69 @@ func main() {
 	}
 	defer log.Close()
 
+	// Feed log aggregators in real time
+	if *logStream != "" && (*headless || *repl || *scenarioArg != "") {
+		stop, err := streamLogs(log, *logStream)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening log stream: %v\n", err)
+			os.Exit(1)
+		}
+		defer stop()
+	}
+
 	log.Info("STARTUP", fmt.Sprintf("%s v%s starting...", AppName, AppVersion))
 	log.Infof("STARTUP", "OS: %s", config.GetOSInfo())
+	for _, o := range cfg.Overrides() {
+		log.Infof("CONFIG", "Override: %s", o)
+	}
 
 	// Create server
 	srv := server.NewServer(cfg)
 
+	// Start control API
+	if cfg.Control.Enabled {
+		api := control.NewAPI(cfg, srv)
+		if err := api.Start(); err != nil {
+			log.Warnf("CONTROL", "Control API disabled: %v", err)
+		} else {
+			defer api.Stop()
+		}
+	}
+
 	// Print warning
 	printWarning()
 
-	if *headless {
+	if *scenarioArg != "" {
+		// Unattended scripted run
+		return runScenario(srv, cfg, *scenarioArg)
+	} else if *repl {
+		// Interactive headless mode
+		runREPL(srv, cfg)
+	} else if *headless {
 		// Headless mode
 		runHeadless(srv, cfg, log)
 	} else {
 		// TUI mode
 		runTUI(srv, cfg)
 	}
+	return 0
+}
+
+// streamLogs streams log entries as NDJSON to a file (appended) or stdout
+func streamLogs(log *logger.Logger, path string) (func(), error) {
+	if path == "-" {
+		return log.StreamTo(os.Stdout), nil
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	stop := log.StreamTo(f)
+	return func() {
+		stop()
+		f.Close()
+	}, nil
 }
 
 func runTUI(srv *server.Server, cfg *config.Config) {
@@ -117,14 +210,20 @@ func runHeadless(srv *server.Server, cfg *config.Config, log *logger.Logger) {
 	}
 
 	fmt.Printf("✅ Server listening on %s\n", srv.GetListenAddress())
+	startSequence(srv, cfg)
 
-	// Wait for interrupt
+	// Wait for interrupt; SIGHUP reloads the config file
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	fmt.Println("Press Ctrl+C to stop...")
 
-	<-sigChan
+	for sig := range sigChan {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		reloadConfig(srv, log)
+	}
 
 	fmt.Println("\n🛑 Shutting down...")
 	srv.Stop()
@@ -132,6 +231,135 @@ func runHeadless(srv *server.Server, cfg *config.Config, log *logger.Logger) {
 	fmt.Println("👋 Goodbye!")
 }
 
+func runREPL(srv *server.Server, cfg *config.Config) {
+	fmt.Println("\n⌨️  Running with interactive prompt (type help, Ctrl+D to exit)...")
+
+	// Start server
+	if err := srv.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting server: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✅ Server listening on %s\n", srv.GetListenAddress())
+	startSequence(srv, cfg)
+
+	cmds := control.NewCommands(cfg, srv)
+
+	// Read stdin in the background so signals are still handled
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+loop:
+	for {
+		fmt.Print("timehammer> ")
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				fmt.Println()
+				break loop
+			}
+			out, err := cmds.Execute(line)
+			if errors.Is(err, control.ErrQuit) {
+				break loop
+			}
+			if err != nil {
+				fmt.Printf("error: %v\n", err)
+				continue
+			}
+			if out != "" {
+				fmt.Println(out)
+			}
+		case <-sigChan:
+			fmt.Println()
+			break loop
+		}
+	}
+
+	fmt.Println("🛑 Shutting down...")
+	if srv.IsRunning() {
+		srv.Stop()
+	}
+	cfg.Save()
+	fmt.Println("👋 Goodbye!")
+}
+
+// runScenario runs a scenario file to completion and prints a summary. The
+// config is not saved afterwards, so scripted attacks don't leak into it.
+func runScenario(srv *server.Server, cfg *config.Config, path string) int {
+	sc, err := scenario.Load(path, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading scenario: %v\n", err)
+		return 1
+	}
+	fmt.Printf("\n🎬 Running scenario %s (%d steps)...\n", sc.Name, len(sc.Steps))
+
+	// Ctrl+C aborts the remaining steps; the run then counts as failed
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	res := scenario.Run(ctx, sc, srv, cfg)
+
+	fmt.Printf("\n📋 Scenario %s (%v)\n", res.Name, res.Duration.Round(time.Millisecond))
+	for _, step := range res.Steps {
+		if step.Err != nil {
+			fmt.Printf("  ❌ %2d %-9s %v\n", step.Index, step.Action, step.Err)
+		} else {
+			fmt.Printf("  ✅ %2d %-9s %s\n", step.Index, step.Action, step.Detail)
+		}
+	}
+	if res.Skipped > 0 {
+		fmt.Printf("  ⏭️  %d step(s) not run\n", res.Skipped)
+	}
+	fmt.Printf("  Requests: %d  Responses: %d  Attacks: %d  Clients: %d\n",
+		res.Stats.TotalRequests, res.Stats.TotalResponses, res.Stats.AttacksExecuted, res.Stats.ActiveClients)
+
+	if !res.OK() {
+		fmt.Println("💥 Scenario failed")
+		return 1
+	}
+	fmt.Println("🏁 Scenario passed")
+	return 0
+}
+
+// reloadConfig re-reads the config file and applies it to the server
+func reloadConfig(srv *server.Server, log *logger.Logger) {
+	log.Info("CONFIG", "SIGHUP received, reloading configuration")
+	newCfg, err := config.Load()
+	if err != nil {
+		log.Errorf("CONFIG", "Reload failed, keeping current config: %v", err)
+		return
+	}
+	if err := srv.Reload(newCfg); err != nil {
+		log.Errorf("CONFIG", "Reload failed: %v", err)
+	}
+}
+
+// startSequence starts the attack sequence given with --sequence, if any
+func startSequence(srv *server.Server, cfg *config.Config) {
+	if *sequence == "" {
+		return
+	}
+	seq, ok := cfg.GetAttackSequence(*sequence)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown attack sequence: %s\n", *sequence)
+		os.Exit(1)
+	}
+	if err := srv.GetAttackEngine().StartSequence(seq); err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting sequence: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("⚔️  Running attack sequence %s (%d steps)\n", seq.Name, len(seq.Steps))
+}
+
 func printBanner() {
 	banner := `
 ╔════════════════════════════════════════════════════════════════╗
@@ -184,13 +412,39 @@ func printHelp() {
 	fmt.Printf(`%s v%s - %s
 
 USAGE:
-    timehammer [OPTIONS]
+    timehammer [serve] [OPTIONS]
+    timehammer COMMAND [ARGS]
 
 OPTIONS:
     --help          Show this help message
     --version       Show version information
     --headless      Run in headless mode (no TUI)
+    --repl          Headless with an interactive command prompt on stdin
     --config PATH   Use specific configuration file
+    --sequence NAME Run an attack sequence after start (headless/repl)
+    --log-stream F  Stream logs as NDJSON to file F, or - for stdout (headless/repl)
+    --scenario F    Run scenario file F unattended; exit code 0 on success, 1 on failure
+    --set KEY=VALUE Override a config key, e.g. --set server.port=1123 (repeatable)
+
+COMMANDS:
+    serve           Run the server (default)
+    status          Print a one-line status of the running instance
+    replay SESSION TARGET
+                    Replay a saved session or vector file to HOST[:PORT]
+                    (-speed X, -dry-run, -no-timing, -upstream,
+                    -rewrite-timestamps)
+    validate [FILE] Check a configuration file (default: the data directory's)
+    export SESSION  Export a saved session (-format pcap|vectors, -o FILE)
+    fingerprint PCAP
+                    Identify the NTP clients in a capture file (-db FILE, -json)
+    report [SESSION]
+                    Write a Markdown/HTML test report (-format md|html, -o FILE)
+    config-keys     List the config keys and their TIMEHAMMER_* variables
+    schema          Print the config file's JSON Schema (-o FILE)
+    capabilities    Print the version, attacks and features of this build (-json)
+    selftest [ATTACK...]
+                    Score each attack against a built-in NTP client; exit 1 if
+                    one falls below the threshold (-port N, -threshold X, -json)
 
 KEYBOARD SHORTCUTS (TUI Mode):
     F1              Dashboard
@@ -198,12 +452,19 @@ KEYBOARD SHORTCUTS (TUI Mode):
     F3              Edit Configuration
     F4              Attack Mode / Security Testing
     F5              Session Management
+    F6              Client Offset Graph
+    F7              Client History
+    F8              Client Details
     F10             Start/Stop Server
     F12 / Esc       Quit
     Ctrl+S          Save Configuration
-    Ctrl+E          Export Logs (JSON & CSV)
+    Ctrl+E          Export Logs (choose path and format)
     Ctrl+R          Toggle Session Recording
     Ctrl+U          Force Upstream Sync
+    Ctrl+X          Cancel Newest In-Flight Operation
+    Ctrl+P          Switch / Save Config Profile
+    /               Filter Logs (log view)
+    f               Follow / Freeze Log Tail (log view)
     ?               Show Help
 
 SECURITY ATTACKS:
@@ -228,9 +489,24 @@ EXAMPLES:
     # Run in headless mode
     timehammer --headless
 
+    # CI run of a scripted timeline
+    timehammer --scenario ./smoke.yaml
+
     # Use specific config
     timehammer --config /path/to/config.yaml
 
+    # Tweak one setting without editing the config file
+    TIMEHAMMER_SERVER_PORT=1123 timehammer --headless --set security.enabled=true
+
+    # Status bar integration (e.g. tmux status-right)
+    timehammer status
+
+    # Scripted checks
+    timehammer validate ./config.yaml
+    timehammer replay -speed 2 session_1700000000 192.168.1.50
+    timehammer fingerprint capture.pcap
+    timehammer report -format html session_1700000000
+
 For more information, visit: https://github.com/neutrinoguy/timehammer
 `, AppName, AppVersion, AppDesc)
 }
@@ -2,14 +2,15 @@
 package attacks
 
 import (
-	"encoding/binary"
 	"fmt"
-	"math/rand"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/neutrinoguy/timehammer/internal/clock"
 	"github.com/neutrinoguy/timehammer/internal/config"
 	"github.com/neutrinoguy/timehammer/internal/logger"
+	"github.com/neutrinoguy/timehammer/internal/netutil"
 	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
 )
 
@@ -23,9 +24,17 @@ const (
 	AttackKissOfDeath  AttackType = "kiss_of_death"
 	AttackStratumLie   AttackType = "stratum_attack"
 	AttackLeapSecond   AttackType = "leap_second"
+	AttackLeapSmear    AttackType = "leap_smear"
 	AttackRollover     AttackType = "rollover"
 	AttackClockStep    AttackType = "clock_step"
 	AttackFuzzing      AttackType = "fuzzing"
+	AttackCryptoNAK    AttackType = "crypto_nak"
+	AttackTimeBomb     AttackType = "time_bomb"
+	AttackDelay        AttackType = "delay"
+	AttackRefID        AttackType = "refid_spoof"
+	AttackRootDistance AttackType = "root_distance"
+	AttackOrigin       AttackType = "origin_attack"
+	AttackClientMix    AttackType = "client_mix"
 )
 
 // AttackInfo provides information about an attack
@@ -65,12 +74,36 @@ func GetAvailableAttacks() []AttackInfo {
 			Description: "Lie about stratum level (claim stratum 1) to become the preferred time source",
 			Severity:    "Medium",
 		},
+		{
+			Type:        AttackRefID,
+			Name:        "Reference ID Spoofing",
+			Description: "Claim an arbitrary reference identifier (refclock code like GPS/PPS or an upstream IP) to test whether devices validate the claimed source",
+			Severity:    "Low",
+		},
+		{
+			Type:        AttackRootDistance,
+			Name:        "Root Distance Manipulation",
+			Description: "Override root delay and dispersion to probe how clients weigh server quality (tiny values look perfect, huge ones get rejected)",
+			Severity:    "Low",
+		},
+		{
+			Type:        AttackOrigin,
+			Name:        "Origin Timestamp Corruption",
+			Description: "Zero, randomize or flip one bit of the echoed origin timestamp to test whether clients match responses to their requests",
+			Severity:    "Medium",
+		},
 		{
 			Type:        AttackLeapSecond,
 			Name:        "Leap Second Injection",
 			Description: "Inject leap indicator flags to trigger leap second handling bugs",
 			Severity:    "Medium",
 		},
+		{
+			Type:        AttackLeapSmear,
+			Name:        "Leap Second Smear",
+			Description: "Spread a leap second over a window as smearing servers do, with the leap indicator clear, to test clients that expect a flagged leap",
+			Severity:    "Low",
+		},
 		{
 			Type:        AttackRollover,
 			Name:        "Timestamp Rollover",
@@ -89,6 +122,30 @@ func GetAvailableAttacks() []AttackInfo {
 			Description: "Randomly mutates NTP fields, timestamps, and headers to test client robustness",
 			Severity:    "Medium",
 		},
+		{
+			Type:        AttackCryptoNAK,
+			Name:        "Crypto-NAK",
+			Description: "Append a crypto-NAK (key ID with no digest) signalling authentication failure to authenticated clients",
+			Severity:    "Medium",
+		},
+		{
+			Type:        AttackTimeBomb,
+			Name:        "Time Bomb",
+			Description: "Serve honest time until a global request count or instant is reached, then switch all clients to another attack",
+			Severity:    "High",
+		},
+		{
+			Type:        AttackClientMix,
+			Name:        "Per-Client Mix",
+			Description: "Give each client one attack from a pool, picked at random (seeded) on its first request and kept for the run, to exercise a fleet with several behaviors at once",
+			Severity:    "High",
+		},
+		{
+			Type:        AttackDelay,
+			Name:        "Asymmetric Delay",
+			Description: "Add simulated one-way inbound/outbound path delay so clients compute a wrong offset from honest timestamps",
+			Severity:    "Medium",
+		},
 	}
 }
 
@@ -97,8 +154,59 @@ type AttackEngine struct {
 	mu           sync.RWMutex
 	cfg          *config.Config
 	log          *logger.Logger
+	clock        clock.Clock
 	driftState   *DriftState
 	requestCount map[string]int // per-client request count for interval-based attacks
+
+	upstreamSynced bool // Whether the upstream time base is currently synchronized
+
+	stratumSource func() uint8 // The real stratum to advertise (upstream + 1)
+
+	sweep *sweepState // Progress of the parameter sweep, if any
+
+	totalRequests int64         // Requests seen while security mode is on
+	bomb          timeBombState // Trigger state of the time bomb attack
+
+	sched scheduleState // Attack scheduler state
+
+	seq *sequenceState // Running attack sequence, if any
+
+	scope *targetScope // Parsed target filter
+
+	fuzz fuzzState // Seeded source of the fuzzing attack
+
+	walk          seededSource // Random walk drift steps
+	stratumJitter seededSource // Steps of the tracked stratum
+
+	kodTested map[string]bool // Client IPs sent their compliance test KoD
+	kodRot    kodRotation     // Position in the kiss code rotation
+
+	ramp rampState // Per-client progress of the time spoofing or clock step ramp
+
+	smearStart time.Time // When the leap smear was enabled
+
+	mix mixState // Per-client assignments of the client mix
+
+	pause pauseState // Whether the active attack is paused
+}
+
+// SetClock replaces the time source of the engine. Drift restarts from the
+// new clock's current time.
+func (e *AttackEngine) SetClock(c clock.Clock) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.clock = c
+	e.driftState = &DriftState{StartTime: c.Now()}
+	e.walk = seededSource{}
+	e.smearStart = c.Now()
+}
+
+// currentTime reads the engine clock. Caller must not hold e.mu.
+func (e *AttackEngine) currentTime() time.Time {
+	e.mu.RLock()
+	c := e.clock
+	e.mu.RUnlock()
+	return c.Now()
 }
 
 // DriftState tracks gradual drift
@@ -106,16 +214,22 @@ type DriftState struct {
 	StartTime    time.Time
 	CurrentDrift time.Duration
 	LastUpdate   time.Time
+
+	walk float64 // Random walk position in seconds, before direction
 }
 
 // NewAttackEngine creates a new attack engine
 func NewAttackEngine(cfg *config.Config) *AttackEngine {
-	return &AttackEngine{
+	e := &AttackEngine{
 		cfg:          cfg,
 		log:          logger.GetLogger(),
-		driftState:   &DriftState{StartTime: time.Now()},
+		clock:        clock.Real{},
 		requestCount: make(map[string]int),
+		kodTested:    make(map[string]bool),
 	}
+	e.driftState = &DriftState{StartTime: e.clock.Now()}
+	e.smearStart = e.clock.Now()
+	return e
 }
 
 // UpdateConfig updates the attack engine configuration
@@ -125,6 +239,14 @@ func (e *AttackEngine) UpdateConfig(cfg *config.Config) {
 	e.cfg = cfg
 }
 
+// EditConfig runs fn, which changes the shared config in place, while the
+// engine is not reading it
+func (e *AttackEngine) EditConfig(fn func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	fn()
+}
+
 // IsEnabled returns whether security mode is enabled
 func (e *AttackEngine) IsEnabled() bool {
 	e.mu.RLock()
@@ -139,13 +261,215 @@ func (e *AttackEngine) GetActiveAttack() AttackType {
 	return AttackType(e.cfg.Security.ActiveAttack)
 }
 
-// ProcessPacket applies the active attack to an NTP response packet
+// SetUpstreamSynced informs the engine of upstream sync transitions so that
+// offset attacks can be suspended while the time base is unreliable
+func (e *AttackEngine) SetUpstreamSynced(synced bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.upstreamSynced == synced {
+		return
+	}
+	e.upstreamSynced = synced
+
+	if !e.cfg.Security.RequireUpstreamSync {
+		return
+	}
+	if synced {
+		e.log.Info("ATTACK", "Upstream sync restored, offset attacks resumed")
+	} else {
+		e.log.Warn("ATTACK", "Upstream sync lost, offset attacks suspended")
+	}
+}
+
+// SetStratumSource sets where the engine reads the real stratum, so attacks
+// can advertise it instead of a value of their own
+func (e *AttackEngine) SetStratumSource(fn func() uint8) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stratumSource = fn
+}
+
+// realStratum returns the real stratum, or fallback without a source.
+// Caller must hold e.mu.
+func (e *AttackEngine) realStratum(fallback uint8) uint8 {
+	if e.stratumSource == nil {
+		return fallback
+	}
+	return e.stratumSource()
+}
+
+// setsStratum reports whether an attack may change the advertised stratum;
+// all others leave it as the honest response had it
+func setsStratum(attack AttackType) bool {
+	switch attack {
+	case AttackStratumLie, AttackRefID, AttackKissOfDeath, AttackFuzzing:
+		return true
+	}
+	return false
+}
+
+// isOffsetAttack reports whether an attack shifts time relative to the upstream baseline
+func isOffsetAttack(attack AttackType) bool {
+	switch attack {
+	case AttackTimeSpoofing, AttackTimeDrift, AttackClockStep, AttackLeapSmear:
+		return true
+	}
+	return false
+}
+
+// DescribeActiveAttack returns the attack currently in effect and a short
+// summary of its parameters (used for session timeline markers)
+func (e *AttackEngine) DescribeActiveAttack() (AttackType, string) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	attack, params := describeAttack(e.cfg.Security)
+	if attack != AttackNone {
+		params += e.sweepProgress() + e.bombProgress() + e.fuzzProgress(attack) + e.walkProgress(attack) + e.stratumProgress(attack) + e.mixProgress(attack)
+		if cond := describeConditions(attackConditions(&e.cfg.Security, attack)); cond != "" {
+			params += " when " + cond
+		}
+	}
+	return attack, params
+}
+
+// describeAttack summarizes the effective attack of a security config
+func describeAttack(sec config.SecurityConfig) (AttackType, string) {
+	if !sec.Enabled {
+		return AttackNone, ""
+	}
+
+	attack := AttackType(sec.ActiveAttack)
+	switch attack {
+	case AttackTimeSpoofing:
+		if !sec.TimeSpoofing.Enabled {
+			return AttackNone, ""
+		}
+		if sec.TimeSpoofing.Ramp.Enabled {
+			return attack, describeRamp(sec.TimeSpoofing.Ramp)
+		}
+		if sec.TimeSpoofing.CustomTime != "" {
+			return attack, fmt.Sprintf("custom_time=%s", sec.TimeSpoofing.CustomTime)
+		}
+		return attack, fmt.Sprintf("offset_secs=%d", sec.TimeSpoofing.OffsetSecs)
+	case AttackTimeDrift:
+		if !sec.TimeDrift.Enabled {
+			return AttackNone, ""
+		}
+		d := sec.TimeDrift
+		switch d.Waveform {
+		case WaveSine, WaveSawtooth:
+			return attack, fmt.Sprintf("waveform=%s amplitude=%g period=%g direction=%s",
+				d.Waveform, d.Amplitude, d.Period, d.Direction)
+		case WaveRandomWalk:
+			return attack, fmt.Sprintf("waveform=%s drift_per_sec=%g amplitude=%g direction=%s",
+				d.Waveform, d.DriftPerSec, d.Amplitude, d.Direction)
+		}
+		return attack, fmt.Sprintf("drift_per_sec=%g max_drift=%g direction=%s",
+			d.DriftPerSec, d.MaxDrift, d.Direction)
+	case AttackKissOfDeath:
+		if !sec.KissOfDeath.Enabled {
+			return AttackNone, ""
+		}
+		if sec.KissOfDeath.ComplianceTest {
+			return attack, fmt.Sprintf("compliance_test code=RATE interval=%d", sec.KissOfDeath.Interval)
+		}
+		return attack, fmt.Sprintf("%s interval=%d", describeKoDCodes(sec.KissOfDeath), sec.KissOfDeath.Interval)
+	case AttackStratumLie:
+		if !sec.StratumAttack.Enabled {
+			return AttackNone, ""
+		}
+		if sec.StratumAttack.TrackUpstream {
+			return attack, fmt.Sprintf("track_upstream jitter=%d", sec.StratumAttack.Jitter)
+		}
+		return attack, fmt.Sprintf("fake_stratum=%d", sec.StratumAttack.FakeStratum)
+	case AttackRefID:
+		if !sec.RefID.Enabled {
+			return AttackNone, ""
+		}
+		return attack, fmt.Sprintf("ref_id=%s stratum=%d", sec.RefID.RefID, sec.RefID.Stratum)
+	case AttackRootDistance:
+		if !sec.RootDistance.Enabled {
+			return AttackNone, ""
+		}
+		return attack, fmt.Sprintf("root_delay_ms=%g root_disp_ms=%g", sec.RootDistance.RootDelayMs, sec.RootDistance.RootDispMs)
+	case AttackOrigin:
+		if !sec.Origin.Enabled {
+			return AttackNone, ""
+		}
+		return attack, fmt.Sprintf("mode=%s interval=%d", sec.Origin.Mode, sec.Origin.Interval)
+	case AttackLeapSecond:
+		if !sec.LeapSecond.Enabled {
+			return AttackNone, ""
+		}
+		return attack, fmt.Sprintf("leap_indicator=%d", sec.LeapSecond.LeapIndicator)
+	case AttackLeapSmear:
+		if !sec.LeapSmear.Enabled {
+			return AttackNone, ""
+		}
+		return attack, fmt.Sprintf("direction=%s window_secs=%d start=%s",
+			sec.LeapSmear.Direction, sec.LeapSmear.WindowSecs, orNone(sec.LeapSmear.Start))
+	case AttackRollover:
+		if !sec.Rollover.Enabled {
+			return AttackNone, ""
+		}
+		return attack, fmt.Sprintf("mode=%s target_year=%d", sec.Rollover.Mode, sec.Rollover.TargetYear)
+	case AttackClockStep:
+		if !sec.ClockStep.Enabled {
+			return AttackNone, ""
+		}
+		if sec.ClockStep.Ramp.Enabled {
+			return attack, fmt.Sprintf("%s interval=%d", describeRamp(sec.ClockStep.Ramp), sec.ClockStep.Interval)
+		}
+		return attack, fmt.Sprintf("step_secs=%d interval=%d", sec.ClockStep.StepSecs, sec.ClockStep.Interval)
+	case AttackFuzzing:
+		if !sec.Fuzzing.Enabled {
+			return AttackNone, ""
+		}
+		return attack, fmt.Sprintf("mode=%s seed=%d", sec.Fuzzing.Mode, sec.Fuzzing.Seed)
+	case AttackCryptoNAK:
+		if !sec.CryptoNAK.Enabled {
+			return AttackNone, ""
+		}
+		return attack, fmt.Sprintf("interval=%d", sec.CryptoNAK.Interval)
+	case AttackDelay:
+		if !sec.Delay.Enabled {
+			return AttackNone, ""
+		}
+		return attack, fmt.Sprintf("inbound_ms=%d outbound_ms=%d", sec.Delay.InboundDelayMs, sec.Delay.OutboundDelayMs)
+	case AttackTimeBomb:
+		if !sec.TimeBomb.Enabled {
+			return AttackNone, ""
+		}
+		return attack, fmt.Sprintf("after_requests=%d at=%s then=%s",
+			sec.TimeBomb.AfterRequests, orNone(sec.TimeBomb.At), sec.TimeBomb.Attack)
+	case AttackClientMix:
+		if !sec.ClientMix.Enabled {
+			return AttackNone, ""
+		}
+		return attack, fmt.Sprintf("attacks=%s seed=%d", strings.Join(sec.ClientMix.Attacks, ","), sec.ClientMix.Seed)
+	default:
+		return AttackNone, ""
+	}
+}
+
+// ProcessPacket applies the active attack to an NTP response packet. req
+// describes the request being answered (nil for broadcasts) and is checked
+// against the attack's conditions.
 // Returns the modified packet and the attack name (if any)
-func (e *AttackEngine) ProcessPacket(packet *ntpcore.NTPPacket, clientAddr string, realTime time.Time) (*ntpcore.NTPPacket, string) {
+func (e *AttackEngine) ProcessPacket(packet *ntpcore.NTPPacket, clientAddr string, realTime time.Time, req *RequestInfo) (*ntpcore.NTPPacket, string) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	if !e.cfg.Security.Enabled {
+	if !e.cfg.Security.Enabled || e.pause.paused {
+		return packet, ""
+	}
+
+	e.totalRequests++
+
+	// Non-targeted clients get the honest response
+	if !e.inScope(clientAddr) {
 		return packet, ""
 	}
 
@@ -153,37 +477,111 @@ func (e *AttackEngine) ProcessPacket(packet *ntpcore.NTPPacket, clientAddr strin
 	e.requestCount[clientAddr]++
 	count := e.requestCount[clientAddr]
 
+	// Move any parameter sweep to its current setpoint
+	e.advanceSweep(e.clock.Now())
+
 	attack := AttackType(e.cfg.Security.ActiveAttack)
 
+	// Requests outside the attack's conditions get the honest response
+	if !conditionsMatch(attackConditions(&e.cfg.Security, attack), req) {
+		return packet, ""
+	}
+
+	// A time bomb is honest until its trigger fires, then acts as its payload
+	if attack == AttackTimeBomb {
+		attack = e.evaluateTimeBomb(e.clock.Now())
+		if attack == AttackNone || !conditionsMatch(attackConditions(&e.cfg.Security, attack), req) {
+			return packet, ""
+		}
+	}
+
+	// A client mix gives each client its own attack from the pool
+	if attack == AttackClientMix {
+		attack = e.assignMixAttack(clientAddr)
+		if attack == AttackNone || !conditionsMatch(attackConditions(&e.cfg.Security, attack), req) {
+			return packet, ""
+		}
+	}
+
+	// Refuse offset attacks when the baseline is not trustworthy
+	if e.cfg.Security.RequireUpstreamSync && !e.upstreamSynced && isOffsetAttack(attack) {
+		return packet, ""
+	}
+
+	stratum := packet.Stratum
+	packet, name := e.dispatchAttack(attack, packet, clientAddr, realTime, count, req)
+
+	// A changed stratum would give away an attack that is not about it
+	if !setsStratum(attack) {
+		packet.Stratum = stratum
+	}
+
+	// The root distance override can ride on top of any other attack
+	if rd := e.cfg.Security.RootDistance; rd.Enabled && rd.Overlay && attack != AttackRootDistance &&
+		conditionsMatch(rd.Conditions, req) {
+		packet, name = e.overlayRootDistance(packet, clientAddr, name)
+	}
+	return packet, name
+}
+
+// dispatchAttack runs a single attack against the response. Caller must
+// hold e.mu.
+func (e *AttackEngine) dispatchAttack(attack AttackType, packet *ntpcore.NTPPacket, clientAddr string, realTime time.Time, count int, req *RequestInfo) (*ntpcore.NTPPacket, string) {
 	switch attack {
 	case AttackTimeSpoofing:
-		return e.applyTimeSpoofing(packet, realTime)
+		return e.applyTimeSpoofing(packet, clientAddr, realTime, req)
 	case AttackTimeDrift:
 		return e.applyTimeDrift(packet, realTime)
 	case AttackKissOfDeath:
 		return e.applyKissOfDeath(packet, clientAddr, count)
 	case AttackStratumLie:
 		return e.applyStratumLie(packet)
+	case AttackRefID:
+		return e.applyRefIDSpoof(packet, clientAddr)
 	case AttackLeapSecond:
 		return e.applyLeapSecond(packet)
+	case AttackLeapSmear:
+		return e.applyLeapSmear(packet, realTime)
 	case AttackRollover:
 		return e.applyRollover(packet)
 	case AttackClockStep:
-		return e.applyClockStep(packet, realTime, count)
+		return e.applyClockStep(packet, clientAddr, realTime, count, req)
 	case AttackFuzzing:
 		return e.applyFuzzing(packet)
+	case AttackCryptoNAK:
+		return e.applyCryptoNAK(packet, clientAddr, count, req)
+	case AttackDelay:
+		return e.applyDelay(packet, clientAddr)
+	case AttackRootDistance:
+		return e.applyRootDistance(packet, clientAddr)
+	case AttackOrigin:
+		return e.applyOriginAttack(packet, clientAddr, count)
 	default:
 		return packet, ""
 	}
 }
 
 // applyTimeSpoofing sends a fake time
-func (e *AttackEngine) applyTimeSpoofing(packet *ntpcore.NTPPacket, realTime time.Time) (*ntpcore.NTPPacket, string) {
+func (e *AttackEngine) applyTimeSpoofing(packet *ntpcore.NTPPacket, clientAddr string, realTime time.Time, req *RequestInfo) (*ntpcore.NTPPacket, string) {
 	cfg := e.cfg.Security.TimeSpoofing
 	if !cfg.Enabled {
 		return packet, ""
 	}
 
+	// A ramp grows the offset per client until the client stops following
+	if cfg.Ramp.Enabled {
+		offset := e.rampOffset(AttackTimeSpoofing, cfg.Ramp, clientAddr, req)
+		fakeTime := realTime.Add(offset)
+
+		packet.SetReceiveTime(fakeTime)
+		packet.SetTransmitTime(fakeTime)
+		packet.SetReferenceTime(fakeTime.Add(-time.Second))
+
+		e.log.LogAttack(string(AttackTimeSpoofing), clientHost(clientAddr),
+			fmt.Sprintf("Sending ramped time: %s (offset: %s)", fakeTime.Format(time.RFC3339), formatRampOffset(offset)))
+		return packet, "Time Spoofing"
+	}
+
 	var fakeTime time.Time
 
 	// Check if custom time is set
@@ -216,13 +614,8 @@ func (e *AttackEngine) applyTimeDrift(packet *ntpcore.NTPPacket, realTime time.T
 	}
 
 	// Calculate drift since start
-	elapsed := time.Since(e.driftState.StartTime).Seconds()
-	driftAmount := elapsed * cfg.DriftPerSec
-
-	// Cap at max drift
-	if driftAmount > cfg.MaxDrift {
-		driftAmount = cfg.MaxDrift
-	}
+	now := e.clock.Now()
+	driftAmount := e.driftSeconds(cfg, now)
 
 	driftDuration := time.Duration(driftAmount * float64(time.Second))
 	if cfg.Direction == "backward" {
@@ -230,7 +623,7 @@ func (e *AttackEngine) applyTimeDrift(packet *ntpcore.NTPPacket, realTime time.T
 	}
 
 	e.driftState.CurrentDrift = driftDuration
-	e.driftState.LastUpdate = time.Now()
+	e.driftState.LastUpdate = now
 
 	fakeTime := realTime.Add(driftDuration)
 
@@ -239,7 +632,7 @@ func (e *AttackEngine) applyTimeDrift(packet *ntpcore.NTPPacket, realTime time.T
 	packet.SetReferenceTime(fakeTime.Add(-time.Second))
 
 	e.log.LogAttack(string(AttackTimeDrift), "all",
-		fmt.Sprintf("Drifting time %s by %v", cfg.Direction, driftDuration))
+		fmt.Sprintf("Drifting time %s by %v (%s)", cfg.Direction, driftDuration, cfg.Waveform))
 
 	return packet, "Time Drift"
 }
@@ -251,9 +644,21 @@ func (e *AttackEngine) applyKissOfDeath(packet *ntpcore.NTPPacket, clientAddr st
 		return packet, ""
 	}
 
-	// Check if we should send KoD based on interval
-	if cfg.Interval > 0 && requestCount%cfg.Interval != 0 {
+	var code string
+	if cfg.ComplianceTest {
+		// One RATE KoD per client, then honest answers while the server
+		// measures whether the client backs off
+		host := netutil.Host(clientAddr)
+		if e.kodTested[host] || requestCount < cfg.Interval {
+			return packet, ""
+		}
+		e.kodTested[host] = true
+		code = ntpcore.KoDRate
+	} else if cfg.Interval > 0 && requestCount%cfg.Interval != 0 {
+		// Check if we should send KoD based on interval
 		return packet, ""
+	} else {
+		code = e.nextKoDCode(clientAddr)
 	}
 
 	// Create KoD packet
@@ -261,15 +666,15 @@ func (e *AttackEngine) applyKissOfDeath(packet *ntpcore.NTPPacket, clientAddr st
 	packet.LeapIndicator = ntpcore.LeapAlarm
 
 	// Set the kiss code
-	if err := packet.SetKissOfDeathCode(cfg.Code); err != nil {
+	if err := packet.SetKissOfDeathCode(code); err != nil {
 		// Use DENY as fallback
 		packet.SetKissOfDeathCode("DENY")
 	}
 
 	e.log.LogAttack(string(AttackKissOfDeath), clientAddr,
-		fmt.Sprintf("Sending KoD packet with code: %s", cfg.Code))
+		fmt.Sprintf("Sending KoD packet with code: %s", printableKoDCode(code)))
 
-	return packet, fmt.Sprintf("Kiss-of-Death (%s)", cfg.Code)
+	return packet, fmt.Sprintf("Kiss-of-Death (%s)", printableKoDCode(code))
 }
 
 // applyStratumLie lies about stratum level
@@ -279,11 +684,18 @@ func (e *AttackEngine) applyStratumLie(packet *ntpcore.NTPPacket) (*ntpcore.NTPP
 		return packet, ""
 	}
 
-	packet.Stratum = uint8(cfg.FakeStratum)
+	if cfg.TrackUpstream {
+		return e.applyTrackedStratum(packet, cfg.Jitter)
+	}
+
+	if err := packet.SetStratum(cfg.FakeStratum); err != nil {
+		e.log.Errorf("ATTACK", "Stratum attack skipped: %v", err)
+		return packet, ""
+	}
 
 	// If claiming stratum 1, set a fake reference ID (like a GPS source)
 	if cfg.FakeStratum == 1 {
-		packet.ReferenceID = binary.BigEndian.Uint32([]byte("GPS\x00"))
+		packet.ReferenceID = e.refClockID()
 	}
 
 	e.log.LogAttack(string(AttackStratumLie), "all",
@@ -299,7 +711,10 @@ func (e *AttackEngine) applyLeapSecond(packet *ntpcore.NTPPacket) (*ntpcore.NTPP
 		return packet, ""
 	}
 
-	packet.LeapIndicator = uint8(cfg.LeapIndicator)
+	if err := packet.SetLeapIndicator(cfg.LeapIndicator); err != nil {
+		e.log.Errorf("ATTACK", "Leap second attack skipped: %v", err)
+		return packet, ""
+	}
 
 	leapDesc := map[int]string{
 		1: "+1 second",
@@ -344,14 +759,17 @@ func (e *AttackEngine) applyRollover(packet *ntpcore.NTPPacket) (*ntpcore.NTPPac
 	packet.SetTransmitTime(rolloverTime)
 	packet.SetReferenceTime(rolloverTime.Add(-time.Second))
 
+	// Past 2036 the seconds field has wrapped; clients must infer the era
+	ts, era := ntpcore.TimeToNTPTimestampEra(rolloverTime)
 	e.log.LogAttack(string(AttackRollover), "all",
-		fmt.Sprintf("Sending rollover timestamp: %s (%s)", rolloverTime.Format(time.RFC3339), description))
+		fmt.Sprintf("Sending rollover timestamp: %s (%s, era %d, seconds field %d)",
+			rolloverTime.Format(time.RFC3339), description, era, ts.Seconds))
 
 	return packet, fmt.Sprintf("Rollover (%s)", description)
 }
 
 // applyClockStep applies sudden time jumps
-func (e *AttackEngine) applyClockStep(packet *ntpcore.NTPPacket, realTime time.Time, requestCount int) (*ntpcore.NTPPacket, string) {
+func (e *AttackEngine) applyClockStep(packet *ntpcore.NTPPacket, clientAddr string, realTime time.Time, requestCount int, req *RequestInfo) (*ntpcore.NTPPacket, string) {
 	cfg := e.cfg.Security.ClockStep
 	if !cfg.Enabled {
 		return packet, ""
@@ -362,6 +780,20 @@ func (e *AttackEngine) applyClockStep(packet *ntpcore.NTPPacket, realTime time.T
 		return packet, ""
 	}
 
+	// A ramp grows the step per client until the client stops following
+	if cfg.Ramp.Enabled {
+		step := e.rampOffset(AttackClockStep, cfg.Ramp, clientAddr, req)
+		steppedTime := realTime.Add(step)
+
+		packet.SetReceiveTime(steppedTime)
+		packet.SetTransmitTime(steppedTime)
+		packet.SetReferenceTime(steppedTime.Add(-time.Second))
+
+		e.log.LogAttack(string(AttackClockStep), clientHost(clientAddr),
+			fmt.Sprintf("Applying ramped clock step: %s (request #%d)", formatRampOffset(step), requestCount))
+		return packet, fmt.Sprintf("Clock Step (%s)", formatRampOffset(step))
+	}
+
 	stepDuration := time.Duration(cfg.StepSecs) * time.Second
 	steppedTime := realTime.Add(stepDuration)
 
@@ -375,11 +807,87 @@ func (e *AttackEngine) applyClockStep(packet *ntpcore.NTPPacket, realTime time.T
 	return packet, fmt.Sprintf("Clock Step (+%ds)", cfg.StepSecs)
 }
 
+// EnableAttack makes the given attack the active one and turns on
+// security mode. Returns the attack's info for display.
+func (e *AttackEngine) EnableAttack(attack AttackType) (AttackInfo, error) {
+	var info AttackInfo
+	found := false
+	for _, a := range GetAvailableAttacks() {
+		if a.Type == attack {
+			info, found = a, true
+			break
+		}
+	}
+	if !found {
+		return AttackInfo{}, fmt.Errorf("unknown attack: %s", attack)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.cfg.Security.Enabled = true
+	e.cfg.Security.ActiveAttack = string(attack)
+	e.enableAttackConfig(attack)
+	e.pause = pauseState{}
+
+	return info, nil
+}
+
+// enableAttackConfig turns on the config section of an attack. Caller must
+// hold e.mu.
+func (e *AttackEngine) enableAttackConfig(attack AttackType) {
+	switch attack {
+	case AttackTimeSpoofing:
+		e.cfg.Security.TimeSpoofing.Enabled = true
+		e.resetRamp(attack)
+	case AttackTimeDrift:
+		e.cfg.Security.TimeDrift.Enabled = true
+		e.driftState = &DriftState{StartTime: e.clock.Now()}
+		e.walk = seededSource{}
+	case AttackKissOfDeath:
+		e.cfg.Security.KissOfDeath.Enabled = true
+		e.resetKoDState()
+	case AttackStratumLie:
+		e.cfg.Security.StratumAttack.Enabled = true
+		e.stratumJitter = seededSource{}
+	case AttackRefID:
+		e.cfg.Security.RefID.Enabled = true
+	case AttackRootDistance:
+		e.cfg.Security.RootDistance.Enabled = true
+	case AttackOrigin:
+		e.cfg.Security.Origin.Enabled = true
+	case AttackLeapSecond:
+		e.cfg.Security.LeapSecond.Enabled = true
+	case AttackLeapSmear:
+		e.cfg.Security.LeapSmear.Enabled = true
+		e.smearStart = e.clock.Now()
+	case AttackRollover:
+		e.cfg.Security.Rollover.Enabled = true
+	case AttackClockStep:
+		e.cfg.Security.ClockStep.Enabled = true
+		e.resetRamp(attack)
+	case AttackFuzzing:
+		e.cfg.Security.Fuzzing.Enabled = true
+		e.seedFuzzer(true)
+	case AttackCryptoNAK:
+		e.cfg.Security.CryptoNAK.Enabled = true
+	case AttackDelay:
+		e.cfg.Security.Delay.Enabled = true
+	case AttackTimeBomb:
+		e.cfg.Security.TimeBomb.Enabled = true
+		e.armTimeBomb()
+	case AttackClientMix:
+		e.cfg.Security.ClientMix.Enabled = true
+		e.seedMix(true)
+	}
+}
+
 // ResetDriftState resets the drift tracking
 func (e *AttackEngine) ResetDriftState() {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	e.driftState = &DriftState{StartTime: time.Now()}
+	e.driftState = &DriftState{StartTime: e.clock.Now()}
+	e.walk = seededSource{}
 }
 
 // ResetRequestCounts resets per-client request counters
@@ -393,7 +901,10 @@ func (e *AttackEngine) ResetRequestCounts() {
 func (e *AttackEngine) GetDriftStatus() (time.Duration, time.Duration) {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
-	elapsed := time.Since(e.driftState.StartTime)
+	if e.pause.paused {
+		return e.driftState.CurrentDrift, e.pause.at.Sub(e.driftState.StartTime)
+	}
+	elapsed := clock.Since(e.clock, e.driftState.StartTime)
 	return e.driftState.CurrentDrift, elapsed
 }
 
@@ -404,6 +915,7 @@ func (e *AttackEngine) ApplyPreset(preset config.AttackPreset) error {
 
 	e.cfg.Security.Enabled = true
 	e.cfg.Security.ActiveAttack = preset.Attack
+	e.pause = pauseState{}
 
 	// Apply preset-specific config
 	switch preset.Attack {
@@ -416,6 +928,7 @@ func (e *AttackEngine) ApplyPreset(preset config.AttackPreset) error {
 			e.cfg.Security.TimeSpoofing.Enabled = true
 			e.cfg.Security.TimeSpoofing.OffsetSecs = int64(offset)
 		}
+		e.resetRamp(AttackTimeSpoofing)
 	case "time_drift":
 		e.cfg.Security.TimeDrift.Enabled = true
 		if drift, ok := preset.Config["drift_per_sec"].(float64); ok {
@@ -430,7 +943,16 @@ func (e *AttackEngine) ApplyPreset(preset config.AttackPreset) error {
 		if dir, ok := preset.Config["direction"].(string); ok {
 			e.cfg.Security.TimeDrift.Direction = dir
 		}
-		e.driftState = &DriftState{StartTime: time.Now()}
+		if wave, ok := preset.Config["waveform"].(string); ok {
+			e.cfg.Security.TimeDrift.Waveform = wave
+		}
+		if amp, ok := presetFloat(preset.Config, "amplitude"); ok {
+			e.cfg.Security.TimeDrift.Amplitude = amp
+		}
+		if period, ok := presetFloat(preset.Config, "period"); ok {
+			e.cfg.Security.TimeDrift.Period = period
+		}
+		e.driftState = &DriftState{StartTime: e.clock.Now()}
 	case "kiss_of_death":
 		e.cfg.Security.KissOfDeath.Enabled = true
 		if code, ok := preset.Config["code"].(string); ok {
@@ -439,6 +961,19 @@ func (e *AttackEngine) ApplyPreset(preset config.AttackPreset) error {
 		if interval, ok := preset.Config["interval"].(int); ok {
 			e.cfg.Security.KissOfDeath.Interval = interval
 		}
+		if test, ok := preset.Config["compliance_test"].(bool); ok {
+			e.cfg.Security.KissOfDeath.ComplianceTest = test
+		}
+		if codes, ok := presetStrings(preset.Config, "codes"); ok {
+			e.cfg.Security.KissOfDeath.Codes = codes
+		}
+		if per, ok := preset.Config["rotate_per"].(string); ok {
+			e.cfg.Security.KissOfDeath.RotatePer = per
+		}
+		if invalid, ok := preset.Config["include_invalid"].(bool); ok {
+			e.cfg.Security.KissOfDeath.IncludeInvalid = invalid
+		}
+		e.resetKoDState()
 	case "rollover":
 		e.cfg.Security.Rollover.Enabled = true
 		if year, ok := preset.Config["target_year"].(int); ok {
@@ -455,13 +990,36 @@ func (e *AttackEngine) ApplyPreset(preset config.AttackPreset) error {
 		if interval, ok := preset.Config["interval"].(int); ok {
 			e.cfg.Security.ClockStep.Interval = interval
 		}
+		e.resetRamp(AttackClockStep)
 	case "fuzzing":
 		e.cfg.Security.Fuzzing.Enabled = true
 		if mode, ok := preset.Config["mode"].(string); ok {
 			e.cfg.Security.Fuzzing.Mode = mode
 		}
+		if seed, ok := preset.Config["seed"].(int); ok {
+			e.cfg.Security.Fuzzing.Seed = int64(seed)
+		}
+	case "refid_spoof":
+		e.cfg.Security.RefID.Enabled = true
+		if id, ok := preset.Config["ref_id"].(string); ok {
+			e.cfg.Security.RefID.RefID = id
+		}
+		if stratum, ok := preset.Config["stratum"].(int); ok {
+			e.cfg.Security.RefID.Stratum = stratum
+		}
+	case "origin_attack":
+		e.cfg.Security.Origin.Enabled = true
+		if mode, ok := preset.Config["mode"].(string); ok {
+			e.cfg.Security.Origin.Mode = mode
+		}
+		if interval, ok := preset.Config["interval"].(int); ok {
+			e.cfg.Security.Origin.Interval = interval
+		}
 	}
 
+	// Root distance keys compose with any attack
+	e.applyRootDistancePreset(preset.Config)
+
 	return nil
 }
 
@@ -472,80 +1030,41 @@ func (e *AttackEngine) DisableAllAttacks() {
 
 	e.cfg.Security.Enabled = false
 	e.cfg.Security.ActiveAttack = ""
+	e.pause = pauseState{}
 	e.cfg.Security.TimeSpoofing.Enabled = false
 	e.cfg.Security.TimeDrift.Enabled = false
 	e.cfg.Security.KissOfDeath.Enabled = false
 	e.cfg.Security.StratumAttack.Enabled = false
+	e.cfg.Security.RefID.Enabled = false
+	e.cfg.Security.RootDistance.Enabled = false
+	e.cfg.Security.Origin.Enabled = false
 	e.cfg.Security.LeapSecond.Enabled = false
+	e.cfg.Security.LeapSmear.Enabled = false
 	e.cfg.Security.Rollover.Enabled = false
 	e.cfg.Security.ClockStep.Enabled = false
 	e.cfg.Security.Fuzzing.Enabled = false
+	e.cfg.Security.CryptoNAK.Enabled = false
+	e.cfg.Security.TimeBomb.Enabled = false
+	e.cfg.Security.ClientMix.Enabled = false
+	e.cfg.Security.Delay.Enabled = false
 }
 
-// applyFuzzing applies random fuzzing mutations
-func (e *AttackEngine) applyFuzzing(packet *ntpcore.NTPPacket) (*ntpcore.NTPPacket, string) {
-	if !e.cfg.Security.Fuzzing.Enabled {
+// applyCryptoNAK replaces any authenticator with a crypto-NAK. Only
+// authenticated requests get one; broadcasts answer no request.
+func (e *AttackEngine) applyCryptoNAK(packet *ntpcore.NTPPacket, clientAddr string, requestCount int, req *RequestInfo) (*ntpcore.NTPPacket, string) {
+	cfg := e.cfg.Security.CryptoNAK
+	if !cfg.Enabled || req == nil || !req.HasMAC {
 		return packet, ""
 	}
 
-	mutationType := rand.Intn(10)
-	mutationName := "Generic Fuzzing"
-
-	switch mutationType {
-	case 0: // Version Fuzzing
-		v := uint8(rand.Intn(8))
-		if v == 3 || v == 4 {
-			// Try to pick an invalid one again
-			v = uint8(rand.Intn(8))
-		}
-		packet.Version = v
-		mutationName = fmt.Sprintf("Fuzz: Version %d", v)
-	case 1: // Mode Fuzzing
-		m := uint8(rand.Intn(8))
-		if m == 4 { // Server
-			m = 0 // Reserved
-		}
-		packet.Mode = m
-		mutationName = fmt.Sprintf("Fuzz: Mode %d", m)
-	case 2: // Stratum Fuzzing
-		s := uint8(rand.Intn(20))
-		if s == 0 {
-			s = 16 // Unsynced
-		} else if s > 16 {
-			s = 0 // Invalid/KoD without code
-		}
-		packet.Stratum = s
-		mutationName = fmt.Sprintf("Fuzz: Stratum %d", s)
-	case 3: // Leap Indicator
-		packet.LeapIndicator = 3 // Alarm
-		mutationName = "Fuzz: LI Alarm"
-	case 4: // Zero Timestamp
-		packet.SetReceiveTime(time.Time{})
-		packet.SetTransmitTime(time.Time{})
-		packet.SetReferenceTime(time.Time{})
-		mutationName = "Fuzz: Zero Timestamps"
-	case 5: // Max Timestamp
-		packet.RecvTimeSec = 0xFFFFFFFF
-		packet.RecvTimeFrac = 0xFFFFFFFF
-		packet.XmitTimeSec = 0xFFFFFFFF
-		packet.XmitTimeFrac = 0xFFFFFFFF
-		mutationName = "Fuzz: Max Timestamps"
-	case 6: // Root Delay/Dispersion
-		packet.RootDelay = 0xFFFF0000
-		packet.RootDisp = 0xFFFF0000
-		mutationName = "Fuzz: Large Root Delay"
-	case 7: // Reference ID
-		packet.ReferenceID = 0x41414141 // AAAA
-		mutationName = "Fuzz: RefID AAAA"
-	case 8: // Origin Timestamp Mismatch
-		packet.OrigTimeSec++
-		mutationName = "Fuzz: Origin Mismatch"
-	case 9: // Poll/Precision
-		packet.Poll = -100
-		packet.Precision = 100
-		mutationName = "Fuzz: Invalid Poll/Prec"
-	}
-
-	e.log.LogAttack(string(AttackFuzzing), "all", mutationName)
-	return packet, mutationName
+	// Check if we should send a crypto-NAK based on interval
+	if cfg.Interval > 0 && requestCount%cfg.Interval != 0 {
+		return packet, ""
+	}
+
+	packet.SetCryptoNAK()
+
+	e.log.LogAttack(string(AttackCryptoNAK), clientAddr, "Sending crypto-NAK (authentication failure)")
+
+	return packet, "Crypto-NAK"
 }
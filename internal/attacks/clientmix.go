@@ -0,0 +1,154 @@
+package attacks
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"sort"
+)
+
+// mixState holds the per-client assignments of the client mix attack
+type mixState struct {
+	seed     int64                 // Seed in use
+	cfgSeed  int64                 // Configured seed the state was built from
+	seeded   bool                  // Whether seed has been chosen
+	assigned map[string]AttackType // Client IP -> its attack
+	requests map[string]int        // Client IP -> requests seen since its assignment
+	badCfg   string                // Last invalid pool entry reported, to avoid log spam
+}
+
+// MixAssignment is the attack the client mix gave one client
+type MixAssignment struct {
+	Client   string
+	Attack   AttackType
+	Requests int // Requests seen since the assignment
+}
+
+// seedMix picks the assignment seed, a time-based one if none is
+// configured, and forgets earlier assignments when it changes or force is
+// set. Caller must hold e.mu.
+func (e *AttackEngine) seedMix(force bool) {
+	cfgSeed := e.cfg.Security.ClientMix.Seed
+	if !force && e.mix.seeded && cfgSeed == e.mix.cfgSeed {
+		return
+	}
+
+	seed := cfgSeed
+	if seed == 0 {
+		seed = e.clock.Now().UnixNano()
+	}
+	e.mix = mixState{
+		seed:     seed,
+		cfgSeed:  cfgSeed,
+		seeded:   true,
+		assigned: make(map[string]AttackType),
+		requests: make(map[string]int),
+	}
+	e.log.Warnf("ATTACK", "Client mix seed %d (set security.client_mix.seed to replay these assignments)", seed)
+}
+
+// mixPool returns the usable attacks of the configured pool. Caller must
+// hold e.mu.
+func (e *AttackEngine) mixPool() []AttackType {
+	var pool []AttackType
+	for _, name := range e.cfg.Security.ClientMix.Attacks {
+		attack := AttackType(name)
+		if attack == AttackClientMix || attack == AttackTimeBomb || !isKnownAttack(attack) {
+			if e.mix.badCfg != name {
+				e.mix.badCfg = name
+				e.log.Errorf("ATTACK", "Client mix attack %q is not a usable attack, skipping it", name)
+			}
+			continue
+		}
+		pool = append(pool, attack)
+	}
+	return pool
+}
+
+// assignMixAttack returns a client's attack, drawing it from the pool on
+// its first request. A client keeps its attack while it stays in the pool.
+// Caller must hold e.mu.
+func (e *AttackEngine) assignMixAttack(clientAddr string) AttackType {
+	e.seedMix(false)
+
+	host := clientHost(clientAddr)
+	pool := e.mixPool()
+	if len(pool) == 0 {
+		return AttackNone
+	}
+	if attack, ok := e.mix.assigned[host]; ok && containsAttack(pool, attack) {
+		e.mix.requests[host]++
+		return attack
+	}
+
+	attack := pool[mixIndex(e.mix.seed, host, len(pool))]
+	e.mix.assigned[host] = attack
+	e.mix.requests[host] = 1
+
+	// The pool attack runs with its own parameters; turn its section on
+	if enabled, _ := attackSection(&e.cfg.Security, attack); enabled != nil && !*enabled {
+		e.enableAttackConfig(attack)
+	}
+
+	e.log.LogAttack(string(AttackClientMix), host,
+		fmt.Sprintf("Assigned %s (seed %d, client %d of the mix)", attack, e.mix.seed, len(e.mix.assigned)))
+	return attack
+}
+
+// mixIndex hashes the seed and a client IP to a pool position, so an
+// assignment does not depend on the order clients show up in
+func mixIndex(seed int64, host string, n int) int {
+	h := fnv.New64a()
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(seed))
+	h.Write(b[:])
+	h.Write([]byte(host))
+	return int(h.Sum64() % uint64(n))
+}
+
+// containsAttack reports whether attack is in list
+func containsAttack(list []AttackType, attack AttackType) bool {
+	for _, a := range list {
+		if a == attack {
+			return true
+		}
+	}
+	return false
+}
+
+// mixProgress names the time-based seed in use, for session markers.
+// Caller must hold e.mu.
+func (e *AttackEngine) mixProgress(attack AttackType) string {
+	if attack != AttackClientMix || !e.mix.seeded || e.mix.cfgSeed != 0 {
+		return ""
+	}
+	return fmt.Sprintf(" run_seed=%d", e.mix.seed)
+}
+
+// ClientAttack returns the attack a client's responses get: its assignment
+// while the client mix is active, the active attack otherwise
+func (e *AttackEngine) ClientAttack(clientAddr string) AttackType {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	attack := AttackType(e.cfg.Security.ActiveAttack)
+	if attack == AttackClientMix {
+		if assigned, ok := e.mix.assigned[clientHost(clientAddr)]; ok {
+			return assigned
+		}
+	}
+	return attack
+}
+
+// GetMixAssignments returns the client mix assignments, sorted by client
+func (e *AttackEngine) GetMixAssignments() []MixAssignment {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	results := make([]MixAssignment, 0, len(e.mix.assigned))
+	for host, attack := range e.mix.assigned {
+		results = append(results, MixAssignment{Client: host, Attack: attack, Requests: e.mix.requests[host]})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Client < results[j].Client })
+	return results
+}
@@ -0,0 +1,146 @@
+package attacks
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+)
+
+// RequestInfo describes the client request a response answers, so attacks
+// can be limited to some clients with conditions
+type RequestInfo struct {
+	Version uint8
+	Mode    uint8
+	Poll    int8
+	Client  string // Identified implementation ("" if unknown)
+	HasMAC  bool   // Whether the request carries an authenticator
+
+	ClientOffset time.Duration // Client clock minus the served time, from its transmit timestamp
+	OffsetKnown  bool          // Whether the client sent a usable transmit timestamp
+}
+
+// attackConditions returns the condition block of an attack
+func attackConditions(sec *config.SecurityConfig, attack AttackType) config.AttackConditions {
+	switch attack {
+	case AttackTimeSpoofing:
+		return sec.TimeSpoofing.Conditions
+	case AttackTimeDrift:
+		return sec.TimeDrift.Conditions
+	case AttackKissOfDeath:
+		return sec.KissOfDeath.Conditions
+	case AttackStratumLie:
+		return sec.StratumAttack.Conditions
+	case AttackLeapSecond:
+		return sec.LeapSecond.Conditions
+	case AttackLeapSmear:
+		return sec.LeapSmear.Conditions
+	case AttackRollover:
+		return sec.Rollover.Conditions
+	case AttackClockStep:
+		return sec.ClockStep.Conditions
+	case AttackFuzzing:
+		return sec.Fuzzing.Conditions
+	case AttackCryptoNAK:
+		return sec.CryptoNAK.Conditions
+	case AttackDelay:
+		return sec.Delay.Conditions
+	case AttackRefID:
+		return sec.RefID.Conditions
+	case AttackRootDistance:
+		return sec.RootDistance.Conditions
+	case AttackOrigin:
+		return sec.Origin.Conditions
+	case AttackTimeBomb:
+		return sec.TimeBomb.Conditions
+	case AttackClientMix:
+		return sec.ClientMix.Conditions
+	default:
+		return config.AttackConditions{}
+	}
+}
+
+// conditionsMatch reports whether a request satisfies an attack's
+// conditions. A nil request (a broadcast) only matches an empty block.
+func conditionsMatch(c config.AttackConditions, req *RequestInfo) bool {
+	if !c.IsSet() {
+		return true
+	}
+	if req == nil {
+		return false
+	}
+
+	if c.MinVersion != 0 && int(req.Version) < c.MinVersion {
+		return false
+	}
+	if c.MaxVersion != 0 && int(req.Version) > c.MaxVersion {
+		return false
+	}
+	if len(c.Modes) > 0 && !containsInt(c.Modes, int(req.Mode)) {
+		return false
+	}
+	if c.MinPoll != nil && int(req.Poll) < *c.MinPoll {
+		return false
+	}
+	if c.MaxPoll != nil && int(req.Poll) > *c.MaxPoll {
+		return false
+	}
+	if len(c.Clients) > 0 {
+		client := strings.ToLower(req.Client)
+		matched := false
+		for _, want := range c.Clients {
+			if want != "" && strings.Contains(client, strings.ToLower(want)) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// describeConditions formats a condition block for display ("" if empty)
+func describeConditions(c config.AttackConditions) string {
+	var parts []string
+	if c.MinVersion != 0 || c.MaxVersion != 0 {
+		parts = append(parts, fmt.Sprintf("version=%s-%s", orAny(c.MinVersion), orAny(c.MaxVersion)))
+	}
+	if len(c.Modes) > 0 {
+		parts = append(parts, fmt.Sprintf("modes=%v", c.Modes))
+	}
+	if c.MinPoll != nil || c.MaxPoll != nil {
+		lo, hi := "any", "any"
+		if c.MinPoll != nil {
+			lo = fmt.Sprint(*c.MinPoll)
+		}
+		if c.MaxPoll != nil {
+			hi = fmt.Sprint(*c.MaxPoll)
+		}
+		parts = append(parts, fmt.Sprintf("poll=%s..%s", lo, hi))
+	}
+	if len(c.Clients) > 0 {
+		parts = append(parts, "clients="+strings.Join(c.Clients, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+// orAny formats an optional bound
+func orAny(v int) string {
+	if v == 0 {
+		return "any"
+	}
+	return fmt.Sprint(v)
+}
+
+// containsInt reports whether v is in list
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package attacks
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// delayDurations returns the configured one-way delays, ignoring negatives
+func delayDurations(cfg config.DelayAttackConfig) (inbound, outbound time.Duration) {
+	if cfg.InboundDelayMs > 0 {
+		inbound = time.Duration(cfg.InboundDelayMs) * time.Millisecond
+	}
+	if cfg.OutboundDelayMs > 0 {
+		outbound = time.Duration(cfg.OutboundDelayMs) * time.Millisecond
+	}
+	return inbound, outbound
+}
+
+// applyDelay shifts the server timestamps by the inbound delay. The server
+// holds the response for the total delay (see ResponseHold) before sending.
+func (e *AttackEngine) applyDelay(packet *ntpcore.NTPPacket, clientAddr string) (*ntpcore.NTPPacket, string) {
+	cfg := e.cfg.Security.Delay
+	if !cfg.Enabled {
+		return packet, ""
+	}
+
+	inbound, outbound := delayDurations(cfg)
+	if inbound == 0 && outbound == 0 {
+		return packet, ""
+	}
+
+	// The request "arrives" late, so receive and transmit move together
+	if inbound > 0 {
+		packet.SetReceiveTime(packet.GetReceiveTime().Add(inbound))
+		packet.SetTransmitTime(packet.GetTransmitTime().Add(inbound))
+	}
+
+	skew := (inbound - outbound) / 2
+	e.log.LogAttack(string(AttackDelay), clientAddr,
+		fmt.Sprintf("Adding delay in=%v out=%v (client offset error %+v)", inbound, outbound, skew))
+
+	return packet, fmt.Sprintf("Delay (in %dms / out %dms)", inbound.Milliseconds(), outbound.Milliseconds())
+}
+
+// ResponseHold returns how long the server should hold a response to a
+// client produced by the delay attack before sending it (0 if the delay
+// attack is not active for the client)
+func (e *AttackEngine) ResponseHold(clientAddr string) time.Duration {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.pause.paused {
+		return 0
+	}
+
+	attack := AttackType(e.cfg.Security.ActiveAttack)
+	switch {
+	case attack == AttackTimeBomb && e.bomb.fired:
+		attack = AttackType(e.cfg.Security.TimeBomb.Attack)
+	case attack == AttackClientMix:
+		attack = e.mix.assigned[clientHost(clientAddr)]
+	}
+	if attack != AttackDelay || !e.cfg.Security.Delay.Enabled {
+		return 0
+	}
+
+	inbound, outbound := delayDurations(e.cfg.Security.Delay)
+	return inbound + outbound
+}
@@ -0,0 +1,57 @@
+package attacks
+
+import (
+	"math"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+)
+
+// Drift waveforms, selectable via TimeDriftConfig.Waveform
+const (
+	WaveLinear     = "linear"      // Ramp at drift_per_sec, capped at max_drift
+	WaveSine       = "sine"        // Oscillate within +/- amplitude
+	WaveSawtooth   = "sawtooth"    // Ramp to amplitude each period, then snap back
+	WaveRandomWalk = "random_walk" // Random steps of drift_per_sec, bounded by amplitude
+)
+
+// walkProgress names the time-based seed in use, for session markers
+func (e *AttackEngine) walkProgress(attack AttackType) string {
+	if attack != AttackTimeDrift || e.cfg.Security.TimeDrift.Waveform != WaveRandomWalk {
+		return ""
+	}
+	return e.walk.runSeed()
+}
+
+// driftSeconds computes the drift for the configured waveform, before the
+// direction is applied. Caller must hold e.mu.
+func (e *AttackEngine) driftSeconds(cfg config.TimeDriftConfig, now time.Time) float64 {
+	elapsed := now.Sub(e.driftState.StartTime).Seconds()
+
+	switch cfg.Waveform {
+	case WaveSine:
+		if cfg.Period <= 0 {
+			return 0
+		}
+		return cfg.Amplitude * math.Sin(2*math.Pi*elapsed/cfg.Period)
+	case WaveSawtooth:
+		if cfg.Period <= 0 {
+			return 0
+		}
+		return cfg.Amplitude * math.Mod(elapsed, cfg.Period) / cfg.Period
+	case WaveRandomWalk:
+		last := e.driftState.LastUpdate
+		if last.IsZero() {
+			last = e.driftState.StartTime
+		}
+		step := now.Sub(last).Seconds() * cfg.DriftPerSec
+		rng := e.seedSource(&e.walk, cfg.Seed, "Random walk", "security.time_drift.seed")
+		if rng.Intn(2) == 0 {
+			step = -step
+		}
+		e.driftState.walk = math.Max(-cfg.Amplitude, math.Min(cfg.Amplitude, e.driftState.walk+step))
+		return e.driftState.walk
+	default:
+		return math.Min(elapsed*cfg.DriftPerSec, cfg.MaxDrift)
+	}
+}
@@ -0,0 +1,114 @@
+package attacks
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/clock"
+	"github.com/neutrinoguy/timehammer/internal/config"
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// driftEngine returns an engine running the time drift attack on a mock
+// clock
+func driftEngine(t *testing.T, drift config.TimeDriftConfig) (*AttackEngine, *clock.Mock) {
+	t.Helper()
+	cfg := config.DefaultConfig()
+	drift.Enabled = true
+	cfg.Security.TimeDrift = drift
+	e := NewAttackEngine(cfg)
+	if _, err := e.EnableAttack(AttackTimeDrift); err != nil {
+		t.Fatal(err)
+	}
+	mock := clock.NewMock(time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC))
+	e.SetClock(mock)
+	return e, mock
+}
+
+// servedOffset returns how far the drift attack moves a response sent at
+// the mock clock's current time
+func servedOffset(t *testing.T, e *AttackEngine, mock *clock.Mock) time.Duration {
+	t.Helper()
+	now := mock.Now()
+	packet := ntpcore.NewPacket()
+	packet.SetTransmitTime(now)
+	packet, name := e.ProcessPacket(packet, "192.0.2.1:123", now, nil)
+	if name == "" {
+		t.Fatal("time drift not applied")
+	}
+	return packet.GetTransmitTime().Sub(now)
+}
+
+func TestDriftFollowsInjectedClock(t *testing.T) {
+	tests := []struct {
+		name  string
+		drift config.TimeDriftConfig
+		steps []time.Duration // Advance before each response
+		want  []time.Duration // Offset of each response
+	}{
+		{
+			name:  "linear",
+			drift: config.TimeDriftConfig{Waveform: WaveLinear, DriftPerSec: 0.5, MaxDrift: 60, Direction: "forward"},
+			steps: []time.Duration{0, 10 * time.Second, 50 * time.Second, time.Hour},
+			want:  []time.Duration{0, 5 * time.Second, 30 * time.Second, time.Minute},
+		},
+		{
+			name:  "linear backward",
+			drift: config.TimeDriftConfig{Waveform: WaveLinear, DriftPerSec: 2, MaxDrift: 600, Direction: "backward"},
+			steps: []time.Duration{90 * time.Second},
+			want:  []time.Duration{-3 * time.Minute},
+		},
+		{
+			name:  "sawtooth",
+			drift: config.TimeDriftConfig{Waveform: WaveSawtooth, Amplitude: 40, Period: 100, Direction: "forward"},
+			steps: []time.Duration{25 * time.Second, 50 * time.Second, 50 * time.Second},
+			want:  []time.Duration{10 * time.Second, 30 * time.Second, 10 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, mock := driftEngine(t, tt.drift)
+			var elapsed time.Duration
+			for i, step := range tt.steps {
+				mock.Advance(step)
+				elapsed += step
+				if got := servedOffset(t, e, mock); got != tt.want[i] {
+					t.Errorf("after %v: offset %v, want %v", elapsed, got, tt.want[i])
+				}
+				if drift, running := e.GetDriftStatus(); drift != tt.want[i] || running != elapsed {
+					t.Errorf("after %v: status %v after %v, want %v after %v", elapsed, drift, running, tt.want[i], elapsed)
+				}
+			}
+		})
+	}
+}
+
+// The same seed replays the same random walk
+func TestRandomWalkReplaysSeed(t *testing.T) {
+	walk := func(seed int64) []time.Duration {
+		e, mock := driftEngine(t, config.TimeDriftConfig{
+			Waveform: WaveRandomWalk, DriftPerSec: 0.1, Amplitude: 2, Direction: "forward", Seed: seed,
+		})
+		var offsets []time.Duration
+		for i := 0; i < 50; i++ {
+			mock.Advance(time.Second)
+			offsets = append(offsets, servedOffset(t, e, mock))
+		}
+		return offsets
+	}
+
+	first, again, other := walk(42), walk(42), walk(43)
+	for i := range first {
+		if first[i] != again[i] {
+			t.Fatalf("step %d: %v, then %v with the same seed", i, first[i], again[i])
+		}
+		if first[i] < -2*time.Second || first[i] > 2*time.Second {
+			t.Errorf("step %d: %v is past the amplitude", i, first[i])
+		}
+	}
+	if slices.Equal(first, other) {
+		t.Error("seeds 42 and 43 took the same walk")
+	}
+}
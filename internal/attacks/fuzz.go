@@ -0,0 +1,171 @@
+package attacks
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// Fuzzing mutation classes, selectable via FuzzingConfig.Mode
+const (
+	FuzzHeader     = "header"     // Version, mode, stratum, leap indicator
+	FuzzTimestamps = "timestamps" // Zero, max and mismatching timestamps
+	FuzzLogic      = "logic"      // Poll, precision, root delay, reference ID
+)
+
+// fuzzMutation is one way of corrupting a response
+type fuzzMutation struct {
+	class string
+	apply func(p *ntpcore.NTPPacket, r *rand.Rand) string
+}
+
+// fuzzMutations lists every mutation; the order is part of the replay
+// contract for a given seed, so only append
+var fuzzMutations = []fuzzMutation{
+	{FuzzHeader, func(p *ntpcore.NTPPacket, r *rand.Rand) string {
+		v := uint8(r.Intn(8))
+		if v == 3 || v == 4 {
+			// Try to pick an invalid one again
+			v = uint8(r.Intn(8))
+		}
+		p.Version = v
+		return fmt.Sprintf("Fuzz: Version %d", v)
+	}},
+	{FuzzHeader, func(p *ntpcore.NTPPacket, r *rand.Rand) string {
+		m := uint8(r.Intn(8))
+		if m == 4 { // Server
+			m = 0 // Reserved
+		}
+		p.Mode = m
+		return fmt.Sprintf("Fuzz: Mode %d", m)
+	}},
+	{FuzzHeader, func(p *ntpcore.NTPPacket, r *rand.Rand) string {
+		s := uint8(r.Intn(20))
+		if s == 0 {
+			s = 16 // Unsynced
+		} else if s > 16 {
+			s = 0 // Invalid/KoD without code
+		}
+		p.Stratum = s
+		return fmt.Sprintf("Fuzz: Stratum %d", s)
+	}},
+	{FuzzHeader, func(p *ntpcore.NTPPacket, r *rand.Rand) string {
+		p.LeapIndicator = 3 // Alarm
+		return "Fuzz: LI Alarm"
+	}},
+	{FuzzTimestamps, func(p *ntpcore.NTPPacket, r *rand.Rand) string {
+		p.SetReceiveTime(time.Time{})
+		p.SetTransmitTime(time.Time{})
+		p.SetReferenceTime(time.Time{})
+		return "Fuzz: Zero Timestamps"
+	}},
+	{FuzzTimestamps, func(p *ntpcore.NTPPacket, r *rand.Rand) string {
+		p.RecvTimeSec = 0xFFFFFFFF
+		p.RecvTimeFrac = 0xFFFFFFFF
+		p.XmitTimeSec = 0xFFFFFFFF
+		p.XmitTimeFrac = 0xFFFFFFFF
+		return "Fuzz: Max Timestamps"
+	}},
+	{FuzzLogic, func(p *ntpcore.NTPPacket, r *rand.Rand) string {
+		p.RootDelay = 0xFFFF0000
+		p.RootDisp = 0xFFFF0000
+		return "Fuzz: Large Root Delay"
+	}},
+	{FuzzLogic, func(p *ntpcore.NTPPacket, r *rand.Rand) string {
+		p.ReferenceID = 0x41414141 // AAAA
+		return "Fuzz: RefID AAAA"
+	}},
+	{FuzzTimestamps, func(p *ntpcore.NTPPacket, r *rand.Rand) string {
+		p.OrigTimeSec++
+		return "Fuzz: Origin Mismatch"
+	}},
+	{FuzzLogic, func(p *ntpcore.NTPPacket, r *rand.Rand) string {
+		p.Poll = -100
+		p.Precision = 100
+		return "Fuzz: Invalid Poll/Prec"
+	}},
+}
+
+// fuzzState is the seeded source and position of the fuzzing run
+type fuzzState struct {
+	rng     *rand.Rand
+	seed    int64 // Seed in use
+	cfgSeed int64 // Configured seed the source was built from (0 = time-based)
+	count   int   // Mutations applied since seeding
+}
+
+// fuzzClasses parses a fuzzing mode into the selected classes (nil = all)
+// and whether mutations are stepped through in order
+func fuzzClasses(mode string) (map[string]bool, bool) {
+	switch mode {
+	case "", "all", "random":
+		return nil, false
+	case "deterministic":
+		return nil, true
+	}
+	classes := make(map[string]bool)
+	for _, c := range strings.Split(mode, ",") {
+		classes[strings.TrimSpace(c)] = true
+	}
+	return classes, false
+}
+
+// seedFuzzer (re)creates the random source when fuzzing starts or the
+// configured seed changes. Caller must hold e.mu.
+func (e *AttackEngine) seedFuzzer(force bool) {
+	cfgSeed := e.cfg.Security.Fuzzing.Seed
+	if !force && e.fuzz.rng != nil && cfgSeed == e.fuzz.cfgSeed {
+		return
+	}
+
+	seed := cfgSeed
+	if seed == 0 {
+		seed = e.clock.Now().UnixNano()
+	}
+	e.fuzz = fuzzState{rng: rand.New(rand.NewSource(seed)), seed: seed, cfgSeed: cfgSeed}
+	e.log.Warnf("ATTACK", "Fuzzing seed %d (set security.fuzzing.seed to replay this run)", seed)
+}
+
+// fuzzProgress names the time-based seed in use, for session markers
+func (e *AttackEngine) fuzzProgress(attack AttackType) string {
+	if attack != AttackFuzzing || e.fuzz.rng == nil || e.fuzz.cfgSeed != 0 {
+		return ""
+	}
+	return fmt.Sprintf(" run_seed=%d", e.fuzz.seed)
+}
+
+// applyFuzzing applies one mutation from the selected classes, drawn from
+// the seeded source so a run can be replayed
+func (e *AttackEngine) applyFuzzing(packet *ntpcore.NTPPacket) (*ntpcore.NTPPacket, string) {
+	if !e.cfg.Security.Fuzzing.Enabled {
+		return packet, ""
+	}
+	e.seedFuzzer(false)
+
+	classes, ordered := fuzzClasses(e.cfg.Security.Fuzzing.Mode)
+	var pool []fuzzMutation
+	for _, m := range fuzzMutations {
+		if classes == nil || classes[m.class] {
+			pool = append(pool, m)
+		}
+	}
+	if len(pool) == 0 {
+		return packet, ""
+	}
+
+	var m fuzzMutation
+	if ordered {
+		m = pool[e.fuzz.count%len(pool)]
+	} else {
+		m = pool[e.fuzz.rng.Intn(len(pool))]
+	}
+	e.fuzz.count++
+
+	mutationName := m.apply(packet, e.fuzz.rng)
+	e.log.LogAttack(string(AttackFuzzing), "all",
+		fmt.Sprintf("%s (seed %d, mutation #%d)", mutationName, e.fuzz.seed, e.fuzz.count))
+	return packet, fmt.Sprintf("%s [seed %d #%d]", mutationName, e.fuzz.seed, e.fuzz.count)
+}
@@ -0,0 +1,106 @@
+package attacks
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+	"github.com/neutrinoguy/timehammer/internal/netutil"
+)
+
+// KoD rotation modes, selectable via KissOfDeathConfig.RotatePer
+const (
+	KoDRotatePerRequest = "request" // One sequence shared by all clients
+	KoDRotatePerClient  = "client"  // Each client steps through the list
+)
+
+// KoDRandomCode in KissOfDeathConfig.Codes sends four random bytes
+const KoDRandomCode = "random"
+
+// kodRotation is the position in the kiss code rotation
+type kodRotation struct {
+	next    int            // Next index for per-request rotation
+	clients map[string]int // Next index per client IP for per-client rotation
+}
+
+// nextKoDCode picks the kiss code for the next KoD sent to a client. Caller
+// must hold e.mu.
+func (e *AttackEngine) nextKoDCode(clientAddr string) string {
+	cfg := e.cfg.Security.KissOfDeath
+	if len(cfg.Codes) == 0 {
+		return cfg.Code
+	}
+
+	var i int
+	if cfg.RotatePer == KoDRotatePerClient {
+		if e.kodRot.clients == nil {
+			e.kodRot.clients = make(map[string]int)
+		}
+		host := netutil.Host(clientAddr)
+		i = e.kodRot.clients[host]
+		e.kodRot.clients[host] = i + 1
+	} else {
+		i = e.kodRot.next
+		e.kodRot.next++
+	}
+
+	code := cfg.Codes[i%len(cfg.Codes)]
+	if strings.EqualFold(code, KoDRandomCode) {
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], rand.Uint32())
+		return string(b[:])
+	}
+	return code
+}
+
+// resetKoDState forgets compliance tests and restarts the code rotation.
+// Caller must hold e.mu.
+func (e *AttackEngine) resetKoDState() {
+	e.kodTested = make(map[string]bool)
+	e.kodRot = kodRotation{}
+}
+
+// printableKoDCode formats a kiss code for logs, quoting codes that are not
+// plain ASCII letters and digits
+func printableKoDCode(code string) string {
+	for _, c := range []byte(code) {
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9') {
+			return fmt.Sprintf("%q", code)
+		}
+	}
+	return code
+}
+
+// describeKoDCodes summarises the code selection of the KoD attack
+func describeKoDCodes(cfg config.KissOfDeathConfig) string {
+	if len(cfg.Codes) == 0 {
+		return "code=" + printableKoDCode(cfg.Code)
+	}
+	desc := fmt.Sprintf("codes=%s per=%s", strings.Join(cfg.Codes, ","), cfg.RotatePer)
+	if cfg.IncludeInvalid {
+		desc += " include_invalid"
+	}
+	return desc
+}
+
+// presetStrings reads a list of strings from preset config, as written in
+// YAML or in Go
+func presetStrings(cfg map[string]interface{}, key string) ([]string, bool) {
+	switch v := cfg[key].(type) {
+	case []string:
+		return v, true
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			out = append(out, s)
+		}
+		return out, true
+	}
+	return nil, false
+}
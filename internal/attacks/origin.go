@@ -0,0 +1,46 @@
+package attacks
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// Origin timestamp corruption modes, selectable via OriginAttackConfig.Mode
+const (
+	OriginZero     = "zero"       // Origin of all zeros
+	OriginRandom   = "random"     // Unrelated random origin
+	OriginOffByOne = "off_by_one" // Lowest fraction bit flipped
+)
+
+// applyOriginAttack corrupts the echoed origin timestamp so clients that
+// match responses to requests should discard the reply
+func (e *AttackEngine) applyOriginAttack(packet *ntpcore.NTPPacket, clientAddr string, requestCount int) (*ntpcore.NTPPacket, string) {
+	cfg := e.cfg.Security.Origin
+	if !cfg.Enabled {
+		return packet, ""
+	}
+	if cfg.Interval > 0 && requestCount%cfg.Interval != 0 {
+		return packet, ""
+	}
+
+	sec, frac := packet.OrigTimeSec, packet.OrigTimeFrac
+	switch cfg.Mode {
+	case OriginZero:
+		packet.SetOriginTime(0, 0)
+	case OriginRandom:
+		packet.SetOriginTime(rand.Uint32(), rand.Uint32())
+	case OriginOffByOne:
+		// The smallest change a strict client can detect
+		packet.SetOriginTime(sec, frac^1)
+	default:
+		return packet, ""
+	}
+
+	e.log.LogAttack(string(AttackOrigin), clientAddr,
+		fmt.Sprintf("Origin timestamp %s: %08X.%08X -> %08X.%08X",
+			cfg.Mode, sec, frac, packet.OrigTimeSec, packet.OrigTimeFrac))
+
+	return packet, fmt.Sprintf("Origin Corruption (%s)", cfg.Mode)
+}
@@ -0,0 +1,74 @@
+package attacks
+
+import (
+	"errors"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/clock"
+)
+
+// pauseState holds whether the active attack is paused and since when
+type pauseState struct {
+	paused bool
+	at     time.Time
+}
+
+// Pause suspends the active attack: clients get honest answers, and the
+// drift, smear, sweep and ramp progress and the request counters hold
+// still until Resume. The scheduler and sequences keep their own timing.
+func (e *AttackEngine) Pause() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.pause.paused {
+		return errors.New("the attack is already paused")
+	}
+	if !e.cfg.Security.Enabled || e.cfg.Security.ActiveAttack == "" {
+		return errors.New("no attack is active")
+	}
+
+	e.pause = pauseState{paused: true, at: e.clock.Now()}
+	e.log.Warnf("ATTACK", "Paused %s (drift %v), responses are honest until resumed",
+		e.cfg.Security.ActiveAttack, e.driftState.CurrentDrift)
+	return nil
+}
+
+// Resume continues a paused attack from where it stopped
+func (e *AttackEngine) Resume() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.pause.paused {
+		return errors.New("the attack is not paused")
+	}
+
+	held := clock.Since(e.clock, e.pause.at)
+	e.shiftProgress(held)
+	e.pause = pauseState{}
+	e.log.Warnf("ATTACK", "Resumed %s after %v", e.cfg.Security.ActiveAttack, held.Round(time.Second))
+	return nil
+}
+
+// IsPaused reports whether the active attack is paused
+func (e *AttackEngine) IsPaused() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.pause.paused
+}
+
+// shiftProgress moves the start times that time-based attacks measure
+// their progress from by d, as if the pause had not happened. Caller must
+// hold e.mu.
+func (e *AttackEngine) shiftProgress(d time.Duration) {
+	e.driftState.StartTime = e.driftState.StartTime.Add(d)
+	if !e.driftState.LastUpdate.IsZero() {
+		e.driftState.LastUpdate = e.driftState.LastUpdate.Add(d)
+	}
+	e.smearStart = e.smearStart.Add(d)
+	if e.sweep != nil {
+		e.sweep.start = e.sweep.start.Add(d)
+	}
+	for _, c := range e.ramp.clients {
+		c.start = c.start.Add(d)
+	}
+}
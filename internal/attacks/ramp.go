@@ -0,0 +1,187 @@
+package attacks
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+)
+
+// Ramp scales
+const (
+	RampLinear      = "linear"
+	RampExponential = "exponential"
+)
+
+// rampClient is one client's progress through an offset ramp
+type rampClient struct {
+	start    time.Time
+	requests int           // Ramped responses sent
+	served   time.Duration // Offset of the last ramped response
+
+	known        bool // Whether the client's clock has been compared yet
+	following    bool // Whether its clock matched the last offset served
+	followed     bool // Whether it ever followed
+	lastFollowed time.Duration
+	broken       bool // Whether it has failed to follow an offset
+	breakAt      time.Duration
+}
+
+// rampState holds the per-client progress of the running ramp
+type rampState struct {
+	attack  AttackType
+	clients map[string]*rampClient // Keyed by client IP
+}
+
+// RampResult is one client's progress through an offset ramp and the
+// offset at which it stopped following
+type RampResult struct {
+	Client   string
+	Attack   AttackType
+	Offset   time.Duration // Offset last served
+	Requests int           // Ramped responses sent
+
+	Known        bool          // Whether the client's clock could be compared
+	Following    bool          // Whether its clock matched the last offset served
+	Followed     bool          // Whether it ever followed
+	LastFollowed time.Duration // Latest offset it followed
+	Broken       bool          // Whether it has failed to follow an offset
+	BreakAt      time.Duration // First offset it did not follow
+}
+
+// resetRamp restarts the ramp of an attack for every client. Caller must
+// hold e.mu.
+func (e *AttackEngine) resetRamp(attack AttackType) {
+	e.ramp = rampState{attack: attack, clients: make(map[string]*rampClient)}
+}
+
+// rampOffset returns the offset to send a client and, from the client's
+// clock, updates whether it followed the previous one. Caller must hold
+// e.mu.
+func (e *AttackEngine) rampOffset(attack AttackType, cfg config.RampConfig, clientAddr string, req *RequestInfo) time.Duration {
+	if e.ramp.attack != attack || e.ramp.clients == nil {
+		e.resetRamp(attack)
+	}
+
+	host := clientHost(clientAddr)
+	now := e.clock.Now()
+	c := e.ramp.clients[host]
+	if c == nil {
+		c = &rampClient{start: now}
+		e.ramp.clients[host] = c
+	} else if req != nil && req.OffsetKnown {
+		e.checkRampFollow(attack, host, c, req.ClientOffset, cfg.ToleranceSecs)
+	}
+
+	var progress float64
+	switch {
+	case cfg.DurationSecs > 0:
+		progress = now.Sub(c.start).Seconds() / cfg.DurationSecs
+	case cfg.Requests > 1:
+		progress = float64(c.requests) / float64(cfg.Requests-1)
+	default:
+		progress = 1
+	}
+
+	c.served = rampValue(cfg, math.Min(progress, 1))
+	c.requests++
+	return c.served
+}
+
+// rampValue returns the ramp offset at progress p (0 = start, 1 = end)
+func rampValue(cfg config.RampConfig, p float64) time.Duration {
+	secs := cfg.StartSecs + (cfg.EndSecs-cfg.StartSecs)*p
+	if cfg.Scale == RampExponential && cfg.StartSecs != 0 && cfg.EndSecs/cfg.StartSecs > 0 {
+		secs = cfg.StartSecs * math.Pow(cfg.EndSecs/cfg.StartSecs, p)
+	}
+	return time.Duration(secs * float64(time.Second))
+}
+
+// checkRampFollow compares a client's clock with the offset it was last
+// sent and logs when it starts or stops following. Caller must hold e.mu.
+func (e *AttackEngine) checkRampFollow(attack AttackType, host string, c *rampClient, clientOffset time.Duration, toleranceSecs float64) {
+	tolerance := time.Duration(toleranceSecs * float64(time.Second))
+	following := (clientOffset - c.served).Abs() <= tolerance
+
+	if c.known && following != c.following {
+		if following {
+			e.log.LogAttack(string(attack), host, fmt.Sprintf("Ramp: client started following at %s",
+				formatRampOffset(c.served)))
+		} else {
+			last := "never followed"
+			if c.followed {
+				last = "last followed " + formatRampOffset(c.lastFollowed)
+			}
+			e.log.LogAttack(string(attack), host, fmt.Sprintf("Ramp: client stopped following at %s (%s, clock at %s)",
+				formatRampOffset(c.served), last, formatRampOffset(clientOffset)))
+		}
+	}
+	if !c.known && !following {
+		e.log.LogAttack(string(attack), host, fmt.Sprintf("Ramp: client did not follow the first offset %s (clock at %s)",
+			formatRampOffset(c.served), formatRampOffset(clientOffset)))
+	}
+
+	if following {
+		c.followed = true
+		c.lastFollowed = c.served
+	} else if !c.broken {
+		c.broken = true
+		c.breakAt = c.served
+	}
+	c.known = true
+	c.following = following
+}
+
+// GetRampResults returns the progress of the current ramp per client,
+// sorted by client
+func (e *AttackEngine) GetRampResults() []RampResult {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	results := make([]RampResult, 0, len(e.ramp.clients))
+	for host, c := range e.ramp.clients {
+		results = append(results, RampResult{
+			Client:       host,
+			Attack:       e.ramp.attack,
+			Offset:       c.served,
+			Requests:     c.requests,
+			Known:        c.known,
+			Following:    c.following,
+			Followed:     c.followed,
+			LastFollowed: c.lastFollowed,
+			Broken:       c.broken,
+			BreakAt:      c.breakAt,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Client < results[j].Client })
+	return results
+}
+
+// describeRamp formats a ramp for attack descriptions
+func describeRamp(r config.RampConfig) string {
+	over := fmt.Sprintf("%d requests", r.Requests)
+	if r.DurationSecs > 0 {
+		over = fmt.Sprintf("%gs", r.DurationSecs)
+	}
+	return fmt.Sprintf("ramp=%g..%gs %s over %s", r.StartSecs, r.EndSecs, r.Scale, over)
+}
+
+// formatRampOffset formats an offset with its sign, rounded to the millisecond
+func formatRampOffset(d time.Duration) string {
+	s := d.Round(time.Millisecond).String()
+	if d >= 0 {
+		s = "+" + s
+	}
+	return s
+}
+
+// clientHost strips the port from a client address
+func clientHost(clientAddr string) string {
+	if host, _, err := net.SplitHostPort(clientAddr); err == nil {
+		return host
+	}
+	return clientAddr
+}
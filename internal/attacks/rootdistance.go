@@ -0,0 +1,84 @@
+package attacks
+
+import (
+	"fmt"
+
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// applyRootDistance overrides the root delay and dispersion with the
+// configured values (negative values leave a field untouched)
+func (e *AttackEngine) applyRootDistance(packet *ntpcore.NTPPacket, clientAddr string) (*ntpcore.NTPPacket, string) {
+	cfg := e.cfg.Security.RootDistance
+	if !cfg.Enabled {
+		return packet, ""
+	}
+
+	if cfg.RootDelayMs >= 0 {
+		packet.RootDelay = ntpcore.CalculateRootDelay(cfg.RootDelayMs)
+	}
+	if cfg.RootDispMs >= 0 {
+		packet.RootDisp = ntpcore.CalculateRootDispersion(cfg.RootDispMs)
+	}
+
+	summary := describeRootDistance(cfg.RootDelayMs, cfg.RootDispMs)
+	e.log.LogAttack(string(AttackRootDistance), clientAddr, "Claiming "+summary)
+
+	return packet, fmt.Sprintf("Root Distance (%s)", summary)
+}
+
+// overlayRootDistance applies the root distance override on top of the
+// attack that already ran, combining the attack names
+func (e *AttackEngine) overlayRootDistance(packet *ntpcore.NTPPacket, clientAddr, name string) (*ntpcore.NTPPacket, string) {
+	packet, overlay := e.applyRootDistance(packet, clientAddr)
+	if name == "" || overlay == "" {
+		return packet, name + overlay
+	}
+	return packet, name + " + " + overlay
+}
+
+// applyRootDistancePreset reads root distance keys from a preset or sequence
+// step config. Present keys enable the override as an overlay, so any step
+// can degrade or polish its claimed quality. Caller must hold e.mu.
+func (e *AttackEngine) applyRootDistancePreset(cfg map[string]interface{}) {
+	delay, hasDelay := presetFloat(cfg, "root_delay_ms")
+	disp, hasDisp := presetFloat(cfg, "root_disp_ms")
+	if !hasDelay && !hasDisp {
+		return
+	}
+
+	rd := &e.cfg.Security.RootDistance
+	rd.Enabled = true
+	rd.Overlay = true
+	rd.RootDelayMs, rd.RootDispMs = -1, -1
+	if hasDelay {
+		rd.RootDelayMs = delay
+	}
+	if hasDisp {
+		rd.RootDispMs = disp
+	}
+}
+
+// presetFloat returns a numeric preset value whether YAML decoded it as an
+// int or a float
+func presetFloat(cfg map[string]interface{}, key string) (float64, bool) {
+	switch v := cfg[key].(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// describeRootDistance summarises the overridden fields
+func describeRootDistance(delayMs, dispMs float64) string {
+	delay, disp := "unchanged", "unchanged"
+	if delayMs >= 0 {
+		delay = fmt.Sprintf("%gms", delayMs)
+	}
+	if dispMs >= 0 {
+		disp = fmt.Sprintf("%gms", dispMs)
+	}
+	return fmt.Sprintf("delay %s, disp %s", delay, disp)
+}
@@ -0,0 +1,333 @@
+package attacks
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+)
+
+// scheduleState tracks the attack scheduler
+type scheduleState struct {
+	running   bool
+	start     time.Time // When the scheduler started (server start)
+	stop      chan struct{}
+	active    AttackType      // Attack the scheduler turned on
+	ownsMode  bool            // Scheduler turned security mode on
+	badConfig map[string]bool // Invalid schedule values already reported
+}
+
+// dailyWindow is a daily time-of-day window in minutes since midnight
+type dailyWindow struct {
+	from, to int
+}
+
+// StartScheduler starts the goroutine that applies attack schedules
+func (e *AttackEngine) StartScheduler() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.sched.running {
+		return
+	}
+	e.sched.running = true
+	e.sched.start = e.clock.Now()
+	e.sched.stop = make(chan struct{})
+	go e.scheduleLoop(e.sched.stop)
+}
+
+// StopScheduler stops applying attack schedules. An attack the scheduler
+// turned on stays as it is.
+func (e *AttackEngine) StopScheduler() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.sched.running {
+		return
+	}
+	e.sched.running = false
+	close(e.sched.stop)
+}
+
+// scheduleLoop re-evaluates schedules every second
+func (e *AttackEngine) scheduleLoop(stop chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	e.tickSchedule(e.currentTime())
+	for {
+		select {
+		case <-ticker.C:
+			e.tickSchedule(e.currentTime())
+		case <-stop:
+			return
+		}
+	}
+}
+
+// tickSchedule switches to the highest priority due attack, if any
+func (e *AttackEngine) tickSchedule(now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.sched.running {
+		return
+	}
+
+	best := AttackNone
+	bestPriority := 0
+	for _, info := range GetAvailableAttacks() {
+		_, sch := attackSection(&e.cfg.Security, info.Type)
+		if sch == nil || !sch.IsSet() {
+			continue
+		}
+		if active, _, _ := e.schedulePhase(*sch, now); !active {
+			continue
+		}
+		// Ties go to the attack listed first
+		if best == AttackNone || sch.Priority < bestPriority {
+			best, bestPriority = info.Type, sch.Priority
+		}
+	}
+
+	if best == e.sched.active {
+		return
+	}
+
+	if prev := e.sched.active; prev != AttackNone {
+		if enabled, _ := attackSection(&e.cfg.Security, prev); enabled != nil {
+			*enabled = false
+		}
+		e.log.Infof("ATTACK", "Scheduled attack %s ended", prev)
+		if best == AttackNone && AttackType(e.cfg.Security.ActiveAttack) == prev {
+			e.cfg.Security.ActiveAttack = ""
+			if e.sched.ownsMode {
+				e.cfg.Security.Enabled = false
+				e.sched.ownsMode = false
+			}
+		}
+	}
+
+	if best != AttackNone {
+		if !e.cfg.Security.Enabled {
+			e.sched.ownsMode = true
+		}
+		e.cfg.Security.Enabled = true
+		e.cfg.Security.ActiveAttack = string(best)
+		e.enableAttackConfig(best)
+		e.log.LogAttack(string(best), "all", fmt.Sprintf("Scheduled attack started (priority %d)", bestPriority))
+	}
+
+	e.sched.active = best
+}
+
+// schedulePhase reports whether a schedule is active at now, when it next
+// starts if not, and when the current or next period ends (zero = never).
+// Caller must hold e.mu.
+func (e *AttackEngine) schedulePhase(sch config.AttackSchedule, now time.Time) (active bool, next, until time.Time) {
+	start := e.sched.start.Add(time.Duration(sch.StartAfter) * time.Second)
+	if sch.StartAt != "" {
+		t, err := time.Parse(time.RFC3339, sch.StartAt)
+		if err != nil {
+			e.reportBadSchedule(sch.StartAt, "start_at is not RFC3339")
+			return false, time.Time{}, time.Time{}
+		}
+		start = t
+	}
+
+	var end time.Time
+	if sch.Duration > 0 {
+		end = start.Add(time.Duration(sch.Duration) * time.Second)
+	}
+	if !end.IsZero() && !now.Before(end) {
+		return false, time.Time{}, time.Time{}
+	}
+
+	if sch.Window == "" {
+		if now.Before(start) {
+			return false, start, end
+		}
+		return true, time.Time{}, end
+	}
+
+	win, err := parseWindow(sch.Window)
+	if err != nil {
+		e.reportBadSchedule(sch.Window, err.Error())
+		return false, time.Time{}, time.Time{}
+	}
+
+	from := now
+	if now.Before(start) {
+		from = start
+	}
+	if !now.Before(start) && win.contains(now) {
+		until = win.closeAfter(now)
+		if !end.IsZero() && end.Before(until) {
+			until = end
+		}
+		return true, time.Time{}, until
+	}
+
+	next = win.openAfter(from)
+	if !end.IsZero() && !next.Before(end) {
+		return false, time.Time{}, time.Time{}
+	}
+	return false, next, end
+}
+
+// reportBadSchedule logs an invalid schedule value once
+func (e *AttackEngine) reportBadSchedule(value, reason string) {
+	if e.sched.badConfig == nil {
+		e.sched.badConfig = make(map[string]bool)
+	}
+	if e.sched.badConfig[value] {
+		return
+	}
+	e.sched.badConfig[value] = true
+	e.log.Errorf("ATTACK", "Ignoring attack schedule %q: %s", value, reason)
+}
+
+// ScheduleStatus summarizes the scheduler for the dashboard, e.g.
+// "clock_step scheduled to start in 3m0s". Empty if nothing is scheduled.
+func (e *AttackEngine) ScheduleStatus() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.sched.running {
+		return ""
+	}
+	now := e.clock.Now()
+
+	if e.sched.active != AttackNone {
+		_, sch := attackSection(&e.cfg.Security, e.sched.active)
+		if sch != nil {
+			if _, _, until := e.schedulePhase(*sch, now); !until.IsZero() {
+				return fmt.Sprintf("%s active, ends in %s", e.sched.active, until.Sub(now).Round(time.Second))
+			}
+		}
+		return fmt.Sprintf("%s active", e.sched.active)
+	}
+
+	var soonest AttackType
+	var soonestAt time.Time
+	for _, info := range GetAvailableAttacks() {
+		_, sch := attackSection(&e.cfg.Security, info.Type)
+		if sch == nil || !sch.IsSet() {
+			continue
+		}
+		if _, next, _ := e.schedulePhase(*sch, now); !next.IsZero() && (soonestAt.IsZero() || next.Before(soonestAt)) {
+			soonest, soonestAt = info.Type, next
+		}
+	}
+	if soonest == AttackNone {
+		return ""
+	}
+	return fmt.Sprintf("%s scheduled to start in %s", soonest, soonestAt.Sub(now).Round(time.Second))
+}
+
+// attackSection returns the Enabled flag and schedule of an attack's config
+func attackSection(sec *config.SecurityConfig, attack AttackType) (*bool, *config.AttackSchedule) {
+	switch attack {
+	case AttackTimeSpoofing:
+		return &sec.TimeSpoofing.Enabled, &sec.TimeSpoofing.Schedule
+	case AttackTimeDrift:
+		return &sec.TimeDrift.Enabled, &sec.TimeDrift.Schedule
+	case AttackKissOfDeath:
+		return &sec.KissOfDeath.Enabled, &sec.KissOfDeath.Schedule
+	case AttackStratumLie:
+		return &sec.StratumAttack.Enabled, &sec.StratumAttack.Schedule
+	case AttackLeapSecond:
+		return &sec.LeapSecond.Enabled, &sec.LeapSecond.Schedule
+	case AttackLeapSmear:
+		return &sec.LeapSmear.Enabled, &sec.LeapSmear.Schedule
+	case AttackRollover:
+		return &sec.Rollover.Enabled, &sec.Rollover.Schedule
+	case AttackClockStep:
+		return &sec.ClockStep.Enabled, &sec.ClockStep.Schedule
+	case AttackFuzzing:
+		return &sec.Fuzzing.Enabled, &sec.Fuzzing.Schedule
+	case AttackCryptoNAK:
+		return &sec.CryptoNAK.Enabled, &sec.CryptoNAK.Schedule
+	case AttackDelay:
+		return &sec.Delay.Enabled, &sec.Delay.Schedule
+	case AttackRefID:
+		return &sec.RefID.Enabled, &sec.RefID.Schedule
+	case AttackRootDistance:
+		return &sec.RootDistance.Enabled, &sec.RootDistance.Schedule
+	case AttackOrigin:
+		return &sec.Origin.Enabled, &sec.Origin.Schedule
+	case AttackTimeBomb:
+		return &sec.TimeBomb.Enabled, &sec.TimeBomb.Schedule
+	case AttackClientMix:
+		return &sec.ClientMix.Enabled, &sec.ClientMix.Schedule
+	default:
+		return nil, nil
+	}
+}
+
+// parseWindow parses a daily window like "09:00-17:30". Windows may wrap
+// past midnight ("22:00-02:00").
+func parseWindow(s string) (dailyWindow, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return dailyWindow{}, fmt.Errorf("window must look like HH:MM-HH:MM")
+	}
+	from, err := parseClock(parts[0])
+	if err != nil {
+		return dailyWindow{}, err
+	}
+	to, err := parseClock(parts[1])
+	if err != nil {
+		return dailyWindow{}, err
+	}
+	if from == to {
+		return dailyWindow{}, fmt.Errorf("window start and end are equal")
+	}
+	return dailyWindow{from: from, to: to}, nil
+}
+
+// parseClock parses "HH:MM" into minutes since midnight
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// contains reports whether t falls inside the window
+func (w dailyWindow) contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if w.from < w.to {
+		return m >= w.from && m < w.to
+	}
+	return m >= w.from || m < w.to
+}
+
+// at returns the instant at the given minute on t's day
+func (w dailyWindow) at(t time.Time, minute int) time.Time {
+	y, mo, d := t.Date()
+	return time.Date(y, mo, d, minute/60, minute%60, 0, 0, t.Location())
+}
+
+// openAfter returns the first window opening at or after t
+func (w dailyWindow) openAfter(t time.Time) time.Time {
+	if w.contains(t) {
+		return t
+	}
+	open := w.at(t, w.from)
+	if open.Before(t) {
+		open = open.AddDate(0, 0, 1)
+	}
+	return open
+}
+
+// closeAfter returns the first window close after t
+func (w dailyWindow) closeAfter(t time.Time) time.Time {
+	closing := w.at(t, w.to)
+	if !closing.After(t) {
+		closing = closing.AddDate(0, 0, 1)
+	}
+	return closing
+}
@@ -0,0 +1,40 @@
+package attacks
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// seededSource is the random source of an attack without an order of its
+// own, built from the attack's seed setting so a run can be replayed
+type seededSource struct {
+	rng     *rand.Rand
+	seed    int64 // Seed in use
+	cfgSeed int64 // Configured seed the source was built from (0 = time-based)
+}
+
+// seedSource returns src's random source, creating it on first use or when
+// the configured seed changed. A time-based seed is picked for seed 0; the
+// seed in use is logged with the config key that replays it. Caller must
+// hold e.mu.
+func (e *AttackEngine) seedSource(src *seededSource, cfgSeed int64, name, key string) *rand.Rand {
+	if src.rng != nil && cfgSeed == src.cfgSeed {
+		return src.rng
+	}
+
+	seed := cfgSeed
+	if seed == 0 {
+		seed = e.clock.Now().UnixNano()
+	}
+	*src = seededSource{rng: rand.New(rand.NewSource(seed)), seed: seed, cfgSeed: cfgSeed}
+	e.log.Warnf("ATTACK", "%s seed %d (set %s to replay this run)", name, seed, key)
+	return src.rng
+}
+
+// runSeed names the time-based seed in use, for session markers
+func (src *seededSource) runSeed() string {
+	if src.rng == nil || src.cfgSeed != 0 {
+		return ""
+	}
+	return fmt.Sprintf(" run_seed=%d", src.seed)
+}
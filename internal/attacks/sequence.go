@@ -0,0 +1,160 @@
+package attacks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+	"github.com/neutrinoguy/timehammer/internal/ops"
+)
+
+// sequenceState tracks the running attack sequence, if any
+type sequenceState struct {
+	name     string
+	step     int
+	total    int
+	stepEnds time.Time
+	cancel   context.CancelFunc
+}
+
+// StartSequence runs the steps of an attack sequence in the background,
+// replacing any sequence already running
+func (e *AttackEngine) StartSequence(seq config.AttackSequence) error {
+	if len(seq.Steps) == 0 {
+		return fmt.Errorf("sequence %q has no steps", seq.Name)
+	}
+	for i, step := range seq.Steps {
+		if !isKnownAttack(AttackType(step.Attack)) {
+			return fmt.Errorf("sequence %q step %d: unknown attack %q", seq.Name, i+1, step.Attack)
+		}
+		if step.Duration <= 0 {
+			return fmt.Errorf("sequence %q step %d: duration must be positive", seq.Name, i+1)
+		}
+	}
+
+	e.StopSequence()
+
+	ctx, done := ops.GetRegistry().Start(context.Background(), "attack sequence "+seq.Name)
+	ctx, cancel := context.WithCancel(ctx)
+
+	e.mu.Lock()
+	e.seq = &sequenceState{name: seq.Name, total: len(seq.Steps), cancel: cancel}
+	e.mu.Unlock()
+
+	e.log.Infof("ATTACK", "Starting attack sequence %s (%d steps, loop=%v)", seq.Name, len(seq.Steps), seq.Loop)
+
+	go func() {
+		defer done()
+		defer cancel()
+		e.runSequence(ctx, seq)
+	}()
+	return nil
+}
+
+// runSequence advances through the steps until done or cancelled
+func (e *AttackEngine) runSequence(ctx context.Context, seq config.AttackSequence) {
+	for {
+		for i, step := range seq.Steps {
+			dur := time.Duration(step.Duration) * time.Second
+
+			if err := e.ApplyStep(seq.Name, step); err != nil {
+				e.log.Errorf("ATTACK", "Sequence %s step %d: %v", seq.Name, i+1, err)
+			}
+
+			e.mu.Lock()
+			if e.seq != nil && e.seq.name == seq.Name {
+				e.seq.step = i
+				e.seq.stepEnds = e.clock.Now().Add(dur)
+			}
+			e.mu.Unlock()
+
+			e.log.LogAttack(step.Attack, "all",
+				fmt.Sprintf("Sequence %s step %d/%d for %v", seq.Name, i+1, len(seq.Steps), dur))
+
+			select {
+			case <-time.After(dur):
+			case <-ctx.Done():
+				e.finishSequence(seq.Name, "stopped")
+				return
+			}
+		}
+
+		if !seq.Loop {
+			e.finishSequence(seq.Name, "finished")
+			return
+		}
+	}
+}
+
+// ApplyStep switches to the attack of a sequence step, applying its config
+// overrides the same way presets do
+func (e *AttackEngine) ApplyStep(name string, step config.SequenceStep) error {
+	// Overlays only last for the step that declares them
+	e.mu.Lock()
+	if e.cfg.Security.RootDistance.Overlay {
+		e.cfg.Security.RootDistance.Enabled = false
+	}
+	e.mu.Unlock()
+
+	e.ApplyPreset(config.AttackPreset{Name: name, Attack: step.Attack, Config: step.Config})
+	_, err := e.EnableAttack(AttackType(step.Attack))
+	return err
+}
+
+// finishSequence clears the sequence state and turns attacks off
+func (e *AttackEngine) finishSequence(name, how string) {
+	e.mu.Lock()
+	current := e.seq != nil && e.seq.name == name
+	if current {
+		e.seq = nil
+	}
+	e.mu.Unlock()
+
+	// A replacement sequence owns the attack state now
+	if !current {
+		return
+	}
+	e.DisableAllAttacks()
+	e.log.Infof("ATTACK", "Attack sequence %s %s, attacks disabled", name, how)
+}
+
+// StopSequence stops the running attack sequence, if any
+func (e *AttackEngine) StopSequence() bool {
+	e.mu.Lock()
+	seq := e.seq
+	e.mu.Unlock()
+
+	if seq == nil {
+		return false
+	}
+	seq.cancel()
+
+	// Wait briefly so the caller sees attacks disabled on return
+	for i := 0; i < 50; i++ {
+		e.mu.RLock()
+		running := e.seq == seq
+		e.mu.RUnlock()
+		if !running {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return true
+}
+
+// SequenceStatus describes the running sequence, e.g.
+// "Soak Test step 2/3, next in 25s". Empty if none is running.
+func (e *AttackEngine) SequenceStatus() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.seq == nil {
+		return ""
+	}
+	next := e.seq.stepEnds.Sub(e.clock.Now()).Round(time.Second)
+	if next < 0 {
+		next = 0
+	}
+	return fmt.Sprintf("%s step %d/%d, next in %s", e.seq.name, e.seq.step+1, e.seq.total, next)
+}
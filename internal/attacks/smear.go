@@ -0,0 +1,68 @@
+package attacks
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// Leap smear directions, selectable via LeapSmearConfig.Direction
+const (
+	SmearPositive = "positive" // Inserted leap second: time runs slow, ends 1s behind
+	SmearNegative = "negative" // Deleted leap second: time runs fast, ends 1s ahead
+)
+
+// smearStartTime returns when the leap smear begins: the configured start,
+// or when the attack was enabled. Caller must hold e.mu.
+func (e *AttackEngine) smearStartTime() time.Time {
+	if start := e.cfg.Security.LeapSmear.Start; start != "" {
+		if t, err := time.Parse(time.RFC3339, start); err == nil {
+			return t
+		}
+	}
+	return e.smearStart
+}
+
+// smearOffset returns the offset the smear has reached at now: 0 before
+// the window, a linear part of the leap second inside it, and the whole
+// second after it
+func smearOffset(start time.Time, window time.Duration, direction string, now time.Time) time.Duration {
+	var offset time.Duration
+	switch elapsed := now.Sub(start); {
+	case elapsed <= 0:
+		return 0
+	case window <= 0 || elapsed >= window:
+		offset = time.Second
+	default:
+		offset = time.Duration(float64(time.Second) * float64(elapsed) / float64(window))
+	}
+	if direction == SmearPositive {
+		offset = -offset
+	}
+	return offset
+}
+
+// applyLeapSmear spreads a leap second over the smear window while keeping
+// the leap indicator clear, as smearing servers do, so clients expecting a
+// flagged leap see only a slow drift
+func (e *AttackEngine) applyLeapSmear(packet *ntpcore.NTPPacket, realTime time.Time) (*ntpcore.NTPPacket, string) {
+	cfg := e.cfg.Security.LeapSmear
+	if !cfg.Enabled {
+		return packet, ""
+	}
+
+	window := time.Duration(cfg.WindowSecs) * time.Second
+	offset := smearOffset(e.smearStartTime(), window, cfg.Direction, e.clock.Now())
+	fakeTime := realTime.Add(offset)
+
+	packet.LeapIndicator = ntpcore.LeapNoWarning
+	packet.SetReceiveTime(fakeTime)
+	packet.SetTransmitTime(fakeTime)
+	packet.SetReferenceTime(fakeTime.Add(-time.Second))
+
+	e.log.LogAttack(string(AttackLeapSmear), "all",
+		fmt.Sprintf("Smearing %s leap second: offset %v of 1s over %v", cfg.Direction, offset, window))
+
+	return packet, fmt.Sprintf("Leap Smear (%v)", offset.Round(time.Millisecond))
+}
@@ -0,0 +1,44 @@
+package attacks
+
+import (
+	"fmt"
+
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// applyTrackedStratum advertises the real stratum, moved by a random step
+// of up to ±jitter and kept within 1-15. An unsynchronized stratum (16) is
+// passed through, since a synced claim would stand out more.
+func (e *AttackEngine) applyTrackedStratum(packet *ntpcore.NTPPacket, jitter int) (*ntpcore.NTPPacket, string) {
+	upstream := e.realStratum(packet.Stratum)
+	stratum := int(upstream)
+	if upstream >= 1 && upstream <= 15 && jitter > 0 {
+		rng := e.seedSource(&e.stratumJitter, e.cfg.Security.StratumAttack.Seed, "Stratum jitter", "security.stratum_attack.seed")
+		stratum = min(max(stratum+rng.Intn(2*jitter+1)-jitter, 1), 15)
+	}
+	packet.Stratum = uint8(stratum)
+
+	e.log.LogAttack(string(AttackStratumLie), "all",
+		fmt.Sprintf("Tracking upstream stratum %d, advertising %d", upstream, stratum))
+
+	return packet, fmt.Sprintf("Stratum Track (%d)", stratum)
+}
+
+// stratumProgress names the time-based seed of the stratum jitter, for
+// session markers
+func (e *AttackEngine) stratumProgress(attack AttackType) string {
+	if cfg := e.cfg.Security.StratumAttack; attack != AttackStratumLie || !cfg.TrackUpstream || cfg.Jitter <= 0 {
+		return ""
+	}
+	return e.stratumJitter.runSeed()
+}
+
+// refClockID returns the reference ID of server.ref_clock, the code a
+// stratum 1 claim comes with, or GPS if it is not a usable code
+func (e *AttackEngine) refClockID() uint32 {
+	id, err := ntpcore.RefClockID(e.cfg.Server.RefClock)
+	if err != nil {
+		id, _ = ntpcore.RefClockID("GPS")
+	}
+	return id
+}
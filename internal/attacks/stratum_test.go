@@ -0,0 +1,44 @@
+package attacks
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// The same seed replays the same stratum steps, which stay within the
+// jitter of the upstream stratum
+func TestTrackedStratumReplaysSeed(t *testing.T) {
+	const upstream, jitter = 5, 2
+	run := func(seed int64) []uint8 {
+		cfg := config.DefaultConfig()
+		cfg.Security.StratumAttack = config.StratumAttackConfig{TrackUpstream: true, Jitter: jitter, Seed: seed}
+		e := NewAttackEngine(cfg)
+		if _, err := e.EnableAttack(AttackStratumLie); err != nil {
+			t.Fatal(err)
+		}
+		e.SetStratumSource(func() uint8 { return upstream })
+
+		var strata []uint8
+		for i := 0; i < 50; i++ {
+			packet, _ := e.ProcessPacket(ntpcore.NewPacket(), "192.0.2.1:123", e.currentTime(), nil)
+			strata = append(strata, packet.Stratum)
+		}
+		return strata
+	}
+
+	first, again, other := run(42), run(42), run(43)
+	if !slices.Equal(first, again) {
+		t.Fatalf("seed 42 gave %v, then %v", first, again)
+	}
+	if slices.Equal(first, other) {
+		t.Error("seeds 42 and 43 gave the same strata")
+	}
+	for i, s := range first {
+		if s < upstream-jitter || s > upstream+jitter {
+			t.Errorf("response %d: stratum %d, want %d±%d", i, s, upstream, jitter)
+		}
+	}
+}
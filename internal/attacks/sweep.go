@@ -0,0 +1,144 @@
+package attacks
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+)
+
+// sweepSetter writes a sweep setpoint into the security config
+type sweepSetter func(sec *config.SecurityConfig, v float64)
+
+// sweepParams lists the parameters that can be swept, per attack
+var sweepParams = map[AttackType]map[string]sweepSetter{
+	AttackTimeSpoofing: {
+		"offset_secs": func(sec *config.SecurityConfig, v float64) { sec.TimeSpoofing.OffsetSecs = int64(math.Round(v)) },
+	},
+	AttackTimeDrift: {
+		"drift_per_sec": func(sec *config.SecurityConfig, v float64) { sec.TimeDrift.DriftPerSec = v },
+		"max_drift":     func(sec *config.SecurityConfig, v float64) { sec.TimeDrift.MaxDrift = v },
+		"amplitude":     func(sec *config.SecurityConfig, v float64) { sec.TimeDrift.Amplitude = v },
+		"period":        func(sec *config.SecurityConfig, v float64) { sec.TimeDrift.Period = v },
+	},
+	AttackKissOfDeath: {
+		"interval": func(sec *config.SecurityConfig, v float64) { sec.KissOfDeath.Interval = int(math.Round(v)) },
+	},
+	AttackStratumLie: {
+		"fake_stratum": func(sec *config.SecurityConfig, v float64) { sec.StratumAttack.FakeStratum = int(math.Round(v)) },
+	},
+	AttackLeapSmear: {
+		"window_secs": func(sec *config.SecurityConfig, v float64) { sec.LeapSmear.WindowSecs = int(math.Round(v)) },
+	},
+	AttackRollover: {
+		"target_year": func(sec *config.SecurityConfig, v float64) { sec.Rollover.TargetYear = int(math.Round(v)) },
+	},
+	AttackCryptoNAK: {
+		"interval": func(sec *config.SecurityConfig, v float64) { sec.CryptoNAK.Interval = int(math.Round(v)) },
+	},
+	AttackRootDistance: {
+		"root_delay_ms": func(sec *config.SecurityConfig, v float64) { sec.RootDistance.RootDelayMs = v },
+		"root_disp_ms":  func(sec *config.SecurityConfig, v float64) { sec.RootDistance.RootDispMs = v },
+	},
+	AttackDelay: {
+		"inbound_ms":  func(sec *config.SecurityConfig, v float64) { sec.Delay.InboundDelayMs = int(math.Round(v)) },
+		"outbound_ms": func(sec *config.SecurityConfig, v float64) { sec.Delay.OutboundDelayMs = int(math.Round(v)) },
+	},
+	AttackClockStep: {
+		"step_secs": func(sec *config.SecurityConfig, v float64) { sec.ClockStep.StepSecs = int64(math.Round(v)) },
+		"interval":  func(sec *config.SecurityConfig, v float64) { sec.ClockStep.Interval = int(math.Round(v)) },
+	},
+}
+
+// sweepState tracks the progress of the configured sweep
+type sweepState struct {
+	key     string // Identifies the sweep definition this state belongs to
+	start   time.Time
+	index   int // Current setpoint index (-1 = not applied yet)
+	invalid bool
+	done    bool
+}
+
+// SweepParams returns the sweepable parameter names for an attack
+func SweepParams(attack AttackType) []string {
+	var names []string
+	for name := range sweepParams[attack] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sweepValue returns the setpoint for step i of the sweep
+func sweepValue(sw config.SweepConfig, i int) float64 {
+	if sw.Steps < 2 {
+		return sw.Start
+	}
+	return sw.Start + (sw.End-sw.Start)*float64(i)/float64(sw.Steps-1)
+}
+
+// advanceSweep moves the sweep to the setpoint due at now and applies it.
+// Must be called with e.mu held.
+func (e *AttackEngine) advanceSweep(now time.Time) {
+	sw := e.cfg.Security.Sweep
+	if !sw.Enabled {
+		e.sweep = nil
+		return
+	}
+
+	attack := AttackType(e.cfg.Security.ActiveAttack)
+	key := fmt.Sprintf("%s/%+v", attack, sw)
+	if e.sweep == nil || e.sweep.key != key {
+		e.sweep = &sweepState{key: key, start: now, index: -1}
+
+		if _, ok := sweepParams[attack][sw.Param]; !ok || sw.Dwell <= 0 {
+			e.sweep.invalid = true
+			e.log.Warnf("ATTACK", "Sweep ignored: %q is not sweepable for %s (options: %s) or dwell is not positive",
+				sw.Param, attack, strings.Join(SweepParams(attack), ", "))
+			return
+		}
+		e.log.Infof("ATTACK", "Sweep started: %s %s from %g to %g in %d steps, %ds each",
+			attack, sw.Param, sw.Start, sw.End, sw.Steps, sw.Dwell)
+	}
+	if e.sweep.invalid || e.sweep.done {
+		return
+	}
+
+	steps := sw.Steps
+	if steps < 1 {
+		steps = 1
+	}
+	idx := int(now.Sub(e.sweep.start) / (time.Duration(sw.Dwell) * time.Second))
+	if idx >= steps {
+		idx = steps - 1
+		if e.sweep.index == idx {
+			e.sweep.done = true
+			e.log.Infof("ATTACK", "Sweep complete, holding %s=%g", sw.Param, sweepValue(sw, idx))
+			return
+		}
+	}
+	if idx == e.sweep.index {
+		return
+	}
+
+	e.sweep.index = idx
+	value := sweepValue(sw, idx)
+	sweepParams[attack][sw.Param](&e.cfg.Security, value)
+	e.log.LogAttack(string(attack), "all",
+		fmt.Sprintf("Sweep setpoint %d/%d: %s=%g", idx+1, steps, sw.Param, value))
+}
+
+// sweepProgress describes the current setpoint for session markers
+func (e *AttackEngine) sweepProgress() string {
+	if e.sweep == nil || e.sweep.invalid || e.sweep.index < 0 {
+		return ""
+	}
+	steps := e.cfg.Security.Sweep.Steps
+	if steps < 1 {
+		steps = 1
+	}
+	return fmt.Sprintf(" sweep=%d/%d", e.sweep.index+1, steps)
+}
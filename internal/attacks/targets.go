@@ -0,0 +1,84 @@
+package attacks
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/neutrinoguy/timehammer/internal/netutil"
+)
+
+// targetScope is the parsed form of the configured target filter
+type targetScope struct {
+	key   string // Config entries the scope was built from
+	allow *netutil.AddrSet
+	deny  *netutil.AddrSet
+	err   error
+}
+
+// currentScope returns the parsed target filter, rebuilding it when the
+// config changed. Caller must hold e.mu.
+func (e *AttackEngine) currentScope() *targetScope {
+	filter := e.cfg.Security.Targets
+	key := strings.Join(filter.Allow, ",") + "|" + strings.Join(filter.Deny, ",")
+	if e.scope != nil && e.scope.key == key {
+		return e.scope
+	}
+
+	scope := &targetScope{key: key}
+	scope.allow, scope.err = netutil.ParseAddrSet(filter.Allow)
+	if scope.err == nil {
+		scope.deny, scope.err = netutil.ParseAddrSet(filter.Deny)
+	}
+	if scope.err != nil {
+		// Fail closed: a broken filter must not widen the blast radius
+		e.log.Errorf("ATTACK", "Invalid target filter, attacking no clients: %v", scope.err)
+	} else if !scope.allow.Empty() || !scope.deny.Empty() {
+		e.log.Infof("ATTACK", "Attack targets: %s", describeScope(scope))
+	}
+
+	e.scope = scope
+	return scope
+}
+
+// inScope reports whether clientAddr is targeted. Caller must hold e.mu.
+func (e *AttackEngine) inScope(clientAddr string) bool {
+	scope := e.currentScope()
+	if scope.err != nil {
+		return false
+	}
+	// Deny wins; an empty deny set would match everything so skip it
+	if !scope.deny.Empty() && scope.deny.ContainsAddr(clientAddr) {
+		return false
+	}
+	return scope.allow.ContainsAddr(clientAddr)
+}
+
+// IsTargeted reports whether attacks apply to the given client address
+func (e *AttackEngine) IsTargeted(clientAddr string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.inScope(clientAddr)
+}
+
+// DescribeTargets returns a short summary of the clients in scope
+func (e *AttackEngine) DescribeTargets() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return describeScope(e.currentScope())
+}
+
+// describeScope formats a target scope for display
+func describeScope(scope *targetScope) string {
+	if scope.err != nil {
+		return fmt.Sprintf("none (invalid filter: %v)", scope.err)
+	}
+
+	desc := "all clients"
+	if !scope.allow.Empty() {
+		desc = strings.Join(scope.allow.Specs(), ", ")
+	}
+	if !scope.deny.Empty() {
+		desc += " except " + strings.Join(scope.deny.Specs(), ", ")
+	}
+	return desc
+}
@@ -0,0 +1,104 @@
+package attacks
+
+import (
+	"fmt"
+	"time"
+)
+
+// timeBombState tracks the global trigger of the time bomb attack
+type timeBombState struct {
+	armedAt int64 // Request count when the bomb was armed
+	fired   bool
+	firedAt time.Time
+	reason  string
+	badCfg  string // Last invalid payload reported, to avoid log spam
+}
+
+// armTimeBomb resets the trigger so counting starts now. Caller must hold e.mu.
+func (e *AttackEngine) armTimeBomb() {
+	e.bomb = timeBombState{armedAt: e.totalRequests}
+}
+
+// evaluateTimeBomb checks the trigger and returns the payload attack once
+// it has fired, or AttackNone while still armed. Caller must hold e.mu.
+func (e *AttackEngine) evaluateTimeBomb(now time.Time) AttackType {
+	cfg := e.cfg.Security.TimeBomb
+	if !cfg.Enabled {
+		return AttackNone
+	}
+
+	payload := AttackType(cfg.Attack)
+	if payload == AttackNone || payload == AttackTimeBomb || !isKnownAttack(payload) {
+		if e.bomb.badCfg != cfg.Attack {
+			e.bomb.badCfg = cfg.Attack
+			e.log.Errorf("ATTACK", "Time bomb payload %q is not a usable attack, falling back to time_spoofing", cfg.Attack)
+		}
+		payload = AttackTimeSpoofing
+	}
+
+	if e.bomb.fired {
+		return payload
+	}
+
+	served := e.totalRequests - e.bomb.armedAt
+	switch {
+	case cfg.AfterRequests > 0 && served >= cfg.AfterRequests:
+		e.bomb.reason = fmt.Sprintf("%d requests served", served)
+	case cfg.At != "":
+		at, err := time.Parse(time.RFC3339, cfg.At)
+		if err != nil {
+			if e.bomb.badCfg != cfg.At {
+				e.bomb.badCfg = cfg.At
+				e.log.Errorf("ATTACK", "Time bomb trigger time %q is not RFC3339: %v", cfg.At, err)
+			}
+			return AttackNone
+		}
+		if now.Before(at) {
+			return AttackNone
+		}
+		e.bomb.reason = fmt.Sprintf("trigger time %s reached", at.Format(time.RFC3339))
+	default:
+		return AttackNone
+	}
+
+	e.bomb.fired = true
+	e.bomb.firedAt = now
+	e.enableAttackConfig(payload)
+
+	e.log.Errorf("ATTACK", "💣 TIME BOMB FIRED (%s): all clients now receive %s", e.bomb.reason, payload)
+	e.log.LogAttack(string(AttackTimeBomb), "all",
+		fmt.Sprintf("Trigger fired after %s, switching to %s", e.bomb.reason, payload))
+
+	return payload
+}
+
+// bombProgress returns a suffix describing the time bomb state for
+// DescribeActiveAttack. It only changes when the bomb fires so session
+// recordings get a single marker. Caller must hold e.mu.
+func (e *AttackEngine) bombProgress() string {
+	if AttackType(e.cfg.Security.ActiveAttack) != AttackTimeBomb {
+		return ""
+	}
+	if e.bomb.fired {
+		return " fired"
+	}
+	return " armed"
+}
+
+// isKnownAttack reports whether attack is one of the available attacks
+func isKnownAttack(attack AttackType) bool {
+	for _, a := range GetAvailableAttacks() {
+		if a.Type == attack {
+			return true
+		}
+	}
+	return false
+}
+
+// orNone returns s or "none" if s is empty
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
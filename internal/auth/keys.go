@@ -0,0 +1,160 @@
+// Package auth reads NTP symmetric keys in the ntpd and chrony keys file
+// format so lab key files can be used unchanged
+package auth
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// maxASCIIKey is the longest key ntpd reads as ASCII; longer keys are hex
+const maxASCIIKey = 20
+
+// Key is one symmetric key
+type Key struct {
+	ID     uint32
+	Type   string // ntpcore.MACAlgoMD5 or ntpcore.MACAlgoSHA1
+	Secret []byte
+}
+
+// Keys maps key IDs to keys
+type Keys map[uint32]Key
+
+// LoadKeys reads a keys file (see ParseKeys)
+func LoadKeys(path string) (Keys, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open keys file: %w", err)
+	}
+	defer f.Close()
+
+	keys, err := ParseKeys(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return keys, nil
+}
+
+// ParseKeys parses ntp.keys lines of the form
+//
+//	keyid type key   # e.g. "1 M secret" or "2 SHA1 0123...cdef"
+//
+// The type is M or MD5 for MD5 and SHA1 or SHA for SHA1; chrony's short
+// form "keyid key" means MD5. As in ntpd, keys of up to 20 characters are
+// ASCII and longer ones hex, unless they are not valid hex; chrony's ASCII:
+// and HEX: prefixes override this. Comments start with '#'.
+func ParseKeys(r io.Reader) (Keys, error) {
+	keys := make(Keys)
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) == 2 {
+			fields = []string{fields[0], ntpcore.MACAlgoMD5, fields[1]}
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: expected \"keyid type key\"", lineNo)
+		}
+
+		id, err := strconv.ParseUint(fields[0], 10, 32)
+		if err != nil || id == 0 {
+			return nil, fmt.Errorf("line %d: invalid key id %q", lineNo, fields[0])
+		}
+		if _, dup := keys[uint32(id)]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key id %d", lineNo, id)
+		}
+
+		keyType, err := parseKeyType(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		secret, err := parseSecret(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		keys[uint32(id)] = Key{ID: uint32(id), Type: keyType, Secret: secret}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read keys file: %w", err)
+	}
+	return keys, nil
+}
+
+// parseKeyType maps a keys file type to a MAC algorithm
+func parseKeyType(s string) (string, error) {
+	switch strings.ToUpper(s) {
+	case "M", ntpcore.MACAlgoMD5:
+		return ntpcore.MACAlgoMD5, nil
+	case "SHA", ntpcore.MACAlgoSHA1:
+		return ntpcore.MACAlgoSHA1, nil
+	default:
+		return "", fmt.Errorf("unsupported key type %q (M, MD5, SHA or SHA1)", s)
+	}
+}
+
+// parseSecret decodes a key as ASCII or hex
+func parseSecret(s string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(s, "ASCII:"):
+		s = strings.TrimPrefix(s, "ASCII:")
+	case strings.HasPrefix(s, "HEX:"):
+		secret, err := hex.DecodeString(strings.TrimPrefix(s, "HEX:"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex key: %w", err)
+		}
+		return secret, nil
+	case len(s) > maxASCIIKey:
+		if secret, err := hex.DecodeString(s); err == nil {
+			return secret, nil
+		}
+	}
+	if s == "" {
+		return nil, fmt.Errorf("empty key")
+	}
+	return []byte(s), nil
+}
+
+// Trusted returns the keys whose IDs are listed, as ntpd's trustedkey
+// does. No IDs trusts every key. Listing an ID missing from the keys is an
+// error, so a typo cannot silently disable a key.
+func (k Keys) Trusted(ids []uint32) (Keys, error) {
+	if len(ids) == 0 {
+		return k, nil
+	}
+	trusted := make(Keys, len(ids))
+	for _, id := range ids {
+		key, ok := k[id]
+		if !ok {
+			return nil, fmt.Errorf("trusted key %d is not in the keys file", id)
+		}
+		trusted[id] = key
+	}
+	return trusted, nil
+}
+
+// IDs returns the key IDs in ascending order
+func (k Keys) IDs() []uint32 {
+	ids := make([]uint32, 0, len(k))
+	for id := range k {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -0,0 +1,79 @@
+// Package clock abstracts the wall clock so time-dependent code can run
+// against a controlled time source
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the current time
+type Clock interface {
+	Now() time.Time
+}
+
+// Since returns the time elapsed on c since t
+func Since(c Clock, t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Real is the system clock
+type Real struct{}
+
+// Now returns time.Now()
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+// Mock is a clock that only moves when told to, for tests
+type Mock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewMock creates a mock clock reading t
+func NewMock(t time.Time) *Mock {
+	return &Mock{now: t}
+}
+
+// Now returns the mock time
+func (m *Mock) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now
+}
+
+// Set moves the mock clock to t
+func (m *Mock) Set(t time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = t
+}
+
+// Advance moves the mock clock forward by d
+func (m *Mock) Advance(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = m.now.Add(d)
+}
+
+// Frozen is a clock pinned to a base time. With advance it runs on from the
+// base at the speed of the system clock; otherwise it always reads the base.
+type Frozen struct {
+	base    time.Time
+	start   time.Time
+	advance bool
+}
+
+// NewFrozen creates a clock starting at base now
+func NewFrozen(base time.Time, advance bool) *Frozen {
+	return &Frozen{base: base, start: time.Now(), advance: advance}
+}
+
+// Now returns the base, plus the time since creation when advancing
+func (f *Frozen) Now() time.Time {
+	if !f.advance {
+		return f.base
+	}
+	return f.base.Add(time.Since(f.start))
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"sync"
 
 	"gopkg.in/yaml.v3"
@@ -17,11 +18,19 @@ const (
 	LogFileName    = "timehammer.log"
 	SessionDirName = "sessions"
 	ExportDirName  = "exports"
+	ProfileDirName = "profiles"
+
+	ClientHistoryFileName = "clients.json"
+
+	// SchemaVersion is bumped when config keys are renamed or removed
+	SchemaVersion = 1
 )
 
 // Config represents the main configuration structure
 type Config struct {
-	mu sync.RWMutex `yaml:"-"`
+	mu         sync.RWMutex        `yaml:"-"`
+	overrides  []string            // Keys set from the environment or --set (see Load)
+	overridden map[string]override // Overridden keys, put back to their file values on save
 
 	// Server settings
 	Server ServerConfig `yaml:"server"`
@@ -37,6 +46,31 @@ type Config struct {
 
 	// Attack presets
 	AttackPresets []AttackPreset `yaml:"attack_presets"`
+
+	// Multi-stage attack sequences
+	AttackSequences []AttackSequence `yaml:"attack_sequences"`
+
+	// Local control API
+	Control ControlConfig `yaml:"control"`
+
+	// Terminal UI settings
+	TUI TUIConfig `yaml:"tui"`
+}
+
+// TUIConfig holds settings for the terminal UI
+type TUIConfig struct {
+	// Color palette: "default", "high_contrast", "colorblind" (no red/green
+	// pairs) or "monochrome". Applied when the TUI starts.
+	Theme string `yaml:"theme"`
+}
+
+// ControlConfig holds settings for the local control API
+type ControlConfig struct {
+	// Enable the control API
+	Enabled bool `yaml:"enabled"`
+
+	// Listen address (keep on loopback unless you trust the network)
+	Address string `yaml:"address"`
 }
 
 // ServerConfig holds server-specific settings
@@ -47,27 +81,271 @@ type ServerConfig struct {
 	// Port to listen on (default: 123)
 	Port int `yaml:"port"`
 
+	// Additional ports to listen on at the same time (best effort)
+	Ports []int `yaml:"ports,omitempty"`
+
 	// Alternative port for unprivileged mode
 	AltPort int `yaml:"alt_port"`
 
 	// Use alternative port if standard port fails
 	UseAltPortOnFail bool `yaml:"use_alt_port_on_fail"`
 
+	// When listening on all interfaces, answer from the local address each
+	// request was sent to, as multi-homed hosts must for strict clients
+	// (false = the routing table picks the source address)
+	ReplyFromRequestAddress bool `yaml:"reply_from_request_address"`
+
 	// Maximum concurrent clients
 	MaxClients int `yaml:"max_clients"`
 
 	// NTP version to advertise
 	NTPVersion int `yaml:"ntp_version"`
 
-	// Stratum level to report
+	// Stratum level to report. 1 poses as a primary server synchronized to
+	// RefClock; other values follow the upstream (its stratum + 1)
 	Stratum int `yaml:"stratum"`
 
+	// Reference clock code advertised at stratum 1, by a stratum 1 server
+	// and by the stratum attack (1-4 characters, e.g. "GPS", "PPS", "DCFa")
+	RefClock string `yaml:"ref_clock"`
+
 	// Enable SNTP mode (simplified responses)
 	SNTPMode bool `yaml:"sntp_mode"`
 
 	// Timezone for NTP responses (IANA timezone name, e.g. "America/New_York", "Asia/Kolkata")
 	// Default: "UTC". When set, NTP timestamps will include the UTC offset for this timezone.
 	Timezone string `yaml:"timezone"`
+
+	// Response signing (marks test traffic for later identification)
+	Signing SigningConfig `yaml:"signing"`
+
+	// Response rate ceiling (anti-amplification safety cap)
+	ResponseCap ResponseCapConfig `yaml:"response_cap"`
+
+	// Mode 6/7 (control/private) amplification measurement
+	AmplificationTest AmplificationTestConfig `yaml:"amplification_test"`
+
+	// Per-client request rate limit (emulates a rate-limited server)
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+
+	// Symmetric key authentication (RFC 5905 Appendix A)
+	Auth AuthConfig `yaml:"auth"`
+
+	// Consecutive send failures before a client is dropped and backed off (0 = never)
+	WriteFailureThreshold int `yaml:"write_failure_threshold"`
+
+	// Seconds to stop answering a client after it hit the failure threshold
+	WriteFailureBackoff int `yaml:"write_failure_backoff"`
+
+	// Seconds Stop waits for requests in flight (e.g. held by the delay
+	// attack or jitter) to be answered before closing the sockets
+	DrainTimeout int `yaml:"drain_timeout"`
+
+	// Fixed random offset chosen at start (simulates a miscalibrated source)
+	BaselineOffset BaselineOffsetConfig `yaml:"baseline_offset"`
+
+	// Unsolicited mode 5 broadcast/multicast transmission
+	Broadcast BroadcastConfig `yaml:"broadcast"`
+
+	// Fraction of responses silently dropped (0.0-1.0) to simulate packet loss
+	DropRate float64 `yaml:"drop_rate"`
+
+	// Per-client drop rates or patterns; the first matching rule replaces drop_rate
+	DropClients []DropRule `yaml:"drop_clients"`
+
+	// Seed for drop decisions (0 = time-based, logged); fixes which requests are dropped
+	DropSeed int64 `yaml:"drop_seed"`
+
+	// Random per-response send delay to emulate path jitter
+	Jitter JitterConfig `yaml:"jitter"`
+
+	// Serve time from a fixed base instead of upstream, for reproducible output
+	FrozenTime FrozenTimeConfig `yaml:"frozen_time"`
+
+	// How requests carrying NTS extension fields are answered
+	NTS NTSConfig `yaml:"nts"`
+
+	// Advertised precision as log2 seconds (-20 = ~1 microsecond)
+	Precision int `yaml:"precision"`
+
+	// Poll interval written into responses
+	PollPolicy PollPolicyConfig `yaml:"poll_policy"`
+
+	// Interleaved mode answers: off, on, inconsistent
+	InterleavedMode string `yaml:"interleaved_mode"`
+
+	// Answer symmetric active (mode 1) packets in symmetric passive mode (2)
+	SymmetricPassive bool `yaml:"symmetric_passive"`
+
+	// Seconds between the advertised reference time (when the server claims
+	// it last synced) and the transmit time; large values look stale
+	RefTimeAge int `yaml:"ref_time_age"`
+
+	// Seconds a request's transmit time may differ from true time before the
+	// request is rejected as replayed or from a broken clock (0 = off)
+	MaxClientSkew int `yaml:"max_client_skew"`
+
+	// Relay requests to a real upstream instead of answering from our clock
+	Proxy ProxyConfig `yaml:"proxy"`
+
+	// NTP versions answered; requests of other versions are dropped
+	// (empty = all)
+	AcceptedVersions []int `yaml:"accepted_versions"`
+
+	// Version field of responses
+	ResponseVersion ResponseVersionPolicy `yaml:"response_version"`
+
+	// Non-standard habits of real servers, applied to every response
+	// before any attack
+	Quirks QuirksConfig `yaml:"quirks"`
+}
+
+// QuirksConfig reproduces the idiosyncrasies of specific real servers, for
+// checking a device against the server it is known to work with
+type QuirksConfig struct {
+	// Leave the reference timestamp zero, as some SNTP servers do
+	OmitReferenceTime bool `yaml:"omit_reference_time"`
+
+	// Send this poll value in every response, overriding poll_policy
+	// (unset = off; some servers always send 0)
+	ForcePoll *int `yaml:"force_poll,omitempty"`
+
+	// Advertise precision 0 (one second), overriding precision
+	ZeroPrecision bool `yaml:"zero_precision"`
+}
+
+// ResponseVersionPolicy controls the version field of responses:
+//   - "echo":  copy the request's version (default)
+//   - "fixed": always send Version
+//   - "cap":   copy the request's version, but never above Version
+type ResponseVersionPolicy struct {
+	Mode    string `yaml:"mode"`
+	Version int    `yaml:"version"`
+}
+
+// PollPolicyConfig controls the poll field of responses (log2 seconds):
+//   - "echo":  copy the client's poll (default)
+//   - "fixed": always send Value
+//   - "clamp": copy the client's poll, limited to Min..Max
+type PollPolicyConfig struct {
+	Mode  string `yaml:"mode"`
+	Value int    `yaml:"value"`
+	Min   int    `yaml:"min"`
+	Max   int    `yaml:"max"`
+}
+
+// NTSConfig controls answers to NTS (RFC 8915) requests. TimeHammer holds
+// no NTS keys, so it can only show how a client degrades:
+//   - "ignore":  plain unauthenticated response, NTS fields dropped
+//   - "reject":  NTS NAK (kiss code NTSN) echoing the Unique Identifier
+//   - "malform": echoed Unique Identifier with a garbage cookie and an
+//     authenticator whose declared lengths overrun the field
+type NTSConfig struct {
+	Mode string `yaml:"mode"`
+}
+
+// DropRule overrides the drop rate for some clients. Pattern, if set, is a
+// repeating sequence where '.' answers and 'x' drops (e.g. "..x" loses every
+// third response) and takes precedence over Rate.
+type DropRule struct {
+	Clients []string `yaml:"clients"` // IPs or CIDRs
+	Rate    float64  `yaml:"rate"`
+	Pattern string   `yaml:"pattern"`
+}
+
+// JitterConfig holds each response for a random time before it is sent, on
+// top of any delay attack. Holds are centred on Ms and vary by ±Ms, so they
+// range from 0 to 2×Ms:
+//   - "uniform": evenly spread over the range
+//   - "normal":  bell curve with a standard deviation of Ms/2, clamped to the range
+type JitterConfig struct {
+	Enabled      bool    `yaml:"enabled"`
+	Ms           float64 `yaml:"ms"`
+	Distribution string  `yaml:"distribution"`
+	Seed         int64   `yaml:"seed"` // RNG seed for reproducible holds (0 = time-based, logged)
+}
+
+// FrozenTimeConfig replaces the upstream time with a fixed base. Without
+// Advance every response carries the same time, so attack output is exactly
+// reproducible; attack progress (drift, schedules) still follows real time.
+type FrozenTimeConfig struct {
+	Base    string `yaml:"base"`    // RFC3339 time, e.g. 2030-01-01T00:00:00Z ("" = off)
+	Advance bool   `yaml:"advance"` // Let the time run on from the base
+}
+
+// ProxyConfig turns the server into an on-path relay: each request is
+// forwarded to a real upstream and its answer passed back through the
+// active attack, emulating a MITM instead of synthesizing responses
+type ProxyConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// host:port to relay to (empty = the active upstream server)
+	Upstream string `yaml:"upstream"`
+}
+
+// BroadcastConfig periodically sends mode 5 packets for clients that listen
+// for broadcast or multicast time instead of querying. Packets carry the
+// served time and go through the active attack like responses do.
+type BroadcastConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	Address      string `yaml:"address"`       // Destination, e.g. 255.255.255.255:123 or 224.0.1.1:123 (port defaults to 123)
+	IntervalSecs int    `yaml:"interval_secs"` // Seconds between packets
+}
+
+// BaselineOffsetConfig serves all time shifted by a constant picked once per
+// server start. It is a control condition, not an attack: attacks are
+// applied on top of the shifted time.
+type BaselineOffsetConfig struct {
+	Enabled bool  `yaml:"enabled"`
+	MaxSecs int64 `yaml:"max_secs"` // Offset is drawn uniformly from [-max_secs, +max_secs]
+	Seed    int64 `yaml:"seed"`     // RNG seed for a reproducible offset (0 = random, logged)
+}
+
+// AuthConfig controls symmetric key MACs on responses. When a keys file is
+// set, requests carrying a key ID are answered with a MAC, or with a
+// crypto-NAK if the key is unknown or the request's MAC does not verify.
+type AuthConfig struct {
+	KeysFile    string   `yaml:"keys_file"`    // ntpd or chrony keys file ("keyid type key" lines)
+	TrustedKeys []uint32 `yaml:"trusted_keys"` // Key IDs used from the file (empty = all)
+	ForgeMAC    bool     `yaml:"forge_mac"`    // Corrupt the digest to test that clients reject forged MACs
+}
+
+// ResponseCapConfig limits how fast the server answers so it cannot be abused
+// as an amplifier. Responses beyond the cap are silently dropped.
+// This is distinct from the Kiss-of-Death RATE attack.
+type ResponseCapConfig struct {
+	Enabled         bool `yaml:"enabled"`
+	GlobalPerSec    int  `yaml:"global_per_sec"`     // Max responses per second overall (0 = unlimited)
+	PerSourcePerSec int  `yaml:"per_source_per_sec"` // Max responses per second per source IP (0 = unlimited)
+}
+
+// AmplificationTestConfig handles mode 6 (control) and mode 7 (private)
+// queries such as monlist, logging the response/request size ratio. With
+// Respond set, a synthetic reply is sent to show what a misconfigured
+// reflector would emit; replies still count against the response cap.
+type AmplificationTestConfig struct {
+	Enabled         bool `yaml:"enabled"`
+	Respond         bool `yaml:"respond"`          // Send a synthetic reply
+	ResponseSize    int  `yaml:"response_size"`    // Bytes per reply packet (max 1472)
+	ResponsePackets int  `yaml:"response_packets"` // Reply packets per query
+}
+
+// RateLimitConfig throttles requests per client IP with a token bucket.
+// Throttled requests are dropped before any processing, or answered with a
+// Kiss-of-Death RATE packet when SendKoD is set.
+type RateLimitConfig struct {
+	Enabled bool    `yaml:"enabled"`
+	PerSec  float64 `yaml:"per_sec"`  // Sustained requests per second per client IP
+	Burst   int     `yaml:"burst"`    // Requests allowed back to back
+	SendKoD bool    `yaml:"send_kod"` // Answer throttled requests with KoD RATE
+}
+
+// SigningConfig controls HMAC tagging of responses
+// Signed responses carry an extra extension field, which changes the wire
+// format and may cause strict clients to reject them.
+type SigningConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Key     string `yaml:"key"` // Hex-encoded run key (empty = random per run)
 }
 
 // UpstreamConfig holds upstream NTP server settings
@@ -83,6 +361,25 @@ type UpstreamConfig struct {
 
 	// Number of retry attempts
 	Retries int `yaml:"retries"`
+
+	// Pin a single server as the time source (empty = use all enabled servers).
+	// When set, no fallback to other servers happens if it is unreachable.
+	PinnedServer string `yaml:"pinned_server"`
+
+	// Time served while unsynchronized: "host" (local clock), "manual"
+	// (fallback_time, running from when sync was lost), "last_good" (last
+	// upstream offset on the monotonic clock) or "refuse" (leap alarm, stratum 16)
+	FallbackMode string `yaml:"fallback_mode"`
+
+	// Base time for the manual fallback (RFC3339)
+	FallbackTime string `yaml:"fallback_time"`
+
+	// Maximum addresses used from each pool server
+	PoolSize int `yaml:"pool_size"`
+
+	// Number of recent syncs the offset stability (stddev, jitter, range)
+	// is computed over
+	StabilityWindow int `yaml:"stability_window"`
 }
 
 // UpstreamServer represents a single upstream NTP server
@@ -98,6 +395,25 @@ type UpstreamServer struct {
 
 	// Enabled status
 	Enabled bool `yaml:"enabled"`
+
+	// Pool hostname: every resolved address is queried as its own server
+	Pool bool `yaml:"pool,omitempty"`
+
+	// Secure transport instead of plain NTP: "https" reads the Date header
+	// of an HTTPS server (port 443 by default). Plain NTP servers that
+	// contradict a secure one are discarded.
+	Secure string `yaml:"secure,omitempty"`
+}
+
+// SecureHTTPS queries an upstream's HTTPS Date header instead of NTP
+const SecureHTTPS = "https"
+
+// DefaultPort returns the port used when an upstream server sets none
+func (s UpstreamServer) DefaultPort() int {
+	if s.Secure == SecureHTTPS {
+		return 443
+	}
+	return 123
 }
 
 // SecurityConfig holds security testing mode settings
@@ -108,6 +424,13 @@ type SecurityConfig struct {
 	// Active attack type
 	ActiveAttack string `yaml:"active_attack"`
 
+	// Suspend offset-based attacks (spoofing, drift, clock step) while the
+	// upstream is unsynchronized, since their baseline would be the host clock
+	RequireUpstreamSync bool `yaml:"require_upstream_sync"`
+
+	// Clients the active attack applies to (others get honest responses)
+	Targets TargetFilter `yaml:"targets"`
+
 	// Time spoofing settings
 	TimeSpoofing TimeSpoofingConfig `yaml:"time_spoofing"`
 
@@ -120,9 +443,21 @@ type SecurityConfig struct {
 	// Stratum attack settings
 	StratumAttack StratumAttackConfig `yaml:"stratum_attack"`
 
+	// Reference ID spoofing settings
+	RefID RefIDAttackConfig `yaml:"refid_spoof"`
+
+	// Root delay/dispersion override settings
+	RootDistance RootDistanceAttackConfig `yaml:"root_distance"`
+
+	// Origin timestamp corruption settings
+	Origin OriginAttackConfig `yaml:"origin_attack"`
+
 	// Leap second settings
 	LeapSecond LeapSecondConfig `yaml:"leap_second"`
 
+	// Leap second smear settings
+	LeapSmear LeapSmearConfig `yaml:"leap_smear"`
+
 	// Rollover attack settings
 	Rollover RolloverConfig `yaml:"rollover"`
 
@@ -131,19 +466,175 @@ type SecurityConfig struct {
 
 	// Fuzzing settings
 	Fuzzing FuzzingConfig `yaml:"fuzzing"`
+
+	// Crypto-NAK settings
+	CryptoNAK CryptoNAKConfig `yaml:"crypto_nak"`
+
+	// Asymmetric delay settings
+	Delay DelayAttackConfig `yaml:"delay"`
+
+	// Time bomb (triggered attack) settings
+	TimeBomb TimeBombConfig `yaml:"time_bomb"`
+
+	// Per-client attack mix settings
+	ClientMix ClientMixConfig `yaml:"client_mix"`
+
+	// Parameter sweep for the active attack
+	Sweep SweepConfig `yaml:"sweep"`
+}
+
+// TargetFilter selects attacked clients by IP or CIDR. An empty allow list
+// means all clients; deny entries win over allow entries.
+type TargetFilter struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
+
+// CryptoNAKConfig for crypto-NAK (authentication failure) responses
+type CryptoNAKConfig struct {
+	Enabled  bool `yaml:"enabled"`
+	Interval int  `yaml:"interval"` // Send crypto-NAK every N requests (0 = always)
+
+	Schedule   AttackSchedule   `yaml:"schedule,omitempty"`
+	Conditions AttackConditions `yaml:"conditions,omitempty"`
+}
+
+// RefIDAttackConfig spoofs the reference identifier. RefID is either a
+// refclock code of up to 4 characters (GPS, PPS, DCFa...) or an IPv4/IPv6
+// address claimed as the upstream server.
+type RefIDAttackConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	RefID   string `yaml:"ref_id"`  // Refclock code or upstream IP
+	Stratum int    `yaml:"stratum"` // Stratum to claim (0 = 1 for codes, 2 for addresses)
+
+	Schedule   AttackSchedule   `yaml:"schedule,omitempty"`
+	Conditions AttackConditions `yaml:"conditions,omitempty"`
+}
+
+// RootDistanceAttackConfig overrides the claimed root delay and dispersion.
+// With Overlay set it also applies on top of whichever attack is active.
+type RootDistanceAttackConfig struct {
+	Enabled     bool    `yaml:"enabled"`
+	RootDelayMs float64 `yaml:"root_delay_ms"` // Claimed root delay (negative = leave unchanged)
+	RootDispMs  float64 `yaml:"root_disp_ms"`  // Claimed root dispersion (negative = leave unchanged)
+	Overlay     bool    `yaml:"overlay"`       // Apply on top of the active attack
+
+	Schedule   AttackSchedule   `yaml:"schedule,omitempty"`
+	Conditions AttackConditions `yaml:"conditions,omitempty"`
+}
+
+// OriginAttackConfig corrupts the origin timestamp that normally echoes the
+// client's transmit timestamp, to test how clients match responses
+type OriginAttackConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Mode     string `yaml:"mode"`     // zero, random, off_by_one
+	Interval int    `yaml:"interval"` // Corrupt every N requests (0 = always)
+
+	Schedule   AttackSchedule   `yaml:"schedule,omitempty"`
+	Conditions AttackConditions `yaml:"conditions,omitempty"`
+}
+
+// DelayAttackConfig simulates asymmetric path delay. Inbound delay shifts the
+// receive/transmit timestamps as if the request arrived late; the response is
+// then held for inbound + outbound delay, so the client sees the extra RTT
+// and an offset error of (inbound - outbound) / 2.
+type DelayAttackConfig struct {
+	Enabled         bool `yaml:"enabled"`
+	InboundDelayMs  int  `yaml:"inbound_delay_ms"`  // Simulated client->server delay
+	OutboundDelayMs int  `yaml:"outbound_delay_ms"` // Simulated server->client delay
+
+	Schedule   AttackSchedule   `yaml:"schedule,omitempty"`
+	Conditions AttackConditions `yaml:"conditions,omitempty"`
+}
+
+// TimeBombConfig for a latent attack that is honest until a global trigger
+// fires. Either trigger may be used; whichever is reached first fires.
+type TimeBombConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	AfterRequests int64  `yaml:"after_requests"` // Fire after N total requests (0 = unused)
+	At            string `yaml:"at"`             // Fire at this RFC3339 instant ("" = unused)
+	Attack        string `yaml:"attack"`         // Attack to switch all clients to once fired
+
+	Schedule   AttackSchedule   `yaml:"schedule,omitempty"`
+	Conditions AttackConditions `yaml:"conditions,omitempty"`
+}
+
+// ClientMixConfig gives each client one attack from a pool, picked on its
+// first request and kept for the rest of the run. The pick hashes the seed
+// with the client IP, so the same seed assigns the same attacks every run.
+type ClientMixConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Attacks []string `yaml:"attacks"` // Pool to draw from
+	Seed    int64    `yaml:"seed"`    // Assignment seed (0 = time-based, logged for replay)
+
+	Schedule   AttackSchedule   `yaml:"schedule,omitempty"`
+	Conditions AttackConditions `yaml:"conditions,omitempty"`
+}
+
+// SweepConfig steps one parameter of the active attack across a range,
+// holding each setpoint for Dwell seconds (useful for threshold finding)
+type SweepConfig struct {
+	Enabled bool    `yaml:"enabled"`
+	Param   string  `yaml:"param"` // e.g. "step_secs", "offset_secs", "drift_per_sec"
+	Start   float64 `yaml:"start"`
+	End     float64 `yaml:"end"`
+	Steps   int     `yaml:"steps"` // Number of setpoints including start and end
+	Dwell   int     `yaml:"dwell"` // Seconds to hold each setpoint
 }
 
 // FuzzingConfig for client fuzzing
 type FuzzingConfig struct {
 	Enabled bool   `yaml:"enabled"`
-	Mode    string `yaml:"mode"` // "random", "deterministic"
+	Mode    string `yaml:"mode"` // "all", "deterministic" or classes: "header", "timestamps", "logic" (comma-separated)
+	Seed    int64  `yaml:"seed"` // Random seed (0 = time-based, logged so the run can be replayed)
+
+	Schedule   AttackSchedule   `yaml:"schedule,omitempty"`
+	Conditions AttackConditions `yaml:"conditions,omitempty"`
+}
+
+// AttackSchedule activates an attack for a period instead of leaving it on.
+// A schedule is in use when any of StartAt, StartAfter or Window is set.
+// When several scheduled attacks are due, the lowest Priority wins.
+type AttackSchedule struct {
+	StartAt    string `yaml:"start_at,omitempty"`    // RFC3339 wall-clock start
+	StartAfter int    `yaml:"start_after,omitempty"` // Seconds after server start
+	Duration   int    `yaml:"duration,omitempty"`    // Seconds to stay active (0 = no end)
+	Window     string `yaml:"window,omitempty"`      // Daily local window "HH:MM-HH:MM"
+	Priority   int    `yaml:"priority,omitempty"`    // Lower wins on overlap
+}
+
+// IsSet reports whether the schedule is in use
+func (s AttackSchedule) IsSet() bool {
+	return s.StartAt != "" || s.StartAfter > 0 || s.Window != ""
+}
+
+// AttackConditions restricts an attack to requests matching every set
+// field; other requests get the honest response. An empty block matches
+// every request. Broadcasts answer no request, so any condition skips them.
+type AttackConditions struct {
+	MinVersion int      `yaml:"min_version,omitempty"` // Lowest request version attacked (0 = any)
+	MaxVersion int      `yaml:"max_version,omitempty"` // Highest request version attacked (0 = any)
+	Modes      []int    `yaml:"modes,omitempty"`       // Request modes attacked (e.g. [3])
+	MinPoll    *int     `yaml:"min_poll,omitempty"`    // Lowest request poll exponent attacked
+	MaxPoll    *int     `yaml:"max_poll,omitempty"`    // Highest request poll exponent attacked
+	Clients    []string `yaml:"clients,omitempty"`     // Identified implementations, e.g. ["W32Time"] (substring, any case)
+}
+
+// IsSet reports whether any condition is configured
+func (c AttackConditions) IsSet() bool {
+	return c.MinVersion != 0 || c.MaxVersion != 0 || len(c.Modes) > 0 ||
+		c.MinPoll != nil || c.MaxPoll != nil || len(c.Clients) > 0
 }
 
 // TimeSpoofingConfig for time spoofing attack
 type TimeSpoofingConfig struct {
-	Enabled    bool   `yaml:"enabled"`
-	OffsetSecs int64  `yaml:"offset_secs"` // Positive = future, Negative = past
-	CustomTime string `yaml:"custom_time"` // RFC3339 format, overrides offset
+	Enabled    bool       `yaml:"enabled"`
+	OffsetSecs int64      `yaml:"offset_secs"` // Positive = future, Negative = past
+	CustomTime string     `yaml:"custom_time"` // RFC3339 format, overrides offset
+	Ramp       RampConfig `yaml:"ramp"`        // Growing offset, overrides both
+
+	Schedule   AttackSchedule   `yaml:"schedule,omitempty"`
+	Conditions AttackConditions `yaml:"conditions,omitempty"`
 }
 
 // TimeDriftConfig for gradual time drift attack
@@ -152,6 +643,13 @@ type TimeDriftConfig struct {
 	DriftPerSec float64 `yaml:"drift_per_sec"` // Seconds to drift per second
 	MaxDrift    float64 `yaml:"max_drift"`     // Maximum total drift in seconds
 	Direction   string  `yaml:"direction"`     // "forward" or "backward"
+	Waveform    string  `yaml:"waveform"`      // linear, sine, sawtooth, random_walk
+	Amplitude   float64 `yaml:"amplitude"`     // Peak drift in seconds (sine, sawtooth, random_walk)
+	Period      float64 `yaml:"period"`        // Seconds per cycle (sine, sawtooth)
+	Seed        int64   `yaml:"seed"`          // Random walk seed (0 = time-based, logged so the run can be replayed)
+
+	Schedule   AttackSchedule   `yaml:"schedule,omitempty"`
+	Conditions AttackConditions `yaml:"conditions,omitempty"`
 }
 
 // KissOfDeathConfig for KoD attack
@@ -159,18 +657,58 @@ type KissOfDeathConfig struct {
 	Enabled  bool   `yaml:"enabled"`
 	Code     string `yaml:"code"`     // DENY, RATE, RSTR, etc.
 	Interval int    `yaml:"interval"` // Send KoD every N requests (0 = always)
+
+	// Send each client a single RATE KoD (on its Nth request with interval N)
+	// and answer honestly afterwards, measuring whether it backs off
+	ComplianceTest bool `yaml:"compliance_test"`
+
+	// Kiss codes to rotate through instead of sending only Code
+	Codes []string `yaml:"codes,omitempty"`
+	// Advance the rotation on every KoD sent ("request") or separately for
+	// each client ("client")
+	RotatePer string `yaml:"rotate_per"`
+	// Allow codes outside RFC 5905 in Code and Codes; "random" in Codes
+	// sends four random bytes
+	IncludeInvalid bool `yaml:"include_invalid"`
+
+	Schedule   AttackSchedule   `yaml:"schedule,omitempty"`
+	Conditions AttackConditions `yaml:"conditions,omitempty"`
 }
 
 // StratumAttackConfig for stratum manipulation
 type StratumAttackConfig struct {
 	Enabled     bool `yaml:"enabled"`
 	FakeStratum int  `yaml:"fake_stratum"` // 0-15, lower = more authoritative
+
+	// Advertise the real stratum (upstream + 1) instead of FakeStratum,
+	// varied by up to ±Jitter per response, to blend in while probing
+	TrackUpstream bool  `yaml:"track_upstream"`
+	Jitter        int   `yaml:"jitter"`
+	Seed          int64 `yaml:"seed"` // Jitter seed (0 = time-based, logged so the run can be replayed)
+
+	Schedule   AttackSchedule   `yaml:"schedule,omitempty"`
+	Conditions AttackConditions `yaml:"conditions,omitempty"`
 }
 
 // LeapSecondConfig for leap second injection
 type LeapSecondConfig struct {
 	Enabled       bool `yaml:"enabled"`
 	LeapIndicator int  `yaml:"leap_indicator"` // 1 = +1 sec, 2 = -1 sec, 3 = alarm
+
+	Schedule   AttackSchedule   `yaml:"schedule,omitempty"`
+	Conditions AttackConditions `yaml:"conditions,omitempty"`
+}
+
+// LeapSmearConfig spreads a leap second over a window with the leap
+// indicator clear, as smearing servers do, instead of flagging it
+type LeapSmearConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	WindowSecs int    `yaml:"window_secs"` // Smear duration (86400 = the common 24h smear)
+	Direction  string `yaml:"direction"`   // "positive" (inserted second, ends 1s behind) or "negative" (ends 1s ahead)
+	Start      string `yaml:"start"`       // RFC3339 smear start ("" = when enabled)
+
+	Schedule   AttackSchedule   `yaml:"schedule,omitempty"`
+	Conditions AttackConditions `yaml:"conditions,omitempty"`
 }
 
 // RolloverConfig for timestamp rollover attack
@@ -178,13 +716,34 @@ type RolloverConfig struct {
 	Enabled    bool   `yaml:"enabled"`
 	TargetYear int    `yaml:"target_year"` // e.g., 2038, 2036 (NTP rollover)
 	Mode       string `yaml:"mode"`        // "y2k38", "ntp_era", "custom"
+
+	Schedule   AttackSchedule   `yaml:"schedule,omitempty"`
+	Conditions AttackConditions `yaml:"conditions,omitempty"`
+}
+
+// RampConfig grows the offset of an attack, per client, from StartSecs to
+// EndSecs over DurationSecs seconds or Requests attacked requests, then
+// holds it. Comparing each client's clock with the offset it was last
+// sent finds the offset at which it stops following.
+type RampConfig struct {
+	Enabled       bool    `yaml:"enabled"`
+	StartSecs     float64 `yaml:"start_secs"`
+	EndSecs       float64 `yaml:"end_secs"`
+	DurationSecs  float64 `yaml:"duration_secs"`  // Seconds from a client's first ramped response to end_secs
+	Requests      int     `yaml:"requests"`       // Or: ramped responses to reach end_secs
+	Scale         string  `yaml:"scale"`          // linear or exponential (equal steps in log scale)
+	ToleranceSecs float64 `yaml:"tolerance_secs"` // How close a client's clock must be to count as following
 }
 
 // ClockStepConfig for sudden clock step attack
 type ClockStepConfig struct {
-	Enabled  bool  `yaml:"enabled"`
-	StepSecs int64 `yaml:"step_secs"` // Sudden jump in seconds
-	Interval int   `yaml:"interval"`  // Apply step every N requests
+	Enabled  bool       `yaml:"enabled"`
+	StepSecs int64      `yaml:"step_secs"` // Sudden jump in seconds
+	Interval int        `yaml:"interval"`  // Apply step every N requests
+	Ramp     RampConfig `yaml:"ramp"`      // Growing step, overrides step_secs
+
+	Schedule   AttackSchedule   `yaml:"schedule,omitempty"`
+	Conditions AttackConditions `yaml:"conditions,omitempty"`
 }
 
 // LoggingConfig holds logging settings
@@ -207,8 +766,77 @@ type LoggingConfig struct {
 	// Session recording
 	RecordSessions bool `yaml:"record_sessions"`
 
+	// Only record these client IPs/CIDRs (empty = all clients)
+	RecordClients []string `yaml:"record_clients"`
+
+	// Start a recording when an attack becomes active and save it once no
+	// attack is, tagged with the attacks used
+	AutoRecordAttacks bool `yaml:"auto_record_attacks"`
+
 	// Maximum log entries to keep in memory
 	MaxLogEntries int `yaml:"max_log_entries"`
+
+	// Keep per-client history (first/last seen, requests, versions, offsets,
+	// attacks) in clients.json across restarts
+	ClientHistory bool `yaml:"client_history"`
+
+	// Order of the active client list: "recent" (last seen first) or
+	// "address"
+	ClientOrder string `yaml:"client_order"`
+
+	// Extra client fingerprint signatures (YAML, relative to the data dir)
+	FingerprintDB string `yaml:"fingerprint_db"`
+
+	// Rotate the log file once it reaches this size in MB (0 = never)
+	MaxSizeMB int `yaml:"max_size_mb"`
+
+	// Rotated log files to keep (0 = keep all)
+	MaxBackups int `yaml:"max_backups"`
+
+	// Delete rotated log files older than this many days (0 = never)
+	MaxAgeDays int `yaml:"max_age_days"`
+
+	// Gzip rotated log files
+	CompressBackups bool `yaml:"compress_backups"`
+
+	// Remote destinations that receive every log entry
+	Sinks []LogSink `yaml:"sinks"`
+
+	// Alerts when a client changes how it behaves
+	BehaviorAlerts BehaviorAlertConfig `yaml:"behavior_alerts"`
+}
+
+// BehaviorAlertConfig raises an alert (a BEHAVIOR log entry, and optionally
+// a webhook POST) when a client goes quiet after a RATE KoD, its clock
+// offset jumps or it switches NTP version
+type BehaviorAlertConfig struct {
+	// Enable behavior alerts
+	Enabled bool `yaml:"enabled"`
+
+	// Smallest change in a client's estimated clock offset between two
+	// requests that counts as a jump, in seconds (0 = no offset alerts)
+	OffsetJump float64 `yaml:"offset_jump"`
+
+	// POST each alert as JSON to this URL (empty = log only)
+	Webhook string `yaml:"webhook"`
+}
+
+// LogSink is a remote log destination
+type LogSink struct {
+	// Sink type: "syslog" (RFC 5424) or "webhook" (batched JSON POST)
+	Type string `yaml:"type"`
+
+	// host:port for syslog, URL for webhook
+	Target string `yaml:"target"`
+
+	// Syslog transport: "udp" (default) or "tcp"
+	Network string `yaml:"network,omitempty"`
+
+	// Minimum level sent (empty = everything the logger keeps)
+	Level string `yaml:"level,omitempty"`
+
+	// Entries per webhook POST (0 = 50)
+	BatchSize int `yaml:"batch_size,omitempty"`
 }
 
 // AttackPreset represents a pre-configured attack scenario
@@ -219,29 +847,120 @@ type AttackPreset struct {
 	Config      map[string]interface{} `yaml:"config"`
 }
 
+// AttackSequence runs several attacks one after another
+type AttackSequence struct {
+	Name        string         `yaml:"name"`
+	Description string         `yaml:"description"`
+	Loop        bool           `yaml:"loop"` // Start over after the last step
+	Steps       []SequenceStep `yaml:"steps"`
+}
+
+// SequenceStep is one stage of an attack sequence. Config overrides use the
+// same keys as attack presets.
+type SequenceStep struct {
+	Attack   string                 `yaml:"attack"`
+	Config   map[string]interface{} `yaml:"config"`
+	Duration int                    `yaml:"duration"` // Seconds
+}
+
+// GetAttackSequence returns the sequence with the given name (case-insensitive)
+func (c *Config) GetAttackSequence(name string) (AttackSequence, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, seq := range c.AttackSequences {
+		if strings.EqualFold(seq.Name, name) {
+			return seq, true
+		}
+	}
+	return AttackSequence{}, false
+}
+
 // DefaultConfig returns a new Config with sensible defaults
 func DefaultConfig() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Interface:        "",
-			Port:             123,
-			AltPort:          1123,
-			UseAltPortOnFail: true,
-			MaxClients:       100,
-			NTPVersion:       4,
-			Stratum:          2,
-			SNTPMode:         false,
-			Timezone:         "UTC",
+			Interface:               "",
+			Port:                    123,
+			AltPort:                 1123,
+			UseAltPortOnFail:        true,
+			ReplyFromRequestAddress: true,
+			MaxClients:              100,
+			NTPVersion:              4,
+			Stratum:                 2,
+			RefClock:                "GPS",
+			SNTPMode:                false,
+			Timezone:                "UTC",
+			Precision:               -20,
+			PollPolicy: PollPolicyConfig{
+				Mode:  "echo",
+				Value: 6,
+				Min:   4,
+				Max:   10,
+			},
+			InterleavedMode: "off",
+			RefTimeAge:      1,
+			ResponseVersion: ResponseVersionPolicy{
+				Mode:    "echo",
+				Version: 3,
+			},
+			Signing: SigningConfig{
+				Enabled: false,
+				Key:     "",
+			},
+			ResponseCap: ResponseCapConfig{
+				Enabled:         true,
+				GlobalPerSec:    500,
+				PerSourcePerSec: 20,
+			},
+			AmplificationTest: AmplificationTestConfig{
+				Enabled:         false,
+				Respond:         false,
+				ResponseSize:    440,
+				ResponsePackets: 1,
+			},
+			RateLimit: RateLimitConfig{
+				Enabled: false,
+				PerSec:  1,
+				Burst:   8,
+				SendKoD: false,
+			},
+			WriteFailureThreshold: 5,
+			WriteFailureBackoff:   60,
+			DrainTimeout:          5,
+			BaselineOffset: BaselineOffsetConfig{
+				Enabled: false,
+				MaxSecs: 300,
+				Seed:    0,
+			},
+			Broadcast: BroadcastConfig{
+				Enabled:      false,
+				Address:      "255.255.255.255:123",
+				IntervalSecs: 64,
+			},
+			DropRate: 0,
+			DropSeed: 0,
+			Jitter: JitterConfig{
+				Enabled:      false,
+				Ms:           5,
+				Distribution: "uniform",
+			},
+			NTS: NTSConfig{
+				Mode: "ignore",
+			},
 		},
 		Upstream: UpstreamConfig{
 			Servers: []UpstreamServer{
 				{Address: "time.google.com", Port: 123, Priority: 1, Enabled: true},
 				{Address: "time.cloudflare.com", Port: 123, Priority: 2, Enabled: true},
-				{Address: "pool.ntp.org", Port: 123, Priority: 3, Enabled: true},
+				{Address: "pool.ntp.org", Port: 123, Priority: 3, Enabled: true, Pool: true},
 			},
-			SyncInterval: 60,
-			Timeout:      5,
-			Retries:      3,
+			SyncInterval:    60,
+			Timeout:         5,
+			Retries:         3,
+			FallbackMode:    "host",
+			PoolSize:        4,
+			StabilityWindow: 20,
 		},
 		Security: SecurityConfig{
 			Enabled:      false,
@@ -249,26 +968,53 @@ func DefaultConfig() *Config {
 			TimeSpoofing: TimeSpoofingConfig{
 				Enabled:    false,
 				OffsetSecs: 3600, // 1 hour
+				Ramp:       defaultRamp(),
 			},
 			TimeDrift: TimeDriftConfig{
 				Enabled:     false,
 				DriftPerSec: 0.001,
 				MaxDrift:    60,
 				Direction:   "forward",
+				Waveform:    "linear",
+				Amplitude:   0.5,
+				Period:      600,
 			},
 			KissOfDeath: KissOfDeathConfig{
-				Enabled:  false,
-				Code:     "DENY",
-				Interval: 0,
+				Enabled:   false,
+				Code:      "DENY",
+				Interval:  0,
+				RotatePer: "request",
 			},
 			StratumAttack: StratumAttackConfig{
 				Enabled:     false,
 				FakeStratum: 1,
 			},
+			RefID: RefIDAttackConfig{
+				Enabled: false,
+				RefID:   "GPS",
+				Stratum: 0,
+			},
+			RootDistance: RootDistanceAttackConfig{
+				Enabled:     false,
+				RootDelayMs: 0,
+				RootDispMs:  0.01,
+				Overlay:     false,
+			},
+			Origin: OriginAttackConfig{
+				Enabled:  false,
+				Mode:     "zero",
+				Interval: 0,
+			},
 			LeapSecond: LeapSecondConfig{
 				Enabled:       false,
 				LeapIndicator: 1,
 			},
+			LeapSmear: LeapSmearConfig{
+				Enabled:    false,
+				WindowSecs: 86400,
+				Direction:  "positive",
+				Start:      "",
+			},
 			Rollover: RolloverConfig{
 				Enabled:    false,
 				TargetYear: 2038,
@@ -278,10 +1024,40 @@ func DefaultConfig() *Config {
 				Enabled:  false,
 				StepSecs: 3600,
 				Interval: 5,
+				Ramp:     defaultRamp(),
 			},
 			Fuzzing: FuzzingConfig{
 				Enabled: false,
-				Mode:    "random",
+				Mode:    "all",
+				Seed:    0,
+			},
+			CryptoNAK: CryptoNAKConfig{
+				Enabled:  false,
+				Interval: 0,
+			},
+			Delay: DelayAttackConfig{
+				Enabled:         false,
+				InboundDelayMs:  0,
+				OutboundDelayMs: 200,
+			},
+			TimeBomb: TimeBombConfig{
+				Enabled:       false,
+				AfterRequests: 1000,
+				At:            "",
+				Attack:        "time_spoofing",
+			},
+			ClientMix: ClientMixConfig{
+				Enabled: false,
+				Attacks: []string{"time_spoofing", "time_drift", "kiss_of_death", "stratum_attack", "leap_second", "clock_step"},
+				Seed:    0,
+			},
+			Sweep: SweepConfig{
+				Enabled: false,
+				Param:   "step_secs",
+				Start:   1,
+				End:     3600,
+				Steps:   10,
+				Dwell:   60,
 			},
 		},
 		Logging: LoggingConfig{
@@ -292,6 +1068,17 @@ func DefaultConfig() *Config {
 			ClientFingerprint: true,
 			RecordSessions:    true,
 			MaxLogEntries:     1000,
+			ClientOrder:       "recent",
+			FingerprintDB:     "fingerprints.yaml",
+			MaxSizeMB:         50,
+			MaxBackups:        5,
+			MaxAgeDays:        30,
+			CompressBackups:   false,
+			BehaviorAlerts: BehaviorAlertConfig{
+				Enabled:    false,
+				OffsetJump: 1,
+				Webhook:    "",
+			},
 		},
 		AttackPresets: []AttackPreset{
 			{
@@ -353,10 +1140,40 @@ func DefaultConfig() *Config {
 				Description: "Continuous random protocol fuzzing (Client Robustness Test)",
 				Attack:      "fuzzing",
 				Config: map[string]interface{}{
-					"mode": "random",
+					"mode": "all",
+				},
+			},
+		},
+		AttackSequences: []AttackSequence{
+			{
+				Name:        "Spoof KoD Step",
+				Description: "2m time spoofing, 30s Kiss-of-Death, then 1m clock steps",
+				Steps: []SequenceStep{
+					{Attack: "time_spoofing", Config: map[string]interface{}{"offset_secs": 3600}, Duration: 120},
+					{Attack: "kiss_of_death", Config: map[string]interface{}{"code": "RATE"}, Duration: 30},
+					{Attack: "clock_step", Config: map[string]interface{}{"step_secs": 86400, "interval": 5}, Duration: 60},
 				},
 			},
 		},
+		Control: ControlConfig{
+			Enabled: true,
+			Address: "127.0.0.1:8123",
+		},
+		TUI: TUIConfig{
+			Theme: "default",
+		},
+	}
+}
+
+// defaultRamp returns the ramp defaults: one second to one day, doubling
+// roughly every four requests
+func defaultRamp() RampConfig {
+	return RampConfig{
+		StartSecs:     1,
+		EndSecs:       86400,
+		Requests:      64,
+		Scale:         "exponential",
+		ToleranceSecs: 1,
 	}
 }
 
@@ -385,7 +1202,7 @@ func EnsureDataDir() (string, error) {
 	}
 
 	// Create subdirectories
-	subdirs := []string{SessionDirName, ExportDirName}
+	subdirs := []string{SessionDirName, ExportDirName, ProfileDirName}
 	for _, subdir := range subdirs {
 		path := filepath.Join(dataDir, subdir)
 		if err := os.MkdirAll(path, 0755); err != nil {
@@ -412,18 +1229,35 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
+	var cfg *Config
 	// Check if config file exists
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		// Create default config
-		cfg := DefaultConfig()
+		cfg = DefaultConfig()
 		if err := cfg.Save(); err != nil {
 			return nil, fmt.Errorf("failed to save default config: %w", err)
 		}
-		return cfg, nil
+	} else if cfg, err = LoadFile(configPath); err != nil {
+		return nil, err
 	}
 
+	// Precedence: defaults < file < environment < --set flags
+	if err := cfg.applyOverrides(); err != nil {
+		return nil, fmt.Errorf("invalid config override: %w", err)
+	}
+	if len(cfg.overrides) > 0 {
+		if err := cfg.validate(); err != nil {
+			return nil, fmt.Errorf("invalid config after overrides (%s):\n%w", strings.Join(cfg.overrides, ", "), err)
+		}
+	}
+	return cfg, nil
+}
+
+// LoadFile loads and validates a configuration file without creating it.
+// Environment and --set overrides are not applied.
+func LoadFile(path string) (*Config, error) {
 	// Read config file
-	data, err := os.ReadFile(configPath)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
@@ -433,25 +1267,40 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s:\n%w", path, err)
+	}
+
 	return cfg, nil
 }
 
 // Save saves configuration to file
 func (c *Config) Save() error {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return err
+	}
+	return c.saveTo(configPath)
+}
+
+// saveTo validates the configuration and writes it to path. Keys still
+// holding an environment or --set override are written with the value
+// they had before it.
+func (c *Config) saveTo(configPath string) error {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	// Ensure data directory exists
-	if _, err := EnsureDataDir(); err != nil {
-		return err
+	saved := c.withoutOverrides()
+	if err := saved.validate(); err != nil {
+		return fmt.Errorf("refusing to save invalid config:\n%w", err)
 	}
 
-	configPath, err := GetConfigPath()
-	if err != nil {
+	// Ensure data directory exists
+	if _, err := EnsureDataDir(); err != nil {
 		return err
 	}
 
-	data, err := yaml.Marshal(c)
+	data, err := yaml.Marshal(saved)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
@@ -488,17 +1337,55 @@ func (c *Config) UpdateFromYAML(yamlStr string) error {
 	if err := yaml.Unmarshal([]byte(yamlStr), newCfg); err != nil {
 		return fmt.Errorf("invalid YAML: %w", err)
 	}
+	if err := newCfg.validate(); err != nil {
+		return err
+	}
 
-	// Copy new values
-	c.Server = newCfg.Server
-	c.Upstream = newCfg.Upstream
-	c.Security = newCfg.Security
-	c.Logging = newCfg.Logging
-	c.AttackPresets = newCfg.AttackPresets
-
+	c.copyFrom(newCfg)
 	return nil
 }
 
+// CopyFrom replaces all settings with those of other, keeping c itself (and
+// every component holding it) in place
+func (c *Config) CopyFrom(other *Config) {
+	if c == other {
+		return
+	}
+	other.mu.RLock()
+	defer other.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.copyFrom(other)
+	c.overrides = other.overrides
+	c.overridden = other.overridden
+}
+
+// copyFrom copies the settings without locking
+func (c *Config) copyFrom(other *Config) {
+	c.Server = other.Server
+	c.Upstream = other.Upstream
+	c.Security = other.Security
+	c.Logging = other.Logging
+	c.AttackPresets = other.AttackPresets
+	c.AttackSequences = other.AttackSequences
+	c.Control = other.Control
+	c.TUI = other.TUI
+}
+
+// GetServer returns a copy of the server settings
+func (c *Config) GetServer() ServerConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Server
+}
+
+// GetLogging returns a copy of the logging settings
+func (c *Config) GetLogging() LoggingConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Logging
+}
+
 // GetActiveUpstreams returns list of enabled upstream servers sorted by priority
 func (c *Config) GetActiveUpstreams() []UpstreamServer {
 	c.mu.RLock()
@@ -508,7 +1395,7 @@ func (c *Config) GetActiveUpstreams() []UpstreamServer {
 	for _, s := range c.Upstream.Servers {
 		if s.Enabled {
 			if s.Port == 0 {
-				s.Port = 123
+				s.Port = s.DefaultPort()
 			}
 			active = append(active, s)
 		}
@@ -516,6 +1403,29 @@ func (c *Config) GetActiveUpstreams() []UpstreamServer {
 	return active
 }
 
+// GetPinnedUpstream returns the pinned upstream server, if one is configured.
+// A pinned address that is not in the server list is used with the default port.
+func (c *Config) GetPinnedUpstream() (UpstreamServer, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	pinned := c.Upstream.PinnedServer
+	if pinned == "" {
+		return UpstreamServer{}, false
+	}
+
+	for _, s := range c.Upstream.Servers {
+		if s.Address == pinned {
+			if s.Port == 0 {
+				s.Port = s.DefaultPort()
+			}
+			s.Enabled = true
+			return s, true
+		}
+	}
+	return UpstreamServer{Address: pinned, Port: 123, Enabled: true}, true
+}
+
 // GetOSInfo returns OS-specific information
 func GetOSInfo() string {
 	return fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH)
@@ -0,0 +1,291 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the environment variables that override config keys,
+// e.g. TIMEHAMMER_SERVER_PORT for server.port
+const EnvPrefix = "TIMEHAMMER_"
+
+// flagOverrides holds the --set key=value overrides, applied after the
+// environment by Load
+var (
+	flagOverridesMu sync.Mutex
+	flagOverrides   []string
+)
+
+// SetFlagOverrides records --set key=value overrides for Load. Keys and
+// value types are checked here so a typo fails before anything starts.
+func SetFlagOverrides(sets []string) error {
+	probe := DefaultConfig()
+	for _, set := range sets {
+		key, value, ok := strings.Cut(set, "=")
+		if !ok {
+			return fmt.Errorf("--set %q: expected key=value", set)
+		}
+		if err := probe.set(key, value); err != nil {
+			return fmt.Errorf("--set %s: %w", key, err)
+		}
+	}
+
+	flagOverridesMu.Lock()
+	defer flagOverridesMu.Unlock()
+	flagOverrides = append([]string(nil), sets...)
+	return nil
+}
+
+// Keys returns every dotted key that can be overridden, sorted. Lists of
+// sections (attack presets, sequences, upstream servers) are single keys
+// taking a YAML flow value.
+func Keys() []string {
+	var keys []string
+	walkKeys(reflect.TypeOf(Config{}), "", func(key string) { keys = append(keys, key) })
+	sort.Strings(keys)
+	return keys
+}
+
+// walkKeys calls fn with the dotted key of every non-struct field below t
+func walkKeys(t reflect.Type, prefix string, fn func(key string)) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := yamlName(f)
+		if name == "" {
+			continue
+		}
+		if f.Type.Kind() == reflect.Struct {
+			walkKeys(f.Type, prefix+name+".", fn)
+			continue
+		}
+		fn(prefix + name)
+	}
+}
+
+// yamlName returns the YAML key of a struct field ("" if not serialized)
+func yamlName(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		name = strings.ToLower(f.Name)
+	}
+	return name
+}
+
+// EnvName returns the environment variable that overrides a key
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// Set sets a dotted key such as "server.port" from a value written as in
+// the YAML file ("1123", "true", "[123, 1123]"). An empty value resets the
+// key to its zero value.
+func (c *Config) Set(key, value string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.set(key, value)
+}
+
+// Get returns the value of a dotted key as Set accepts it, with lists and
+// maps in YAML flow style ("[123, 1123]")
+func (c *Config) Get(key string) (string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.get(key)
+}
+
+// get is Get without locking
+func (c *Config) get(key string) (string, error) {
+	field, err := c.lookup(key)
+	if err != nil {
+		return "", err
+	}
+	if field.Kind() == reflect.String {
+		return field.String(), nil
+	}
+
+	var node yaml.Node
+	if err := node.Encode(field.Interface()); err != nil {
+		return "", err
+	}
+	flowStyle(&node)
+	data, err := yaml.Marshal(&node)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// flowStyle switches a YAML node and its children to flow style
+func flowStyle(n *yaml.Node) {
+	if n.Kind == yaml.SequenceNode || n.Kind == yaml.MappingNode {
+		n.Style = yaml.FlowStyle
+	}
+	for _, child := range n.Content {
+		flowStyle(child)
+	}
+}
+
+// lookup returns the field of a dotted key, which must not be a section
+func (c *Config) lookup(key string) (reflect.Value, error) {
+	field := reflect.ValueOf(c).Elem()
+	for _, part := range strings.Split(key, ".") {
+		if field.Kind() != reflect.Struct {
+			return reflect.Value{}, fmt.Errorf("unknown key %q", key)
+		}
+		next := reflect.Value{}
+		for i := 0; i < field.NumField(); i++ {
+			if yamlName(field.Type().Field(i)) == part {
+				next = field.Field(i)
+				break
+			}
+		}
+		if !next.IsValid() {
+			return reflect.Value{}, fmt.Errorf("unknown key %q", key)
+		}
+		field = next
+	}
+	if field.Kind() == reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("%q is a section; set one of its keys", key)
+	}
+	return field, nil
+}
+
+// set is Set without locking
+func (c *Config) set(key, value string) error {
+	field, err := c.lookup(key)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case value == "":
+		field.Set(reflect.Zero(field.Type()))
+	case field.Kind() == reflect.String:
+		field.SetString(value)
+	default:
+		v := reflect.New(field.Type())
+		if err := yaml.Unmarshal([]byte(value), v.Interface()); err != nil {
+			return fmt.Errorf("invalid value %q for %s: %w", value, key, err)
+		}
+		field.Set(v.Elem())
+	}
+	return nil
+}
+
+// override is an overridden key's value from the file and the value the
+// override gave it
+type override struct {
+	file  string
+	value string
+}
+
+// applyOverrides applies TIMEHAMMER_* environment variables and then the
+// --set flags, and remembers what was overridden
+func (c *Config) applyOverrides() error {
+	c.overrides = nil
+	c.overridden = nil
+	for _, key := range Keys() {
+		env := EnvName(key)
+		value, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+		if err := c.override(key, value); err != nil {
+			return fmt.Errorf("%s: %w", env, err)
+		}
+		c.overrides = append(c.overrides, fmt.Sprintf("%s=%s (%s)", key, value, env))
+	}
+	if err := checkEnv(); err != nil {
+		return err
+	}
+
+	flagOverridesMu.Lock()
+	sets := flagOverrides
+	flagOverridesMu.Unlock()
+	for _, set := range sets {
+		key, value, _ := strings.Cut(set, "=")
+		if err := c.override(key, value); err != nil {
+			return fmt.Errorf("--set %s: %w", key, err)
+		}
+		c.overrides = append(c.overrides, fmt.Sprintf("%s=%s (--set)", key, value))
+	}
+	return nil
+}
+
+// override sets an overridden key, keeping the value it had in the file
+func (c *Config) override(key, value string) error {
+	file, err := c.get(key)
+	if err != nil {
+		return err
+	}
+	if err := c.set(key, value); err != nil {
+		return err
+	}
+	set, err := c.get(key)
+	if err != nil {
+		return err
+	}
+
+	if c.overridden == nil {
+		c.overridden = make(map[string]override)
+	}
+	if prev, ok := c.overridden[key]; ok {
+		file = prev.file // --set on top of the environment
+	}
+	c.overridden[key] = override{file: file, value: set}
+	return nil
+}
+
+// withoutOverrides returns the settings as they are to be saved: c with
+// every key that still holds its override value put back to its file
+// value. A key changed since, in the TUI for example, keeps the change.
+// Caller must hold c.mu.
+func (c *Config) withoutOverrides() *Config {
+	if len(c.overridden) == 0 {
+		return c
+	}
+	saved := &Config{}
+	saved.copyFrom(c)
+	for key, o := range c.overridden {
+		if value, err := c.get(key); err == nil && value == o.value {
+			saved.set(key, o.file)
+		}
+	}
+	return saved
+}
+
+// checkEnv rejects TIMEHAMMER_* variables that name no key, so a typo is
+// not silently ignored
+func checkEnv() error {
+	known := make(map[string]bool)
+	for _, key := range Keys() {
+		known[EnvName(key)] = true
+	}
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(name, EnvPrefix) && !known[name] {
+			return fmt.Errorf("%s does not name a config key", name)
+		}
+	}
+	return nil
+}
+
+// Overrides lists the keys Load took from the environment or --set flags,
+// as "key=value (source)"
+func (c *Config) Overrides() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]string(nil), c.overrides...)
+}
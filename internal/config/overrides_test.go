@@ -0,0 +1,59 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// Saving writes the file values of overridden keys, not the overrides,
+// unless a key was changed after it was overridden
+func TestSaveKeepsOverridesOutOfFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv(EnvName("server.port"), "1123")
+	t.Setenv(EnvName("server.drop_rate"), "0.1")
+	if err := SetFlagOverrides([]string{"server.drop_rate=0.2", "server.timezone=Asia/Kolkata"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetFlagOverrides(nil) })
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Port != 1123 || cfg.Server.DropRate != 0.2 {
+		t.Fatalf("overrides not applied: port %d, drop rate %v", cfg.Server.Port, cfg.Server.DropRate)
+	}
+
+	// The TUI saves by way of the editor's YAML
+	yaml, err := cfg.GetYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.UpdateFromYAML(yaml); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Set("server.timezone", "Europe/Berlin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := LoadFile(filepath.Join(DataDirName, ConfigFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults := DefaultConfig()
+	if saved.Server.Port != defaults.Server.Port {
+		t.Errorf("saved port %d, want the file's %d", saved.Server.Port, defaults.Server.Port)
+	}
+	if saved.Server.DropRate != defaults.Server.DropRate {
+		t.Errorf("saved drop rate %v, want the file's %v", saved.Server.DropRate, defaults.Server.DropRate)
+	}
+	if saved.Server.Timezone != "Europe/Berlin" {
+		t.Errorf("saved timezone %q, want the change made after the override", saved.Server.Timezone)
+	}
+	if cfg.Server.Port != 1123 {
+		t.Errorf("saving reverted the live port to %d", cfg.Server.Port)
+	}
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// profileExt is the file extension of saved profiles
+const profileExt = ".yaml"
+
+// ProfilePath returns the file of a named profile. Names may contain
+// letters, digits, '-', '_' and '.', and may not start with a dot.
+func ProfilePath(name string) (string, error) {
+	if name == "" || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid profile name %q", name)
+	}
+	for _, r := range name {
+		ok := r == '-' || r == '_' || r == '.' ||
+			(r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+		if !ok {
+			return "", fmt.Errorf("invalid profile name %q (use letters, digits, '-', '_' or '.')", name)
+		}
+	}
+
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, ProfileDirName, name+profileExt), nil
+}
+
+// SaveProfile writes the configuration as a named profile, replacing any
+// profile of that name
+func (c *Config) SaveProfile(name string) error {
+	path, err := ProfilePath(name)
+	if err != nil {
+		return err
+	}
+	return c.saveTo(path)
+}
+
+// LoadProfile loads and validates a named profile
+func LoadProfile(name string) (*Config, error) {
+	path, err := ProfilePath(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("profile %q not found", name)
+	}
+	return LoadFile(path)
+}
+
+// ListProfiles returns the names of the saved profiles, sorted
+func ListProfiles() ([]string, error) {
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dataDir, ProfileDirName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), profileExt) {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(e.Name(), profileExt))
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,238 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// enums lists the allowed values of constrained string fields, keyed by
+// "StructType.yaml_key". Validation and the JSON Schema both read it.
+var enums = map[string][]string{
+	"JitterConfig.distribution":     {"uniform", "normal"},
+	"NTSConfig.mode":                {"ignore", "reject", "malform"},
+	"PollPolicyConfig.mode":         {"echo", "fixed", "clamp"},
+	"ResponseVersionPolicy.mode":    {"echo", "fixed", "cap"},
+	"ServerConfig.interleaved_mode": {"off", "on", "inconsistent"},
+	"UpstreamConfig.fallback_mode":  {"host", "manual", "last_good", "refuse"},
+	"UpstreamServer.secure":         {"", "https"},
+	"TimeDriftConfig.direction":     {"forward", "backward"},
+	"TimeDriftConfig.waveform":      {"linear", "sine", "sawtooth", "random_walk"},
+	"KissOfDeathConfig.rotate_per":  {"request", "client"},
+	"LeapSmearConfig.direction":     {"positive", "negative"},
+	"OriginAttackConfig.mode":       {"zero", "random", "off_by_one"},
+	"RolloverConfig.mode":           {"y2k38", "ntp_era", "custom"},
+	"FuzzingConfig.mode":            {"all", "random", "deterministic", "header", "timestamps", "logic"},
+	"RampConfig.scale":              {"linear", "exponential"},
+	"LoggingConfig.level":           {"debug", "info", "warn", "error"},
+	"LoggingConfig.client_order":    {"recent", "address"},
+	"LogSink.type":                  {"syslog", "webhook"},
+	"LogSink.network":               {"udp", "tcp"},
+	"LogSink.level":                 {"debug", "info", "warn", "error"},
+	"TUIConfig.theme":               {"default", "high_contrast", "colorblind", "monochrome"},
+}
+
+// commaLists are enum fields holding a comma-separated list of values
+var commaLists = map[string]bool{
+	"FuzzingConfig.mode": true,
+}
+
+// attackFields are the fields naming an attack
+var attackFields = map[string]bool{
+	"SecurityConfig.active_attack": true,
+	"TimeBombConfig.attack":        true,
+	"ClientMixConfig.attacks":      true,
+	"AttackPreset.attack":          true,
+	"SequenceStep.attack":          true,
+}
+
+// kissCodeFields are the fields holding a KoD code
+var kissCodeFields = map[string]bool{
+	"KissOfDeathConfig.code":  true,
+	"KissOfDeathConfig.codes": true,
+}
+
+// AttackNames returns the attack names, which are the security sections
+// that have a conditions block
+func AttackNames() []string {
+	var names []string
+	t := reflect.TypeOf(SecurityConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Type.Kind() != reflect.Struct {
+			continue
+		}
+		if _, ok := f.Type.FieldByName("Conditions"); ok {
+			names = append(names, yamlName(f))
+		}
+	}
+	return names
+}
+
+// mixableAttacks returns the attacks a client mix can assign: all but the
+// ones that stand for other attacks
+func mixableAttacks() []string {
+	var names []string
+	for _, name := range AttackNames() {
+		if name != "client_mix" && name != "time_bomb" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// AllowedValues returns the values a dotted key accepts, or nil if it is
+// not limited to a fixed set
+func AllowedValues(key string) []string {
+	t := reflect.TypeOf(Config{})
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		f, ok := fieldByYAMLName(t, part)
+		if !ok {
+			return nil
+		}
+		if i < len(parts)-1 {
+			t = f.Type
+			if t.Kind() != reflect.Struct {
+				return nil
+			}
+			continue
+		}
+		if f.Type.Kind() != reflect.String {
+			return nil
+		}
+		registry := t.Name() + "." + part
+		switch {
+		case attackFields[registry]:
+			return append([]string{""}, AttackNames()...)
+		case commaLists[registry]:
+			return nil
+		}
+		return enums[registry]
+	}
+	return nil
+}
+
+// fieldByYAMLName finds the struct field with a YAML key
+func fieldByYAMLName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if yamlName(t.Field(i)) == name {
+			return t.Field(i), true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// JSONSchema returns a JSON Schema (draft 2020-12) of the config file, with
+// the defaults and the allowed values of constrained fields, for editors
+// that validate and complete YAML
+func JSONSchema() ([]byte, error) {
+	schema := schemaFor(reflect.TypeOf(Config{}), reflect.ValueOf(DefaultConfig()).Elem(), "")
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "TimeHammer configuration"
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// schemaFor describes a type. def holds its default value (invalid inside
+// lists, which have none); key is "StructType.yaml_key" for registry lookups.
+func schemaFor(t reflect.Type, def reflect.Value, key string) map[string]interface{} {
+	s := map[string]interface{}{}
+	switch t.Kind() {
+	case reflect.Struct:
+		props := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := yamlName(f)
+			if name == "" {
+				continue
+			}
+			var fieldDef reflect.Value
+			if def.IsValid() {
+				fieldDef = def.Field(i)
+			}
+			props[name] = schemaFor(f.Type, fieldDef, t.Name()+"."+name)
+		}
+		s["type"] = "object"
+		s["properties"] = props
+		s["additionalProperties"] = false
+		return s
+	case reflect.Ptr:
+		s = schemaFor(t.Elem(), reflect.Value{}, key)
+		s["type"] = []interface{}{s["type"], "null"}
+		return s
+	case reflect.Slice:
+		s["type"] = "array"
+		s["items"] = schemaFor(t.Elem(), reflect.Value{}, key)
+	case reflect.Map:
+		s["type"] = "object"
+	case reflect.Bool:
+		s["type"] = "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s["type"] = "integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s["type"] = "integer"
+		s["minimum"] = 0
+	case reflect.Float32, reflect.Float64:
+		s["type"] = "number"
+	case reflect.String:
+		s["type"] = "string"
+		constrainString(s, key)
+	}
+
+	if def.IsValid() && !(t.Kind() == reflect.Slice && def.Len() == 0) && t.Kind() != reflect.Map {
+		s["default"] = yamlValue(def.Interface())
+	}
+	return s
+}
+
+// yamlValue returns a value as the YAML file spells it, so list defaults
+// use YAML keys rather than Go field names
+func yamlValue(v interface{}) interface{} {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := yaml.Unmarshal(data, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+// constrainString adds the allowed values of a string field
+func constrainString(s map[string]interface{}, key string) {
+	switch {
+	case attackFields[key]:
+		s["enum"] = append([]string{""}, AttackNames()...)
+	case kissCodeFields[key]:
+		// Any four bytes are allowed with include_invalid
+		codes := ntpcore.KissCodes
+		if strings.HasSuffix(key, ".codes") {
+			codes = append([]string{"random"}, codes...) // A random code per KoD
+		}
+		s["anyOf"] = []interface{}{
+			map[string]interface{}{"enum": codes},
+			map[string]interface{}{"minLength": 4, "maxLength": 4},
+		}
+	case commaLists[key]:
+		alt := "(" + strings.Join(quoteAll(enums[key]), "|") + ")"
+		s["pattern"] = `^\s*` + alt + `(\s*,\s*` + alt + `)*\s*$`
+		s["examples"] = enums[key]
+	case enums[key] != nil:
+		s["enum"] = enums[key]
+	}
+}
+
+// quoteAll escapes values for use in a regular expression
+func quoteAll(values []string) []string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = regexp.QuoteMeta(v)
+	}
+	return quoted
+}
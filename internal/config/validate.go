@@ -0,0 +1,427 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/netutil"
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// validator collects every problem found instead of stopping at the first
+type validator struct {
+	errs []error
+}
+
+// addf records a problem with a field
+func (v *validator) addf(field, format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+}
+
+// intRange checks min <= val <= max
+func (v *validator) intRange(field string, val, min, max int) {
+	if val < min || val > max {
+		v.addf(field, "%d out of range %d-%d", val, min, max)
+	}
+}
+
+// oneOf checks val is one of the allowed values
+func (v *validator) oneOf(field, val string, allowed ...string) {
+	for _, a := range allowed {
+		if val == a {
+			return
+		}
+	}
+	v.addf(field, "%q must be one of %s", val, strings.Join(allowed, ", "))
+}
+
+// timestamp checks an optional RFC3339 value
+func (v *validator) timestamp(field, val string) {
+	if val == "" {
+		return
+	}
+	if _, err := time.Parse(time.RFC3339, val); err != nil {
+		v.addf(field, "%q is not an RFC3339 time", val)
+	}
+}
+
+// addrs checks a list of IPs/CIDRs
+func (v *validator) addrs(field string, specs []string) {
+	if _, err := netutil.ParseAddrSet(specs); err != nil {
+		v.addf(field, "%v", err)
+	}
+}
+
+// kissCode checks a KoD code: an RFC 5905 kiss code, or any four bytes
+// when invalid codes are allowed
+func (v *validator) kissCode(field, code string, includeInvalid bool) {
+	switch {
+	case len(code) != 4:
+		v.addf(field, "%q must be exactly 4 bytes", code)
+	case !includeInvalid && !ntpcore.IsKissCode(code):
+		v.addf(field, "%q is not a known kiss code (%s; set include_invalid to send it anyway)",
+			code, strings.Join(ntpcore.KissCodes, ", "))
+	}
+}
+
+// ramp checks an enabled offset ramp
+func (v *validator) ramp(field string, r RampConfig) {
+	if !r.Enabled {
+		return
+	}
+	v.oneOf(field+".scale", r.Scale, enums["RampConfig.scale"]...)
+	if r.Scale == "exponential" && (r.StartSecs == 0 || r.EndSecs == 0 || (r.StartSecs < 0) != (r.EndSecs < 0)) {
+		v.addf(field, "an exponential ramp needs non-zero start_secs and end_secs of the same sign")
+	}
+	if r.DurationSecs < 0 || r.Requests < 0 {
+		v.addf(field, "duration_secs and requests must not be negative")
+	}
+	if (r.DurationSecs > 0) == (r.Requests > 0) {
+		v.addf(field, "set exactly one of duration_secs and requests")
+	}
+	if r.ToleranceSecs <= 0 {
+		v.addf(field+".tolerance_secs", "must be positive")
+	}
+}
+
+// conditions checks an attack condition block
+func (v *validator) conditions(field string, c AttackConditions) {
+	v.intRange(field+".min_version", c.MinVersion, 0, 7)
+	v.intRange(field+".max_version", c.MaxVersion, 0, 7)
+	if c.MinVersion != 0 && c.MaxVersion != 0 && c.MinVersion > c.MaxVersion {
+		v.addf(field, "min_version %d is above max_version %d", c.MinVersion, c.MaxVersion)
+	}
+	for _, m := range c.Modes {
+		v.intRange(field+".modes", m, 0, 7)
+	}
+	if c.MinPoll != nil {
+		v.intRange(field+".min_poll", *c.MinPoll, -128, 127)
+	}
+	if c.MaxPoll != nil {
+		v.intRange(field+".max_poll", *c.MaxPoll, -128, 127)
+	}
+	if c.MinPoll != nil && c.MaxPoll != nil && *c.MinPoll > *c.MaxPoll {
+		v.addf(field, "min_poll %d is above max_poll %d", *c.MinPoll, *c.MaxPoll)
+	}
+}
+
+// Validate range-checks the configuration and returns every problem found
+// joined into one error, or nil if the config is usable
+func (c *Config) Validate() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.validate()
+}
+
+// validate is Validate without locking
+func (c *Config) validate() error {
+	v := &validator{}
+
+	// Server
+	s := c.Server
+	v.intRange("server.port", s.Port, 1, 65535)
+	seenPorts := map[int]bool{s.Port: true}
+	for i, p := range s.Ports {
+		field := fmt.Sprintf("server.ports[%d]", i)
+		v.intRange(field, p, 1, 65535)
+		if seenPorts[p] {
+			v.addf(field, "port %d is listed twice", p)
+		}
+		seenPorts[p] = true
+	}
+	if s.AltPort != 0 {
+		v.intRange("server.alt_port", s.AltPort, 1, 65535)
+	}
+	v.intRange("server.ntp_version", s.NTPVersion, 1, 4)
+	v.intRange("server.stratum", s.Stratum, 0, 16)
+	if _, err := ntpcore.RefClockID(s.RefClock); err != nil {
+		v.addf("server.ref_clock", "%v", err)
+	}
+	if s.MaxClients < 0 {
+		v.addf("server.max_clients", "must not be negative")
+	}
+	if s.Timezone != "" {
+		if _, err := time.LoadLocation(s.Timezone); err != nil {
+			v.addf("server.timezone", "unknown timezone %q", s.Timezone)
+		}
+	}
+	if s.WriteFailureThreshold < 0 {
+		v.addf("server.write_failure_threshold", "must not be negative")
+	}
+	if s.WriteFailureBackoff < 0 {
+		v.addf("server.write_failure_backoff", "must not be negative")
+	}
+	if s.DrainTimeout < 0 {
+		v.addf("server.drain_timeout", "must not be negative")
+	}
+	if s.BaselineOffset.MaxSecs < 0 {
+		v.addf("server.baseline_offset.max_secs", "must not be negative")
+	}
+	if s.RateLimit.PerSec < 0 || s.RateLimit.Burst < 0 {
+		v.addf("server.rate_limit", "per_sec and burst must not be negative")
+	}
+	if s.ResponseCap.GlobalPerSec < 0 || s.ResponseCap.PerSourcePerSec < 0 {
+		v.addf("server.response_cap", "limits must not be negative")
+	}
+	if s.AmplificationTest.ResponseSize < 0 || s.AmplificationTest.ResponsePackets < 0 {
+		v.addf("server.amplification_test", "response size and packets must not be negative")
+	}
+	if s.DropRate < 0 || s.DropRate > 1 {
+		v.addf("server.drop_rate", "%g out of range 0-1", s.DropRate)
+	}
+	for i, r := range s.DropClients {
+		field := fmt.Sprintf("server.drop_clients[%d]", i)
+		v.addrs(field+".clients", r.Clients)
+		if r.Rate < 0 || r.Rate > 1 {
+			v.addf(field+".rate", "%g out of range 0-1", r.Rate)
+		}
+		if strings.Trim(r.Pattern, ".x") != "" {
+			v.addf(field+".pattern", "%q may only contain '.' (answer) and 'x' (drop)", r.Pattern)
+		}
+	}
+	v.timestamp("server.frozen_time.base", s.FrozenTime.Base)
+	if s.Jitter.Ms < 0 {
+		v.addf("server.jitter.ms", "must not be negative")
+	}
+	v.oneOf("server.jitter.distribution", s.Jitter.Distribution, enums["JitterConfig.distribution"]...)
+	v.oneOf("server.nts.mode", s.NTS.Mode, enums["NTSConfig.mode"]...)
+	v.intRange("server.precision", s.Precision, -128, 127)
+	v.oneOf("server.poll_policy.mode", s.PollPolicy.Mode, enums["PollPolicyConfig.mode"]...)
+	v.intRange("server.poll_policy.value", s.PollPolicy.Value, -128, 127)
+	v.intRange("server.poll_policy.min", s.PollPolicy.Min, -128, 127)
+	v.intRange("server.poll_policy.max", s.PollPolicy.Max, -128, 127)
+	if s.Quirks.ForcePoll != nil {
+		v.intRange("server.quirks.force_poll", *s.Quirks.ForcePoll, -128, 127)
+	}
+	v.oneOf("server.interleaved_mode", s.InterleavedMode, enums["ServerConfig.interleaved_mode"]...)
+	if s.RefTimeAge < 0 {
+		v.addf("server.ref_time_age", "must not be negative")
+	}
+	if s.MaxClientSkew < 0 {
+		v.addf("server.max_client_skew", "must not be negative")
+	}
+	for i, version := range s.AcceptedVersions {
+		v.intRange(fmt.Sprintf("server.accepted_versions[%d]", i), version, 0, 7)
+	}
+	v.oneOf("server.response_version.mode", s.ResponseVersion.Mode, enums["ResponseVersionPolicy.mode"]...)
+	v.intRange("server.response_version.version", s.ResponseVersion.Version, 0, 7)
+	if s.Proxy.Upstream != "" {
+		if _, _, err := net.SplitHostPort(s.Proxy.Upstream); err != nil {
+			v.addf("server.proxy.upstream", "must be host:port: %v", err)
+		}
+	}
+	if len(s.Auth.TrustedKeys) > 0 && s.Auth.KeysFile == "" {
+		v.addf("server.auth.trusted_keys", "requires server.auth.keys_file")
+	}
+	for i, id := range s.Auth.TrustedKeys {
+		if id == 0 {
+			v.addf(fmt.Sprintf("server.auth.trusted_keys[%d]", i), "key ID 0 is not valid")
+		}
+	}
+	if s.PollPolicy.Min > s.PollPolicy.Max {
+		v.addf("server.poll_policy", "min %d is above max %d", s.PollPolicy.Min, s.PollPolicy.Max)
+	}
+	if s.Broadcast.Enabled {
+		if strings.TrimSpace(s.Broadcast.Address) == "" {
+			v.addf("server.broadcast.address", "must not be empty")
+		}
+		if s.Broadcast.IntervalSecs < 1 {
+			v.addf("server.broadcast.interval_secs", "must be at least 1")
+		}
+	}
+
+	// Upstream
+	u := c.Upstream
+	for i, srv := range u.Servers {
+		field := fmt.Sprintf("upstream.servers[%d]", i)
+		if strings.TrimSpace(srv.Address) == "" {
+			v.addf(field+".address", "must not be empty")
+		}
+		if srv.Port != 0 {
+			v.intRange(field+".port", srv.Port, 1, 65535)
+		}
+		if srv.Secure != "" && srv.Secure != SecureHTTPS {
+			v.addf(field+".secure", "%q must be empty (plain NTP) or %s", srv.Secure, SecureHTTPS)
+		}
+		if srv.Secure == SecureHTTPS && srv.Port == 123 {
+			v.addf(field+".port", "123 is the NTP port; use 443 (or leave it empty) for an HTTPS source")
+		}
+	}
+	if u.SyncInterval <= 0 {
+		v.addf("upstream.sync_interval", "must be positive")
+	}
+	if u.Timeout <= 0 {
+		v.addf("upstream.timeout", "must be positive")
+	}
+	if u.Retries < 1 {
+		v.addf("upstream.retries", "must be at least 1")
+	}
+	v.intRange("upstream.pool_size", u.PoolSize, 1, 16)
+	v.intRange("upstream.stability_window", u.StabilityWindow, 2, 1000)
+	v.oneOf("upstream.fallback_mode", u.FallbackMode, enums["UpstreamConfig.fallback_mode"]...)
+	v.timestamp("upstream.fallback_time", u.FallbackTime)
+	if u.FallbackMode == "manual" && u.FallbackTime == "" {
+		v.addf("upstream.fallback_time", "required when fallback_mode is manual")
+	}
+
+	// Security
+	sec := c.Security
+	v.addrs("security.targets.allow", sec.Targets.Allow)
+	v.addrs("security.targets.deny", sec.Targets.Deny)
+	v.timestamp("security.time_spoofing.custom_time", sec.TimeSpoofing.CustomTime)
+	v.ramp("security.time_spoofing.ramp", sec.TimeSpoofing.Ramp)
+	v.oneOf("security.time_drift.direction", sec.TimeDrift.Direction, enums["TimeDriftConfig.direction"]...)
+	if sec.TimeDrift.DriftPerSec < 0 || sec.TimeDrift.MaxDrift < 0 {
+		v.addf("security.time_drift", "drift_per_sec and max_drift must not be negative")
+	}
+	v.oneOf("security.time_drift.waveform", sec.TimeDrift.Waveform, enums["TimeDriftConfig.waveform"]...)
+	if sec.TimeDrift.Amplitude < 0 {
+		v.addf("security.time_drift.amplitude", "must not be negative")
+	}
+	if w := sec.TimeDrift.Waveform; (w == "sine" || w == "sawtooth") && sec.TimeDrift.Period <= 0 {
+		v.addf("security.time_drift.period", "must be positive for the %s waveform", w)
+	}
+	v.kissCode("security.kiss_of_death.code", sec.KissOfDeath.Code, sec.KissOfDeath.IncludeInvalid)
+	for i, code := range sec.KissOfDeath.Codes {
+		field := fmt.Sprintf("security.kiss_of_death.codes[%d]", i)
+		if strings.EqualFold(code, "random") {
+			if !sec.KissOfDeath.IncludeInvalid {
+				v.addf(field, "random codes need include_invalid")
+			}
+			continue
+		}
+		v.kissCode(field, code, sec.KissOfDeath.IncludeInvalid)
+	}
+	v.oneOf("security.kiss_of_death.rotate_per", sec.KissOfDeath.RotatePer, enums["KissOfDeathConfig.rotate_per"]...)
+	if sec.KissOfDeath.Interval < 0 {
+		v.addf("security.kiss_of_death.interval", "must not be negative")
+	}
+	v.intRange("security.stratum_attack.fake_stratum", sec.StratumAttack.FakeStratum, 0, 16)
+	v.intRange("security.stratum_attack.jitter", sec.StratumAttack.Jitter, 0, 14)
+	if _, _, err := ntpcore.ParseReferenceID(sec.RefID.RefID); err != nil {
+		v.addf("security.refid_spoof.ref_id", "%v", err)
+	}
+	v.intRange("security.refid_spoof.stratum", sec.RefID.Stratum, 0, 15)
+	v.oneOf("security.origin_attack.mode", sec.Origin.Mode, enums["OriginAttackConfig.mode"]...)
+	if sec.Origin.Interval < 0 {
+		v.addf("security.origin_attack.interval", "must not be negative")
+	}
+	v.intRange("security.leap_second.leap_indicator", sec.LeapSecond.LeapIndicator, 0, 3)
+	if sec.LeapSmear.WindowSecs <= 0 {
+		v.addf("security.leap_smear.window_secs", "must be positive")
+	}
+	v.oneOf("security.leap_smear.direction", sec.LeapSmear.Direction, enums["LeapSmearConfig.direction"]...)
+	v.timestamp("security.leap_smear.start", sec.LeapSmear.Start)
+	v.oneOf("security.rollover.mode", sec.Rollover.Mode, enums["RolloverConfig.mode"]...)
+	if sec.ClockStep.Interval < 0 {
+		v.addf("security.clock_step.interval", "must not be negative")
+	}
+	v.ramp("security.clock_step.ramp", sec.ClockStep.Ramp)
+	for _, class := range strings.Split(sec.Fuzzing.Mode, ",") {
+		v.oneOf("security.fuzzing.mode", strings.TrimSpace(class), enums["FuzzingConfig.mode"]...)
+	}
+	if sec.CryptoNAK.Interval < 0 {
+		v.addf("security.crypto_nak.interval", "must not be negative")
+	}
+	v.timestamp("security.time_bomb.at", sec.TimeBomb.At)
+	if sec.TimeBomb.AfterRequests < 0 {
+		v.addf("security.time_bomb.after_requests", "must not be negative")
+	}
+	if len(sec.ClientMix.Attacks) == 0 {
+		v.addf("security.client_mix.attacks", "must name at least one attack")
+	}
+	for i, name := range sec.ClientMix.Attacks {
+		v.oneOf(fmt.Sprintf("security.client_mix.attacks[%d]", i), name, mixableAttacks()...)
+	}
+	if sec.Sweep.Enabled && sec.Sweep.Steps < 2 {
+		v.addf("security.sweep.steps", "must be at least 2")
+	}
+	for _, c := range []struct {
+		name string
+		cond AttackConditions
+	}{
+		{"time_spoofing", sec.TimeSpoofing.Conditions},
+		{"time_drift", sec.TimeDrift.Conditions},
+		{"kiss_of_death", sec.KissOfDeath.Conditions},
+		{"stratum_attack", sec.StratumAttack.Conditions},
+		{"refid_spoof", sec.RefID.Conditions},
+		{"root_distance", sec.RootDistance.Conditions},
+		{"origin_attack", sec.Origin.Conditions},
+		{"leap_second", sec.LeapSecond.Conditions},
+		{"leap_smear", sec.LeapSmear.Conditions},
+		{"rollover", sec.Rollover.Conditions},
+		{"clock_step", sec.ClockStep.Conditions},
+		{"fuzzing", sec.Fuzzing.Conditions},
+		{"crypto_nak", sec.CryptoNAK.Conditions},
+		{"delay", sec.Delay.Conditions},
+		{"time_bomb", sec.TimeBomb.Conditions},
+		{"client_mix", sec.ClientMix.Conditions},
+	} {
+		v.conditions("security."+c.name+".conditions", c.cond)
+	}
+
+	// Logging
+	v.oneOf("logging.level", c.Logging.Level, enums["LoggingConfig.level"]...)
+	v.addrs("logging.record_clients", c.Logging.RecordClients)
+	v.oneOf("logging.client_order", c.Logging.ClientOrder, enums["LoggingConfig.client_order"]...)
+	for i, sink := range c.Logging.Sinks {
+		field := fmt.Sprintf("logging.sinks[%d]", i)
+		v.oneOf(field+".type", sink.Type, enums["LogSink.type"]...)
+		switch {
+		case strings.TrimSpace(sink.Target) == "":
+			v.addf(field+".target", "must not be empty")
+		case sink.Type == "syslog":
+			if _, _, err := net.SplitHostPort(sink.Target); err != nil {
+				v.addf(field+".target", "must be host:port: %v", err)
+			}
+		case sink.Type == "webhook":
+			if !strings.HasPrefix(sink.Target, "http://") && !strings.HasPrefix(sink.Target, "https://") {
+				v.addf(field+".target", "must be an http:// or https:// URL")
+			}
+		}
+		if sink.Network != "" {
+			v.oneOf(field+".network", sink.Network, enums["LogSink.network"]...)
+		}
+		if sink.Level != "" {
+			v.oneOf(field+".level", sink.Level, enums["LogSink.level"]...)
+		}
+		if sink.BatchSize < 0 {
+			v.addf(field+".batch_size", "must not be negative")
+		}
+	}
+	if ba := c.Logging.BehaviorAlerts; ba.Enabled {
+		if ba.OffsetJump < 0 {
+			v.addf("logging.behavior_alerts.offset_jump", "must not be negative")
+		}
+		if ba.Webhook != "" && !strings.HasPrefix(ba.Webhook, "http://") && !strings.HasPrefix(ba.Webhook, "https://") {
+			v.addf("logging.behavior_alerts.webhook", "must be an http:// or https:// URL")
+		}
+	}
+	if c.Logging.MaxSizeMB < 0 || c.Logging.MaxBackups < 0 || c.Logging.MaxAgeDays < 0 {
+		v.addf("logging", "max_size_mb, max_backups and max_age_days must not be negative")
+	}
+
+	// Sequences
+	for i, seq := range c.AttackSequences {
+		for j, step := range seq.Steps {
+			if step.Duration <= 0 {
+				v.addf(fmt.Sprintf("attack_sequences[%d].steps[%d].duration", i, j), "must be positive")
+			}
+		}
+	}
+
+	// Control API
+	if c.Control.Enabled {
+		if _, _, err := net.SplitHostPort(c.Control.Address); err != nil {
+			v.addf("control.address", "%q is not host:port", c.Control.Address)
+		}
+	}
+
+	// Terminal UI
+	v.oneOf("tui.theme", c.TUI.Theme, enums["TUIConfig.theme"]...)
+
+	return errors.Join(v.errs...)
+}
@@ -0,0 +1,184 @@
+// Package control provides the local HTTP control API used by scripts and
+// the CLI subcommands to talk to a running instance
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+	"github.com/neutrinoguy/timehammer/internal/logger"
+	"github.com/neutrinoguy/timehammer/internal/ops"
+	"github.com/neutrinoguy/timehammer/internal/server"
+)
+
+// API serves the control endpoints for a running server
+type API struct {
+	cfg     *config.Config
+	srv     *server.Server
+	log     *logger.Logger
+	cmds    *Commands
+	httpSrv *http.Server
+}
+
+// NewAPI creates a control API bound to the given server
+func NewAPI(cfg *config.Config, srv *server.Server) *API {
+	a := &API{
+		cfg:  cfg,
+		srv:  srv,
+		log:  logger.GetLogger(),
+		cmds: NewCommands(cfg, srv),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/capabilities", a.handleCapabilities)
+	mux.HandleFunc("/status", a.handleStatus)
+	mux.HandleFunc("/upstream", a.handleUpstream)
+	mux.HandleFunc("/ops", a.handleOps)
+	mux.HandleFunc("/ops/cancel", a.handleCancelOp)
+	mux.HandleFunc("/command", a.handleCommand)
+
+	a.httpSrv = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return a
+}
+
+// Start begins serving the API on the configured address
+func (a *API) Start() error {
+	addr := a.cfg.Control.Address
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	if host, _, err := net.SplitHostPort(ln.Addr().String()); err == nil {
+		if ip := net.ParseIP(host); ip != nil && !ip.IsLoopback() {
+			a.log.Warnf("CONTROL", "Control API is reachable from the network on %s", ln.Addr())
+		}
+	}
+
+	go func() {
+		if err := a.httpSrv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.log.Errorf("CONTROL", "Control API stopped: %v", err)
+		}
+	}()
+
+	a.log.Infof("CONTROL", "Control API listening on %s", ln.Addr())
+	return nil
+}
+
+// Stop shuts the API down
+func (a *API) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return a.httpSrv.Shutdown(ctx)
+}
+
+// handleCapabilities describes what this build supports
+func (a *API) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	caps, err := DescribeCapabilities(true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintln(w, caps)
+}
+
+// handleStatus returns the one-line status
+func (a *API) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, a.srv.StatusLine())
+}
+
+// handleUpstream returns the upstream sync status, with the offset
+// stability, as JSON
+func (a *API) handleUpstream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, a.srv.GetUpstreamStatus())
+}
+
+// handleOps lists in-flight operations as JSON
+func (a *API) handleOps(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, ops.GetRegistry().List())
+}
+
+// handleCancelOp cancels an operation given by the id query parameter
+func (a *API) handleCancelOp(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	if err := ops.GetRegistry().Cancel(id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	a.log.Infof("CONTROL", "Cancelled operation #%d via API", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleCommand runs a REPL command given as the request body
+func (a *API) handleCommand(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 4096))
+	if err != nil {
+		http.Error(w, "failed to read command", http.StatusBadRequest)
+		return
+	}
+
+	out, err := a.cmds.Execute(string(body))
+	if errors.Is(err, ErrQuit) {
+		http.Error(w, "quit is only available in the REPL", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, out)
+}
+
+// writeJSON encodes v as the response body
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,153 @@
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/neutrinoguy/timehammer/internal/attacks"
+	"github.com/neutrinoguy/timehammer/internal/config"
+)
+
+// CapabilitiesVersion is bumped on incompatible changes to the
+// Capabilities layout. New fields and features may be added without a bump.
+const CapabilitiesVersion = 1
+
+// BuildVersion is the application version, set by main at startup
+var BuildVersion = "dev"
+
+// Capabilities describes what a running build supports so tooling can
+// adapt to the server version
+type Capabilities struct {
+	CapabilitiesVersion int             `json:"capabilities_version"`
+	Version             string          `json:"version"`
+	ConfigSchema        int             `json:"config_schema"`
+	Attacks             []string        `json:"attacks"`
+	Commands            []string        `json:"commands"`
+	Features            map[string]bool `json:"features"`
+}
+
+// features lists optional functionality by stable name. Add an entry when a
+// feature lands; never rename or remove one.
+var features = map[string]bool{
+	"amplification_test": true,
+	"attack_form":        true,
+	"auto_record":        true,
+	"baseline_offset":    true,
+	"behavior_alerts":    true,
+	"broadcast":          true,
+	"client_history":     true,
+	"client_mix":         true,
+	"client_order":       true,
+	"config_overrides":   true,
+	"crypto_nak":         true,
+	"drift_waveforms":    true,
+	"drop_simulation":    true,
+	"export_path":        true,
+	"fallback_source":    true,
+	"frozen_time":        true,
+	"interleaved_mode":   true,
+	"jitter":             true,
+	"json_schema":        true,
+	"kod_compliance":     true,
+	"kod_rotation":       true,
+	"leap_smear":         true,
+	"log_sinks":          true,
+	"mac_auth":           true,
+	"max_client_skew":    true,
+	"nts":                true,
+	"ops":                true,
+	"packet_builder":     true,
+	"pause_resume":       true,
+	"pcap":               true,
+	"poll_policy":        true,
+	"profiles":           true,
+	"proxy":              true,
+	"query_intervals":    true,
+	"quirks":             true,
+	"ramp":               true,
+	"rate_limit":         true,
+	"record_filter":      true,
+	"ref_clock":          true,
+	"replay":             true,
+	"reply_source":       true,
+	"report":             true,
+	"request_conditions": true,
+	"response_cap":       true,
+	"response_signing":   true,
+	"schedules":          true,
+	"secure_upstream":    true,
+	"selftest":           true,
+	"sequences":          true,
+	"session_diff":       true,
+	"sntp_mode":          true,
+	"stratum_tracking":   true,
+	"sweep":              true,
+	"symmetric_passive":  true,
+	"target_filter":      true,
+	"test_vectors":       true,
+	"themes":             true,
+	"trusted_keys":       true,
+	"upstream_health":    true,
+	"upstream_stability": true,
+	"version_policy":     true,
+}
+
+// commandNames lists the commands accepted by Execute
+var commandNames = []string{
+	"attack", "attacks", "cancel", "capabilities", "diff", "logs", "ops", "pcap", "preset",
+	"presets", "profile", "profiles", "record", "replay", "report", "sequence", "sequences", "start", "stats",
+	"status", "stop", "sync", "upstreams", "vectors",
+}
+
+// GetCapabilities returns the capabilities of this build
+func GetCapabilities() Capabilities {
+	caps := Capabilities{
+		CapabilitiesVersion: CapabilitiesVersion,
+		Version:             BuildVersion,
+		ConfigSchema:        config.SchemaVersion,
+		Commands:            append([]string(nil), commandNames...),
+		Features:            make(map[string]bool, len(features)),
+	}
+	for _, info := range attacks.GetAvailableAttacks() {
+		caps.Attacks = append(caps.Attacks, string(info.Type))
+	}
+	for name, on := range features {
+		caps.Features[name] = on
+	}
+	return caps
+}
+
+// DescribeCapabilities renders the capabilities of this build as text, or
+// as the JSON that GET /capabilities returns. The REPL, the command
+// endpoint and the capabilities subcommand all print this.
+func DescribeCapabilities(asJSON bool) (string, error) {
+	if !asJSON {
+		return formatCapabilities(GetCapabilities()), nil
+	}
+	data, err := json.MarshalIndent(GetCapabilities(), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// formatCapabilities renders capabilities as text
+func formatCapabilities(caps Capabilities) string {
+	names := make([]string, 0, len(caps.Features))
+	for name, on := range caps.Features {
+		if on {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Version:   %s (capabilities v%d, config schema v%d)\n",
+		caps.Version, caps.CapabilitiesVersion, caps.ConfigSchema)
+	fmt.Fprintf(&b, "Attacks:   %s\n", strings.Join(caps.Attacks, ", "))
+	fmt.Fprintf(&b, "Commands:  %s\n", strings.Join(caps.Commands, ", "))
+	fmt.Fprintf(&b, "Features:  %s", strings.Join(names, ", "))
+	return b.String()
+}
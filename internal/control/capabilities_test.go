@@ -0,0 +1,163 @@
+package control
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+)
+
+// featureToggles maps the config keys that switch functionality on to the
+// capability that advertises it. A "*" segment matches any one segment; a
+// feature of "*" is the attack the wildcard matched. The first match wins.
+var featureToggles = []struct {
+	key     string
+	feature string // "" = exempt
+}{
+	{"control.enabled", ""},  // Serves the capabilities themselves
+	{"security.enabled", ""}, // Master switch of the attacks listed under attacks
+	{"security.sweep.enabled", "sweep"},
+	{"security.*.ramp.enabled", "ramp"},
+	{"security.*.conditions.modes", "request_conditions"},
+	{"security.time_drift.waveform", "drift_waveforms"},
+	{"security.kiss_of_death.compliance_test", "kod_compliance"},
+	{"security.kiss_of_death.rotate_per", "kod_rotation"},
+	{"security.*.mode", "*"}, // Attack variants
+	{"security.*.enabled", "*"},
+	{"logging.behavior_alerts.enabled", "behavior_alerts"},
+	{"logging.client_history", "client_history"},
+	{"logging.auto_record_attacks", "auto_record"},
+	{"server.amplification_test.enabled", "amplification_test"},
+	{"server.baseline_offset.enabled", "baseline_offset"},
+	{"server.broadcast.enabled", "broadcast"},
+	{"server.drop_rate", "drop_simulation"},
+	{"server.frozen_time.base", "frozen_time"},
+	{"server.interleaved_mode", "interleaved_mode"},
+	{"server.jitter.enabled", "jitter"},
+	{"server.nts.mode", "nts"},
+	{"server.poll_policy.mode", "poll_policy"},
+	{"server.proxy.enabled", "proxy"},
+	{"server.rate_limit.enabled", "rate_limit"},
+	{"server.response_cap.enabled", "response_cap"},
+	{"server.response_version.mode", "version_policy"},
+	{"server.signing.enabled", "response_signing"},
+	{"server.sntp_mode", "sntp_mode"},
+	{"upstream.fallback_mode", "fallback_source"},
+}
+
+// isToggle reports whether a config key switches functionality on or
+// picks between modes of it
+func isToggle(key string) bool {
+	for _, suffix := range []string{".enabled", ".mode", "_mode", ".modes", ".waveform"} {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	for _, t := range featureToggles {
+		if t.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// matchToggle returns the capability of a toggle key and whether the
+// table covers it
+func matchToggle(key string) (string, bool) {
+	parts := strings.Split(key, ".")
+	for _, t := range featureToggles {
+		pattern := strings.Split(t.key, ".")
+		if len(pattern) != len(parts) {
+			continue
+		}
+		wildcard, ok := "", true
+		for i := range pattern {
+			switch pattern[i] {
+			case "*":
+				wildcard = parts[i]
+			case parts[i]:
+			default:
+				ok = false
+			}
+		}
+		if !ok {
+			continue
+		}
+		if t.feature == "*" {
+			return wildcard, true
+		}
+		return t.feature, true
+	}
+	return "", false
+}
+
+func TestFeatureTogglesHaveCapabilities(t *testing.T) {
+	caps := GetCapabilities()
+	attacks := make(map[string]bool)
+	for _, a := range caps.Attacks {
+		attacks[a] = true
+	}
+	keys := make(map[string]bool)
+	for _, key := range config.Keys() {
+		keys[key] = true
+	}
+
+	for key := range keys {
+		if !isToggle(key) {
+			continue
+		}
+		feature, ok := matchToggle(key)
+		switch {
+		case !ok:
+			t.Errorf("config toggle %s has no capability; add a feature key and map it in featureToggles", key)
+		case feature == "":
+		case !caps.Features[feature] && !attacks[feature]:
+			t.Errorf("config toggle %s maps to %q, which is neither a feature nor an attack", key, feature)
+		}
+	}
+
+	// Keep the table from outliving renamed keys
+	for _, tt := range featureToggles {
+		if !strings.Contains(tt.key, "*") && !keys[tt.key] {
+			t.Errorf("featureToggles lists %s, which is not a config key", tt.key)
+		}
+	}
+}
+
+// Every control surface serves the same capabilities document
+func TestCapabilitiesMatchAcrossSurfaces(t *testing.T) {
+	rec := httptest.NewRecorder()
+	(&API{}).handleCapabilities(rec, httptest.NewRequest(http.MethodGet, "/capabilities", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /capabilities: %d", rec.Code)
+	}
+	rest := strings.TrimSpace(rec.Body.String())
+
+	cmds := &Commands{}
+	for _, tt := range []struct {
+		line   string
+		asJSON bool
+	}{
+		{"capabilities", false},
+		{"caps", false},
+		{"capabilities json", true},
+		{"CAPS JSON", true},
+	} {
+		got, err := cmds.Execute(tt.line)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.line, err)
+		}
+		want, err := DescribeCapabilities(tt.asJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s: output differs from DescribeCapabilities(%v)", tt.line, tt.asJSON)
+		}
+		if tt.asJSON && got != rest {
+			t.Errorf("%s: output differs from GET /capabilities", tt.line)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package control
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// clientTimeout bounds how long CLI subcommands wait for a running instance
+const clientTimeout = 3 * time.Second
+
+// FetchStatus reads the one-line status from a running instance
+func FetchStatus(addr string) (string, error) {
+	body, err := get(addr, "/status")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(body), nil
+}
+
+// get performs a GET request against the control API
+func get(addr, path string) (string, error) {
+	client := &http.Client{Timeout: clientTimeout}
+
+	resp, err := client.Get("http://" + addr + path)
+	if err != nil {
+		return "", fmt.Errorf("no running instance at %s: %w", addr, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("control API returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return string(body), nil
+}
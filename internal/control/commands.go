@@ -0,0 +1,630 @@
+package control
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/attacks"
+	"github.com/neutrinoguy/timehammer/internal/config"
+	"github.com/neutrinoguy/timehammer/internal/logger"
+	"github.com/neutrinoguy/timehammer/internal/ops"
+	"github.com/neutrinoguy/timehammer/internal/report"
+	"github.com/neutrinoguy/timehammer/internal/server"
+	"github.com/neutrinoguy/timehammer/internal/session"
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// ErrQuit is returned by Execute when the caller asked to leave the REPL
+var ErrQuit = errors.New("quit")
+
+// attackAliases maps short command names to attack types
+var attackAliases = map[string]attacks.AttackType{
+	"spoof":   attacks.AttackTimeSpoofing,
+	"drift":   attacks.AttackTimeDrift,
+	"kod":     attacks.AttackKissOfDeath,
+	"stratum": attacks.AttackStratumLie,
+	"leap":    attacks.AttackLeapSecond,
+	"smear":   attacks.AttackLeapSmear,
+	"step":    attacks.AttackClockStep,
+	"fuzz":    attacks.AttackFuzzing,
+	"nak":     attacks.AttackCryptoNAK,
+	"bomb":    attacks.AttackTimeBomb,
+	"mix":     attacks.AttackClientMix,
+	"delay":   attacks.AttackDelay,
+	"refid":   attacks.AttackRefID,
+	"root":    attacks.AttackRootDistance,
+	"origin":  attacks.AttackOrigin,
+}
+
+// commandHelp is printed by the help command
+const commandHelp = `Commands:
+  status               One-line status
+  capabilities [json]  Version, attacks and features of this build (json:
+                       as GET /capabilities returns them)
+  stats                Server statistics
+  start | stop         Start or stop the NTP server
+  sync                 Force an upstream sync
+  upstreams            Health of each upstream server
+  attacks              List attacks
+  attack NAME          Enable an attack (e.g. drift, kod, clock_step)
+  attack off           Disable all attacks
+  attack pause|resume  Answer honestly for a while, then continue the
+                       attack where it stopped (drift, counters)
+  presets              List attack presets
+  preset NAME          Apply a preset (e.g. preset Y2K38 Test)
+  sequences            List attack sequences
+  sequence NAME|stop   Run an attack sequence in the background, or stop it
+  profiles             List saved config profiles
+  profile save NAME    Save the current config as a profile
+  profile load NAME    Switch to a saved profile
+  record start [ADDR]  Start recording (only the given IPs/CIDRs, if any)
+  record stop          Stop recording and save the session
+  pcap ID              Export a saved session as a pcap file
+  vectors ID           Export a saved session as editable test vectors
+  report [html] [ID]   Write a test report (Markdown, or HTML) of the live
+                       log, or of a saved session
+  diff ID ID           Compare two sessions (or vector files) for behavior changes
+  replay ID TARGET [X] Replay a saved session (or a .yaml/.json vector
+                       file) to HOST[:PORT] at X speed
+                       (add "dry" to only log what would be sent, "now"
+                       to move the recorded timestamps to the present)
+  ops                  List in-flight operations
+  cancel ID            Cancel an operation
+  logs [N]             Show the last N log entries (default 10)
+  help                 Show this help
+  quit                 Exit`
+
+// Commands executes text control commands. It backs both the stdin REPL
+// and the API command endpoint so they behave identically.
+type Commands struct {
+	cfg *config.Config
+	srv *server.Server
+	log *logger.Logger
+}
+
+// NewCommands creates a command handler for a server
+func NewCommands(cfg *config.Config, srv *server.Server) *Commands {
+	return &Commands{
+		cfg: cfg,
+		srv: srv,
+		log: logger.GetLogger(),
+	}
+}
+
+// Execute runs one command line and returns its output
+func (c *Commands) Execute(line string) (string, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", nil
+	}
+	cmd, args := strings.ToLower(fields[0]), fields[1:]
+
+	switch cmd {
+	case "help", "?":
+		return commandHelp, nil
+	case "quit", "exit":
+		return "", ErrQuit
+	case "status":
+		return c.srv.StatusLine(), nil
+	case "capabilities", "caps":
+		return c.capabilities(args)
+	case "stats":
+		return c.stats(), nil
+	case "start":
+		if err := c.srv.Start(); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Server listening on %s", c.srv.GetListenAddress()), nil
+	case "stop":
+		if err := c.srv.Stop(); err != nil {
+			return "", err
+		}
+		return "Server stopped", nil
+	case "sync":
+		c.srv.ForceUpstreamSync()
+		return "Upstream sync requested", nil
+	case "upstreams":
+		return c.upstreams(), nil
+	case "attacks":
+		return c.listAttacks(), nil
+	case "attack":
+		return c.attack(args)
+	case "presets":
+		return c.listPresets(), nil
+	case "preset":
+		return c.preset(strings.Join(args, " "))
+	case "sequences":
+		return c.listSequences(), nil
+	case "sequence":
+		return c.sequence(strings.Join(args, " "))
+	case "profiles":
+		return c.profiles(nil)
+	case "profile":
+		return c.profiles(args)
+	case "record":
+		return c.record(args)
+	case "replay":
+		return c.replay(args)
+	case "pcap":
+		if len(args) != 1 {
+			return "", fmt.Errorf("usage: pcap SESSION_ID")
+		}
+		path, err := session.ExportSessionPCAP(args[0])
+		if err != nil {
+			return "", err
+		}
+		c.log.Infof("EXPORT", "Exported session %s to %s", args[0], path)
+		return fmt.Sprintf("Exported to %s", path), nil
+	case "vectors":
+		if len(args) != 1 {
+			return "", fmt.Errorf("usage: vectors SESSION_ID")
+		}
+		path, err := session.ExportSessionVectors(args[0])
+		if err != nil {
+			return "", err
+		}
+		c.log.Infof("EXPORT", "Exported session %s vectors to %s", args[0], path)
+		return fmt.Sprintf("Exported to %s", path), nil
+	case "report":
+		return c.report(args)
+	case "diff":
+		return c.diff(args)
+	case "ops":
+		return c.listOps(), nil
+	case "cancel":
+		return c.cancel(args)
+	case "logs":
+		return c.logs(args)
+	default:
+		return "", fmt.Errorf("unknown command %q (try help)", cmd)
+	}
+}
+
+// capabilities describes this build, as text or as JSON
+func (c *Commands) capabilities(args []string) (string, error) {
+	switch {
+	case len(args) == 0:
+		return DescribeCapabilities(false)
+	case len(args) == 1 && strings.ToLower(args[0]) == "json":
+		return DescribeCapabilities(true)
+	}
+	return "", errors.New("usage: capabilities [json]")
+}
+
+// stats formats the server statistics
+func (c *Commands) stats() string {
+	st := c.srv.GetStats()
+	out := fmt.Sprintf("uptime %s\nrequests %d\nresponses %d\nerrors %d\nattacks %d\ncapped %d\nthrottled %d\ndropped %d\nskewed %d\nversion_refused %d\nmax_amplification %.1f\nclients %d",
+		st.Uptime.Round(time.Second), st.TotalRequests, st.TotalResponses, st.ErrorCount,
+		st.AttacksExecuted, st.CappedResponses, st.Throttled, st.Dropped, st.Skewed, st.VersionRefused, st.MaxAmplification, st.ActiveClients)
+	if st.Proxied > 0 || st.ProxyErrors > 0 {
+		out += fmt.Sprintf("\nproxied %d\nproxy_errors %d\nproxy_rtt %v", st.Proxied, st.ProxyErrors, st.ProxyRTT)
+	}
+	for mode, n := range st.Modes {
+		if n > 0 {
+			name := strings.ReplaceAll(strings.ToLower(ntpcore.ModeString(uint8(mode))), " ", "_")
+			out += fmt.Sprintf("\nmode %d %s %d", mode, name, n)
+		}
+	}
+	for _, r := range c.srv.GetKoDCompliance() {
+		out += fmt.Sprintf("\nkod %s %s backoff %v baseline %v after %d", r.Client, r.Verdict,
+			r.Backoff.Round(time.Second), r.Baseline.Round(time.Second), r.After)
+	}
+	for _, r := range c.srv.GetAttackEngine().GetRampResults() {
+		state := "unknown"
+		switch {
+		case r.Known && r.Following:
+			state = "following"
+		case r.Known:
+			state = "not_following"
+		}
+		out += fmt.Sprintf("\nramp %s %s offset %v requests %d %s", r.Client, r.Attack,
+			r.Offset.Round(time.Millisecond), r.Requests, state)
+		if r.Followed {
+			out += fmt.Sprintf(" last_followed %v", r.LastFollowed.Round(time.Millisecond))
+		}
+		if r.Broken {
+			out += fmt.Sprintf(" break %v", r.BreakAt.Round(time.Millisecond))
+		}
+	}
+	for _, m := range c.srv.GetAttackEngine().GetMixAssignments() {
+		out += fmt.Sprintf("\nmix %s %s requests %d", m.Client, m.Attack, m.Requests)
+	}
+	if j := st.Jitter; j.Count > 0 {
+		out += fmt.Sprintf("\njitter %d mean %v min %v max %v", j.Count,
+			j.Mean.Round(time.Microsecond), j.Min.Round(time.Microsecond), j.Max.Round(time.Microsecond))
+	}
+	for _, sk := range c.log.SinkStats() {
+		out += fmt.Sprintf("\nsink %s sent %d dropped %d failed %d", sk.Name, sk.Sent, sk.Dropped, sk.Failed)
+		if sk.LastError != "" {
+			out += fmt.Sprintf(" error %q", sk.LastError)
+		}
+	}
+	return out
+}
+
+// upstreams formats the health of each upstream server
+func (c *Commands) upstreams() string {
+	health := c.srv.GetUpstreamHealth()
+	if len(health) == 0 {
+		return "No upstream servers enabled"
+	}
+
+	var b strings.Builder
+	if st := c.srv.GetUpstreamStatus().Stability; st.Samples > 0 {
+		fmt.Fprintf(&b, "stability syncs %d mean %v stddev %v jitter %v min %v max %v\n", st.Samples,
+			st.Mean.Round(time.Microsecond), st.StdDev.Round(time.Microsecond), st.Jitter.Round(time.Microsecond),
+			st.Min.Round(time.Microsecond), st.Max.Round(time.Microsecond))
+	}
+	for _, h := range health {
+		last := "never"
+		if !h.LastSuccess.IsZero() {
+			last = h.LastSuccess.Format("15:04:05")
+		}
+		fmt.Fprintf(&b, "%s:%d %s rtt %v ok %d fail %d (%d in a row) last_ok %s",
+			h.Address, h.Port, h.Status(), h.RTT.Round(time.Millisecond), h.Successes, h.Failures,
+			h.ConsecutiveFailures, last)
+		if h.Pool != "" {
+			fmt.Fprintf(&b, " pool %s", h.Pool)
+		}
+		if h.Secure != "" {
+			fmt.Fprintf(&b, " secure %s", h.Secure)
+		}
+		if h.LastError != "" {
+			fmt.Fprintf(&b, " error %q", h.LastError)
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// listAttacks lists the available attacks and marks the active one
+func (c *Commands) listAttacks() string {
+	active, _ := c.srv.GetAttackEngine().DescribeActiveAttack()
+
+	var b strings.Builder
+	for _, info := range attacks.GetAvailableAttacks() {
+		marker := " "
+		if info.Type == active {
+			marker = "*"
+		}
+		fmt.Fprintf(&b, "%s %-16s %s\n", marker, info.Type, info.Name)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// attack enables an attack by type or alias, disables all with "off", or
+// pauses and resumes the active one
+func (c *Commands) attack(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("usage: attack NAME|off|pause|resume")
+	}
+	engine := c.srv.GetAttackEngine()
+	name := strings.ToLower(args[0])
+
+	switch name {
+	case "off", "none":
+		engine.DisableAllAttacks()
+		c.log.Info("ATTACK", "All attacks disabled")
+		return "All attacks disabled", nil
+	case "pause":
+		if err := engine.Pause(); err != nil {
+			return "", err
+		}
+		return "Attack paused", nil
+	case "resume":
+		if err := engine.Resume(); err != nil {
+			return "", err
+		}
+		return "Attack resumed", nil
+	}
+
+	attackType, ok := attackAliases[name]
+	if !ok {
+		attackType = attacks.AttackType(name)
+	}
+	info, err := engine.EnableAttack(attackType)
+	if err != nil {
+		return "", err
+	}
+	c.log.Infof("ATTACK", "Enabled attack: %s - %s", info.Name, info.Description)
+	return fmt.Sprintf("Enabled %s", info.Name), nil
+}
+
+// listPresets lists the configured attack presets
+func (c *Commands) listPresets() string {
+	var b strings.Builder
+	for _, p := range c.cfg.AttackPresets {
+		fmt.Fprintf(&b, "%-20s %s\n", p.Name, p.Description)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// preset applies a preset by (case-insensitive) name
+func (c *Commands) preset(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("usage: preset NAME")
+	}
+	for _, p := range c.cfg.AttackPresets {
+		if strings.EqualFold(p.Name, name) {
+			if err := c.srv.GetAttackEngine().ApplyPreset(p); err != nil {
+				return "", err
+			}
+			c.log.Infof("ATTACK", "Applied preset: %s", p.Name)
+			return fmt.Sprintf("Applied preset %s", p.Name), nil
+		}
+	}
+	return "", fmt.Errorf("unknown preset %q", name)
+}
+
+// listSequences lists the configured attack sequences
+func (c *Commands) listSequences() string {
+	if len(c.cfg.AttackSequences) == 0 {
+		return "No attack sequences configured"
+	}
+
+	var b strings.Builder
+	for _, seq := range c.cfg.AttackSequences {
+		total := 0
+		for _, step := range seq.Steps {
+			total += step.Duration
+		}
+		loop := ""
+		if seq.Loop {
+			loop = ", loops"
+		}
+		fmt.Fprintf(&b, "%-20s %d steps, %s%s\n", seq.Name, len(seq.Steps), time.Duration(total)*time.Second, loop)
+	}
+	if status := c.srv.GetAttackEngine().SequenceStatus(); status != "" {
+		fmt.Fprintf(&b, "Running: %s\n", status)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// sequence starts or stops an attack sequence
+func (c *Commands) sequence(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("usage: sequence NAME | sequence stop")
+	}
+	engine := c.srv.GetAttackEngine()
+
+	if strings.EqualFold(name, "stop") {
+		if !engine.StopSequence() {
+			return "No attack sequence running", nil
+		}
+		return "Attack sequence stopped", nil
+	}
+
+	seq, ok := c.cfg.GetAttackSequence(name)
+	if !ok {
+		return "", fmt.Errorf("unknown sequence %q", name)
+	}
+	if err := engine.StartSequence(seq); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Started sequence %s (%d steps)", seq.Name, len(seq.Steps)), nil
+}
+
+// profiles lists, saves or loads config profiles
+func (c *Commands) profiles(args []string) (string, error) {
+	if len(args) == 0 {
+		names, err := config.ListProfiles()
+		if err != nil {
+			return "", err
+		}
+		if len(names) == 0 {
+			return "No saved profiles", nil
+		}
+		return strings.Join(names, "\n"), nil
+	}
+	if len(args) != 2 {
+		return "", fmt.Errorf("usage: profile save|load NAME")
+	}
+
+	name := args[1]
+	switch strings.ToLower(args[0]) {
+	case "save":
+		if err := c.cfg.SaveProfile(name); err != nil {
+			return "", err
+		}
+		c.log.Infof("CONFIG", "Saved profile %s", name)
+		return fmt.Sprintf("Saved profile %s", name), nil
+	case "load":
+		cfg, err := config.LoadProfile(name)
+		if err != nil {
+			return "", err
+		}
+		if err := c.srv.Reload(cfg); err != nil {
+			return "", err
+		}
+		c.srv.UpdateConfig(c.cfg)
+		c.log.Infof("CONFIG", "Switched to profile %s", name)
+		return fmt.Sprintf("Switched to profile %s", name), nil
+	default:
+		return "", fmt.Errorf("usage: profile save|load NAME")
+	}
+}
+
+// record starts or stops session recording
+func (c *Commands) record(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("usage: record start [IP|CIDR ...] | record stop")
+	}
+	recorder := session.GetRecorder()
+
+	switch strings.ToLower(args[0]) {
+	case "start":
+		clients := args[1:]
+		if len(clients) == 0 {
+			clients = c.cfg.Logging.RecordClients
+		}
+		opts := session.RecordingOptions{Description: "Recording from command", Clients: clients}
+		if err := recorder.StartRecordingWithOptions(opts); err != nil {
+			return "", err
+		}
+		if len(clients) > 0 {
+			c.log.Infof("SESSION", "Recording started (clients: %s)", strings.Join(clients, ", "))
+			return fmt.Sprintf("Recording started for %s", strings.Join(clients, ", ")), nil
+		}
+		c.log.Info("SESSION", "Recording started")
+		return "Recording started", nil
+	case "stop":
+		sess, err := recorder.StopRecording()
+		if err != nil {
+			return "", err
+		}
+		c.log.Infof("SESSION", "Recording stopped, saved as %s", sess.ID)
+		return fmt.Sprintf("Saved %s (%d events)", sess.ID, len(sess.Events)), nil
+	default:
+		return "", fmt.Errorf("usage: record start [IP|CIDR ...] | record stop")
+	}
+}
+
+// replay starts replaying a saved session in the background
+func (c *Commands) replay(args []string) (string, error) {
+	usage := fmt.Errorf("usage: replay SESSION_ID|VECTOR_FILE HOST[:PORT] [SPEED] [dry] [now]")
+	if len(args) < 2 {
+		return "", usage
+	}
+
+	sess, err := session.LoadSource(args[0])
+	if err != nil {
+		return "", err
+	}
+
+	opts := session.ReplayOptions{PreserveTiming: true, Speed: 1, SkipUpstream: true}
+	for _, arg := range args[2:] {
+		if strings.EqualFold(arg, "dry") {
+			opts.DryRun = true
+			continue
+		}
+		if strings.EqualFold(arg, "now") {
+			opts.RewriteTimestamps = true
+			continue
+		}
+		speed, err := strconv.ParseFloat(strings.TrimSuffix(arg, "x"), 64)
+		if err != nil || speed <= 0 {
+			return "", usage
+		}
+		opts.Speed = speed
+	}
+
+	target := args[1]
+	go func() {
+		if err := session.NewReplayer().Replay(sess, target, opts); err != nil {
+			c.log.Errorf("SESSION", "Replay failed: %v", err)
+		}
+	}()
+	return fmt.Sprintf("Replaying %s to %s (see ops to cancel)", sess.ID, target), nil
+}
+
+// report writes a test report of the live log or a saved session
+func (c *Commands) report(args []string) (string, error) {
+	format := report.FormatMarkdown
+	opts := report.Options{
+		Config:  c.cfg,
+		Engine:  c.srv.GetAttackEngine(),
+		Entries: c.log.GetAllEntries(),
+		Version: BuildVersion,
+	}
+	for _, arg := range args {
+		switch arg {
+		case report.FormatMarkdown, report.FormatHTML:
+			format = arg
+		default:
+			if opts.Session != nil {
+				return "", fmt.Errorf("usage: report [md|html] [SESSION_ID]")
+			}
+			sess, err := session.LoadSession(arg)
+			if err != nil {
+				return "", err
+			}
+			opts.Session = sess
+		}
+	}
+
+	path, err := report.Write(opts, format, "")
+	if err != nil {
+		return "", err
+	}
+	c.log.Infof("EXPORT", "Wrote test report to %s", path)
+	return fmt.Sprintf("Report written to %s", path), nil
+}
+
+// diff compares two saved sessions or vector files
+func (c *Commands) diff(args []string) (string, error) {
+	if len(args) != 2 {
+		return "", fmt.Errorf("usage: diff SESSION_A SESSION_B")
+	}
+
+	a, err := session.LoadSource(args[0])
+	if err != nil {
+		return "", err
+	}
+	b, err := session.LoadSource(args[1])
+	if err != nil {
+		return "", err
+	}
+
+	report, err := session.Diff(a, b)
+	if err != nil {
+		return "", err
+	}
+	c.log.Infof("SESSION", "Compared %s with %s: %d packet differences", a.ID, b.ID, len(report.Packets))
+	return strings.TrimRight(report.String(), "\n"), nil
+}
+
+// listOps lists in-flight operations
+func (c *Commands) listOps() string {
+	running := ops.GetRegistry().List()
+	if len(running) == 0 {
+		return "No operations running"
+	}
+
+	var b strings.Builder
+	for _, op := range running {
+		state := ""
+		if op.Cancelling {
+			state = " (cancelling)"
+		}
+		fmt.Fprintf(&b, "#%d %s since %s%s\n", op.ID, op.Name, op.Started.Format("15:04:05"), state)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// cancel cancels an operation by ID
+func (c *Commands) cancel(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("usage: cancel ID")
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(args[0], "#"), 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid operation id %q", args[0])
+	}
+	if err := ops.GetRegistry().Cancel(id); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Cancelling operation #%d", id), nil
+}
+
+// logs returns the most recent log entries
+func (c *Commands) logs(args []string) (string, error) {
+	n := 10
+	if len(args) > 0 {
+		v, err := strconv.Atoi(args[0])
+		if err != nil || v <= 0 {
+			return "", fmt.Errorf("invalid count %q", args[0])
+		}
+		n = v
+	}
+
+	var b strings.Builder
+	for _, entry := range c.log.GetEntries(n) {
+		b.WriteString(logger.FormatEntryPlain(entry))
+		b.WriteString("\n")
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
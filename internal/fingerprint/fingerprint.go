@@ -0,0 +1,314 @@
+// Package fingerprint guesses the NTP client implementation behind a request
+// by matching packet features against a table of signatures
+package fingerprint
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// Transmit timestamp patterns
+const (
+	TxZero    = "zero"    // Transmit timestamp not set
+	TxSeconds = "seconds" // Whole seconds, no fraction
+	TxMillis  = "ms"      // Fraction at millisecond resolution
+	TxMicros  = "us"      // Fraction at microsecond resolution
+	TxFine    = "fine"    // Finer resolution or fuzzed low bits
+	TxRandom  = "random"  // Not near the current time (randomised cookie)
+)
+
+// Field weights; version, precision and timestamp resolution say the most
+// about the implementation
+var weights = struct {
+	version, mode, poll, precision, leap, stratum, tx float64
+}{2, 1, 1, 2, 1, 1, 2}
+
+// minConfidence is the lowest score reported as a candidate
+const minConfidence = 0.7
+
+// Signature describes the requests of one client implementation. Empty
+// lists match anything and do not count towards the score.
+type Signature struct {
+	Name       string   `yaml:"name"`
+	Versions   []int    `yaml:"versions,omitempty"`
+	Modes      []int    `yaml:"modes,omitempty"`
+	Polls      []int    `yaml:"polls,omitempty"`
+	Precisions []int    `yaml:"precisions,omitempty"`
+	Leap       []int    `yaml:"leap,omitempty"`
+	Stratum    []int    `yaml:"stratum,omitempty"`
+	TxPattern  []string `yaml:"tx_pattern,omitempty"` // zero, seconds, ms, us, fine, random
+}
+
+// Match is a candidate implementation with a confidence in [0, 1]
+type Match struct {
+	Name       string  `json:"name"`
+	Confidence float64 `json:"confidence"`
+}
+
+// String formats the match as "name (NN%)"
+func (m Match) String() string {
+	return fmt.Sprintf("%s (%.0f%%)", m.Name, m.Confidence*100)
+}
+
+// Features are the request properties signatures are matched against
+type Features struct {
+	Version   int
+	Mode      int
+	Poll      int
+	Precision int
+	Leap      int
+	Stratum   int
+	TxPattern string
+}
+
+// Database holds the signature table
+type Database struct {
+	mu   sync.RWMutex
+	sigs []Signature
+}
+
+var (
+	globalDB *Database
+	dbOnce   sync.Once
+)
+
+// GetDatabase returns the global signature database, seeded with the
+// built-in signatures
+func GetDatabase() *Database {
+	dbOnce.Do(func() {
+		globalDB = &Database{sigs: append([]Signature(nil), builtinSignatures...)}
+	})
+	return globalDB
+}
+
+// Signatures returns a copy of the signature table
+func (d *Database) Signatures() []Signature {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return append([]Signature(nil), d.sigs...)
+}
+
+// Add adds a signature, replacing any with the same name
+func (d *Database) Add(sig Signature) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range d.sigs {
+		if strings.EqualFold(d.sigs[i].Name, sig.Name) {
+			d.sigs[i] = sig
+			return
+		}
+	}
+	d.sigs = append(d.sigs, sig)
+}
+
+// LoadFile adds the signatures in a YAML file (a list of signatures, or a
+// map with a "signatures" list). Returns the number loaded.
+func (d *Database) LoadFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var file struct {
+		Signatures []Signature `yaml:"signatures"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		if err := yaml.Unmarshal(data, &file.Signatures); err != nil {
+			return 0, fmt.Errorf("invalid signature file %s: %w", path, err)
+		}
+	}
+
+	for i, sig := range file.Signatures {
+		if sig.Name == "" {
+			return i, fmt.Errorf("signature %d in %s has no name", i+1, path)
+		}
+		d.Add(sig)
+	}
+	return len(file.Signatures), nil
+}
+
+// Identify returns the candidate implementations for a request received at
+// rx, best first
+func (d *Database) Identify(p *ntpcore.NTPPacket, rx time.Time) []Match {
+	f := Extract(p, rx)
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var matches []Match
+	for _, sig := range d.sigs {
+		if c := sig.score(f); c >= minConfidence {
+			matches = append(matches, Match{Name: sig.Name, Confidence: c})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Confidence > matches[j].Confidence })
+	return matches
+}
+
+// Extract reads the matched features from a request
+func Extract(p *ntpcore.NTPPacket, rx time.Time) Features {
+	return Features{
+		Version:   int(p.Version),
+		Mode:      int(p.Mode),
+		Poll:      int(p.Poll),
+		Precision: int(p.Precision),
+		Leap:      int(p.LeapIndicator),
+		Stratum:   int(p.Stratum),
+		TxPattern: txPattern(p.XmitTimeSec, p.XmitTimeFrac, rx),
+	}
+}
+
+// score returns the weighted share of the signature's fields that match
+func (s Signature) score(f Features) float64 {
+	var total, matched float64
+	check := func(w float64, set []int, v int) {
+		if len(set) == 0 {
+			return
+		}
+		total += w
+		for _, x := range set {
+			if x == v {
+				matched += w
+				return
+			}
+		}
+	}
+	check(weights.version, s.Versions, f.Version)
+	check(weights.mode, s.Modes, f.Mode)
+	check(weights.poll, s.Polls, f.Poll)
+	check(weights.precision, s.Precisions, f.Precision)
+	check(weights.leap, s.Leap, f.Leap)
+	check(weights.stratum, s.Stratum, f.Stratum)
+	if len(s.TxPattern) > 0 {
+		total += weights.tx
+		for _, p := range s.TxPattern {
+			if p == f.TxPattern {
+				matched += weights.tx
+				break
+			}
+		}
+	}
+
+	if total == 0 {
+		return 0
+	}
+	return matched / total
+}
+
+// txPattern classifies the transmit timestamp by how it was produced
+func txPattern(sec, frac uint32, rx time.Time) string {
+	if sec == 0 && frac == 0 {
+		return TxZero
+	}
+	tx := ntpcore.NTPTimestampToTimeNear(ntpcore.NTPTimestamp{Seconds: sec, Fraction: frac}, rx)
+	if d := tx.Sub(rx); d > 24*time.Hour || d < -24*time.Hour {
+		return TxRandom
+	}
+	switch {
+	case frac == 0:
+		return TxSeconds
+	case onGrid(frac, 1e3):
+		return TxMillis
+	case onGrid(frac, 1e6):
+		return TxMicros
+	default:
+		return TxFine
+	}
+}
+
+// onGrid reports whether a fraction is a whole number of 1/perSec seconds,
+// allowing for the rounding of the conversion
+func onGrid(frac uint32, perSec float64) bool {
+	const scale = 1 << 32
+	units := math.Round(float64(frac) * perSec / scale)
+	return math.Abs(float64(frac)-units*scale/perSec) <= 16
+}
+
+// span returns the integers from lo to hi inclusive
+func span(lo, hi int) []int {
+	s := make([]int, 0, hi-lo+1)
+	for i := lo; i <= hi; i++ {
+		s = append(s, i)
+	}
+	return s
+}
+
+// builtinSignatures are typical request shapes of common clients. They are
+// heuristics: configuration and versions change what a client sends.
+var builtinSignatures = []Signature{
+	{
+		Name:       "ntpd",
+		Versions:   []int{4},
+		Modes:      []int{3},
+		Polls:      span(6, 10),
+		Precisions: span(-25, -18),
+		TxPattern:  []string{TxFine},
+	},
+	{
+		Name:      "chrony",
+		Versions:  []int{4},
+		Modes:     []int{3},
+		Polls:     span(6, 10),
+		TxPattern: []string{TxRandom},
+	},
+	{
+		Name:       "systemd-timesyncd",
+		Versions:   []int{4},
+		Modes:      []int{3},
+		Polls:      []int{0},
+		Precisions: []int{0},
+		Stratum:    []int{0},
+		TxPattern:  []string{TxFine, TxMicros},
+	},
+	{
+		Name:      "Windows W32Time",
+		Versions:  []int{3},
+		Modes:     []int{3},
+		Polls:     span(6, 17),
+		Leap:      []int{0, 3},
+		TxPattern: []string{TxFine},
+	},
+	{
+		Name:       "BusyBox ntpd",
+		Versions:   []int{4},
+		Modes:      []int{3},
+		Polls:      span(0, 6),
+		Precisions: span(-9, -6),
+		TxPattern:  []string{TxMicros, TxFine},
+	},
+	{
+		Name:       "ESP32/lwIP SNTP",
+		Versions:   []int{4},
+		Modes:      []int{3},
+		Polls:      []int{0},
+		Precisions: []int{0},
+		Leap:       []int{0},
+		Stratum:    []int{0},
+		TxPattern:  []string{TxZero, TxMicros, TxSeconds},
+	},
+	{
+		Name:       "Android SntpClient",
+		Versions:   []int{3},
+		Modes:      []int{3},
+		Polls:      []int{0},
+		Precisions: []int{0},
+		Leap:       []int{0},
+		TxPattern:  []string{TxMillis},
+	},
+	{
+		Name:      "macOS sntp",
+		Versions:  []int{4},
+		Modes:     []int{3},
+		Polls:     []int{10},
+		TxPattern: []string{TxFine, TxMicros},
+	},
+}
@@ -0,0 +1,176 @@
+// Package kodcheck measures whether clients back off after a RATE
+// Kiss-of-Death, as RFC 5905 requires
+package kodcheck
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MinBackoff is the shortest silence after a RATE KoD that counts as backing
+// off: the minimum NTP poll interval (2^4 seconds)
+const MinBackoff = 16 * time.Second
+
+// maxIntervals is how many recent query intervals form a client's baseline
+const maxIntervals = 8
+
+// Compliance verdicts
+const (
+	Pending  = "pending"  // Silent so far, but not yet for long enough
+	Complied = "complied" // Backed off
+	Ignored  = "ignored"  // Queried again too soon
+)
+
+// Result is the measured reaction of one client to its RATE KoD
+type Result struct {
+	Client   string        `json:"client"`
+	KoDAt    time.Time     `json:"kod_at"`
+	Baseline time.Duration `json:"baseline"`       // Mean query interval before the KoD (0 = unknown)
+	Required time.Duration `json:"required"`       // Silence needed to comply
+	Backoff  time.Duration `json:"backoff"`        // Silence after the KoD (so far, while pending)
+	After    int           `json:"requests_after"` // Requests since the KoD
+	Verdict  string        `json:"verdict"`
+}
+
+// client is the query history of one client
+type client struct {
+	last      time.Time
+	intervals []time.Duration // Recent intervals before the KoD
+	kodAt     time.Time       // First RATE KoD sent (zero = none)
+	nextAt    time.Time       // First request after the KoD
+	after     int
+}
+
+// Tracker correlates client query times with the RATE KoDs sent to them.
+// Only the first KoD per client is measured.
+type Tracker struct {
+	mu      sync.Mutex
+	clients map[string]*client
+}
+
+// NewTracker creates an empty tracker
+func NewTracker() *Tracker {
+	return &Tracker{clients: make(map[string]*client)}
+}
+
+// Request records a query from a client IP
+func (t *Tracker) Request(ip string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c := t.clients[ip]
+	if c == nil {
+		t.clients[ip] = &client{last: at}
+		return
+	}
+
+	if c.kodAt.IsZero() {
+		if gap := at.Sub(c.last); gap > 0 {
+			c.intervals = append(c.intervals, gap)
+			if len(c.intervals) > maxIntervals {
+				c.intervals = c.intervals[1:]
+			}
+		}
+	} else {
+		if c.nextAt.IsZero() {
+			c.nextAt = at
+		}
+		c.after++
+	}
+	c.last = at
+}
+
+// KoD records a RATE KoD sent to a client in answer to its last request
+func (t *Tracker) KoD(ip string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c := t.clients[ip]
+	if c == nil {
+		c = &client{last: at}
+		t.clients[ip] = c
+	}
+	if c.kodAt.IsZero() {
+		c.kodAt = at
+	}
+}
+
+// Results returns the verdicts of all clients sent a KoD, oldest first
+func (t *Tracker) Results(now time.Time) []Result {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var results []Result
+	for ip, c := range t.clients {
+		if c.kodAt.IsZero() {
+			continue
+		}
+		results = append(results, c.result(ip, now))
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if !results[i].KoDAt.Equal(results[j].KoDAt) {
+			return results[i].KoDAt.Before(results[j].KoDAt)
+		}
+		return results[i].Client < results[j].Client
+	})
+	return results
+}
+
+// result judges a client that was sent a KoD. A client complies when its
+// next query comes at least twice its old interval (and MinBackoff) later.
+func (c *client) result(ip string, now time.Time) Result {
+	r := Result{Client: ip, KoDAt: c.kodAt, After: c.after, Required: MinBackoff}
+
+	if len(c.intervals) > 0 {
+		var total time.Duration
+		for _, d := range c.intervals {
+			total += d
+		}
+		r.Baseline = total / time.Duration(len(c.intervals))
+		r.Required = max(2*r.Baseline, MinBackoff)
+	}
+
+	if c.nextAt.IsZero() {
+		r.Backoff = now.Sub(c.kodAt)
+	} else {
+		r.Backoff = c.nextAt.Sub(c.kodAt)
+	}
+
+	switch {
+	case r.Backoff >= r.Required:
+		r.Verdict = Complied
+	case c.nextAt.IsZero():
+		r.Verdict = Pending
+	default:
+		r.Verdict = Ignored
+	}
+	return r
+}
+
+// Prune forgets clients that are no longer active and were never sent a
+// KoD. Clients that were are kept: going quiet is what they should do.
+func (t *Tracker) Prune(active map[string]time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for ip, c := range t.clients {
+		if _, ok := active[ip]; !ok && c.kodAt.IsZero() {
+			delete(t.clients, ip)
+		}
+	}
+}
+
+// Summary counts the results per verdict
+func Summary(results []Result) (complied, ignored, pending int) {
+	for _, r := range results {
+		switch r.Verdict {
+		case Complied:
+			complied++
+		case Ignored:
+			ignored++
+		default:
+			pending++
+		}
+	}
+	return complied, ignored, pending
+}
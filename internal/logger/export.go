@@ -0,0 +1,172 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+)
+
+// Log export formats, also used as the file extension
+const (
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+// ExportFormats lists the log export formats
+var ExportFormats = []string{FormatJSON, FormatNDJSON, FormatCSV}
+
+// ExportDir returns the default export directory, .timehammer/exports
+func ExportDir() (string, error) {
+	dataDir, err := config.GetDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, config.ExportDirName), nil
+}
+
+// exportNamed writes the logs to a file in the exports directory
+func (l *Logger) exportNamed(filename, format string) error {
+	dir, err := ExportDir()
+	if err != nil {
+		return err
+	}
+	return l.ExportTo(filepath.Join(dir, filename), format)
+}
+
+// ExportTo writes the logs to path in one of ExportFormats, creating
+// missing parent directories
+func (l *Logger) ExportTo(path, format string) error {
+	var write func(io.Writer, []LogEntry) error
+	switch format {
+	case FormatJSON:
+		write = writeJSON
+	case FormatNDJSON:
+		write = writeNDJSON
+	case FormatCSV:
+		write = writeCSV
+	default:
+		return fmt.Errorf("unknown export format %q (want %s)", format, strings.Join(ExportFormats, ", "))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	l.mu.RLock()
+	err = write(f, l.entries)
+	l.mu.RUnlock()
+
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ExportLogs writes the logs in each format (all of them if none) and
+// returns the files written. dest may be a directory, which gets
+// logs_<time>.<format> files, or a file whose extension is replaced by each
+// format's when more than one is written. An empty dest means the exports
+// directory; a relative one is taken from the working directory.
+func (l *Logger) ExportLogs(dest string, formats []string) ([]string, error) {
+	if len(formats) == 0 {
+		formats = ExportFormats
+	}
+	if dest == "" {
+		var err error
+		if dest, err = ExportDir(); err != nil {
+			return nil, err
+		}
+	}
+
+	paths := exportPaths(dest, formats, time.Now())
+	for i, path := range paths {
+		if err := l.ExportTo(path, formats[i]); err != nil {
+			return paths[:i], fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return paths, nil
+}
+
+// exportPaths returns the file to write for each format
+func exportPaths(dest string, formats []string, now time.Time) []string {
+	paths := make([]string, len(formats))
+	if info, err := os.Stat(dest); (err == nil && info.IsDir()) || strings.HasSuffix(dest, string(filepath.Separator)) {
+		stamp := now.Format("20060102_150405")
+		for i, format := range formats {
+			paths[i] = filepath.Join(dest, fmt.Sprintf("logs_%s.%s", stamp, format))
+		}
+		return paths
+	}
+
+	if len(formats) == 1 {
+		paths[0] = dest
+		return paths
+	}
+	base := strings.TrimSuffix(dest, filepath.Ext(dest))
+	for i, format := range formats {
+		paths[i] = base + "." + format
+	}
+	return paths
+}
+
+// writeJSON writes entries as one indented JSON array
+func writeJSON(w io.Writer, entries []LogEntry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// writeCSV writes entries as CSV with a header row
+func writeCSV(w io.Writer, entries []LogEntry) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("Timestamp,Level,Category,Message,ClientIP,ClientPort,UpstreamIP,Attack,ClientVersion,ClientMode,Authenticated,KeyID,MACStatus\n")
+
+	for _, entry := range entries {
+		clientVersion := ""
+		clientMode := ""
+		authenticated, keyID, macStatus := "", "", ""
+		if fp := entry.Fingerprint; fp != nil {
+			clientVersion = fmt.Sprintf("%d", fp.Version)
+			clientMode = fp.ModeString
+			authenticated = strconv.FormatBool(fp.Authenticated)
+			if fp.Authenticated {
+				keyID = strconv.FormatUint(uint64(fp.KeyID), 10)
+				macStatus = fp.MACStatus
+			}
+		}
+
+		fmt.Fprintf(bw, "%s,%s,%s,\"%s\",%s,%d,%s,%s,%s,%s,%s,%s,%s\n",
+			entry.Timestamp.Format(time.RFC3339),
+			entry.LevelStr,
+			entry.Category,
+			entry.Message,
+			entry.ClientIP,
+			entry.ClientPort,
+			entry.UpstreamIP,
+			entry.Attack,
+			clientVersion,
+			clientMode,
+			authenticated,
+			keyID,
+			macStatus,
+		)
+	}
+	return bw.Flush()
+}
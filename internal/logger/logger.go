@@ -4,7 +4,6 @@ package logger
 import (
 	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
 	"sync"
 	"time"
@@ -77,6 +76,13 @@ type ClientFingerprint struct {
 	Poll           int    `json:"poll"`
 	Precision      int    `json:"precision"`
 	PossibleClient string `json:"possible_client,omitempty"`
+
+	Confidence float64  `json:"confidence,omitempty"` // Confidence of PossibleClient (0-1)
+	Candidates []string `json:"candidates,omitempty"` // Other plausible clients, best first
+
+	Authenticated bool   `json:"authenticated,omitempty"` // Request carried a key ID and MAC
+	KeyID         uint32 `json:"key_id,omitempty"`
+	MACStatus     string `json:"mac_status,omitempty"` // verified, bad_mac, unknown_key, crypto_nak or unchecked (no keys)
 }
 
 // Logger is the main logger instance
@@ -86,8 +92,9 @@ type Logger struct {
 	maxEntries  int
 	level       LogLevel
 	logToFile   bool
-	fileHandle  *os.File
+	fileHandle  *rotatingFile
 	subscribers []chan LogEntry
+	sinks       []*sink
 }
 
 // Global logger instance
@@ -122,21 +129,30 @@ func (l *Logger) Initialize(cfg *config.Config) error {
 			return err
 		}
 		logPath := filepath.Join(dataDir, config.LogFileName)
-		f, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		f, err := openRotatingFile(logPath, cfg.Logging.MaxSizeMB, cfg.Logging.MaxBackups,
+			cfg.Logging.MaxAgeDays, cfg.Logging.CompressBackups)
 		if err != nil {
 			return fmt.Errorf("failed to open log file: %w", err)
 		}
 		l.fileHandle = f
 	}
 
+	old := l.sinks
+	l.sinks = newSinks(cfg.Logging.Sinks)
+	go stopSinks(old)
 	return nil
 }
 
-// Close closes the logger
-func (l *Logger) Close() {
+// SetLevel changes the minimum level of entries that are kept
+func (l *Logger) SetLevel(level string) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	l.level = parseLevel(level)
+}
 
+// Close closes the logger, flushing remote sinks
+func (l *Logger) Close() {
+	l.mu.Lock()
 	if l.fileHandle != nil {
 		l.fileHandle.Close()
 	}
@@ -144,6 +160,12 @@ func (l *Logger) Close() {
 	for _, ch := range l.subscribers {
 		close(ch)
 	}
+
+	sinks := l.sinks
+	l.sinks = nil
+	l.mu.Unlock()
+
+	stopSinks(sinks)
 }
 
 // Subscribe returns a channel that receives new log entries
@@ -184,7 +206,14 @@ func (l *Logger) log(level LogLevel, category, message string, extra map[string]
 		Extra:     extra,
 	}
 
+	l.publish(entry)
+}
+
+// publish stores an entry and hands it to the file, subscribers and sinks
+func (l *Logger) publish(entry LogEntry) {
 	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	// Add to in-memory buffer
 	l.entries = append(l.entries, entry)
 	if len(l.entries) > l.maxEntries {
@@ -205,7 +234,11 @@ func (l *Logger) log(level LogLevel, category, message string, extra map[string]
 			// Channel full, skip
 		}
 	}
-	l.mu.Unlock()
+
+	// Remote sinks buffer and drop the same way
+	for _, sk := range l.sinks {
+		sk.enqueue(entry)
+	}
 }
 
 // Debug logs a debug message
@@ -255,31 +288,29 @@ func (l *Logger) LogClientRequest(clientIP string, clientPort int, fp *ClientFin
 		Level:       LevelInfo,
 		LevelStr:    LevelInfo.String(),
 		Category:    "CLIENT",
-		Message:     fmt.Sprintf("Request from %s:%d", clientIP, clientPort),
+		Message:     fmt.Sprintf("Request from %s:%d%s", clientIP, clientPort, describeAuth(fp)),
 		ClientIP:    clientIP,
 		ClientPort:  clientPort,
 		Fingerprint: fp,
 		Attack:      attack,
 	}
 
-	l.mu.Lock()
-	l.entries = append(l.entries, entry)
-	if len(l.entries) > l.maxEntries {
-		l.entries = l.entries[1:]
-	}
-
-	if l.fileHandle != nil {
-		jsonLine, _ := json.Marshal(entry)
-		l.fileHandle.Write(append(jsonLine, '\n'))
-	}
+	l.publish(entry)
+}
 
-	for _, ch := range l.subscribers {
-		select {
-		case ch <- entry:
-		default:
-		}
-	}
-	l.mu.Unlock()
+// describeAuth notes the authentication of a request for its log message
+func describeAuth(fp *ClientFingerprint) string {
+	if fp == nil || !fp.Authenticated {
+		return ""
+	}
+	status := map[string]string{
+		"verified":    "verified",
+		"bad_mac":     "failed",
+		"unknown_key": "unknown key",
+		"crypto_nak":  "crypto-NAK",
+		"unchecked":   "not checked",
+	}[fp.MACStatus]
+	return fmt.Sprintf(" (authenticated, key %d, MAC %s)", fp.KeyID, orDefault(status, fp.MACStatus))
 }
 
 // LogUpstreamRequest logs an upstream NTP query
@@ -305,24 +336,7 @@ func (l *Logger) LogUpstreamRequest(upstreamIP string, success bool, rtt time.Du
 		},
 	}
 
-	l.mu.Lock()
-	l.entries = append(l.entries, entry)
-	if len(l.entries) > l.maxEntries {
-		l.entries = l.entries[1:]
-	}
-
-	if l.fileHandle != nil {
-		jsonLine, _ := json.Marshal(entry)
-		l.fileHandle.Write(append(jsonLine, '\n'))
-	}
-
-	for _, ch := range l.subscribers {
-		select {
-		case ch <- entry:
-		default:
-		}
-	}
-	l.mu.Unlock()
+	l.publish(entry)
 }
 
 // LogAttack logs a security attack being executed
@@ -337,24 +351,7 @@ func (l *Logger) LogAttack(attackType, target, details string) {
 		ClientIP:  target,
 	}
 
-	l.mu.Lock()
-	l.entries = append(l.entries, entry)
-	if len(l.entries) > l.maxEntries {
-		l.entries = l.entries[1:]
-	}
-
-	if l.fileHandle != nil {
-		jsonLine, _ := json.Marshal(entry)
-		l.fileHandle.Write(append(jsonLine, '\n'))
-	}
-
-	for _, ch := range l.subscribers {
-		select {
-		case ch <- entry:
-		default:
-		}
-	}
-	l.mu.Unlock()
+	l.publish(entry)
 }
 
 // GetEntries returns recent log entries
@@ -393,69 +390,14 @@ func (l *Logger) ClearEntries() {
 	l.entries = make([]LogEntry, 0)
 }
 
-// ExportJSON exports logs to a JSON file
+// ExportJSON exports logs to a JSON file in the exports directory
 func (l *Logger) ExportJSON(filename string) error {
-	l.mu.RLock()
-	defer l.mu.RUnlock()
-
-	dataDir, err := config.GetDataDir()
-	if err != nil {
-		return err
-	}
-
-	exportPath := filepath.Join(dataDir, config.ExportDirName, filename)
-	data, err := json.MarshalIndent(l.entries, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	return os.WriteFile(exportPath, data, 0644)
+	return l.exportNamed(filename, FormatJSON)
 }
 
-// ExportCSV exports logs to a CSV file
+// ExportCSV exports logs to a CSV file in the exports directory
 func (l *Logger) ExportCSV(filename string) error {
-	l.mu.RLock()
-	defer l.mu.RUnlock()
-
-	dataDir, err := config.GetDataDir()
-	if err != nil {
-		return err
-	}
-
-	exportPath := filepath.Join(dataDir, config.ExportDirName, filename)
-	f, err := os.Create(exportPath)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	// Write header
-	f.WriteString("Timestamp,Level,Category,Message,ClientIP,ClientPort,UpstreamIP,Attack,ClientVersion,ClientMode\n")
-
-	for _, entry := range l.entries {
-		clientVersion := ""
-		clientMode := ""
-		if entry.Fingerprint != nil {
-			clientVersion = fmt.Sprintf("%d", entry.Fingerprint.Version)
-			clientMode = entry.Fingerprint.ModeString
-		}
-
-		line := fmt.Sprintf("%s,%s,%s,\"%s\",%s,%d,%s,%s,%s,%s\n",
-			entry.Timestamp.Format(time.RFC3339),
-			entry.LevelStr,
-			entry.Category,
-			entry.Message,
-			entry.ClientIP,
-			entry.ClientPort,
-			entry.UpstreamIP,
-			entry.Attack,
-			clientVersion,
-			clientMode,
-		)
-		f.WriteString(line)
-	}
-
-	return nil
+	return l.exportNamed(filename, FormatCSV)
 }
 
 // parseLevel parses a string log level
@@ -492,3 +434,11 @@ func FormatEntryPlain(entry LogEntry) string {
 	return fmt.Sprintf("%s [%s] [%s] %s",
 		timestamp, entry.LevelStr, entry.Category, entry.Message)
 }
+
+// orDefault returns s, or def if s is empty
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
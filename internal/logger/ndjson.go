@@ -0,0 +1,86 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// ExportNDJSON exports logs to a file in the exports directory with one
+// JSON object per line, writing straight from the buffer instead of
+// building one large array
+func (l *Logger) ExportNDJSON(filename string) error {
+	return l.exportNamed(filename, FormatNDJSON)
+}
+
+// writeNDJSON writes entries as newline-delimited JSON
+func writeNDJSON(w io.Writer, entries []LogEntry) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadNDJSON reads log entries written one JSON object per line, such as
+// the log file. Lines that do not parse are skipped.
+func ReadNDJSON(path string) ([]LogEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []LogEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry LogEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// StreamTo writes every new log entry to w as one JSON line until the
+// returned stop function is called or a write fails. Entries are fed by a
+// subscriber goroutine, so like other subscribers a slow writer misses
+// entries rather than blocking logging.
+func (l *Logger) StreamTo(w io.Writer) (stop func()) {
+	ch := l.Subscribe()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer l.Unsubscribe(ch)
+
+		enc := json.NewEncoder(w)
+		for {
+			select {
+			case entry, ok := <-ch:
+				if !ok {
+					return
+				}
+				if err := enc.Encode(entry); err != nil {
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		wg.Wait()
+	}
+}
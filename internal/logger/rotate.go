@@ -0,0 +1,190 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rotatingFile is an append-only log file that rolls over to path.1,
+// path.2, ... (optionally gzipped) once it reaches maxSize
+type rotatingFile struct {
+	path       string
+	f          *os.File
+	size       int64
+	maxSize    int64         // Bytes (0 = never rotate)
+	maxBackups int           // Rotated files to keep (0 = all)
+	maxAge     time.Duration // Delete rotated files older than this (0 = never)
+	compress   bool
+}
+
+// openRotatingFile opens path for appending and prunes expired backups
+func openRotatingFile(path string, maxSizeMB, maxBackups, maxAgeDays int, compress bool) (*rotatingFile, error) {
+	r := &rotatingFile{
+		path:       path,
+		maxSize:    int64(maxSizeMB) * 1024 * 1024,
+		maxBackups: maxBackups,
+		maxAge:     time.Duration(maxAgeDays) * 24 * time.Hour,
+		compress:   compress,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	r.prune()
+	return r, nil
+}
+
+// open (re)opens the active file and records its current size
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f = f
+	r.size = info.Size()
+	return nil
+}
+
+// Write appends p, rotating first if it would push the file past maxSize
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	if r.f == nil {
+		return 0, os.ErrClosed
+	}
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			// Keep logging to whatever we have rather than losing entries
+			fmt.Fprintf(os.Stderr, "log rotation failed: %v\n", err)
+		}
+		if r.f == nil {
+			return 0, os.ErrClosed
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the active file
+func (r *rotatingFile) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+// rotate shifts the backups up by one, moves the active file to path.1 and
+// starts a fresh file
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	r.f = nil
+
+	// Find the highest existing backup so nothing is overwritten
+	last := 0
+	for r.backupPath(last+1) != "" {
+		last++
+	}
+	if r.maxBackups > 0 {
+		for ; last >= r.maxBackups; last-- {
+			os.Remove(r.backupPath(last))
+		}
+	}
+	for i := last; i >= 1; i-- {
+		old := r.backupPath(i)
+		os.Rename(old, r.numbered(i+1)+strings.TrimPrefix(old, r.numbered(i)))
+	}
+
+	if err := os.Rename(r.path, r.numbered(1)); err != nil {
+		r.open()
+		return err
+	}
+	if r.compress {
+		if err := gzipFile(r.numbered(1)); err != nil {
+			fmt.Fprintf(os.Stderr, "log compression failed: %v\n", err)
+		}
+	}
+
+	r.prune()
+	return r.open()
+}
+
+// prune deletes backups older than maxAge
+func (r *rotatingFile) prune() {
+	if r.maxAge <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-r.maxAge)
+	for i := 1; ; i++ {
+		path := r.backupPath(i)
+		if path == "" {
+			return
+		}
+		if info, err := os.Stat(path); err == nil && info.ModTime().Before(cutoff) {
+			os.Remove(path)
+		}
+	}
+}
+
+// numbered returns the uncompressed name of backup i
+func (r *rotatingFile) numbered(i int) string {
+	return r.path + "." + strconv.Itoa(i)
+}
+
+// backupPath returns the existing file for backup i, compressed or not,
+// or "" if there is none
+func (r *rotatingFile) backupPath(i int) string {
+	for _, p := range []string{r.numbered(i), r.numbered(i) + ".gz"} {
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+// gzipFile compresses path to path.gz and removes the original
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := path + ".gz.tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	zw.Name = filepath.Base(path)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
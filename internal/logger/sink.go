@@ -0,0 +1,287 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+)
+
+// Sink tuning
+const (
+	sinkQueueSize     = 1000            // Entries buffered per sink before dropping
+	sinkFlushInterval = 2 * time.Second // Longest a partial webhook batch waits
+	sinkTimeout       = 5 * time.Second // Dial, write and HTTP timeout
+	defaultBatchSize  = 50
+
+	syslogFacility = 16 // local0
+)
+
+// SinkStatus reports the delivery counters of one remote sink
+type SinkStatus struct {
+	Name      string `json:"name"`
+	Sent      uint64 `json:"sent"`
+	Dropped   uint64 `json:"dropped"` // Queue full
+	Failed    uint64 `json:"failed"`  // Delivery errors
+	LastError string `json:"last_error,omitempty"`
+}
+
+// sinkWriter delivers batches of entries to a remote destination
+type sinkWriter interface {
+	write(entries []LogEntry) error
+	close()
+}
+
+// sink feeds a writer from a buffered queue on its own goroutine, so a slow
+// or dead destination never blocks logging
+type sink struct {
+	name     string
+	minLevel LogLevel
+	batch    int
+	queue    chan LogEntry
+	done     chan struct{}
+	writer   sinkWriter
+
+	sent    atomic.Uint64
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+
+	mu      sync.Mutex
+	lastErr string
+}
+
+// newSinks starts a sink for each configured destination
+func newSinks(cfgs []config.LogSink) []*sink {
+	var sinks []*sink
+	for _, c := range cfgs {
+		s := &sink{
+			name:  c.Type + " " + c.Target,
+			batch: 1,
+			queue: make(chan LogEntry, sinkQueueSize),
+			done:  make(chan struct{}),
+		}
+		if c.Level != "" {
+			s.minLevel = parseLevel(c.Level)
+		}
+
+		switch c.Type {
+		case "syslog":
+			network := c.Network
+			if network == "" {
+				network = "udp"
+			}
+			s.writer = newSyslogWriter(network, c.Target)
+		case "webhook":
+			s.writer = &webhookWriter{url: c.Target, client: &http.Client{Timeout: sinkTimeout}}
+			s.batch = c.BatchSize
+			if s.batch <= 0 {
+				s.batch = defaultBatchSize
+			}
+		default:
+			continue
+		}
+
+		go s.run()
+		sinks = append(sinks, s)
+	}
+	return sinks
+}
+
+// stopSinks flushes and stops sinks
+func stopSinks(sinks []*sink) {
+	for _, s := range sinks {
+		close(s.queue)
+	}
+	for _, s := range sinks {
+		<-s.done
+	}
+}
+
+// SetSinks replaces the remote sinks. Old sinks flush in the background.
+func (l *Logger) SetSinks(cfgs []config.LogSink) {
+	l.mu.Lock()
+	old := l.sinks
+	l.sinks = newSinks(cfgs)
+	l.mu.Unlock()
+
+	go stopSinks(old)
+}
+
+// SinkStats returns the delivery counters of each remote sink
+func (l *Logger) SinkStats() []SinkStatus {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	out := make([]SinkStatus, 0, len(l.sinks))
+	for _, s := range l.sinks {
+		s.mu.Lock()
+		lastErr := s.lastErr
+		s.mu.Unlock()
+		out = append(out, SinkStatus{
+			Name:      s.name,
+			Sent:      s.sent.Load(),
+			Dropped:   s.dropped.Load(),
+			Failed:    s.failed.Load(),
+			LastError: lastErr,
+		})
+	}
+	return out
+}
+
+// enqueue queues an entry without blocking, counting it if the queue is full
+func (s *sink) enqueue(entry LogEntry) {
+	if entry.Level < s.minLevel {
+		return
+	}
+	select {
+	case s.queue <- entry:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// run delivers queued entries in batches until the queue is closed
+func (s *sink) run() {
+	defer close(s.done)
+	defer s.writer.close()
+
+	batch := make([]LogEntry, 0, s.batch)
+	timer := time.NewTimer(sinkFlushInterval)
+	timer.Stop()
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.writer.write(batch); err != nil {
+			s.failed.Add(uint64(len(batch)))
+			s.mu.Lock()
+			s.lastErr = err.Error()
+			s.mu.Unlock()
+		} else {
+			s.sent.Add(uint64(len(batch)))
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case entry, ok := <-s.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, entry)
+			if len(batch) >= s.batch {
+				flush()
+			} else if len(batch) == 1 {
+				timer.Reset(sinkFlushInterval)
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+// syslogWriter sends RFC 5424 messages over UDP, or TCP with octet-counting
+// framing (RFC 6587). TCP connections are redialed after a failure.
+type syslogWriter struct {
+	network  string
+	addr     string
+	hostname string
+	pid      int
+	conn     net.Conn
+}
+
+// newSyslogWriter creates a syslog writer; the connection is dialed lazily
+func newSyslogWriter(network, addr string) *syslogWriter {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	return &syslogWriter{network: network, addr: addr, hostname: hostname, pid: os.Getpid()}
+}
+
+func (w *syslogWriter) write(entries []LogEntry) error {
+	for _, entry := range entries {
+		if w.conn == nil {
+			conn, err := net.DialTimeout(w.network, w.addr, sinkTimeout)
+			if err != nil {
+				return err
+			}
+			w.conn = conn
+		}
+
+		msg := formatSyslog(entry, w.hostname, w.pid)
+		if w.network == "tcp" {
+			msg = fmt.Sprintf("%d %s", len(msg), msg)
+		}
+		w.conn.SetWriteDeadline(time.Now().Add(sinkTimeout))
+		if _, err := w.conn.Write([]byte(msg)); err != nil {
+			w.conn.Close()
+			w.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *syslogWriter) close() {
+	if w.conn != nil {
+		w.conn.Close()
+	}
+}
+
+// formatSyslog formats an entry as an RFC 5424 message with the category
+// as MSGID
+func formatSyslog(entry LogEntry, hostname string, pid int) string {
+	severity := 6 // Informational
+	switch entry.Level {
+	case LevelDebug:
+		severity = 7
+	case LevelWarn:
+		severity = 4
+	case LevelError:
+		severity = 3
+	}
+
+	msgID := entry.Category
+	if msgID == "" {
+		msgID = "-"
+	}
+	return fmt.Sprintf("<%d>1 %s %s timehammer %d %s - %s",
+		syslogFacility*8+severity,
+		entry.Timestamp.Format("2006-01-02T15:04:05.000000Z07:00"),
+		hostname, pid, msgID, entry.Message)
+}
+
+// webhookWriter POSTs batches as a JSON array
+type webhookWriter struct {
+	url    string
+	client *http.Client
+}
+
+func (w *webhookWriter) write(entries []LogEntry) error {
+	body, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (w *webhookWriter) close() {}
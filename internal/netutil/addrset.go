@@ -0,0 +1,96 @@
+// Package netutil provides small network helpers shared across packages
+package netutil
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// AddrSet matches IP addresses against a list of single IPs and CIDR ranges
+type AddrSet struct {
+	specs []string
+	nets  []*net.IPNet
+}
+
+// ParseAddrSet builds a set from entries like "192.168.1.50" or "10.0.0.0/24".
+// An empty list yields an empty set that matches everything.
+func ParseAddrSet(specs []string) (*AddrSet, error) {
+	set := &AddrSet{}
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		if strings.Contains(spec, "/") {
+			_, ipNet, err := net.ParseCIDR(spec)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q: %w", spec, err)
+			}
+			set.nets = append(set.nets, ipNet)
+		} else {
+			ip := net.ParseIP(spec)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", spec)
+			}
+			bits := 128
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			set.nets = append(set.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		}
+		set.specs = append(set.specs, spec)
+	}
+	return set, nil
+}
+
+// Empty reports whether the set has no entries
+func (s *AddrSet) Empty() bool {
+	return s == nil || len(s.nets) == 0
+}
+
+// Contains reports whether ip is in the set. An empty set matches everything.
+func (s *AddrSet) Contains(ip net.IP) bool {
+	if s.Empty() {
+		return true
+	}
+	if ip == nil {
+		return false
+	}
+	for _, n := range s.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsAddr is like Contains for an address string with or without a port
+func (s *AddrSet) ContainsAddr(addr string) bool {
+	if s.Empty() {
+		return true
+	}
+	return s.Contains(net.ParseIP(Host(addr)))
+}
+
+// Host strips the port, if any, from an address string
+func Host(addr string) string {
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		return h
+	}
+	return addr
+}
+
+// Specs returns the entries the set was built from
+func (s *AddrSet) Specs() []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string(nil), s.specs...)
+}
+
+// String returns a comma-separated list of the entries
+func (s *AddrSet) String() string {
+	return strings.Join(s.Specs(), ",")
+}
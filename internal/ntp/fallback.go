@@ -0,0 +1,84 @@
+package ntp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/clock"
+)
+
+// Fallback modes (upstream.fallback_mode), used while no upstream is synchronized
+const (
+	FallbackHost     = "host"      // Serve the host clock
+	FallbackManual   = "manual"    // Serve fallback_time, running from when sync was lost
+	FallbackLastGood = "last_good" // Keep applying the last upstream offset
+	FallbackRefuse   = "refuse"    // Answer with leap alarm and stratum 16
+)
+
+// SourceUpstream is the time source while synchronized
+const SourceUpstream = "upstream"
+
+// manualStratum is claimed while serving the manual base time, like a local
+// clock driver
+const manualStratum = 10
+
+// fallbackMode returns the configured fallback, or "" while synchronized.
+// Last-good needs a previous sync and is host time until there is one.
+// Caller must hold c.mu.
+func (c *UpstreamClient) fallbackMode() string {
+	if c.syncStatus.Synchronized {
+		return ""
+	}
+	switch mode := c.cfg.Upstream.FallbackMode; mode {
+	case FallbackManual, FallbackRefuse:
+		return mode
+	case FallbackLastGood:
+		if !c.lastSync.IsZero() {
+			return mode
+		}
+	}
+	return FallbackHost
+}
+
+// manualTime returns the manual base time advanced by the time spent
+// unsynchronized. Caller must hold c.mu.
+func (c *UpstreamClient) manualTime() time.Time {
+	base, err := time.Parse(time.RFC3339, c.cfg.Upstream.FallbackTime)
+	if err != nil {
+		return c.clock.Now()
+	}
+	return base.Add(clock.Since(c.clock, c.unsyncedSince))
+}
+
+// TimeSource reports what currently feeds responses ("upstream" or a
+// fallback mode) and a short human-readable detail
+func (c *UpstreamClient) TimeSource() (string, string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	switch mode := c.fallbackMode(); mode {
+	case "":
+		return SourceUpstream, c.syncStatus.ActiveServer
+	case FallbackManual:
+		return mode, "manual time " + c.manualTime().UTC().Format(time.RFC3339)
+	case FallbackLastGood:
+		return mode, fmt.Sprintf("last good offset %v from %s (%s ago)", c.clockOffset,
+			c.syncStatus.ActiveServer, clock.Since(c.clock, c.lastSync).Round(time.Second))
+	case FallbackRefuse:
+		return mode, "refusing to serve time (leap alarm, stratum 16)"
+	default:
+		return FallbackHost, "host clock"
+	}
+}
+
+// Refusing reports whether responses should be flagged unsynchronized
+// (leap alarm) because the fallback mode is refuse
+func (c *UpstreamClient) Refusing() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.fallbackMode() == FallbackRefuse
+}
+
+// localClockRefID is the reference ID of the manual fallback ("LOCL")
+var localClockRefID = binary.BigEndian.Uint32([]byte("LOCL"))
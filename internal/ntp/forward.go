@@ -0,0 +1,89 @@
+package ntp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+)
+
+// maxReply bounds a relayed upstream answer (header, extensions and MAC)
+const maxReply = 1024
+
+// Forward sends a client's request unchanged to an upstream server and
+// returns the server's answer, for proxying. target is host:port; empty
+// means the active upstream server. Answers that do not echo the request's
+// transmit timestamp as their origin are ignored until the timeout.
+// Returns the answer, the address it came from and the round trip time.
+func (c *UpstreamClient) Forward(request []byte, target string) ([]byte, string, time.Duration, error) {
+	if len(request) < 48 {
+		return nil, "", 0, errors.New("request shorter than an NTP header")
+	}
+
+	c.mu.RLock()
+	timeout := time.Duration(c.cfg.Upstream.Timeout) * time.Second
+	active := c.syncStatus.ActiveServer
+	c.mu.RUnlock()
+
+	if target == "" {
+		if active == "" {
+			return nil, "", 0, errors.New("no active upstream server to relay to")
+		}
+		server := c.activeServer(active)
+		if server.Secure != "" {
+			return nil, "", 0, fmt.Errorf("active upstream %s is an %s time source; set server.proxy.upstream", active, server.Secure)
+		}
+		target = net.JoinHostPort(active, strconv.Itoa(server.Port))
+	}
+
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("invalid upstream %q: %w", target, err)
+	}
+	ip, err := c.resolver.resolve(host)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	conn, err := net.Dial("udp", net.JoinHostPort(ip.String(), port))
+	if err != nil {
+		return nil, "", 0, err
+	}
+	defer conn.Close()
+
+	sent := time.Now()
+	if err := conn.SetDeadline(sent.Add(timeout)); err != nil {
+		return nil, "", 0, err
+	}
+	if _, err := conn.Write(request); err != nil {
+		return nil, "", 0, err
+	}
+
+	buf := make([]byte, maxReply)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, "", 0, fmt.Errorf("no answer from %s: %w", target, err)
+		}
+		// The origin timestamp of the answer echoes our transmit timestamp
+		if n >= 48 && bytes.Equal(buf[24:32], request[40:48]) {
+			return append([]byte(nil), buf[:n]...), target, time.Since(sent), nil
+		}
+	}
+}
+
+// activeServer returns the configured upstream server of an address, with
+// the NTP port if it is not configured
+func (c *UpstreamClient) activeServer(address string) config.UpstreamServer {
+	servers, _ := c.expandPools(c.cfg.GetActiveUpstreams(), false)
+	for _, s := range servers {
+		if s.Address == address && s.Port != 0 {
+			return s
+		}
+	}
+	return config.UpstreamServer{Address: address, Port: 123}
+}
@@ -0,0 +1,150 @@
+package ntp
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+)
+
+// Health tracking tuning
+const (
+	healthDemoteAfter = 3    // Consecutive failures before a server is demoted
+	healthProbeEvery  = 5    // A demoted server is still queried every Nth sync
+	healthRTTWeight   = 0.25 // Weight of a new sample in the RTT average
+)
+
+// ServerHealth is the long-running health of one upstream server
+type ServerHealth struct {
+	Address             string        `json:"address"`
+	Port                int           `json:"port"`
+	Pool                string        `json:"pool,omitempty"`   // Pool hostname Address was resolved from
+	Secure              string        `json:"secure,omitempty"` // Secure transport ("https"), if any
+	Successes           int           `json:"successes"`
+	Failures            int           `json:"failures"`
+	ConsecutiveFailures int           `json:"consecutive_failures"`
+	LastSuccess         time.Time     `json:"last_success"`
+	LastError           string        `json:"last_error,omitempty"`
+	RTT                 time.Duration `json:"rtt"`     // Moving average of successful queries
+	Demoted             bool          `json:"demoted"` // Only probed occasionally
+}
+
+// Status returns a short state word for display
+func (h ServerHealth) Status() string {
+	switch {
+	case h.Demoted:
+		return "demoted"
+	case h.ConsecutiveFailures > 0:
+		return "failing"
+	case h.Successes == 0:
+		return "unknown"
+	default:
+		return "ok"
+	}
+}
+
+// healthTracker remembers how each upstream server has behaved across syncs
+type healthTracker struct {
+	mu      sync.Mutex
+	servers map[string]*ServerHealth
+	syncs   int
+}
+
+// newHealthTracker creates an empty health tracker
+func newHealthTracker() *healthTracker {
+	return &healthTracker{servers: make(map[string]*ServerHealth)}
+}
+
+// healthKey identifies a server by address and port
+func healthKey(server config.UpstreamServer) string {
+	return fmt.Sprintf("%s:%d", server.Address, server.Port)
+}
+
+// entry returns the health of a server, creating it if needed. Callers
+// hold t.mu.
+func (t *healthTracker) entry(server config.UpstreamServer) *ServerHealth {
+	key := healthKey(server)
+	h, ok := t.servers[key]
+	if !ok {
+		h = &ServerHealth{Address: server.Address, Port: server.Port, Secure: server.Secure}
+		t.servers[key] = h
+	}
+	return h
+}
+
+// candidates returns the servers to query this sync: healthy servers first
+// in priority order, then demoted servers that are due a probe. If every
+// server is demoted they are all queried so sync can recover.
+func (t *healthTracker) candidates(servers []config.UpstreamServer) []config.UpstreamServer {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.syncs++
+	probe := t.syncs%healthProbeEvery == 0
+
+	var healthy, demoted []config.UpstreamServer
+	for _, s := range servers {
+		if t.entry(s).Demoted {
+			demoted = append(demoted, s)
+		} else {
+			healthy = append(healthy, s)
+		}
+	}
+	if len(healthy) == 0 || probe {
+		return append(healthy, demoted...)
+	}
+	return healthy
+}
+
+// record updates the health of the queried servers from their samples and
+// returns the servers that were demoted or recovered
+func (t *healthTracker) record(servers []config.UpstreamServer, samples []*ServerSample, now time.Time) (demoted, recovered []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, s := range samples {
+		h := t.entry(servers[i])
+		if s.OK {
+			h.Successes++
+			h.ConsecutiveFailures = 0
+			h.LastSuccess = now
+			h.LastError = ""
+			if h.RTT == 0 {
+				h.RTT = s.RTT
+			} else {
+				h.RTT += time.Duration(healthRTTWeight * float64(s.RTT-h.RTT))
+			}
+			if h.Demoted {
+				h.Demoted = false
+				recovered = append(recovered, healthKey(servers[i]))
+			}
+			continue
+		}
+
+		h.Failures++
+		h.ConsecutiveFailures++
+		h.LastError = s.Error
+		if !h.Demoted && h.ConsecutiveFailures >= healthDemoteAfter {
+			h.Demoted = true
+			demoted = append(demoted, healthKey(servers[i]))
+		}
+	}
+	return demoted, recovered
+}
+
+// snapshot returns the health of the given servers in their order
+func (t *healthTracker) snapshot(servers []config.UpstreamServer) []ServerHealth {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make([]ServerHealth, 0, len(servers))
+	for _, s := range servers {
+		if h, ok := t.servers[healthKey(s)]; ok {
+			out = append(out, *h)
+		} else {
+			out = append(out, ServerHealth{Address: s.Address, Port: s.Port})
+		}
+	}
+	return out
+}
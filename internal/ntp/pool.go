@@ -0,0 +1,57 @@
+package ntp
+
+import "github.com/neutrinoguy/timehammer/internal/config"
+
+// expandPools replaces each pool server with one server per resolved
+// address, up to the configured pool size, so every address is queried and
+// health-tracked on its own. With lookup false only cached addresses are
+// used (no DNS traffic). A pool that cannot be resolved is kept as a
+// hostname so the failure is reported. Returns the servers and the pool
+// hostname of each expanded address.
+func (c *UpstreamClient) expandPools(servers []config.UpstreamServer, lookup bool) ([]config.UpstreamServer, map[string]string) {
+	size := c.cfg.Upstream.PoolSize
+	if size < 1 {
+		size = 1
+	}
+
+	var out []config.UpstreamServer
+	pools := make(map[string]string)
+	seen := make(map[string]bool)
+	for _, s := range servers {
+		if !s.Pool {
+			if !seen[healthKey(s)] {
+				seen[healthKey(s)] = true
+				out = append(out, s)
+			}
+			continue
+		}
+
+		// The resolver logs failures and keeps the last good addresses
+		ips := c.resolver.cachedAll(s.Address)
+		if lookup {
+			ips, _ = c.resolver.resolveAll(s.Address)
+		}
+		if len(ips) == 0 {
+			out = append(out, s)
+			continue
+		}
+
+		taken := 0
+		for _, ip := range ips {
+			if taken == size {
+				break
+			}
+			member := s
+			member.Address = ip.String()
+			member.Pool = false
+			if seen[healthKey(member)] {
+				continue
+			}
+			seen[healthKey(member)] = true
+			pools[member.Address] = s.Address
+			out = append(out, member)
+			taken++
+		}
+	}
+	return out, pools
+}
@@ -0,0 +1,243 @@
+package ntp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/clock"
+	"github.com/neutrinoguy/timehammer/internal/logger"
+)
+
+// ErrResolve indicates that an upstream hostname could not be resolved
+var ErrResolve = errors.New("upstream resolution failed")
+
+// ResolveError wraps a DNS failure for a specific upstream host
+type ResolveError struct {
+	Host string
+	Err  error
+}
+
+func (e *ResolveError) Error() string {
+	return fmt.Sprintf("resolve %s: %v", e.Host, e.Err)
+}
+
+// Is makes errors.Is(err, ErrResolve) match any ResolveError
+func (e *ResolveError) Is(target error) bool {
+	return target == ErrResolve
+}
+
+// Unwrap returns the underlying lookup error
+func (e *ResolveError) Unwrap() error {
+	return e.Err
+}
+
+// Resolution cache tuning
+const (
+	resolveTTL         = 5 * time.Minute
+	resolveBackoffBase = 5 * time.Second
+	resolveBackoffMax  = 5 * time.Minute
+)
+
+// ResolveInfo is the resolution state of one upstream host
+type ResolveInfo struct {
+	IP         string    `json:"ip,omitempty"`  // Preferred address
+	IPs        []string  `json:"ips,omitempty"` // Every address of the host
+	ResolvedAt time.Time `json:"resolved_at"`   // Last successful resolution
+	Failures   int       `json:"failures"`      // Consecutive failures
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+// resolveEntry is the cached state for one host
+type resolveEntry struct {
+	ips         []net.IP // Preferred address first
+	resolvedAt  time.Time
+	failures    int
+	nextAttempt time.Time
+	lastErr     error
+}
+
+// resolver resolves upstream hostnames with caching and failure backoff
+type resolver struct {
+	mu      sync.Mutex
+	log     *logger.Logger
+	clock   clock.Clock
+	entries map[string]*resolveEntry
+	lookup  func(host string) ([]net.IP, error)
+}
+
+// newResolver creates a resolver using the system DNS
+func newResolver() *resolver {
+	return &resolver{
+		log:     logger.GetLogger(),
+		clock:   clock.Real{},
+		entries: make(map[string]*resolveEntry),
+		lookup:  net.LookupIP,
+	}
+}
+
+// setClock replaces the time source the cache ages and backoffs use
+func (r *resolver) setClock(c clock.Clock) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clock = c
+}
+
+// resolve returns the preferred IP for host (IPv4 if it has one)
+func (r *resolver) resolve(host string) (net.IP, error) {
+	ips, err := r.resolveAll(host)
+	if err != nil {
+		return nil, err
+	}
+	return ips[0], nil
+}
+
+// resolveAll returns every IP of host, preferred first, using the cache
+// while it is fresh and falling back to the last known addresses while
+// lookups are failing
+func (r *resolver) resolveAll(host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.clock.Now()
+	entry, ok := r.entries[host]
+	if !ok {
+		entry = &resolveEntry{}
+		r.entries[host] = entry
+	}
+
+	if len(entry.ips) > 0 && entry.failures == 0 && now.Sub(entry.resolvedAt) < resolveTTL {
+		return entry.ips, nil
+	}
+
+	// Still backing off from earlier failures
+	if now.Before(entry.nextAttempt) {
+		if len(entry.ips) > 0 {
+			return entry.ips, nil
+		}
+		return nil, &ResolveError{Host: host, Err: entry.lastErr}
+	}
+
+	ips, err := r.lookupPreferV4(host)
+	if err != nil {
+		entry.failures++
+		entry.lastErr = err
+		entry.nextAttempt = now.Add(resolveBackoff(entry.failures))
+
+		if entry.failures == 1 {
+			r.log.Warnf("UPSTREAM", "DNS resolution failed for %s: %v (backing off)", host, err)
+		} else {
+			r.log.Debugf("UPSTREAM", "DNS resolution for %s still failing (%d attempts)", host, entry.failures)
+		}
+
+		if len(entry.ips) > 0 {
+			return entry.ips, nil
+		}
+		return nil, &ResolveError{Host: host, Err: err}
+	}
+
+	if entry.failures > 0 {
+		r.log.Infof("UPSTREAM", "DNS resolution for %s recovered after %d failures", host, entry.failures)
+	}
+	entry.ips = ips
+	entry.resolvedAt = now
+	entry.failures = 0
+	entry.lastErr = nil
+	entry.nextAttempt = time.Time{}
+	return ips, nil
+}
+
+// cached returns the last resolved IP for host without performing a lookup
+func (r *resolver) cached(host string) net.IP {
+	if ips := r.cachedAll(host); len(ips) > 0 {
+		return ips[0]
+	}
+	return nil
+}
+
+// cachedAll returns the last resolved IPs for host without a lookup
+func (r *resolver) cachedAll(host string) []net.IP {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if entry, ok := r.entries[host]; ok {
+		return entry.ips
+	}
+	return nil
+}
+
+// status returns the resolution state of every host seen so far
+func (r *resolver) status() map[string]ResolveInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make(map[string]ResolveInfo, len(r.entries))
+	for host, entry := range r.entries {
+		info := ResolveInfo{
+			ResolvedAt: entry.resolvedAt,
+			Failures:   entry.failures,
+		}
+		for _, ip := range entry.ips {
+			info.IPs = append(info.IPs, ip.String())
+		}
+		if len(info.IPs) > 0 {
+			info.IP = info.IPs[0]
+		}
+		if entry.lastErr != nil {
+			info.LastError = entry.lastErr.Error()
+		}
+		out[host] = info
+	}
+	return out
+}
+
+// lookupPreferV4 resolves host to its distinct addresses, IPv4 first
+func (r *resolver) lookupPreferV4(host string) ([]net.IP, error) {
+	ips, err := r.lookup(host)
+	if err != nil {
+		return nil, err
+	}
+
+	var v4, v6 []net.IP
+	seen := make(map[string]bool)
+	for _, ip := range ips {
+		if ipv4 := ip.To4(); ipv4 != nil {
+			ip = ipv4
+		}
+		if seen[ip.String()] {
+			continue
+		}
+		seen[ip.String()] = true
+		if len(ip) == net.IPv4len {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+	if len(v4)+len(v6) == 0 {
+		return nil, fmt.Errorf("no addresses found")
+	}
+	return append(v4, v6...), nil
+}
+
+// resolveBackoff returns the wait before the next lookup after n failures
+func resolveBackoff(failures int) time.Duration {
+	d := resolveBackoffBase
+	for i := 1; i < failures && d < resolveBackoffMax; i++ {
+		d *= 2
+	}
+	if d > resolveBackoffMax {
+		d = resolveBackoffMax
+	}
+	return d
+}
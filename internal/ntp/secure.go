@@ -0,0 +1,106 @@
+package ntp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"time"
+
+	"github.com/beevik/ntp"
+	"github.com/neutrinoguy/timehammer/internal/config"
+)
+
+// httpsResolution is the resolution of an HTTP Date header. The server's
+// time lies somewhere in the second it names.
+const httpsResolution = time.Second
+
+// httpsStratum is the stratum assumed for an HTTPS time source, like a
+// web server synced from public NTP
+const httpsStratum = 2
+
+// queryHTTPS reads the Date header of an HTTPS server as a time source
+// that TLS protects on the way. The offset is taken at the middle of the
+// second the header names against the middle of the request's round trip,
+// so its error bound is half a second plus half the round trip.
+func (c *UpstreamClient) queryHTTPS(server config.UpstreamServer) (*ntp.Response, error) {
+	ip, err := c.resolver.resolve(server.Address)
+	if err != nil {
+		return nil, err
+	}
+	addr := net.JoinHostPort(ip.String(), strconv.Itoa(server.Port))
+	timeout := time.Duration(c.cfg.Upstream.Timeout) * time.Second
+
+	// Dial the resolved address; TLS still verifies the configured name
+	dialer := &net.Dialer{Timeout: timeout}
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+			TLSClientConfig:   &tls.Config{ServerName: server.Address, MinVersion: tls.VersionTLS12},
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	url := "https://" + net.JoinHostPort(server.Address, strconv.Itoa(server.Port)) + "/"
+
+	var lastErr error
+	for i := 0; i < c.cfg.Upstream.Retries; i++ {
+		response, err := queryDateHeader(client, url)
+		if err == nil {
+			return response, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// queryDateHeader sends one HEAD request and measures the offset to its
+// Date header. The round trip runs from writing the request to the first
+// response byte, leaving out the connection and TLS setup.
+func queryDateHeader(client *http.Client, url string) (*ntp.Response, error) {
+	var sent, received time.Time
+	trace := &httptrace.ClientTrace{
+		WroteRequest:         func(httptrace.WroteRequestInfo) { sent = time.Now() },
+		GotFirstResponseByte: func() { received = time.Now() },
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodHead, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	header := resp.Header.Get("Date")
+	if header == "" {
+		return nil, errors.New("no Date header in HTTPS response")
+	}
+	date, err := http.ParseTime(header)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Date header %q: %w", header, err)
+	}
+	if sent.IsZero() || received.IsZero() {
+		return nil, errors.New("HTTPS round trip not measured")
+	}
+
+	rtt := received.Sub(sent)
+	local := sent.Add(rtt / 2)
+	return &ntp.Response{
+		Time:         date,
+		ClockOffset:  date.Add(httpsResolution / 2).Sub(local),
+		RTT:          rtt,
+		Stratum:      httpsStratum,
+		RootDistance: httpsResolution/2 + rtt/2,
+		Precision:    httpsResolution,
+	}, nil
+}
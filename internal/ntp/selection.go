@@ -0,0 +1,153 @@
+package ntp
+
+import (
+	"sort"
+	"time"
+)
+
+// minDistance keeps weights finite for servers reporting no error bound
+const minDistance = time.Millisecond
+
+// ServerSample is the result of querying one upstream server during a sync
+type ServerSample struct {
+	Address  string        `json:"address"`
+	Pool     string        `json:"pool,omitempty"` // Pool hostname Address was resolved from
+	OK       bool          `json:"ok"`
+	Stratum  int           `json:"stratum,omitempty"`
+	Offset   time.Duration `json:"offset,omitempty"`
+	RTT      time.Duration `json:"rtt,omitempty"`
+	Distance time.Duration `json:"distance,omitempty"` // Root distance, the error bound of Offset
+	Agreed   bool          `json:"agreed"`             // Survived falseticker selection
+	Secure   bool          `json:"secure,omitempty"`   // Reached over a secure transport
+	Error    string        `json:"error,omitempty"`
+}
+
+// distance returns the sample's error bound with a floor
+func (s *ServerSample) distance() time.Duration {
+	if s.Distance < minDistance {
+		return minDistance
+	}
+	return s.Distance
+}
+
+// selectTruechimers marks the samples that agree on the time. Each answer
+// is treated as the interval offset +/- root distance; the region covered
+// by the most intervals (Marzullo's algorithm, as in NTP's selection) wins
+// and every interval containing it is a truechimer. Without a majority the
+// median sample alone is used. Returns the number of agreeing samples.
+func selectTruechimers(samples []*ServerSample) int {
+	var ok []*ServerSample
+	for _, s := range samples {
+		s.Agreed = false
+		if s.OK {
+			ok = append(ok, s)
+		}
+	}
+	ok = secureAnchored(ok)
+	if len(ok) == 0 {
+		return 0
+	}
+
+	type edge struct {
+		at    time.Duration
+		start bool
+	}
+	edges := make([]edge, 0, 2*len(ok))
+	for _, s := range ok {
+		d := s.distance()
+		edges = append(edges, edge{s.Offset - d, true}, edge{s.Offset + d, false})
+	}
+	// Starts sort before ends at the same point so touching intervals overlap
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].at != edges[j].at {
+			return edges[i].at < edges[j].at
+		}
+		return edges[i].start && !edges[j].start
+	})
+
+	best, count := 0, 0
+	var lo, hi time.Duration
+	for i, e := range edges {
+		if e.start {
+			count++
+			if count > best {
+				best = count
+				lo, hi = e.at, edges[i+1].at
+			}
+		} else {
+			count--
+		}
+	}
+
+	if best*2 <= len(ok) && len(ok) > 1 {
+		// No majority: trust only the median answer
+		sort.Slice(ok, func(i, j int) bool { return ok[i].Offset < ok[j].Offset })
+		median := ok[(len(ok)-1)/2]
+		if len(ok)%2 == 0 && ok[len(ok)/2].distance() < median.distance() {
+			median = ok[len(ok)/2]
+		}
+		median.Agreed = true
+		return 1
+	}
+
+	mid := lo + (hi-lo)/2
+	agreed := 0
+	for _, s := range ok {
+		d := s.distance()
+		if s.Offset-d <= mid && mid <= s.Offset+d {
+			s.Agreed = true
+			agreed++
+		}
+	}
+	return agreed
+}
+
+// secureAnchored drops the answers that contradict every secure source, so
+// servers reached over plain NTP, which anyone on the path can spoof,
+// cannot outvote the time a secure source vouches for
+func secureAnchored(ok []*ServerSample) []*ServerSample {
+	var secure []*ServerSample
+	for _, s := range ok {
+		if s.Secure {
+			secure = append(secure, s)
+		}
+	}
+	if len(secure) == 0 {
+		return ok
+	}
+
+	var kept []*ServerSample
+	for _, s := range ok {
+		for _, anchor := range secure {
+			if s.Offset-s.distance() <= anchor.Offset+anchor.distance() &&
+				anchor.Offset-anchor.distance() <= s.Offset+s.distance() {
+				kept = append(kept, s)
+				break
+			}
+		}
+	}
+	return kept
+}
+
+// combineOffsets returns the distance-weighted mean offset of the agreeing
+// samples and the agreeing sample with the smallest distance
+func combineOffsets(samples []*ServerSample) (time.Duration, *ServerSample) {
+	var sum, weights float64
+	var peer *ServerSample
+	for _, s := range samples {
+		if !s.Agreed {
+			continue
+		}
+		w := 1 / s.distance().Seconds()
+		sum += w * float64(s.Offset)
+		weights += w
+		if peer == nil || s.distance() < peer.distance() ||
+			(s.distance() == peer.distance() && s.Stratum < peer.Stratum) {
+			peer = s
+		}
+	}
+	if weights == 0 {
+		return 0, nil
+	}
+	return time.Duration(sum / weights), peer
+}
@@ -0,0 +1,67 @@
+package ntp
+
+import (
+	"math"
+	"time"
+)
+
+// OffsetStability summarizes the combined upstream offsets of the last
+// syncs. A ground truth that moves by more than an attack's effect makes
+// the attack's results hard to read.
+type OffsetStability struct {
+	Samples int           `json:"samples"` // Syncs in the window
+	Mean    time.Duration `json:"mean"`
+	StdDev  time.Duration `json:"stddev"`
+	Jitter  time.Duration `json:"jitter"` // RMS of the change between consecutive syncs
+	Min     time.Duration `json:"min"`
+	Max     time.Duration `json:"max"`
+}
+
+// Range returns the spread between the lowest and highest offset
+func (s OffsetStability) Range() time.Duration {
+	return s.Max - s.Min
+}
+
+// offsetWindow keeps the offsets of the last syncs, oldest first
+type offsetWindow struct {
+	offsets []time.Duration
+}
+
+// add records the offset of a sync, keeping at most size offsets
+func (w *offsetWindow) add(offset time.Duration, size int) {
+	w.offsets = append(w.offsets, offset)
+	if size > 0 && len(w.offsets) > size {
+		w.offsets = append(w.offsets[:0], w.offsets[len(w.offsets)-size:]...)
+	}
+}
+
+// stability computes the statistics of the window
+func (w *offsetWindow) stability() OffsetStability {
+	n := len(w.offsets)
+	if n == 0 {
+		return OffsetStability{}
+	}
+
+	s := OffsetStability{Samples: n, Min: w.offsets[0], Max: w.offsets[0]}
+	var sum, sqDiff, jitterSum float64
+	for i, o := range w.offsets {
+		sum += float64(o)
+		s.Min, s.Max = min(s.Min, o), max(s.Max, o)
+		if i > 0 {
+			d := float64(o - w.offsets[i-1])
+			jitterSum += d * d
+		}
+	}
+	mean := sum / float64(n)
+	for _, o := range w.offsets {
+		d := float64(o) - mean
+		sqDiff += d * d
+	}
+
+	s.Mean = time.Duration(mean)
+	if n > 1 {
+		s.StdDev = time.Duration(math.Sqrt(sqDiff / float64(n-1)))
+		s.Jitter = time.Duration(math.Sqrt(jitterSum / float64(n-1)))
+	}
+	return s
+}
@@ -2,14 +2,20 @@
 package ntp
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/beevik/ntp"
+	"github.com/neutrinoguy/timehammer/internal/clock"
 	"github.com/neutrinoguy/timehammer/internal/config"
 	"github.com/neutrinoguy/timehammer/internal/logger"
+	"github.com/neutrinoguy/timehammer/internal/ops"
 )
 
 // UpstreamClient manages connections to upstream NTP servers
@@ -17,12 +23,22 @@ type UpstreamClient struct {
 	mu          sync.RWMutex
 	cfg         *config.Config
 	log         *logger.Logger
+	clock       clock.Clock
 	currentTime time.Time
 	clockOffset time.Duration
 	lastSync    time.Time
 	syncStatus  SyncStatus
 	stopChan    chan struct{}
 	wg          sync.WaitGroup
+	resolver    *resolver
+	health      *healthTracker
+
+	unsyncedSince time.Time // When sync was last lost (start of the manual fallback clock)
+
+	offsets offsetWindow // Combined offsets of the last syncs
+
+	// Callbacks invoked on sync state transitions
+	syncListeners []func(old, new SyncStatus)
 }
 
 // SyncStatus represents the upstream sync status
@@ -34,38 +50,77 @@ type SyncStatus struct {
 	RTT          time.Duration `json:"rtt"`
 	LastSync     time.Time     `json:"last_sync"`
 	LastError    string        `json:"last_error,omitempty"`
+
+	// Per-server results of the last sync; Agreed marks the servers whose
+	// offsets were combined
+	Servers []ServerSample `json:"servers,omitempty"`
+
+	// Spread of the combined offset over the last syncs
+	Stability OffsetStability `json:"stability"`
+}
+
+// AgreedServers returns the servers that agreed in the last sync
+func (s SyncStatus) AgreedServers() []string {
+	var agreed []string
+	for _, srv := range s.Servers {
+		if srv.Agreed {
+			agreed = append(agreed, srv.Address)
+		}
+	}
+	return agreed
 }
 
 // NewUpstreamClient creates a new upstream NTP client
 func NewUpstreamClient(cfg *config.Config) *UpstreamClient {
-	return &UpstreamClient{
+	c := &UpstreamClient{
 		cfg:      cfg,
 		log:      logger.GetLogger(),
+		clock:    clock.Real{},
 		stopChan: make(chan struct{}),
+		resolver: newResolver(),
+		health:   newHealthTracker(),
 		syncStatus: SyncStatus{
 			Synchronized: false,
 		},
 	}
+	c.unsyncedSince = c.clock.Now()
+	return c
+}
+
+// SetClock replaces the local time source the synced time is derived from
+func (c *UpstreamClient) SetClock(clk clock.Clock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clock = clk
+	c.unsyncedSince = clk.Now()
+	c.resolver.setClock(clk)
 }
 
 // Start begins the upstream sync loop
 func (c *UpstreamClient) Start() {
+	c.mu.Lock()
+	c.stopChan = make(chan struct{})
+	stop := c.stopChan
+	c.mu.Unlock()
+
 	c.wg.Add(1)
-	go c.syncLoop()
+	go c.syncLoop(stop)
 }
 
 // Stop stops the upstream sync
 func (c *UpstreamClient) Stop() {
+	c.mu.Lock()
 	close(c.stopChan)
+	c.mu.Unlock()
 	c.wg.Wait()
 }
 
 // syncLoop runs the periodic sync
-func (c *UpstreamClient) syncLoop() {
+func (c *UpstreamClient) syncLoop(stop chan struct{}) {
 	defer c.wg.Done()
 
 	// Initial sync
-	c.syncNow()
+	c.syncNow(context.Background())
 
 	interval := time.Duration(c.cfg.Upstream.SyncInterval) * time.Second
 	ticker := time.NewTicker(interval)
@@ -74,70 +129,198 @@ func (c *UpstreamClient) syncLoop() {
 	for {
 		select {
 		case <-ticker.C:
-			c.syncNow()
-		case <-c.stopChan:
+			c.syncNow(context.Background())
+		case <-stop:
 			return
 		}
 	}
 }
 
-// syncNow performs an immediate sync with upstream servers
-func (c *UpstreamClient) syncNow() {
+// syncNow performs an immediate sync with upstream servers.
+// Cancelling ctx abandons the attempt between servers without touching the status.
+func (c *UpstreamClient) syncNow(ctx context.Context) {
 	servers := c.cfg.GetActiveUpstreams()
+
+	// A pinned server replaces the list entirely so nothing else can substitute
+	pinned, isPinned := c.cfg.GetPinnedUpstream()
+	if isPinned {
+		servers = []config.UpstreamServer{pinned}
+	}
+
 	if len(servers) == 0 {
 		c.log.Warn("UPSTREAM", "No upstream servers configured")
-		c.mu.Lock()
-		c.syncStatus.Synchronized = false
-		c.syncStatus.LastError = "No upstream servers configured"
-		c.mu.Unlock()
+		c.setSyncStatus(func(st *SyncStatus) {
+			st.Synchronized = false
+			st.LastError = "No upstream servers configured"
+		})
 		return
 	}
 
-	// Try servers in order of priority
-	for _, server := range servers {
-		addr := fmt.Sprintf("%s:%d", server.Address, server.Port)
+	// Pools become one server per address, and persistently failing
+	// servers are only probed now and then
+	var pools map[string]string
+	if !isPinned {
+		servers, pools = c.expandPools(servers, true)
+		servers = c.health.candidates(servers)
+	}
 
-		c.log.Debugf("UPSTREAM", "Querying upstream server: %s", addr)
+	// Query all servers at once so one bad server cannot skew the result
+	samples := c.queryAll(servers)
+	for _, s := range samples {
+		s.Pool = pools[s.Address]
+	}
+	demoted, recovered := c.health.record(servers, samples, c.clock.Now())
+	for _, addr := range demoted {
+		c.log.Warnf("UPSTREAM", "Demoted %s after %d consecutive failures, probing every %d syncs",
+			addr, healthDemoteAfter, healthProbeEvery)
+	}
+	for _, addr := range recovered {
+		c.log.Infof("UPSTREAM", "Upstream %s recovered", addr)
+	}
+	if ctx.Err() != nil {
+		c.log.Info("UPSTREAM", "Upstream sync cancelled")
+		return
+	}
 
-		response, err := c.queryServer(server.Address)
-		if err != nil {
-			c.log.Warnf("UPSTREAM", "Failed to query %s: %v", addr, err)
-			c.log.LogUpstreamRequest(addr, false, 0, 0)
-			continue
+	if agreed := selectTruechimers(samples); agreed > 0 {
+		offset, peer := combineOffsets(samples)
+
+		var names, falsetickers []string
+		recorded := make([]ServerSample, len(samples))
+		for i, s := range samples {
+			recorded[i] = *s
+			switch {
+			case s.Agreed:
+				names = append(names, s.Address)
+			case s.OK:
+				falsetickers = append(falsetickers, fmt.Sprintf("%s (%v)", s.Address, s.Offset))
+			}
 		}
 
-		// Success!
-		c.mu.Lock()
-		c.clockOffset = response.ClockOffset
-		c.currentTime = time.Now().Add(response.ClockOffset)
-		c.lastSync = time.Now()
-		c.syncStatus = SyncStatus{
-			Synchronized: true,
-			ActiveServer: server.Address,
-			Stratum:      int(response.Stratum),
-			Offset:       response.ClockOffset,
-			RTT:          response.RTT,
-			LastSync:     time.Now(),
+		c.setSyncStatus(func(st *SyncStatus) {
+			c.clockOffset = offset
+			c.currentTime = c.clock.Now().Add(offset)
+			c.lastSync = c.clock.Now()
+			c.offsets.add(offset, c.cfg.Upstream.StabilityWindow)
+			*st = SyncStatus{
+				Synchronized: true,
+				ActiveServer: peer.Address,
+				Stratum:      peer.Stratum,
+				Offset:       offset,
+				RTT:          peer.RTT,
+				LastSync:     c.clock.Now(),
+				Servers:      recorded,
+				Stability:    c.offsets.stability(),
+			}
+		})
+
+		if len(falsetickers) > 0 {
+			c.log.Warnf("UPSTREAM", "Discarded falsetickers: %s", strings.Join(falsetickers, ", "))
 		}
-		c.mu.Unlock()
-
-		c.log.Infof("UPSTREAM", "Synced with %s (stratum %d, offset %v, RTT %v)",
-			server.Address, response.Stratum, response.ClockOffset, response.RTT)
-		c.log.LogUpstreamRequest(addr, true, response.RTT, response.ClockOffset)
+		c.log.Infof("UPSTREAM", "Synced with %d/%d servers (%s), peer %s stratum %d, offset %v, RTT %v",
+			agreed, len(samples), strings.Join(names, ", "), peer.Address, peer.Stratum, offset, peer.RTT)
+		return
+	}
 
+	if isPinned {
+		c.setSyncStatus(func(st *SyncStatus) {
+			st.Synchronized = false
+			st.LastError = fmt.Sprintf("Pinned server %s unreachable", pinned.Address)
+		})
+		c.log.Errorf("UPSTREAM", "Pinned upstream %s:%d unreachable, staying unsynchronized (no fallback)", pinned.Address, pinned.Port)
 		return
 	}
 
 	// All servers failed
+	c.setSyncStatus(func(st *SyncStatus) {
+		st.Synchronized = false
+		st.LastError = "All upstream servers failed"
+		st.Servers = nil
+	})
+	c.log.Error("UPSTREAM", "Failed to sync with any upstream server")
+}
+
+// queryAll queries every server concurrently and returns one sample each,
+// in the order given
+func (c *UpstreamClient) queryAll(servers []config.UpstreamServer) []*ServerSample {
+	samples := make([]*ServerSample, len(servers))
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		samples[i] = &ServerSample{Address: server.Address, Secure: server.Secure != ""}
+		wg.Add(1)
+		go func(server config.UpstreamServer, sample *ServerSample) {
+			defer wg.Done()
+
+			addr := fmt.Sprintf("%s:%d", server.Address, server.Port)
+			c.log.Debugf("UPSTREAM", "Querying upstream server: %s", addr)
+
+			response, err := c.queryServer(server)
+			if err != nil {
+				// The resolver already logs DNS failures once per outage
+				if !errors.Is(err, ErrResolve) {
+					c.log.Warnf("UPSTREAM", "Failed to query %s: %v", addr, err)
+				}
+				c.log.LogUpstreamRequest(addr, false, 0, 0)
+				sample.Error = err.Error()
+				return
+			}
+
+			sample.OK = true
+			sample.Stratum = int(response.Stratum)
+			sample.Offset = response.ClockOffset
+			sample.RTT = response.RTT
+			sample.Distance = response.RootDistance
+			c.log.LogUpstreamRequest(addr, true, response.RTT, response.ClockOffset)
+		}(server, samples[i])
+	}
+	wg.Wait()
+	return samples
+}
+
+// OnSyncChange registers a callback invoked whenever sync is gained or lost
+// or the active server changes. Callbacks run on the sync goroutine and
+// must not block.
+func (c *UpstreamClient) OnSyncChange(fn func(old, new SyncStatus)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.syncListeners = append(c.syncListeners, fn)
+}
+
+// setSyncStatus applies an update to the sync status under lock and
+// notifies listeners if the status transitioned
+func (c *UpstreamClient) setSyncStatus(update func(st *SyncStatus)) {
 	c.mu.Lock()
-	c.syncStatus.Synchronized = false
-	c.syncStatus.LastError = "All upstream servers failed"
+	old := c.syncStatus
+	update(&c.syncStatus)
+	updated := c.syncStatus
+	if old.Synchronized && !updated.Synchronized {
+		c.unsyncedSince = c.clock.Now()
+	}
+	listeners := make([]func(old, new SyncStatus), len(c.syncListeners))
+	copy(listeners, c.syncListeners)
 	c.mu.Unlock()
-	c.log.Error("UPSTREAM", "Failed to sync with any upstream server")
+
+	if old.Synchronized == updated.Synchronized && old.ActiveServer == updated.ActiveServer {
+		return
+	}
+	for _, fn := range listeners {
+		fn(old, updated)
+	}
 }
 
-// queryServer queries a single NTP server
-func (c *UpstreamClient) queryServer(addr string) (*ntp.Response, error) {
+// queryServer queries a single NTP server via its resolved address, or
+// its HTTPS Date header for a secure source
+func (c *UpstreamClient) queryServer(server config.UpstreamServer) (*ntp.Response, error) {
+	if server.Secure == config.SecureHTTPS {
+		return c.queryHTTPS(server)
+	}
+
+	ip, err := c.resolver.resolve(server.Address)
+	if err != nil {
+		return nil, err
+	}
+	addr := net.JoinHostPort(ip.String(), strconv.Itoa(server.Port))
+
 	options := ntp.QueryOptions{
 		Timeout: time.Duration(c.cfg.Upstream.Timeout) * time.Second,
 		TTL:     128,
@@ -168,13 +351,19 @@ func (c *UpstreamClient) GetCurrentTime() time.Time {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	if !c.syncStatus.Synchronized {
-		// Fall back to local time if not synchronized
-		return time.Now()
+	switch c.fallbackMode() {
+	case "", FallbackLastGood:
+		// Synchronized, or holding over on the last good offset
+	case FallbackManual:
+		return c.manualTime()
+	default:
+		// Host clock (refuse also flags responses as unsynchronized)
+		return c.clock.Now()
 	}
 
-	// Calculate time based on last sync and offset
-	elapsed := time.Since(c.lastSync)
+	// Calculate time based on last sync and offset; elapsed uses the
+	// monotonic clock, so host clock steps do not leak in
+	elapsed := clock.Since(c.clock, c.lastSync)
 	return c.lastSync.Add(elapsed).Add(c.clockOffset)
 }
 
@@ -190,7 +379,11 @@ func (c *UpstreamClient) GetStratum() uint8 {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	if !c.syncStatus.Synchronized {
+	switch c.fallbackMode() {
+	case "", FallbackLastGood:
+	case FallbackManual:
+		return manualStratum
+	default:
 		return 16 // Unsynchronized
 	}
 
@@ -206,29 +399,49 @@ func (c *UpstreamClient) GetReferenceID() uint32 {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	if !c.syncStatus.Synchronized || c.syncStatus.ActiveServer == "" {
+	switch c.fallbackMode() {
+	case "", FallbackLastGood:
+	case FallbackManual:
+		return localClockRefID
+	default:
 		return 0
 	}
-
-	// Try to resolve the active server to an IP
-	ips, err := net.LookupIP(c.syncStatus.ActiveServer)
-	if err != nil || len(ips) == 0 {
+	if c.syncStatus.ActiveServer == "" {
 		return 0
 	}
 
-	// Use the first IPv4 address
-	for _, ip := range ips {
-		if ipv4 := ip.To4(); ipv4 != nil {
-			return uint32(ipv4[0])<<24 | uint32(ipv4[1])<<16 | uint32(ipv4[2])<<8 | uint32(ipv4[3])
-		}
+	// Reuse the address the last sync resolved to
+	ip := c.resolver.cached(c.syncStatus.ActiveServer)
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return uint32(ipv4[0])<<24 | uint32(ipv4[1])<<16 | uint32(ipv4[2])<<8 | uint32(ipv4[3])
 	}
 
 	return 0
 }
 
-// ForceSync triggers an immediate sync
+// GetHealth returns the health of each enabled upstream server in
+// priority order, with pools listed per address
+func (c *UpstreamClient) GetHealth() []ServerHealth {
+	servers, pools := c.expandPools(c.cfg.GetActiveUpstreams(), false)
+	health := c.health.snapshot(servers)
+	for i := range health {
+		health[i].Pool = pools[health[i].Address]
+	}
+	return health
+}
+
+// GetResolveStatus returns the DNS resolution state per upstream host
+func (c *UpstreamClient) GetResolveStatus() map[string]ResolveInfo {
+	return c.resolver.status()
+}
+
+// ForceSync triggers an immediate sync, tracked as a cancellable operation
 func (c *UpstreamClient) ForceSync() {
-	go c.syncNow()
+	ctx, done := ops.GetRegistry().Start(context.Background(), "upstream force-sync")
+	go func() {
+		defer done()
+		c.syncNow(ctx)
+	}()
 }
 
 // UpdateConfig updates the client configuration
@@ -237,3 +450,11 @@ func (c *UpstreamClient) UpdateConfig(cfg *config.Config) {
 	defer c.mu.Unlock()
 	c.cfg = cfg
 }
+
+// EditConfig runs fn, which changes the shared config in place, while the
+// client is not reading it
+func (c *UpstreamClient) EditConfig(fn func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fn()
+}
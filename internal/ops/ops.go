@@ -0,0 +1,118 @@
+// Package ops tracks named in-flight background operations so they can be
+// listed and cancelled from the API or TUI
+package ops
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned when cancelling an unknown or finished operation
+var ErrNotFound = errors.New("operation not found")
+
+// Info describes an in-flight operation
+type Info struct {
+	ID         uint64    `json:"id"`
+	Name       string    `json:"name"`
+	Started    time.Time `json:"started"`
+	Cancelling bool      `json:"cancelling"`
+}
+
+// operation is a registered task and its cancel function
+type operation struct {
+	info   Info
+	cancel context.CancelFunc
+}
+
+// Registry holds the currently running operations
+type Registry struct {
+	mu     sync.Mutex
+	nextID uint64
+	ops    map[uint64]*operation
+}
+
+// Global registry instance
+var globalRegistry *Registry
+var registryOnce sync.Once
+
+// GetRegistry returns the global operations registry
+func GetRegistry() *Registry {
+	registryOnce.Do(func() {
+		globalRegistry = &Registry{
+			ops: make(map[uint64]*operation),
+		}
+	})
+	return globalRegistry
+}
+
+// Start registers a new operation. The returned context is cancelled when
+// the operation is cancelled; done must be called when the work finishes.
+func (r *Registry) Start(parent context.Context, name string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+
+	r.mu.Lock()
+	r.nextID++
+	id := r.nextID
+	r.ops[id] = &operation{
+		info:   Info{ID: id, Name: name, Started: time.Now()},
+		cancel: cancel,
+	}
+	r.mu.Unlock()
+
+	done := func() {
+		r.mu.Lock()
+		delete(r.ops, id)
+		r.mu.Unlock()
+		cancel()
+	}
+	return ctx, done
+}
+
+// List returns the running operations, oldest first
+func (r *Registry) List() []Info {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := make([]Info, 0, len(r.ops))
+	for _, op := range r.ops {
+		list = append(list, op.info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// Cancel requests cancellation of an operation by ID
+func (r *Registry) Cancel(id uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	op, ok := r.ops[id]
+	if !ok {
+		return ErrNotFound
+	}
+	op.info.Cancelling = true
+	op.cancel()
+	return nil
+}
+
+// CancelNewest cancels the most recently started operation
+func (r *Registry) CancelNewest() (Info, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var newest *operation
+	for _, op := range r.ops {
+		if !op.info.Cancelling && (newest == nil || op.info.ID > newest.info.ID) {
+			newest = op
+		}
+	}
+	if newest == nil {
+		return Info{}, ErrNotFound
+	}
+	newest.info.Cancelling = true
+	newest.cancel()
+	return newest.info, nil
+}
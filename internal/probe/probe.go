@@ -0,0 +1,171 @@
+// Package probe is a small NTP client that measures what a server's answers
+// would do to a careful client, and runs the self-test that points it at
+// TimeHammer's own server
+package probe
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// maxDist is the largest root distance RFC 5905 clients accept (MAXDIST)
+const maxDist = 1500 * time.Millisecond
+
+// Result is what one query measured. Offset and Delay are computed as
+// RFC 5905 does; an answer a careful client would discard is Rejected, with
+// the first failed check as Reason.
+type Result struct {
+	Sent      time.Time     `json:"sent"`
+	Offset    time.Duration `json:"offset"`
+	Delay     time.Duration `json:"delay"`
+	Leap      uint8         `json:"leap"`
+	Version   uint8         `json:"version"`
+	Mode      uint8         `json:"mode"`
+	Stratum   uint8         `json:"stratum"`
+	Poll      int8          `json:"poll"`
+	Precision int8          `json:"precision"`
+	RootDelay time.Duration `json:"root_delay"`
+	RootDisp  time.Duration `json:"root_dispersion"`
+	RefID     string        `json:"ref_id"`
+	Kiss      string        `json:"kiss,omitempty"`
+	Transmit  time.Time     `json:"transmit"`
+	CryptoNAK bool          `json:"crypto_nak,omitempty"`
+	Rejected  bool          `json:"rejected,omitempty"`
+	Reason    string        `json:"reason,omitempty"`
+}
+
+// probeKey authenticates probe requests that must carry a MAC; the server
+// need not trust it
+var probeKey = []byte("timehammer-probe")
+
+// Query sends one client request to addr (host:port) and measures the
+// answer. An error means no answer arrived before the timeout.
+func Query(addr string, timeout time.Duration) (Result, error) {
+	return query(addr, timeout, false)
+}
+
+// QueryAuthenticated is Query with a SHA1 MAC (key ID 1) on the request,
+// for attacks that only target authenticated clients
+func QueryAuthenticated(addr string, timeout time.Duration) (Result, error) {
+	return query(addr, timeout, true)
+}
+
+// query sends one client request, authenticated if mac is set
+func query(addr string, timeout time.Duration, mac bool) (Result, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return Result{}, err
+	}
+	defer conn.Close()
+
+	req := ntpcore.NewPacket()
+	req.Mode = ntpcore.ModeClient
+	req.Stratum = 0
+	req.Precision = 0
+
+	// Random low fraction bits make the origin check meaningful
+	var nonce [4]byte
+	rand.Read(nonce[:])
+	sent := time.Now()
+	ts := ntpcore.TimeToNTPTimestamp(sent)
+	req.XmitTimeSec = ts.Seconds
+	req.XmitTimeFrac = ts.Fraction&^0xFFFF | binary.BigEndian.Uint32(nonce[:])&0xFFFF
+	if mac {
+		if err := req.SetMAC(1, probeKey, ntpcore.MACAlgoSHA1); err != nil {
+			return Result{}, err
+		}
+	}
+
+	if err := conn.SetDeadline(sent.Add(timeout)); err != nil {
+		return Result{}, err
+	}
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		return Result{}, err
+	}
+
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return Result{Sent: sent}, fmt.Errorf("no answer from %s: %w", addr, err)
+	}
+	received := time.Now()
+
+	resp, err := ntpcore.ParsePacket(buf[:n])
+	if err != nil {
+		return Result{Sent: sent, Rejected: true, Reason: "unparsable: " + err.Error()}, nil
+	}
+	return measure(req, resp, sent, received), nil
+}
+
+// measure computes the offset and delay of an answer and runs the sanity
+// checks of RFC 5905 and the kiss-o'-death rules
+func measure(req, resp *ntpcore.NTPPacket, sent, received time.Time) Result {
+	// T1 is what was put on the wire, not the unrounded local clock
+	t1 := ntpcore.NTPTimestampToTimeNear(ntpcore.NTPTimestamp{Seconds: req.XmitTimeSec, Fraction: req.XmitTimeFrac}, sent)
+	t2 := resp.ReceiveTimeNear(sent)
+	t3 := resp.TransmitTimeNear(sent)
+	t4 := received
+
+	r := Result{
+		Sent:      sent,
+		Offset:    (t2.Sub(t1) + t3.Sub(t4)) / 2,
+		Delay:     t4.Sub(t1) - t3.Sub(t2),
+		Leap:      resp.LeapIndicator,
+		Version:   resp.Version,
+		Mode:      resp.Mode,
+		Stratum:   resp.Stratum,
+		Poll:      resp.Poll,
+		Precision: resp.Precision,
+		RootDelay: shortDuration(resp.RootDelay),
+		RootDisp:  shortDuration(resp.RootDisp),
+		RefID:     refIDString(resp),
+		Kiss:      strings.TrimRight(resp.GetKissOfDeathCode(), "\x00"),
+		Transmit:  t3,
+		CryptoNAK: resp.IsCryptoNAK(),
+	}
+
+	switch {
+	case resp.Mode != ntpcore.ModeServer:
+		r.Reason = fmt.Sprintf("mode %d, not server", resp.Mode)
+	case resp.Version < ntpcore.VersionNTPv3 || resp.Version > ntpcore.VersionNTPv4:
+		r.Reason = fmt.Sprintf("version %d", resp.Version)
+	case resp.OrigTimeSec != req.XmitTimeSec || resp.OrigTimeFrac != req.XmitTimeFrac:
+		r.Reason = "origin mismatch"
+	case resp.Stratum == 0:
+		r.Reason = fmt.Sprintf("kiss-o'-death %q", r.Kiss)
+	case r.CryptoNAK:
+		r.Reason = "crypto-NAK"
+	case resp.LeapIndicator == ntpcore.LeapAlarm:
+		r.Reason = "unsynchronized (LI 3)"
+	case resp.Stratum >= 16:
+		r.Reason = fmt.Sprintf("stratum %d", resp.Stratum)
+	case resp.XmitTimeSec == 0 && resp.XmitTimeFrac == 0:
+		r.Reason = "zero transmit timestamp"
+	case r.RootDelay/2+r.RootDisp > maxDist:
+		r.Reason = fmt.Sprintf("root distance %v over %v", r.RootDelay/2+r.RootDisp, maxDist)
+	}
+	r.Rejected = r.Reason != ""
+	return r
+}
+
+// shortDuration converts an NTP short format value (16.16 seconds)
+func shortDuration(v uint32) time.Duration {
+	return time.Duration(uint64(v) * uint64(time.Second) >> 16)
+}
+
+// refIDString returns the reference ID as clients show it: a code at
+// stratum 0 and 1, an IPv4 address above
+func refIDString(p *ntpcore.NTPPacket) string {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], p.ReferenceID)
+	if p.Stratum <= 1 {
+		return strings.TrimRight(string(b[:]), "\x00")
+	}
+	return net.IP(b[:]).String()
+}
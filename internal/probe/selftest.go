@@ -0,0 +1,488 @@
+package probe
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/attacks"
+	"github.com/neutrinoguy/timehammer/internal/config"
+	"github.com/neutrinoguy/timehammer/internal/server"
+)
+
+// DefaultThreshold is the score an attack needs to pass the self-test
+const DefaultThreshold = 0.8
+
+// queryTimeout bounds each probe; the delay check holds answers 200ms
+const queryTimeout = time.Second
+
+// Options tune a self-test run
+type Options struct {
+	Port      int      // Loopback port of the test server (0 = any free port)
+	Attacks   []string // Attacks to check (empty = all)
+	Threshold float64  // Score needed to pass (0 = DefaultThreshold)
+}
+
+// Score is how well one attack did what it claims against the probe
+type Score struct {
+	Attack   string   `json:"attack"`
+	Score    float64  `json:"score"` // 0 = no effect, 1 = exactly as configured
+	Passed   bool     `json:"passed"`
+	Expected string   `json:"expected"`
+	Observed string   `json:"observed"`
+	Probes   int      `json:"probes"`
+	Rejected int      `json:"rejected"` // Answers the probe would discard, lost ones included
+	Results  []Result `json:"results"`
+}
+
+// Report is the outcome of a self-test run
+type Report struct {
+	Address   string        `json:"address"`
+	Baseline  Result        `json:"baseline"` // Honest answer, with the median offset
+	Threshold float64       `json:"threshold"`
+	Scores    []Score       `json:"scores"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// Passed reports whether every attack checked reached the threshold
+func (r *Report) Passed() bool {
+	for _, s := range r.Scores {
+		if !s.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// check is one attack's settings, probe plan and expectation. score gets
+// every answer (lost ones as rejected) and the honest baseline answer.
+type check struct {
+	attack   attacks.AttackType
+	set      map[string]string // Keys below "security."
+	probes   int
+	gap      time.Duration
+	expected string
+	score    func(results []Result, base Result) (float64, string)
+
+	authenticated bool // Probe with MAC-carrying requests
+}
+
+// checks lists the attacks the self-test knows how to judge
+var checks = []check{
+	{
+		attack:   attacks.AttackTimeSpoofing,
+		set:      map[string]string{"time_spoofing.offset_secs": "3600", "time_spoofing.custom_time": "", "time_spoofing.ramp.enabled": "false"},
+		probes:   3,
+		expected: "offset +3600s",
+		score:    offsetScore(time.Hour),
+	},
+	{
+		attack: attacks.AttackTimeDrift,
+		set: map[string]string{"time_drift.drift_per_sec": "0.5", "time_drift.max_drift": "3600",
+			"time_drift.direction": "forward", "time_drift.waveform": "linear"},
+		probes:   6,
+		gap:      400 * time.Millisecond,
+		expected: "offset growing 0.5s per second",
+		score: func(results []Result, _ Result) (float64, string) {
+			first, last, ok := acceptedEnds(results)
+			if !ok {
+				return 0, "too few accepted answers"
+			}
+			slope := (last.Offset - first.Offset).Seconds() / last.Sent.Sub(first.Sent).Seconds()
+			return closeness(slope, 0.5), fmt.Sprintf("offset growing %.3fs per second (%+.3fs → %+.3fs)",
+				slope, first.Offset.Seconds(), last.Offset.Seconds())
+		},
+	},
+	{
+		attack:   attacks.AttackKissOfDeath,
+		set:      map[string]string{"kiss_of_death.code": "RATE", "kiss_of_death.codes": "", "kiss_of_death.interval": "1", "kiss_of_death.compliance_test": "false"},
+		probes:   3,
+		expected: "kiss-o'-death RATE",
+		score: matchScore(func(r Result) bool { return r.Stratum == 0 && r.Kiss == "RATE" }, func(r Result) string {
+			return orReason(r, fmt.Sprintf("stratum %d kiss %q", r.Stratum, r.Kiss))
+		}),
+	},
+	{
+		attack:   attacks.AttackStratumLie,
+		set:      map[string]string{"stratum_attack.fake_stratum": "1", "stratum_attack.track_upstream": "false"},
+		probes:   3,
+		expected: "stratum 1 (GPS)",
+		score: matchScore(func(r Result) bool { return !r.Rejected && r.Stratum == 1 && r.RefID == "GPS" }, func(r Result) string {
+			return orReason(r, fmt.Sprintf("stratum %d (%s)", r.Stratum, r.RefID))
+		}),
+	},
+	{
+		attack:   attacks.AttackRefID,
+		set:      map[string]string{"refid_spoof.ref_id": "GOOG", "refid_spoof.stratum": "0"},
+		probes:   3,
+		expected: "stratum 1 (GOOG)",
+		score: matchScore(func(r Result) bool { return !r.Rejected && r.Stratum == 1 && r.RefID == "GOOG" }, func(r Result) string {
+			return orReason(r, fmt.Sprintf("stratum %d (%s)", r.Stratum, r.RefID))
+		}),
+	},
+	{
+		attack:   attacks.AttackRootDistance,
+		set:      map[string]string{"root_distance.root_delay_ms": "1000", "root_distance.root_disp_ms": "5000", "root_distance.overlay": "false"},
+		probes:   3,
+		expected: "root delay 1s, dispersion 5s",
+		score: matchScore(func(r Result) bool {
+			return near(r.RootDelay, time.Second, time.Millisecond) && near(r.RootDisp, 5*time.Second, time.Millisecond)
+		}, func(r Result) string {
+			return fmt.Sprintf("root delay %v, dispersion %v", r.RootDelay.Round(time.Millisecond), r.RootDisp.Round(time.Millisecond))
+		}),
+	},
+	{
+		attack:   attacks.AttackOrigin,
+		set:      map[string]string{"origin_attack.mode": "zero", "origin_attack.interval": "1"},
+		probes:   3,
+		expected: "answers discarded for origin mismatch",
+		score: matchScore(func(r Result) bool { return r.Reason == "origin mismatch" }, func(r Result) string {
+			return orReason(r, "origin echoed")
+		}),
+	},
+	{
+		attack:   attacks.AttackLeapSecond,
+		set:      map[string]string{"leap_second.leap_indicator": "1"},
+		probes:   3,
+		expected: "leap indicator 1",
+		score: matchScore(func(r Result) bool { return !r.Rejected && r.Leap == 1 }, func(r Result) string {
+			return orReason(r, fmt.Sprintf("leap indicator %d", r.Leap))
+		}),
+	},
+	{
+		attack:   attacks.AttackLeapSmear,
+		set:      map[string]string{"leap_smear.window_secs": "2", "leap_smear.direction": "negative", "leap_smear.start": ""},
+		probes:   7,
+		gap:      500 * time.Millisecond,
+		expected: "offset smeared to +1s, leap indicator 0",
+		score: func(results []Result, base Result) (float64, string) {
+			first, last, ok := acceptedEnds(results)
+			if !ok {
+				return 0, "too few accepted answers"
+			}
+			unflagged := fraction(results, func(r Result) bool { return !r.Rejected && r.Leap == 0 })
+			return closeness((last.Offset-base.Offset).Seconds(), 1) * unflagged,
+				fmt.Sprintf("offset %+.3fs → %+.3fs, %.0f%% without leap indicator",
+					(first.Offset - base.Offset).Seconds(), (last.Offset - base.Offset).Seconds(), 100*unflagged)
+		},
+	},
+	{
+		attack:   attacks.AttackRollover,
+		set:      map[string]string{"rollover.mode": "y2k38"},
+		probes:   3,
+		expected: "time 2038-01-19T03:14:07Z",
+		score: matchScore(func(r Result) bool {
+			return !r.Rejected && near(r.Transmit.Sub(y2k38), 0, time.Second)
+		}, func(r Result) string {
+			return orReason(r, "time "+r.Transmit.UTC().Format(time.RFC3339))
+		}),
+	},
+	{
+		attack:   attacks.AttackClockStep,
+		set:      map[string]string{"clock_step.step_secs": "60", "clock_step.interval": "1", "clock_step.ramp.enabled": "false"},
+		probes:   3,
+		expected: "offset +60s",
+		score:    offsetScore(time.Minute),
+	},
+	{
+		attack:   attacks.AttackFuzzing,
+		set:      map[string]string{"fuzzing.mode": "deterministic", "fuzzing.seed": "1"},
+		probes:   10,
+		expected: "every answer malformed",
+		score:    fuzzScore,
+	},
+	{
+		attack:        attacks.AttackCryptoNAK,
+		set:           map[string]string{"crypto_nak.interval": "1"},
+		probes:        3,
+		expected:      "crypto-NAK",
+		authenticated: true,
+		score: matchScore(func(r Result) bool { return r.CryptoNAK }, func(r Result) string {
+			return orReason(r, "no crypto-NAK")
+		}),
+	},
+	{
+		attack: attacks.AttackTimeBomb,
+		set: map[string]string{"time_bomb.after_requests": "2", "time_bomb.at": "", "time_bomb.attack": "time_spoofing",
+			"time_spoofing.offset_secs": "3600", "time_spoofing.custom_time": "", "time_spoofing.ramp.enabled": "false"},
+		probes:   5,
+		expected: "honest first, then offset +3600s",
+		score: func(results []Result, base Result) (float64, string) {
+			honest := !results[0].Rejected && near(results[0].Offset, base.Offset, time.Second)
+			tail := results[len(results)-2:]
+			fired := fraction(tail, func(r Result) bool { return !r.Rejected && near(r.Offset-base.Offset, time.Hour, time.Second) })
+			score := fired / 2
+			if honest {
+				score += 0.5
+			}
+			return score, fmt.Sprintf("offset %+.3fs first, %+.3fs last",
+				(results[0].Offset - base.Offset).Seconds(), (results[len(results)-1].Offset - base.Offset).Seconds())
+		},
+	},
+	{
+		attack:   attacks.AttackDelay,
+		set:      map[string]string{"delay.inbound_delay_ms": "200", "delay.outbound_delay_ms": "0"},
+		probes:   3,
+		expected: "offset +100ms, delay 200ms",
+		score: func(results []Result, base Result) (float64, string) {
+			offsets, delays := accepted(results, base.Offset)
+			if len(offsets) == 0 {
+				return 0, "no accepted answers"
+			}
+			offset, delay := median(offsets), median(delays)
+			return closeness(offset.Seconds(), 0.1) * float64(len(offsets)) / float64(len(results)),
+				fmt.Sprintf("offset %+v, delay %v", offset.Round(time.Millisecond), delay.Round(time.Millisecond))
+		},
+	},
+}
+
+// y2k38 is the time the rollover attack serves in y2k38 mode
+var y2k38 = time.Date(2038, 1, 19, 3, 14, 7, 0, time.UTC)
+
+// CheckedAttacks returns the attacks the self-test can judge, in run order
+func CheckedAttacks() []string {
+	names := make([]string, len(checks))
+	for i, c := range checks {
+		names[i] = string(c.attack)
+	}
+	return names
+}
+
+// Run starts a server on loopback with no upstream, so the host clock is
+// the honest baseline, and turns on one attack at a time while the probe
+// measures what the answers would do to a client
+func Run(opts Options) (*Report, error) {
+	plan, err := selectChecks(opts.Attacks)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Threshold <= 0 {
+		opts.Threshold = DefaultThreshold
+	}
+
+	cfg := selfTestConfig(opts.Port)
+	srv := server.NewServer(cfg)
+	if err := srv.Start(); err != nil {
+		return nil, err
+	}
+	defer srv.Stop()
+
+	start := time.Now()
+	report := &Report{Address: srv.GetListenAddress(), Threshold: opts.Threshold}
+
+	// A server that already misleads the probe makes every score meaningless
+	var offsets []time.Duration
+	for i := 0; i < 3; i++ {
+		r, err := Query(report.Address, queryTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("baseline: %w", err)
+		}
+		if r.Rejected {
+			return nil, fmt.Errorf("baseline answer rejected: %s", r.Reason)
+		}
+		offsets = append(offsets, r.Offset)
+		report.Baseline = r
+	}
+	report.Baseline.Offset = median(offsets)
+
+	engine := srv.GetAttackEngine()
+	for _, c := range plan {
+		engine.DisableAllAttacks()
+		engine.ResetRequestCounts()
+		for key, value := range c.set {
+			if err := cfg.Set("security."+key, value); err != nil {
+				return nil, fmt.Errorf("%s: %w", c.attack, err)
+			}
+		}
+		if _, err := engine.EnableAttack(c.attack); err != nil {
+			return nil, err
+		}
+
+		results := make([]Result, 0, c.probes)
+		for i := 0; i < c.probes; i++ {
+			if i > 0 {
+				time.Sleep(c.gap)
+			}
+			ask := Query
+			if c.authenticated {
+				ask = QueryAuthenticated
+			}
+			r, err := ask(report.Address, queryTimeout)
+			if err != nil {
+				r.Rejected, r.Reason = true, "no answer"
+			}
+			results = append(results, r)
+		}
+
+		score, observed := c.score(results, report.Baseline)
+		score = math.Max(0, math.Min(1, score))
+		s := Score{
+			Attack:   string(c.attack),
+			Score:    score,
+			Passed:   score >= opts.Threshold,
+			Expected: c.expected,
+			Observed: observed,
+			Probes:   len(results),
+			Results:  results,
+		}
+		for _, r := range results {
+			if r.Rejected {
+				s.Rejected++
+			}
+		}
+		report.Scores = append(report.Scores, s)
+	}
+	engine.DisableAllAttacks()
+
+	report.Duration = time.Since(start)
+	return report, nil
+}
+
+// selectChecks returns the checks of the named attacks, in run order
+func selectChecks(names []string) ([]check, error) {
+	if len(names) == 0 {
+		return checks, nil
+	}
+	known := CheckedAttacks()
+	for _, name := range names {
+		if !slices.Contains(known, name) {
+			return nil, fmt.Errorf("no self-test for attack %q (have %v)", name, known)
+		}
+	}
+	var plan []check
+	for _, c := range checks {
+		if slices.Contains(names, string(c.attack)) {
+			plan = append(plan, c)
+		}
+	}
+	return plan, nil
+}
+
+// selfTestConfig is the default config on a loopback port, without
+// upstream servers, persistence or anything else that changes answers
+func selfTestConfig(port int) *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.Server.Interface = "127.0.0.1"
+	cfg.Server.Port = port
+	cfg.Server.Ports = nil
+	cfg.Server.UseAltPortOnFail = false
+	cfg.Server.Broadcast.Enabled = false
+	cfg.Server.ResponseCap.Enabled = false // Probes come faster than a client polls
+	cfg.Upstream.Servers = nil
+	cfg.Upstream.PinnedServer = ""
+	// Without a base time the manual fallback is the host clock claimed as
+	// a stratum 10 local clock, which the probe accepts; host fallback
+	// would honestly answer stratum 16
+	cfg.Upstream.FallbackMode = "manual"
+	cfg.Upstream.FallbackTime = ""
+	cfg.Security.RequireUpstreamSync = false
+	cfg.Logging.ClientHistory = false
+	cfg.Logging.AutoRecordAttacks = false
+	return cfg
+}
+
+// offsetScore judges attacks that shift the served time by want
+func offsetScore(want time.Duration) func([]Result, Result) (float64, string) {
+	return func(results []Result, base Result) (float64, string) {
+		offsets, _ := accepted(results, base.Offset)
+		if len(offsets) == 0 {
+			return 0, "no accepted answers"
+		}
+		got := median(offsets)
+		return closeness(got.Seconds(), want.Seconds()) * float64(len(offsets)) / float64(len(results)),
+			fmt.Sprintf("offset %+.3fs", got.Seconds())
+	}
+}
+
+// matchScore judges attacks by the share of answers showing the effect,
+// describing the last answer
+func matchScore(ok func(Result) bool, describe func(Result) string) func([]Result, Result) (float64, string) {
+	return func(results []Result, _ Result) (float64, string) {
+		return fraction(results, ok), describe(results[len(results)-1])
+	}
+}
+
+// fuzzScore judges fuzzing by the share of answers that differ from an
+// honest one: discarded, lost, or with a header field or time off
+func fuzzScore(results []Result, base Result) (float64, string) {
+	malformed := func(r Result) bool {
+		return r.Rejected || r.Leap != base.Leap || r.Version != base.Version || r.Mode != base.Mode ||
+			r.Stratum != base.Stratum || r.Poll != base.Poll || r.Precision != base.Precision ||
+			r.RootDelay != base.RootDelay || r.RootDisp != base.RootDisp || r.RefID != base.RefID ||
+			!near(r.Offset, base.Offset, time.Second)
+	}
+	share := fraction(results, malformed)
+	return share, fmt.Sprintf("%.0f%% of answers malformed", 100*share)
+}
+
+// orReason describes an answer, or why the probe discarded it
+func orReason(r Result, desc string) string {
+	if r.Rejected {
+		return "discarded: " + r.Reason
+	}
+	return desc
+}
+
+// accepted returns the offsets from the baseline and the delays of the
+// answers the probe kept
+func accepted(results []Result, baseline time.Duration) (offsets, delays []time.Duration) {
+	for _, r := range results {
+		if !r.Rejected {
+			offsets = append(offsets, r.Offset-baseline)
+			delays = append(delays, r.Delay)
+		}
+	}
+	return offsets, delays
+}
+
+// acceptedEnds returns the first and last kept answers, if two were kept
+func acceptedEnds(results []Result) (first, last Result, ok bool) {
+	var kept []Result
+	for _, r := range results {
+		if !r.Rejected {
+			kept = append(kept, r)
+		}
+	}
+	if len(kept) < 2 {
+		return Result{}, Result{}, false
+	}
+	return kept[0], kept[len(kept)-1], true
+}
+
+// fraction returns the share of results for which ok holds
+func fraction(results []Result, ok func(Result) bool) float64 {
+	if len(results) == 0 {
+		return 0
+	}
+	n := 0
+	for _, r := range results {
+		if ok(r) {
+			n++
+		}
+	}
+	return float64(n) / float64(len(results))
+}
+
+// closeness scores a measurement against its expected value: 1 when equal,
+// falling linearly to 0 at an error as large as the value itself
+func closeness(got, want float64) float64 {
+	if want == 0 {
+		return 0
+	}
+	return math.Max(0, 1-math.Abs(got-want)/math.Abs(want))
+}
+
+// near reports whether d is within tolerance of want
+func near(d, want, tolerance time.Duration) bool {
+	diff := d - want
+	return diff >= -tolerance && diff <= tolerance
+}
+
+// median returns the median of durations
+func median(ds []time.Duration) time.Duration {
+	sorted := append([]time.Duration(nil), ds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
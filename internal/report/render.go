@@ -0,0 +1,232 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/kodcheck"
+)
+
+// timeLayout formats timestamps in reports
+const timeLayout = "2006-01-02 15:04:05.000 MST"
+
+// section is one titled part of a report. Both output formats are rendered
+// from the same sections so they always carry the same content.
+type section struct {
+	Title  string
+	Fields [][2]string // Label/value pairs
+	Header []string    // Table header (nil = no table)
+	Rows   [][]string
+	Empty  string // Shown instead of the table when it has no rows
+	Pre    string // Preformatted block
+}
+
+// sections lays out the report content
+func (r *Report) sections() []section {
+	security := "disabled"
+	if r.SecurityEnabled {
+		security = "enabled"
+	}
+	active := r.ActiveAttack
+	if active == "" {
+		active = "none"
+	}
+	overview := section{Title: "Overview", Fields: [][2]string{{"Generated", r.Generated.Format(timeLayout)}}}
+	if r.Version != "" {
+		overview.Fields = append(overview.Fields, [2]string{"Version", r.Version})
+	}
+	overview.Fields = append(overview.Fields,
+		[2]string{"Security mode", security},
+		[2]string{"Active attack", active},
+		[2]string{"Targets", r.Targets})
+	secs := []section{overview}
+
+	if s := r.Session; s != nil {
+		fields := [][2]string{
+			{"Session", s.ID},
+			{"Description", orDash(s.Description)},
+			{"Started", s.StartTime.Format(timeLayout)},
+		}
+		if !s.EndTime.IsZero() {
+			fields = append(fields,
+				[2]string{"Ended", s.EndTime.Format(timeLayout)},
+				[2]string{"Duration", s.EndTime.Sub(s.StartTime).Round(time.Second).String()})
+		}
+		if len(s.ClientFilter) > 0 {
+			fields = append(fields, [2]string{"Recorded clients", strings.Join(s.ClientFilter, ", ")})
+		}
+		fields = append(fields,
+			[2]string{"Requests", fmt.Sprint(s.Stats.TotalRequests)},
+			[2]string{"Responses", fmt.Sprint(s.Stats.TotalResponses)},
+			[2]string{"Unique clients", fmt.Sprint(s.Stats.UniqueClients)},
+			[2]string{"Upstream queries", fmt.Sprint(s.Stats.UpstreamQueries)},
+			[2]string{"Attacks executed", fmt.Sprint(s.Stats.AttacksExecuted)},
+			[2]string{"Avg response time", s.Stats.AvgResponseTime.String()})
+		secs = append(secs, section{Title: "Session", Fields: fields})
+
+		executed := section{
+			Title:  "Executed Attacks",
+			Header: []string{"Attack", "CVE", "Severity", "Client", "Responses", "First", "Last"},
+			Empty:  "No attacks were applied to recorded clients.",
+		}
+		for _, ex := range r.Executed {
+			executed.Rows = append(executed.Rows, []string{
+				ex.Name, orDash(ex.CVE), orDash(ex.Severity), ex.Client, fmt.Sprint(ex.Count),
+				ex.First.Format(timeLayout), ex.Last.Format(timeLayout),
+			})
+		}
+		secs = append(secs, executed)
+
+		if len(s.Timeline) > 0 {
+			timeline := section{Title: "Timeline", Header: []string{"Time", "Event", "Attack", "Client", "Detail"}}
+			for _, e := range s.Timeline {
+				timeline.Rows = append(timeline.Rows, []string{
+					e.Time.Format(timeLayout), e.Kind, orDash(e.Attack), orDash(e.Client), e.Detail,
+				})
+			}
+			secs = append(secs, timeline)
+		}
+	}
+
+	if len(r.KoDCompliance) > 0 {
+		kod := section{
+			Title:  "KoD Compliance",
+			Header: []string{"Client", "KoD sent", "Usual interval", "Required", "Backoff", "Requests after", "Verdict"},
+		}
+		for _, c := range r.KoDCompliance {
+			baseline := "unknown"
+			if c.Baseline > 0 {
+				baseline = c.Baseline.Round(time.Millisecond).String()
+			}
+			kod.Rows = append(kod.Rows, []string{
+				c.Client, c.KoDAt.Format(timeLayout), baseline, c.Required.String(),
+				c.Backoff.Round(time.Millisecond).String(), fmt.Sprint(c.After), c.Verdict,
+			})
+		}
+		complied, ignored, pending := kodcheck.Summary(r.KoDCompliance)
+		kod.Fields = [][2]string{{"Summary", fmt.Sprintf("%d complied, %d ignored, %d pending", complied, ignored, pending)}}
+		secs = append(secs, kod)
+	}
+
+	log := section{
+		Title:  "Attack Log",
+		Header: []string{"Time", "Attack", "Target", "Message"},
+		Empty:  "No attacks were logged.",
+	}
+	for _, e := range r.Log {
+		log.Rows = append(log.Rows, []string{e.Timestamp.Format(timeLayout), e.Attack, orDash(e.ClientIP), e.Message})
+	}
+	secs = append(secs, log)
+
+	catalog := section{
+		Title:  "Attack Catalog",
+		Header: []string{"Attack", "Type", "CVE", "Severity", "Applied", "Logged", "Description"},
+	}
+	for _, c := range r.Catalog {
+		catalog.Rows = append(catalog.Rows, []string{
+			c.Name, string(c.Type), orDash(c.CVE), c.Severity,
+			fmt.Sprint(c.Applied), fmt.Sprint(c.Logged), c.Description,
+		})
+	}
+	secs = append(secs, catalog)
+
+	if r.ConfigYAML != "" {
+		secs = append(secs, section{Title: "Configuration", Pre: r.ConfigYAML})
+	}
+	return secs
+}
+
+// renderMarkdown formats a report as Markdown
+func renderMarkdown(r *Report) []byte {
+	var sb strings.Builder
+	sb.WriteString("# TimeHammer Test Report\n")
+
+	for _, sec := range r.sections() {
+		fmt.Fprintf(&sb, "\n## %s\n\n", sec.Title)
+		for _, f := range sec.Fields {
+			fmt.Fprintf(&sb, "- **%s:** %s\n", f[0], mdEscape(f[1]))
+		}
+		if len(sec.Fields) > 0 && (sec.Header != nil || sec.Pre != "") {
+			sb.WriteString("\n")
+		}
+		if sec.Header != nil {
+			if len(sec.Rows) == 0 {
+				fmt.Fprintf(&sb, "_%s_\n", sec.Empty)
+			} else {
+				sb.WriteString("| " + strings.Join(sec.Header, " | ") + " |\n")
+				sb.WriteString(strings.Repeat("|---", len(sec.Header)) + "|\n")
+				for _, row := range sec.Rows {
+					cells := make([]string, len(row))
+					for i, cell := range row {
+						cells[i] = mdEscape(cell)
+					}
+					sb.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+				}
+			}
+		}
+		if sec.Pre != "" {
+			sb.WriteString("```yaml\n" + strings.TrimRight(sec.Pre, "\n") + "\n```\n")
+		}
+	}
+	return []byte(sb.String())
+}
+
+// mdEscape keeps a value on one line and inside its table cell
+func mdEscape(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+// htmlTemplate lays out the HTML report
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>TimeHammer Test Report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin: 0.5em 0 1.5em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f0f0f0; }
+dt { font-weight: bold; float: left; clear: left; width: 12em; }
+dd { margin-left: 12em; }
+pre { background: #f6f6f6; padding: 1em; overflow-x: auto; }
+.empty { color: #888; font-style: italic; }
+</style>
+</head>
+<body>
+<h1>TimeHammer Test Report</h1>
+{{range .}}
+<h2>{{.Title}}</h2>
+{{if .Fields}}<dl>{{range .Fields}}
+<dt>{{index . 0}}</dt><dd>{{index . 1}}</dd>{{end}}
+</dl>{{end}}
+{{if .Header}}{{if .Rows}}<table>
+<tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr>{{range .Rows}}
+<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>{{end}}
+</table>{{else}}<p class="empty">{{.Empty}}</p>{{end}}{{end}}
+{{if .Pre}}<pre>{{.Pre}}</pre>{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+// renderHTML formats a report as a standalone HTML page
+func renderHTML(r *Report) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, r.sections()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// orDash substitutes "-" for an empty value
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
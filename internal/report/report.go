@@ -0,0 +1,223 @@
+// Package report generates test reports combining the attack catalog, the
+// active configuration, session statistics and the log of executed attacks
+package report
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/attacks"
+	"github.com/neutrinoguy/timehammer/internal/config"
+	"github.com/neutrinoguy/timehammer/internal/kodcheck"
+	"github.com/neutrinoguy/timehammer/internal/logger"
+	"github.com/neutrinoguy/timehammer/internal/netutil"
+	"github.com/neutrinoguy/timehammer/internal/session"
+)
+
+// Report formats
+const (
+	FormatMarkdown = "md"
+	FormatHTML     = "html"
+)
+
+// Options selects what goes into a report
+type Options struct {
+	Config  *config.Config
+	Engine  *attacks.AttackEngine // Describes the active attack (nil = derived from Config)
+	Session *session.Session      // Recorded test to report on (nil = live log only)
+	Entries []logger.LogEntry     // Log entries to take executed attacks from
+	Version string                // Application version ("" = omitted)
+}
+
+// Report is the data rendered into a report document
+type Report struct {
+	Generated time.Time
+	Version   string
+
+	SecurityEnabled bool
+	ActiveAttack    string // Active attack and its parameters ("" = none)
+	Targets         string
+	ConfigYAML      string
+
+	Catalog  []CatalogEntry
+	Executed []ExecutedAttack
+	Log      []logger.LogEntry // ATTACK log entries, oldest first
+
+	Session       *session.Session
+	KoDCompliance []kodcheck.Result
+}
+
+// CatalogEntry is one available attack and how often it was applied
+type CatalogEntry struct {
+	attacks.AttackInfo
+	Applied int // Responses the attack was applied to in the session
+	Logged  int // ATTACK log entries for the attack
+}
+
+// ExecutedAttack is one attack applied to one client during the session
+type ExecutedAttack struct {
+	Attack   string
+	Name     string
+	CVE      string
+	Severity string
+	Client   string
+	Count    int
+	First    time.Time
+	Last     time.Time
+}
+
+// Build collects the report data
+func Build(opts Options) *Report {
+	engine := opts.Engine
+	if engine == nil {
+		engine = attacks.NewAttackEngine(opts.Config)
+	}
+
+	r := &Report{
+		Generated:       time.Now(),
+		Version:         opts.Version,
+		SecurityEnabled: opts.Config.Security.Enabled,
+		Targets:         engine.DescribeTargets(),
+		Session:         opts.Session,
+	}
+	if attack, params := engine.DescribeActiveAttack(); attack != attacks.AttackNone {
+		r.ActiveAttack = strings.TrimSpace(string(attack) + " " + params)
+	}
+	if yaml, err := opts.Config.GetYAML(); err == nil {
+		r.ConfigYAML = yaml
+	}
+
+	infos := make(map[string]attacks.AttackInfo)
+	for _, info := range attacks.GetAvailableAttacks() {
+		infos[string(info.Type)] = info
+	}
+
+	var from, to time.Time
+	if s := opts.Session; s != nil {
+		from, to = s.StartTime, s.EndTime
+		r.Executed = executedAttacks(s.Events, infos)
+		r.KoDCompliance = s.Stats.KoDCompliance
+	}
+	for _, entry := range opts.Entries {
+		if entry.Category != "ATTACK" || entry.Attack == "" {
+			continue
+		}
+		if !from.IsZero() && entry.Timestamp.Before(from) {
+			continue
+		}
+		if !to.IsZero() && entry.Timestamp.After(to) {
+			continue
+		}
+		r.Log = append(r.Log, entry)
+	}
+	sort.SliceStable(r.Log, func(i, j int) bool { return r.Log[i].Timestamp.Before(r.Log[j].Timestamp) })
+
+	applied := make(map[string]int)
+	for _, ex := range r.Executed {
+		applied[ex.Attack] += ex.Count
+	}
+	logged := make(map[string]int)
+	for _, entry := range r.Log {
+		logged[entry.Attack]++
+	}
+	for _, info := range attacks.GetAvailableAttacks() {
+		r.Catalog = append(r.Catalog, CatalogEntry{
+			AttackInfo: info,
+			Applied:    applied[string(info.Type)],
+			Logged:     logged[string(info.Type)],
+		})
+	}
+	return r
+}
+
+// executedAttacks tallies the attack-applied requests of a session per
+// attack and client IP. Requests carry the label of the applied attack, so
+// each is attributed to the attack type of the preceding state marker.
+func executedAttacks(events []session.SessionEvent, infos map[string]attacks.AttackInfo) []ExecutedAttack {
+	index := make(map[string]int)
+	var executed []ExecutedAttack
+
+	state := ""
+	for _, ev := range events {
+		if ev.Type == "attack_state" {
+			state = ev.AttackMode
+			continue
+		}
+		if ev.Type != "request" || ev.AttackMode == "" {
+			continue
+		}
+		attack := state
+		if attack == "" {
+			attack = ev.AttackMode
+		}
+		client := netutil.Host(ev.ClientAddr)
+		key := attack + "|" + client
+		i, ok := index[key]
+		if !ok {
+			info := infos[attack]
+			name := info.Name
+			if name == "" {
+				name = attack
+			}
+			executed = append(executed, ExecutedAttack{
+				Attack:   attack,
+				Name:     name,
+				CVE:      info.CVE,
+				Severity: info.Severity,
+				Client:   client,
+				First:    ev.Timestamp,
+			})
+			i = len(executed) - 1
+			index[key] = i
+		}
+		executed[i].Count++
+		executed[i].Last = ev.Timestamp
+	}
+
+	sort.SliceStable(executed, func(i, j int) bool { return executed[i].First.Before(executed[j].First) })
+	return executed
+}
+
+// Render formats a report as Markdown or HTML
+func Render(r *Report, format string) ([]byte, error) {
+	switch format {
+	case FormatMarkdown:
+		return renderMarkdown(r), nil
+	case FormatHTML:
+		return renderHTML(r)
+	default:
+		return nil, fmt.Errorf("unknown report format %q (use md or html)", format)
+	}
+}
+
+// Write builds and renders a report to path. An empty path writes to the
+// exports directory as <session>.report.<format>, or report_<time>.<format>
+// without a session. Returns the path written.
+func Write(opts Options, format, path string) (string, error) {
+	r := Build(opts)
+	data, err := Render(r, format)
+	if err != nil {
+		return "", err
+	}
+
+	if path == "" {
+		dataDir, err := config.GetDataDir()
+		if err != nil {
+			return "", err
+		}
+		name := fmt.Sprintf("report_%s.%s", r.Generated.Format("20060102_150405"), format)
+		if opts.Session != nil {
+			name = fmt.Sprintf("%s.report.%s", opts.Session.ID, format)
+		}
+		path = filepath.Join(dataDir, config.ExportDirName, name)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,313 @@
+// Package scenario runs scripted, unattended test timelines
+package scenario
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/neutrinoguy/timehammer/internal/attacks"
+	"github.com/neutrinoguy/timehammer/internal/config"
+	"github.com/neutrinoguy/timehammer/internal/logger"
+	"github.com/neutrinoguy/timehammer/internal/server"
+	"github.com/neutrinoguy/timehammer/internal/session"
+)
+
+// Scenario step actions
+const (
+	ActionStart    = "start"    // Start the NTP server
+	ActionStop     = "stop"     // Stop the NTP server
+	ActionWait     = "wait"     // Sleep for Duration seconds
+	ActionAttack   = "attack"   // Switch to Attack with Config overrides
+	ActionDisable  = "disable"  // Turn all attacks off
+	ActionSequence = "sequence" // Run a named attack sequence to its end
+	ActionRecord   = "record"   // Start or stop session recording
+	ActionExpect   = "expect"   // Fail unless the server saw enough traffic
+)
+
+// Scenario is a timeline of actions run from top to bottom
+type Scenario struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Steps       []Step `yaml:"steps"`
+}
+
+// Step is one action of a scenario. Only the fields of its action are used.
+type Step struct {
+	Action   string                 `yaml:"action"`
+	Duration int                    `yaml:"duration"` // wait: seconds; sequence: stop after (0 = run to the end)
+	Attack   string                 `yaml:"attack"`   // attack: attack type
+	Config   map[string]interface{} `yaml:"config"`   // attack: overrides, same keys as presets
+	Sequence string                 `yaml:"sequence"` // sequence: name from the config
+	Record   string                 `yaml:"record"`   // record: start or stop
+
+	MinRequests uint64 `yaml:"min_requests"` // expect: total requests seen so far
+	MinClients  int    `yaml:"min_clients"`  // expect: active clients right now
+}
+
+// StepResult is the outcome of one step
+type StepResult struct {
+	Index   int
+	Action  string
+	Detail  string
+	Err     error
+	Elapsed time.Duration
+}
+
+// Result summarizes a scenario run
+type Result struct {
+	Name     string
+	Steps    []StepResult
+	Skipped  int // Steps not run after a failure
+	Duration time.Duration
+	Stats    server.Stats
+}
+
+// OK reports whether every step ran and succeeded
+func (r *Result) OK() bool {
+	if r.Skipped > 0 {
+		return false
+	}
+	for _, s := range r.Steps {
+		if s.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Load reads and checks a scenario file. Named sequences are resolved
+// against cfg.
+func Load(path string, cfg *config.Config) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %w", err)
+	}
+
+	var sc Scenario
+	if err := yaml.Unmarshal(data, &sc); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario: %w", err)
+	}
+	if sc.Name == "" {
+		sc.Name = path
+	}
+	if len(sc.Steps) == 0 {
+		return nil, fmt.Errorf("scenario %s has no steps", sc.Name)
+	}
+
+	for i, step := range sc.Steps {
+		if err := step.check(cfg); err != nil {
+			return nil, fmt.Errorf("scenario %s step %d (%s): %w", sc.Name, i+1, step.Action, err)
+		}
+	}
+	return &sc, nil
+}
+
+// check validates the fields of a step's action
+func (s Step) check(cfg *config.Config) error {
+	switch s.Action {
+	case ActionStart, ActionStop, ActionDisable:
+	case ActionWait:
+		if s.Duration <= 0 {
+			return fmt.Errorf("duration must be positive")
+		}
+	case ActionAttack:
+		if !isKnownAttack(s.Attack) {
+			return fmt.Errorf("unknown attack %q", s.Attack)
+		}
+	case ActionSequence:
+		seq, ok := cfg.GetAttackSequence(s.Sequence)
+		if !ok {
+			return fmt.Errorf("unknown sequence %q", s.Sequence)
+		}
+		if seq.Loop && s.Duration <= 0 {
+			return fmt.Errorf("sequence %q loops forever, set a duration", seq.Name)
+		}
+	case ActionRecord:
+		if s.Record != "start" && s.Record != "stop" {
+			return fmt.Errorf("record must be start or stop")
+		}
+	case ActionExpect:
+		if s.MinRequests == 0 && s.MinClients == 0 {
+			return fmt.Errorf("set min_requests or min_clients")
+		}
+	default:
+		return fmt.Errorf("unknown action")
+	}
+	return nil
+}
+
+// isKnownAttack reports whether name is an available attack type
+func isKnownAttack(name string) bool {
+	for _, info := range attacks.GetAvailableAttacks() {
+		if string(info.Type) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Run executes the steps in order, stopping at the first failure or when
+// ctx is cancelled. The server is stopped and any recording saved before
+// returning.
+func Run(ctx context.Context, sc *Scenario, srv *server.Server, cfg *config.Config) *Result {
+	log := logger.GetLogger()
+	res := &Result{Name: sc.Name}
+	start := time.Now()
+
+	log.Infof("CONTROL", "Running scenario %s (%d steps)", sc.Name, len(sc.Steps))
+	for i, step := range sc.Steps {
+		if ctx.Err() != nil {
+			res.Skipped = len(sc.Steps) - i
+			break
+		}
+
+		stepStart := time.Now()
+		detail, err := runStep(ctx, step, srv, cfg)
+		res.Steps = append(res.Steps, StepResult{
+			Index:   i + 1,
+			Action:  step.Action,
+			Detail:  detail,
+			Err:     err,
+			Elapsed: time.Since(stepStart),
+		})
+
+		if err != nil {
+			log.Errorf("CONTROL", "Scenario %s step %d (%s) failed: %v", sc.Name, i+1, step.Action, err)
+			res.Skipped = len(sc.Steps) - i - 1
+			break
+		}
+		log.Infof("CONTROL", "Scenario %s step %d/%d: %s", sc.Name, i+1, len(sc.Steps), detail)
+	}
+
+	// Leave nothing running behind the scenario
+	srv.GetAttackEngine().StopSequence()
+	if recorder := session.GetRecorder(); recorder.IsRecording() {
+		if sess, err := recorder.StopRecording(); err == nil {
+			log.Infof("SESSION", "Recording stopped, saved as %s", sess.ID)
+		}
+	}
+	res.Stats = srv.GetStats()
+	if srv.IsRunning() {
+		srv.Stop()
+	}
+
+	res.Duration = time.Since(start)
+	return res
+}
+
+// runStep executes one step and describes what it did
+func runStep(ctx context.Context, step Step, srv *server.Server, cfg *config.Config) (string, error) {
+	engine := srv.GetAttackEngine()
+
+	switch step.Action {
+	case ActionStart:
+		if err := srv.Start(); err != nil {
+			return "", err
+		}
+		return "server listening on " + srv.GetListenAddress(), nil
+
+	case ActionStop:
+		if err := srv.Stop(); err != nil {
+			return "", err
+		}
+		return "server stopped", nil
+
+	case ActionWait:
+		d := time.Duration(step.Duration) * time.Second
+		if err := sleep(ctx, d); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("waited %v", d), nil
+
+	case ActionAttack:
+		if err := engine.ApplyStep("scenario", config.SequenceStep{Attack: step.Attack, Config: step.Config}); err != nil {
+			return "", err
+		}
+		attack, params := engine.DescribeActiveAttack()
+		return fmt.Sprintf("attack %s %s", attack, params), nil
+
+	case ActionDisable:
+		engine.StopSequence()
+		engine.DisableAllAttacks()
+		return "attacks disabled", nil
+
+	case ActionSequence:
+		seq, _ := cfg.GetAttackSequence(step.Sequence)
+		if err := engine.StartSequence(seq); err != nil {
+			return "", err
+		}
+		limit := time.Duration(step.Duration) * time.Second
+		if err := waitSequence(ctx, engine, limit); err != nil {
+			engine.StopSequence()
+			return "", err
+		}
+		return fmt.Sprintf("sequence %s done", seq.Name), nil
+
+	case ActionRecord:
+		recorder := session.GetRecorder()
+		if step.Record == "start" {
+			opts := session.RecordingOptions{Description: "Scenario recording", Clients: cfg.Logging.RecordClients}
+			if err := recorder.StartRecordingWithOptions(opts); err != nil {
+				return "", err
+			}
+			return "recording started", nil
+		}
+		sess, err := recorder.StopRecording()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("recording saved as %s (%d events)", sess.ID, len(sess.Events)), nil
+
+	case ActionExpect:
+		st := srv.GetStats()
+		if st.TotalRequests < step.MinRequests {
+			return "", fmt.Errorf("expected at least %d requests, saw %d", step.MinRequests, st.TotalRequests)
+		}
+		if st.ActiveClients < step.MinClients {
+			return "", fmt.Errorf("expected at least %d clients, saw %d", step.MinClients, st.ActiveClients)
+		}
+		return fmt.Sprintf("%d requests from %d clients", st.TotalRequests, st.ActiveClients), nil
+	}
+	return "", fmt.Errorf("unknown action %q", step.Action)
+}
+
+// waitSequence waits until the running sequence ends, or stops it after
+// limit (0 = no limit)
+func waitSequence(ctx context.Context, engine *attacks.AttackEngine, limit time.Duration) error {
+	var deadline <-chan time.Time
+	if limit > 0 {
+		timer := time.NewTimer(limit)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for engine.SequenceStatus() != "" {
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			engine.StopSequence()
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// sleep waits for d or until ctx is cancelled
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+const (
+	// maxAmplificationPacket keeps synthetic replies within a typical MTU
+	maxAmplificationPacket = 1472
+
+	// monlistItemSize is the size of one mode 7 monlist entry
+	monlistItemSize = 72
+)
+
+// isControlQuery reports whether a datagram is a mode 6 or mode 7 query
+func isControlQuery(data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+	mode := data[0] & 0x07
+	return mode == ntpcore.ModeControl || mode == ntpcore.ModePrivate
+}
+
+// handleAmplificationProbe answers (or not) a mode 6/7 query and records the
+// response-to-request size ratio a reflector would have produced
+func (s *Server) handleAmplificationProbe(st *runState, path replyPath, data []byte, clientAddr *net.UDPAddr) {
+	cfg := st.server.AmplificationTest
+	mode := data[0] & 0x07
+	version := (data[0] >> 3) & 0x07
+	s.stats.Modes[mode].Add(1)
+
+	desc := describeControlQuery(data)
+	sent := 0
+	if cfg.Respond {
+		size := cfg.ResponseSize
+		if size > maxAmplificationPacket {
+			size = maxAmplificationPacket
+		}
+		if size < 12 {
+			size = 12
+		}
+		packets := cfg.ResponsePackets
+		if packets < 1 {
+			packets = 1
+		}
+
+		for i := 0; i < packets; i++ {
+			// Synthetic replies are exactly what the response cap exists for
+			if !s.allowResponse(st, clientAddr.IP.String()) {
+				break
+			}
+			reply := buildControlReply(data, mode, version, i, i < packets-1, size)
+			n, err := s.send(path, reply, clientAddr)
+			if err != nil {
+				s.stats.ErrorCount.Add(1)
+				s.log.Debugf("SERVER", "Failed to send mode %d reply to %s: %v", mode, clientAddr, err)
+				break
+			}
+			sent += n
+		}
+	}
+
+	factor := float64(sent) / float64(len(data))
+	s.stats.mu.Lock()
+	if factor > s.stats.MaxAmplification {
+		s.stats.MaxAmplification = factor
+	}
+	s.stats.mu.Unlock()
+
+	s.log.Warnf("SERVER", "Mode %d query from %s (%s): request %d bytes, response %d bytes, amplification %.1fx",
+		mode, clientAddr, desc, len(data), sent, factor)
+}
+
+// describeControlQuery names the opcode or request code of a mode 6/7 query
+func describeControlQuery(data []byte) string {
+	mode := data[0] & 0x07
+	if mode == ntpcore.ModeControl {
+		if len(data) < 2 {
+			return "truncated"
+		}
+		return fmt.Sprintf("opcode %d", data[1]&0x1f)
+	}
+
+	if len(data) < 4 {
+		return "truncated"
+	}
+	// MON_GETLIST (20) and MON_GETLIST_1 (42) are the classic monlist requests
+	code := data[3]
+	if code == 20 || code == 42 {
+		return fmt.Sprintf("monlist, impl %d", data[2])
+	}
+	return fmt.Sprintf("impl %d req %d", data[2], code)
+}
+
+// buildControlReply builds one synthetic mode 6/7 reply packet of the given
+// size, echoing the request's identifying fields
+func buildControlReply(req []byte, mode, version uint8, seq int, more bool, size int) []byte {
+	reply := make([]byte, size)
+	reply[0] = version<<3 | mode
+
+	if mode == ntpcore.ModeControl {
+		// R bit, M bit, opcode; sequence echoed from the request
+		reply[1] = 0x80
+		if more {
+			reply[1] |= 0x20
+		}
+		if len(req) >= 4 {
+			reply[1] |= req[1] & 0x1f
+			copy(reply[2:4], req[2:4])
+		}
+		binary.BigEndian.PutUint16(reply[8:10], uint16(seq*(size-12)))
+		binary.BigEndian.PutUint16(reply[10:12], uint16(size-12))
+		return reply
+	}
+
+	// Mode 7: R bit and M bit live in the first byte
+	reply[0] |= 0x80
+	if more {
+		reply[0] |= 0x40
+	}
+	reply[1] = byte(seq) & 0x7f
+	if len(req) >= 4 {
+		copy(reply[2:4], req[2:4])
+	}
+	items := (size - 8) / monlistItemSize
+	binary.BigEndian.PutUint16(reply[4:6], uint16(items)&0x0fff)
+	binary.BigEndian.PutUint16(reply[6:8], monlistItemSize)
+	return reply
+}
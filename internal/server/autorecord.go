@@ -0,0 +1,98 @@
+package server
+
+import (
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/attacks"
+	"github.com/neutrinoguy/timehammer/internal/session"
+)
+
+// autoRecordInterval is how often the attack state is checked for
+// logging.auto_record_attacks
+const autoRecordInterval = time.Second
+
+// autoRecorder tracks the recording started for an attack period
+type autoRecorder struct {
+	id        string // Recording started here ("" = none)
+	suspended bool   // Stopped by hand; wait until attacks are off
+}
+
+// autoRecordLoop starts a recording when an attack becomes active and saves
+// it once no attack is, so attack periods are never left unrecorded
+func (s *Server) autoRecordLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(autoRecordInterval)
+	defer ticker.Stop()
+
+	var ar autoRecorder
+	for {
+		select {
+		case <-ticker.C:
+			s.autoRecord(&ar)
+		case <-s.stopChan:
+			s.stopAutoRecording(&ar, "server stopped")
+			return
+		}
+	}
+}
+
+// autoRecord reconciles the recording with the attack in effect
+func (s *Server) autoRecord(ar *autoRecorder) {
+	// A recording stopped or replaced by hand is no longer ours
+	if ar.id != "" {
+		if cur := s.recorder.GetCurrentSession(); cur == nil || cur.ID != ar.id {
+			ar.id = ""
+			ar.suspended = true
+		}
+	}
+
+	attack, _ := s.attackEngine.DescribeActiveAttack()
+	if !s.state().logging.AutoRecordAttacks || attack == attacks.AttackNone {
+		ar.suspended = false
+		s.stopAutoRecording(ar, "no attack active")
+		return
+	}
+
+	if ar.id != "" {
+		s.recorder.AddTag(string(attack))
+		return
+	}
+	if ar.suspended || s.recorder.IsRecording() {
+		return // The user is in charge of recording
+	}
+
+	opts := session.RecordingOptions{
+		Description: "Auto-recorded attack run",
+		Clients:     s.state().logging.RecordClients,
+		Tags:        []string{string(attack)},
+	}
+	if err := s.recorder.StartRecordingWithOptions(opts); err != nil {
+		s.log.Errorf("SESSION", "Failed to start auto-recording: %v", err)
+		return
+	}
+	if cur := s.recorder.GetCurrentSession(); cur != nil {
+		ar.id = cur.ID
+	}
+	s.log.Infof("SESSION", "Attack %s active, auto-recording started (%s)", attack, ar.id)
+}
+
+// stopAutoRecording stops and saves the recording started for an attack
+// period, if it is still running
+func (s *Server) stopAutoRecording(ar *autoRecorder, reason string) {
+	if ar.id == "" {
+		return
+	}
+	id := ar.id
+	ar.id = ""
+	if cur := s.recorder.GetCurrentSession(); cur == nil || cur.ID != id {
+		return
+	}
+
+	sess, err := s.recorder.StopRecording()
+	if err != nil {
+		s.log.Errorf("SESSION", "Failed to save auto-recording: %v", err)
+		return
+	}
+	s.log.Infof("SESSION", "Auto-recording stopped (%s), saved as %s", reason, sess.ID)
+}
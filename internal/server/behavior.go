@@ -0,0 +1,208 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/kodcheck"
+)
+
+// Behavior alert kinds
+const (
+	AlertKoDSilence    = "kod_silence"    // Stopped querying after a RATE KoD
+	AlertOffsetJump    = "offset_jump"    // Clock offset estimate moved by offset_jump or more
+	AlertVersionChange = "version_change" // Switched NTP version
+)
+
+// Behavior detector tuning
+const (
+	behaviorCheckInterval = 5 * time.Second // How often quiet KoD clients are checked
+	alertQueueSize        = 100             // Alerts buffered for the webhook before dropping
+	alertTimeout          = 5 * time.Second // Webhook HTTP timeout
+)
+
+// BehaviorAlert is a change in how a client behaves
+type BehaviorAlert struct {
+	Time    time.Time `json:"time"`
+	Client  string    `json:"client"`
+	Kind    string    `json:"kind"`
+	Message string    `json:"message"`
+}
+
+// behaviorClient is what the detector last saw of a client
+type behaviorClient struct {
+	version     int
+	offset      time.Duration
+	offsetKnown bool
+	kodSilent   bool // Already reported quiet after its KoD
+}
+
+// behaviorDetector compares each request of a client with its previous one
+// and raises an alert when the client's behavior changes
+type behaviorDetector struct {
+	mu      sync.Mutex
+	clients map[string]*behaviorClient
+	queue   chan BehaviorAlert // Alerts waiting for the webhook
+	http    *http.Client
+}
+
+// newBehaviorDetector creates a detector with no clients
+func newBehaviorDetector() *behaviorDetector {
+	return &behaviorDetector{
+		clients: make(map[string]*behaviorClient),
+		queue:   make(chan BehaviorAlert, alertQueueSize),
+		http:    &http.Client{Timeout: alertTimeout},
+	}
+}
+
+// reset forgets all clients, for a new run
+func (d *behaviorDetector) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.clients = make(map[string]*behaviorClient)
+}
+
+// request compares a request with the client's last one. A client's first
+// request only sets its baseline.
+func (d *behaviorDetector) request(ip string, version int, offset time.Duration, offsetOK bool, jump time.Duration) []BehaviorAlert {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	c := d.clients[ip]
+	if c == nil {
+		d.clients[ip] = &behaviorClient{version: version, offset: offset, offsetKnown: offsetOK}
+		return nil
+	}
+
+	var alerts []BehaviorAlert
+	if version != c.version {
+		alerts = append(alerts, BehaviorAlert{Client: ip, Kind: AlertVersionChange,
+			Message: fmt.Sprintf("switched from NTPv%d to NTPv%d", c.version, version)})
+		c.version = version
+	}
+	if offsetOK {
+		if change := offset - c.offset; c.offsetKnown && jump > 0 && (change >= jump || change <= -jump) {
+			alerts = append(alerts, BehaviorAlert{Client: ip, Kind: AlertOffsetJump,
+				Message: fmt.Sprintf("clock offset jumped %s, from %s to %s",
+					signedDuration(change), signedDuration(c.offset), signedDuration(offset))})
+		}
+		c.offset, c.offsetKnown = offset, true
+	}
+	return alerts
+}
+
+// kodSilence reports the clients that have stayed quiet for long enough
+// after their RATE KoD to count as backing off, once each
+func (d *behaviorDetector) kodSilence(results []kodcheck.Result) []BehaviorAlert {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var alerts []BehaviorAlert
+	for _, r := range results {
+		if r.Verdict != kodcheck.Complied || r.After > 0 {
+			continue
+		}
+		c := d.clients[r.Client]
+		if c == nil {
+			c = &behaviorClient{}
+			d.clients[r.Client] = c
+		}
+		if c.kodSilent {
+			continue
+		}
+		c.kodSilent = true
+		alerts = append(alerts, BehaviorAlert{Client: r.Client, Kind: AlertKoDSilence,
+			Message: fmt.Sprintf("stopped querying after a RATE KoD (silent %s, needed %s)",
+				compactDuration(r.Backoff), compactDuration(r.Required))})
+	}
+	return alerts
+}
+
+// prune forgets clients that are no longer active. Clients reported quiet
+// after a KoD are kept so they are not reported again.
+func (d *behaviorDetector) prune(active map[string]time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for ip, c := range d.clients {
+		if _, ok := active[ip]; !ok && !c.kodSilent {
+			delete(d.clients, ip)
+		}
+	}
+}
+
+// checkBehavior compares a client's request with its previous one when
+// behavior alerts are on
+func (s *Server) checkBehavior(st *runState, ip string, version int, offset time.Duration, offsetOK bool) {
+	cfg := st.logging.BehaviorAlerts
+	if !cfg.Enabled {
+		return
+	}
+	jump := time.Duration(cfg.OffsetJump * float64(time.Second))
+	s.raiseAlerts(cfg.Webhook, s.behavior.request(ip, version, offset, offsetOK, jump))
+}
+
+// raiseAlerts logs alerts and queues them for the webhook, dropping them
+// when it falls behind
+func (s *Server) raiseAlerts(webhook string, alerts []BehaviorAlert) {
+	for _, a := range alerts {
+		a.Time = s.clock.Now()
+		s.log.Warnf("BEHAVIOR", "%s %s: %s", a.Client, a.Kind, a.Message)
+		if webhook == "" {
+			continue
+		}
+		select {
+		case s.behavior.queue <- a:
+		default:
+			s.log.Warnf("BEHAVIOR", "Webhook queue full, alert for %s not sent", a.Client)
+		}
+	}
+}
+
+// watchBehavior reports clients that go quiet after a KoD and delivers
+// queued alerts to the webhook
+func (s *Server) watchBehavior() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(behaviorCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if cfg := s.state().logging.BehaviorAlerts; cfg.Enabled {
+				s.raiseAlerts(cfg.Webhook, s.behavior.kodSilence(s.GetKoDCompliance()))
+			}
+		case a := <-s.behavior.queue:
+			if err := s.postAlert(a); err != nil {
+				s.log.Errorf("BEHAVIOR", "Webhook delivery failed: %v", err)
+			}
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// postAlert POSTs one alert as JSON to the configured webhook
+func (s *Server) postAlert(a BehaviorAlert) error {
+	url := s.state().logging.BehaviorAlerts.Webhook
+	if url == "" {
+		return nil
+	}
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	resp, err := s.behavior.http.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"math"
+	"net"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// startBroadcast starts the broadcast sender if broadcasting is enabled,
+// stopping the one already running. Caller must hold s.mu.
+func (s *Server) startBroadcast(cfg config.BroadcastConfig) {
+	if s.stopBcast != nil {
+		close(s.stopBcast)
+		s.stopBcast = nil
+	}
+	if !cfg.Enabled {
+		return
+	}
+	s.stopBcast = make(chan struct{})
+	s.wg.Add(1)
+	go s.broadcastLoop(cfg, s.stopBcast)
+}
+
+// broadcastLoop sends a mode 5 packet to the broadcast address every
+// interval until the server stops or stop is closed
+func (s *Server) broadcastLoop(cfg config.BroadcastConfig, stop chan struct{}) {
+	defer s.wg.Done()
+
+	target := cfg.Address
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		target = net.JoinHostPort(target, "123")
+	}
+	addr, err := net.ResolveUDPAddr("udp", target)
+	if err != nil {
+		s.log.Errorf("SERVER", "Broadcast disabled: invalid address %q: %v", cfg.Address, err)
+		return
+	}
+
+	interval := time.Duration(cfg.IntervalSecs) * time.Second
+	s.log.Infof("SERVER", "Broadcasting mode 5 time to %s every %v", addr, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	s.sendBroadcast(addr, cfg.IntervalSecs)
+	for {
+		select {
+		case <-ticker.C:
+			s.sendBroadcast(addr, cfg.IntervalSecs)
+		case <-s.stopChan:
+			return
+		case <-stop:
+			return
+		}
+	}
+}
+
+// sendBroadcast builds and sends one broadcast packet, applying the active
+// attack as for a response
+func (s *Server) sendBroadcast(addr *net.UDPAddr, intervalSecs int) {
+	dest := addr.String()
+	st := s.state()
+	currentTime := s.serverClock(st)
+
+	packet := ntpcore.NewPacket()
+	packet.Version = 4
+	packet.Mode = ntpcore.ModeBroadcast
+	packet.Stratum, packet.ReferenceID = s.advertisedSource(st)
+	packet.Poll = int8(math.Round(math.Log2(float64(intervalSecs))))
+	packet.Precision = int8(st.server.Precision)
+
+	// Broadcasts answer no request, so only reference and transmit are set
+	packet.SetReferenceTime(referenceTime(st, currentTime))
+	packet.SetTransmitTime(currentTime)
+
+	syncStatus := s.upstream.GetSyncStatus()
+	packet.RootDelay = ntpcore.CalculateRootDelay(float64(syncStatus.RTT) / float64(time.Millisecond))
+	packet.RootDisp = ntpcore.CalculateRootDispersion(10)
+	if s.upstream.Refusing() {
+		packet.LeapIndicator = ntpcore.LeapAlarm
+	}
+	applyQuirks(st.server.Quirks, packet)
+
+	attackName := ""
+	if s.attackEngine.IsEnabled() {
+		packet, attackName = s.attackEngine.ProcessPacket(packet, dest, currentTime, nil)
+		if attackName != "" {
+			s.stats.AttacksExecuted.Add(1)
+		}
+	}
+
+	if st.signKey != nil {
+		packet.Sign(st.signKey)
+	}
+
+	if s.recorder.IsRecording() {
+		activeAttack, params := s.attackEngine.DescribeActiveAttack()
+		s.recorder.RecordAttackState(string(activeAttack), params)
+		s.recorder.RecordClientResponse(dest, packet, 0)
+	}
+
+	if _, err := s.send(replyPath{conn: s.conns[0]}, packet.Bytes(), addr); err != nil {
+		s.stats.ErrorCount.Add(1)
+		s.log.Warnf("SERVER", "Broadcast to %s failed: %v", dest, err)
+		return
+	}
+
+	if attackName != "" {
+		s.log.Debugf("SERVER", "Sent broadcast to %s with attack: %s", dest, attackName)
+	} else {
+		s.log.Debugf("SERVER", "Sent broadcast to %s (time: %s)", dest, currentTime.Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"time"
+
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// clientOffset is the estimated clock offset of a client from true time
+type clientOffset struct {
+	first time.Duration // Estimate when the client was first seen
+	last  time.Duration // Latest estimate
+}
+
+// estimateClientOffset estimates how far a client's clock is from true time
+// using the transmit timestamp of its request. The one-way network delay is
+// not removed, so the estimate reads low by about half the round trip. ok
+// is false when the client sends no transmit timestamp.
+func estimateClientOffset(packet *ntpcore.NTPPacket, trueRx time.Time) (time.Duration, bool) {
+	if packet.XmitTimeSec == 0 && packet.XmitTimeFrac == 0 {
+		return 0, false
+	}
+	return packet.TransmitTimeNear(trueRx).Sub(trueRx), true
+}
+
+// skewed reports whether a request's clock offset exceeds
+// server.max_client_skew, counting and logging the rejection
+func (s *Server) skewed(st *runState, offset time.Duration, clientStr string) bool {
+	limit := time.Duration(st.server.MaxClientSkew) * time.Second
+	if limit <= 0 || (offset <= limit && offset >= -limit) {
+		return false
+	}
+	s.stats.Skewed.Add(1)
+	s.log.Warnf("SERVER", "Rejected request from %s: transmit time is %v off true time (max_client_skew %v)",
+		clientStr, offset.Round(time.Millisecond), limit)
+	return true
+}
+
+// recordClientOffset stores the latest offset estimate for a client. Caller
+// must hold s.stats.mu.
+func (s *Server) recordClientOffset(ip string, offset time.Duration) {
+	est, seen := s.stats.clientOffsets[ip]
+	if !seen {
+		est.first = offset
+	}
+	est.last = offset
+	s.stats.clientOffsets[ip] = est
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"net/netip"
+	"sort"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/logger"
+)
+
+// pollHistorySize is how many poll values are kept per active client
+const pollHistorySize = 16
+
+// clientDetail is what the server tracks about an active client beyond
+// its last-seen time and clock offset
+type clientDetail struct {
+	requests    int
+	attacks     int
+	versions    []int  // Ascending
+	polls       []int8 // Oldest first
+	fingerprint logger.ClientFingerprint
+
+	lastArrival          time.Time
+	intervals            []time.Duration // Times between its requests, oldest first
+	intervalBeforeAttack time.Duration   // Observed interval up to its first attacked response
+}
+
+// detail returns the detail record of an active client, creating it
+// on first use. Caller must hold st.mu.
+func (st *ServerStats) detail(ip string) *clientDetail {
+	d := st.clientDetails[ip]
+	if d == nil {
+		d = &clientDetail{}
+		st.clientDetails[ip] = d
+	}
+	return d
+}
+
+// recordClientDetail notes the fingerprint of a client's request. Caller
+// must hold s.stats.mu.
+func (s *Server) recordClientDetail(ip string, fp *logger.ClientFingerprint) {
+	d := s.stats.detail(ip)
+	d.fingerprint = *fp
+
+	i := sort.SearchInts(d.versions, fp.Version)
+	if i == len(d.versions) || d.versions[i] != fp.Version {
+		d.versions = append(d.versions, 0)
+		copy(d.versions[i+1:], d.versions[i:])
+		d.versions[i] = fp.Version
+	}
+
+	d.polls = append(d.polls, int8(fp.Poll))
+	if len(d.polls) > pollHistorySize {
+		d.polls = d.polls[len(d.polls)-pollHistorySize:]
+	}
+}
+
+// recordClientAttack counts an attacked response to a client
+func (s *Server) recordClientAttack(ip string) {
+	s.stats.mu.Lock()
+	defer s.stats.mu.Unlock()
+	if d := s.stats.clientDetails[ip]; d != nil {
+		d.markAttacked()
+		d.attacks++
+	}
+}
+
+// sortClients orders clients by address, or most recently seen first
+// ("recent", the default) with the address breaking ties, so lists built
+// from the client map keep their order between refreshes
+func sortClients(clients []ClientInfo, order string) {
+	sort.Slice(clients, func(i, j int) bool {
+		if order != "address" && !clients[i].LastSeen.Equal(clients[j].LastSeen) {
+			return clients[i].LastSeen.After(clients[j].LastSeen)
+		}
+		return addressLess(clients[i].Address, clients[j].Address)
+	})
+}
+
+// addressLess orders IP addresses numerically, IPv4 before IPv6, and
+// anything else as text
+func addressLess(a, b string) bool {
+	ipA, errA := netip.ParseAddr(a)
+	ipB, errB := netip.ParseAddr(b)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+	return ipA.Less(ipB)
+}
+
+// forgetClient drops an active client. Caller must hold st.mu.
+func (st *ServerStats) forgetClient(ip string) {
+	delete(st.ActiveClients, ip)
+	delete(st.clientOffsets, ip)
+	delete(st.clientDetails, ip)
+}
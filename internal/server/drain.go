@@ -0,0 +1,53 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// errStopped is returned by send once the server has closed its sockets
+var errStopped = errors.New("server stopped")
+
+// send writes a packet unless the server has closed its sockets, so late
+// responses never hit a closed connection. A source address the kernel
+// refuses (a subnet broadcast the request was sent to) falls back to the
+// routed one.
+func (s *Server) send(path replyPath, b []byte, addr *net.UDPAddr) (int, error) {
+	s.sendMu.RLock()
+	defer s.sendMu.RUnlock()
+	if s.connsClosed {
+		return 0, errStopped
+	}
+	if path.oob != nil {
+		if n, _, err := path.conn.WriteMsgUDP(b, path.oob, addr); err == nil {
+			return n, nil
+		}
+	}
+	return path.conn.WriteToUDP(b, addr)
+}
+
+// drainRequests waits up to server.drain_timeout for requests in flight.
+// Requests still running afterwards are not answered.
+func (s *Server) drainRequests() {
+	done := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(done)
+	}()
+
+	timeout := time.Duration(s.state().server.DrainTimeout) * time.Second
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return
+	default:
+	}
+	select {
+	case <-done:
+	case <-timer.C:
+		s.log.Warnf("SERVER", "Requests still in flight after %v; closing sockets without answering them", timeout)
+	}
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+	"github.com/neutrinoguy/timehammer/internal/logger"
+)
+
+// Requests arriving on the wire and requests still being processed while
+// Stop runs must neither panic nor write to the closed sockets
+func TestStopWithRequestsInFlight(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.ResponseCap.Enabled = false
+	s := startTestServer(t, cfg)
+	path := replyPath{conn: s.conns[0]}
+	serverAddr := s.conns[0].LocalAddr().(*net.UDPAddr)
+	client := testClient(t)
+	clientAddr := client.LocalAddr().(*net.UDPAddr)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+
+	// Traffic over the socket, which the readers stop taking in
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			client.WriteToUDP(clientRequest(), serverAddr)
+			time.Sleep(100 * time.Microsecond)
+		}
+	}()
+
+	// Requests the drain does not wait for, answered before and after the
+	// sockets close
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				s.processRequest(path, clientRequest(), clientAddr)
+			}
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond) // Keep answering after the sockets closed
+	close(done)
+	wg.Wait()
+
+	if n := s.GetStats().ErrorCount; n != 0 {
+		t.Errorf("ErrorCount = %d after stop, want 0", n)
+	}
+	for _, e := range logger.GetLogger().GetEntries(1000) {
+		if strings.Contains(e.Message, "use of closed network connection") {
+			t.Errorf("wrote to a closed socket: %s", e.Message)
+		}
+	}
+	if s.GetStats().TotalResponses == 0 {
+		t.Error("no request was answered before the stop")
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+	"github.com/neutrinoguy/timehammer/internal/netutil"
+)
+
+// dropRule is a parsed per-client drop rule
+type dropRule struct {
+	clients *netutil.AddrSet
+	rate    float64
+	pattern string
+}
+
+// dropper decides which responses are silently dropped. Decisions depend
+// only on the seed, the client and its request number, so the same clients
+// see the same losses on every run regardless of interleaving.
+type dropper struct {
+	mu     sync.Mutex
+	seed   int64
+	rules  []dropRule
+	counts map[string]uint64 // Requests seen per client IP
+}
+
+// newDropper parses the drop settings; seed 0 picks a seed from now
+func newDropper(cfg config.ServerConfig, now time.Time) (*dropper, error) {
+	d := &dropper{seed: cfg.DropSeed, counts: make(map[string]uint64)}
+	if d.seed == 0 {
+		d.seed = now.UnixNano()
+	}
+	for _, r := range cfg.DropClients {
+		set, err := netutil.ParseAddrSet(r.Clients)
+		if err != nil {
+			return nil, err
+		}
+		d.rules = append(d.rules, dropRule{clients: set, rate: r.Rate, pattern: r.Pattern})
+	}
+	return d, nil
+}
+
+// drop reports whether the response to this request should be dropped
+func (d *dropper) drop(ip net.IP, defaultRate float64) bool {
+	key := ip.String()
+
+	d.mu.Lock()
+	n := d.counts[key]
+	d.counts[key]++
+	d.mu.Unlock()
+
+	rate, pattern := defaultRate, ""
+	for _, r := range d.rules {
+		if r.clients.Contains(ip) {
+			rate, pattern = r.rate, r.pattern
+			break
+		}
+	}
+
+	// A pattern such as "..x" repeats: '.' answers, 'x' drops
+	if pattern != "" {
+		return pattern[n%uint64(len(pattern))] == 'x'
+	}
+	if rate <= 0 {
+		return false
+	}
+	return d.roll(key, n) < rate
+}
+
+// roll returns a deterministic uniform value in [0, 1) for a client request
+func (d *dropper) roll(key string, n uint64) float64 {
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[0:8], uint64(d.seed))
+	binary.BigEndian.PutUint64(buf[8:16], n)
+
+	h := fnv.New64a()
+	h.Write(buf[:])
+	h.Write([]byte(key))
+	return float64(h.Sum64()>>11) / (1 << 53)
+}
+
+// prune forgets clients that are no longer tracked as active
+func (d *dropper) prune(active map[string]time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for key := range d.counts {
+		if _, ok := active[key]; !ok {
+			delete(d.counts, key)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/neutrinoguy/timehammer/internal/attacks"
+	"github.com/neutrinoguy/timehammer/internal/config"
+)
+
+// Dropped responses are never sent, so no attack is counted for them
+func TestDroppedResponsesAreNotAttacked(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Security.Enabled = true
+	cfg.Security.ActiveAttack = string(attacks.AttackTimeDrift)
+	cfg.Security.TimeDrift.Enabled = true
+	cfg.Server.ResponseCap.Enabled = false
+	cfg.Server.DropRate = 1
+	s := startTestServer(t, cfg)
+	path := replyPath{conn: s.conns[0]}
+	clientAddr := testClient(t).LocalAddr().(*net.UDPAddr)
+
+	const requests = 10
+	for i := 0; i < requests; i++ {
+		s.processRequest(path, clientRequest(), clientAddr)
+	}
+
+	stats := s.GetStats()
+	if stats.Dropped != requests || stats.TotalResponses != 0 {
+		t.Fatalf("%d dropped, %d sent, want all %d dropped", stats.Dropped, stats.TotalResponses, requests)
+	}
+	if stats.AttacksExecuted != 0 {
+		t.Errorf("AttacksExecuted = %d for responses never sent", stats.AttacksExecuted)
+	}
+	for _, c := range s.GetActiveClients() {
+		if c.Attacks != 0 {
+			t.Errorf("%s: %d attacked responses recorded", c.Address, c.Attacks)
+		}
+	}
+	for _, r := range s.GetClientHistory() {
+		if len(r.Attacks) != 0 {
+			t.Errorf("%s: history records attacks %v", r.Address, r.Attacks)
+		}
+	}
+}
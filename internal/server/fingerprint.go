@@ -0,0 +1,53 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+	"github.com/neutrinoguy/timehammer/internal/fingerprint"
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// identifyClient attempts to identify the NTP client implementation,
+// returning the best guess, its confidence and the runner-up candidates
+func identifyClient(packet *ntpcore.NTPPacket, rx time.Time) (string, float64, []string) {
+	matches := fingerprint.GetDatabase().Identify(packet, rx)
+	if len(matches) == 0 {
+		return fmt.Sprintf("NTPv%d Client", packet.Version), 0, nil
+	}
+
+	var others []string
+	for _, m := range matches[1:] {
+		others = append(others, m.String())
+	}
+	return matches[0].Name, matches[0].Confidence, others
+}
+
+// loadFingerprints adds the signatures from the configured fingerprint file
+func (s *Server) loadFingerprints() {
+	path := s.state().logging.FingerprintDB
+	if path == "" {
+		return
+	}
+	if !filepath.IsAbs(path) {
+		dataDir, err := config.GetDataDir()
+		if err != nil {
+			return
+		}
+		path = filepath.Join(dataDir, path)
+	}
+
+	n, err := fingerprint.GetDatabase().LoadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		// The file is optional
+	case err != nil:
+		s.log.Warnf("SERVER", "Failed to load client signatures: %v", err)
+	default:
+		s.log.Infof("SERVER", "Loaded %d client signature(s) from %s", n, path)
+	}
+}
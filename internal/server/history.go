@@ -0,0 +1,259 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+)
+
+// ClientRecord is the long-term history of one client IP
+type ClientRecord struct {
+	Address   string    `json:"address"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Requests  uint64    `json:"requests"`
+	Versions  []int     `json:"versions"` // NTP versions seen, ascending
+
+	OffsetKnown bool          `json:"offset_known"` // Whether any offset estimate was made
+	FirstOffset time.Duration `json:"first_offset"` // Estimated client clock offset when first measured
+	LastOffset  time.Duration `json:"last_offset"`
+	MinOffset   time.Duration `json:"min_offset"`
+	MaxOffset   time.Duration `json:"max_offset"`
+
+	Attacks map[string]uint64 `json:"attacks,omitempty"` // Attacked requests per attack type
+
+	ObservedInterval   time.Duration `json:"observed_interval,omitempty"`   // Median time between its requests, as last measured
+	AdvertisedInterval time.Duration `json:"advertised_interval,omitempty"` // Poll interval its last request announced
+}
+
+// clientHistory keeps a ClientRecord per client IP for the life of the
+// process, and in a JSON file across restarts when persistence is on
+type clientHistory struct {
+	mu      sync.Mutex
+	records map[string]*ClientRecord
+	path    string // Store file ("" = not persisted)
+	dirty   bool   // Changed since the last save
+}
+
+// newClientHistory creates an empty, unpersisted history
+func newClientHistory() *clientHistory {
+	return &clientHistory{records: make(map[string]*ClientRecord)}
+}
+
+// clientHistoryPath returns the store file in the data directory
+func clientHistoryPath() (string, error) {
+	dataDir, err := config.GetDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, config.ClientHistoryFileName), nil
+}
+
+// persist starts saving to path, first merging in the records stored there.
+// Clients already seen by this process keep their in-memory record. An
+// empty path stops persisting.
+func (h *clientHistory) persist(path string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if path == h.path {
+		return nil
+	}
+	h.path = path
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var stored []ClientRecord
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("invalid client history %s: %w", path, err)
+	}
+	for i := range stored {
+		if _, ok := h.records[stored[i].Address]; !ok {
+			h.records[stored[i].Address] = &stored[i]
+		}
+	}
+	return nil
+}
+
+// request records a request from a client IP
+func (h *clientHistory) request(ip string, at time.Time, version int, offset time.Duration, offsetOK bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := h.records[ip]
+	if r == nil {
+		r = &ClientRecord{Address: ip, FirstSeen: at}
+		h.records[ip] = r
+	}
+	r.LastSeen = at
+	r.Requests++
+
+	i := sort.SearchInts(r.Versions, version)
+	if i == len(r.Versions) || r.Versions[i] != version {
+		r.Versions = append(r.Versions, 0)
+		copy(r.Versions[i+1:], r.Versions[i:])
+		r.Versions[i] = version
+	}
+
+	if offsetOK {
+		if !r.OffsetKnown {
+			r.OffsetKnown = true
+			r.FirstOffset, r.MinOffset, r.MaxOffset = offset, offset, offset
+		}
+		r.LastOffset = offset
+		r.MinOffset = min(r.MinOffset, offset)
+		r.MaxOffset = max(r.MaxOffset, offset)
+	}
+	h.dirty = true
+}
+
+// interval records a client's observed and advertised query intervals.
+// An unmeasured interval keeps the last measurement, which may come from
+// an earlier run.
+func (h *clientHistory) interval(ip string, observed, advertised time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := h.records[ip]
+	if r == nil {
+		return
+	}
+	if observed > 0 {
+		r.ObservedInterval = observed
+	}
+	r.AdvertisedInterval = advertised
+	h.dirty = true
+}
+
+// attack records an attack applied to a client's request
+func (h *clientHistory) attack(ip, attack string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := h.records[ip]
+	if r == nil {
+		return
+	}
+	if r.Attacks == nil {
+		r.Attacks = make(map[string]uint64)
+	}
+	r.Attacks[attack]++
+	h.dirty = true
+}
+
+// snapshot returns copies of all records, most recently seen first
+func (h *clientHistory) snapshot() []ClientRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.copyRecords()
+}
+
+// copyRecords returns copies of all records, most recently seen first.
+// Caller must hold h.mu.
+func (h *clientHistory) copyRecords() []ClientRecord {
+	records := make([]ClientRecord, 0, len(h.records))
+	for _, r := range h.records {
+		c := *r
+		c.Versions = append([]int(nil), r.Versions...)
+		if r.Attacks != nil {
+			c.Attacks = make(map[string]uint64, len(r.Attacks))
+			for k, v := range r.Attacks {
+				c.Attacks[k] = v
+			}
+		}
+		records = append(records, c)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].LastSeen.Equal(records[j].LastSeen) {
+			return records[i].LastSeen.After(records[j].LastSeen)
+		}
+		return records[i].Address < records[j].Address
+	})
+	return records
+}
+
+// save writes the history to its store file if it changed. Changes made
+// while it writes stay dirty for the next save, and so does the snapshot
+// if the write fails.
+func (h *clientHistory) save() error {
+	h.mu.Lock()
+	path := h.path
+	if path == "" || !h.dirty {
+		h.mu.Unlock()
+		return nil
+	}
+	records := h.copyRecords()
+	h.dirty = false
+	h.mu.Unlock()
+
+	if err := writeClientHistory(path, records); err != nil {
+		h.mu.Lock()
+		h.dirty = true
+		h.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// writeClientHistory stores records in the file at path
+func writeClientHistory(path string, records []ClientRecord) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write then rename so a crash never leaves a truncated store
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// GetClientHistory returns the history of every client seen, including
+// those from earlier runs when client_history is on, most recent first
+func (s *Server) GetClientHistory() []ClientRecord {
+	return s.history.snapshot()
+}
+
+// setupClientHistory starts or stops persisting the client history.
+// Caller must hold s.mu.
+func (s *Server) setupClientHistory() {
+	path := ""
+	if s.state().logging.ClientHistory {
+		var err error
+		if path, err = clientHistoryPath(); err != nil {
+			s.log.Errorf("SERVER", "Client history not persisted: %v", err)
+			return
+		}
+	}
+	if err := s.history.persist(path); err != nil {
+		s.log.Errorf("SERVER", "Failed to load client history: %v", err)
+		return
+	}
+	if path != "" {
+		s.log.Infof("SERVER", "Client history persisted to %s", path)
+	}
+}
+
+// saveClientHistory writes the persisted client history, if it changed
+func (s *Server) saveClientHistory() {
+	if err := s.history.save(); err != nil {
+		s.log.Errorf("SERVER", "Failed to save client history: %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// A failed write keeps the history dirty so the next save retries it
+func TestClientHistorySaveRetriesAfterFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "missing", "clients.json") // Parent does not exist yet
+	h := newClientHistory()
+	if err := h.persist(path); err != nil {
+		t.Fatal(err)
+	}
+	h.request("192.0.2.1", time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC), 4, 0, false)
+
+	if err := h.save(); err == nil {
+		t.Fatal("save into a missing directory succeeded")
+	}
+	if err := os.Mkdir(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("history not written on retry: %v", err)
+	}
+	if h.dirty {
+		t.Error("still dirty after a successful save")
+	}
+}
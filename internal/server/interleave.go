@@ -0,0 +1,105 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// interleaveEntry is what was sent in the last response to a client
+type interleaveEntry struct {
+	rxSec, rxFrac uint32 // Receive timestamp of the last response
+	txSec, txFrac uint32 // Transmit timestamp of the last response
+	replies       int    // Interleaved responses sent so far
+	seen          time.Time
+
+	// Basic-mode transmit timestamp of an interleaved response being sent,
+	// which is what the next interleaved response must report
+	pending                 bool
+	pendingSec, pendingFrac uint32
+}
+
+// interleaveState remembers the last response to each client so requests
+// in interleaved mode can be answered with the previous transmit timestamp.
+// Clients are keyed by IP because they may change source port between
+// requests.
+type interleaveState struct {
+	mu      sync.Mutex
+	clients map[string]*interleaveEntry
+}
+
+// newInterleaveState creates an empty interleave tracker
+func newInterleaveState() *interleaveState {
+	return &interleaveState{clients: make(map[string]*interleaveEntry)}
+}
+
+// apply rewrites a basic-mode response for an interleaved request. A
+// client asks for interleaved mode by putting the receive timestamp of our
+// previous response in its origin field. The answer then carries the
+// request's receive field (the client's receive time of that response) as
+// origin and the transmit timestamp of the previous response. In
+// "inconsistent" mode every other answer keeps the basic-mode transmit
+// timestamp while still claiming interleaved mode. Returns whether the
+// response was rewritten.
+func (st *interleaveState) apply(ip, mode string, request, response *ntpcore.NTPPacket) bool {
+	if mode != "on" && mode != "inconsistent" {
+		return false
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	e, ok := st.clients[ip]
+	if !ok {
+		return false
+	}
+	e.pending = false // Left over if the last response was never sent
+
+	if request.OrigTimeSec == 0 && request.OrigTimeFrac == 0 {
+		return false
+	}
+	if request.OrigTimeSec != e.rxSec || request.OrigTimeFrac != e.rxFrac {
+		return false
+	}
+
+	e.replies++
+	e.pending, e.pendingSec, e.pendingFrac = true, response.XmitTimeSec, response.XmitTimeFrac
+	response.SetOriginTime(request.RecvTimeSec, request.RecvTimeFrac)
+	if mode == "inconsistent" && e.replies%2 == 0 {
+		return true
+	}
+	response.XmitTimeSec, response.XmitTimeFrac = e.txSec, e.txFrac
+	return true
+}
+
+// sent records the timestamps of a response that went out
+func (st *interleaveState) sent(ip string, response *ntpcore.NTPPacket, now time.Time) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	e, ok := st.clients[ip]
+	if !ok {
+		e = &interleaveEntry{}
+		st.clients[ip] = e
+	}
+	e.rxSec, e.rxFrac = response.RecvTimeSec, response.RecvTimeFrac
+	e.txSec, e.txFrac = response.XmitTimeSec, response.XmitTimeFrac
+	if e.pending {
+		e.txSec, e.txFrac = e.pendingSec, e.pendingFrac
+		e.pending = false
+	}
+	e.seen = now
+}
+
+// prune forgets clients not answered within maxAge
+func (st *interleaveState) prune(now time.Time, maxAge time.Duration) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	for ip, e := range st.clients {
+		if now.Sub(e.seen) > maxAge {
+			delete(st.clients, ip)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"slices"
+	"time"
+)
+
+// fastPollRatio is how far below its advertised poll interval a client may
+// query before it counts as polling fast; allows for clock filter bursts
+// and rounding of the poll exponent
+const fastPollRatio = 0.75
+
+// recordArrival notes the arrival of a client's request and returns the
+// client's observed query interval, 0 until there are two requests. Caller
+// must hold st.mu.
+func (d *clientDetail) recordArrival(at time.Time) time.Duration {
+	if !d.lastArrival.IsZero() && at.After(d.lastArrival) {
+		d.intervals = append(d.intervals, at.Sub(d.lastArrival))
+		if len(d.intervals) > pollHistorySize {
+			d.intervals = d.intervals[len(d.intervals)-pollHistorySize:]
+		}
+	}
+	d.lastArrival = at
+	return d.observedInterval()
+}
+
+// observedInterval returns the median time between a client's recent
+// requests, so a burst or a missed poll does not skew it
+func (d *clientDetail) observedInterval() time.Duration {
+	if len(d.intervals) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(d.intervals)
+	slices.Sort(sorted)
+	return sorted[len(sorted)/2]
+}
+
+// markAttacked keeps the cadence a client had before its first attacked
+// response and starts measuring afresh, so a change after a KoD or an
+// attack shows. Caller must hold st.mu.
+func (d *clientDetail) markAttacked() {
+	if d.attacks > 0 {
+		return
+	}
+	d.intervalBeforeAttack = d.observedInterval()
+	d.intervals = nil
+}
+
+// advertisedInterval returns the poll interval a poll exponent announces,
+// or 0 if the client does not advertise one (SNTP clients send 0)
+func advertisedInterval(poll int8) time.Duration {
+	if poll <= 0 || poll > 17 {
+		return 0
+	}
+	return time.Duration(1<<poll) * time.Second
+}
+
+// PollsFast reports whether the client queries noticeably faster than the
+// poll interval it advertises
+func (c ClientInfo) PollsFast() bool {
+	return c.ObservedInterval > 0 && c.AdvertisedInterval > 0 &&
+		float64(c.ObservedInterval) < fastPollRatio*float64(c.AdvertisedInterval)
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	mrand "math/rand"
+	"sync"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+)
+
+// JitterStats summarizes the holds added by the jitter emulation
+type JitterStats struct {
+	Count uint64 // Responses held
+	Mean  time.Duration
+	Min   time.Duration
+	Max   time.Duration
+}
+
+// jitterer draws random per-response holds and keeps their stats
+type jitterer struct {
+	mu    sync.Mutex
+	seed  int64
+	rng   *mrand.Rand
+	count uint64
+	total time.Duration
+	min   time.Duration
+	max   time.Duration
+}
+
+// newJitterer creates a jitter source; seed 0 picks a seed from now
+func newJitterer(seed int64, now time.Time) *jitterer {
+	if seed == 0 {
+		seed = now.UnixNano()
+	}
+	return &jitterer{seed: seed, rng: mrand.New(mrand.NewSource(seed))}
+}
+
+// next draws the hold for one response and records it
+func (j *jitterer) next(cfg config.JitterConfig) time.Duration {
+	if cfg.Ms <= 0 {
+		return 0
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	// Offset from the centre in [-1, 1]
+	var u float64
+	if cfg.Distribution == "normal" {
+		u = j.rng.NormFloat64() / 2
+		if u < -1 {
+			u = -1
+		} else if u > 1 {
+			u = 1
+		}
+	} else {
+		u = j.rng.Float64()*2 - 1
+	}
+	hold := time.Duration((1 + u) * cfg.Ms * float64(time.Millisecond))
+
+	if j.count == 0 || hold < j.min {
+		j.min = hold
+	}
+	if hold > j.max {
+		j.max = hold
+	}
+	j.count++
+	j.total += hold
+	return hold
+}
+
+// stats returns the holds added so far
+func (j *jitterer) stats() JitterStats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	st := JitterStats{Count: j.count, Min: j.min, Max: j.max}
+	if j.count > 0 {
+		st.Mean = j.total / time.Duration(j.count)
+	}
+	return st
+}
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// Results of checking the MAC of an authenticated request
+const (
+	macVerified   = "verified"
+	macBad        = "bad_mac"
+	macUnknownKey = "unknown_key"
+	macCryptoNAK  = "crypto_nak"
+	macUnchecked  = "unchecked" // Authentication is disabled
+)
+
+// checkRequestMAC verifies the MAC of an authenticated request against the
+// configured keys
+func (s *Server) checkRequestMAC(st *runState, request *ntpcore.NTPPacket) string {
+	if request.IsCryptoNAK() {
+		return macCryptoNAK
+	}
+	if st.keys == nil {
+		return macUnchecked
+	}
+
+	key, ok := st.keys[request.KeyID]
+	if !ok {
+		return macUnknownKey
+	}
+	if valid, err := request.VerifyMAC(key.Secret, key.Type); err != nil || !valid {
+		return macBad
+	}
+	return macVerified
+}
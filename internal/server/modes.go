@@ -0,0 +1,36 @@
+package server
+
+import "github.com/neutrinoguy/timehammer/pkg/ntpcore"
+
+// acceptNonClient logs a packet that is not a client request and reports
+// whether it is answered anyway. Symmetric active (mode 1) and broadcast
+// (mode 5) packets usually come from misconfigured devices, so they are
+// logged at info level; a mode 1 packet is answered in symmetric passive
+// mode when server.symmetric_passive is on.
+func (s *Server) acceptNonClient(st *runState, packet *ntpcore.NTPPacket, clientStr string) bool {
+	switch packet.Mode {
+	case ntpcore.ModeSymmetricActive:
+		if st.server.SymmetricPassive && packet.IsValidSymmetricActive() {
+			s.log.Infof("SERVER", "Symmetric active packet from %s (v%d), answering in symmetric passive mode",
+				clientStr, packet.Version)
+			return true
+		}
+		s.log.Infof("SERVER", "Symmetric active packet from %s (v%d, stratum %d) not answered; the device is configured to peer",
+			clientStr, packet.Version, packet.Stratum)
+	case ntpcore.ModeBroadcast:
+		s.log.Infof("SERVER", "Broadcast packet from %s (v%d, stratum %d); the device is sending broadcasts",
+			clientStr, packet.Version, packet.Stratum)
+	default:
+		s.log.Debugf("SERVER", "Non-client packet from %s (mode: %s)", clientStr, packet.GetModeString())
+	}
+	return false
+}
+
+// modeCounts returns the number of packets received per NTP mode
+func (st *ServerStats) modeCounts() [8]uint64 {
+	var counts [8]uint64
+	for mode := range counts {
+		counts[mode] = st.Modes[mode].Load()
+	}
+	return counts
+}
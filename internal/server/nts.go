@@ -0,0 +1,43 @@
+package server
+
+import (
+	"crypto/rand"
+
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// Sizes of the fabricated NTS fields sent in malform mode
+const (
+	ntsFakeCookieSize = 100 // Typical AES-SIV-CMAC-256 cookie
+	ntsFakeNonceSize  = 16
+	ntsFakeCipherSize = 16
+)
+
+// applyNTS shapes the response to an NTS request according to
+// server.nts.mode and returns a short description of what was sent
+func (s *Server) applyNTS(st *runState, nts ntpcore.NTSRequest, response *ntpcore.NTPPacket) string {
+	switch st.server.NTS.Mode {
+	case "reject":
+		// NTS NAK: kiss code NTSN plus the echoed Unique Identifier (RFC 8915 section 5.7)
+		response.SetKissOfDeathCode(ntpcore.KoDNTSNak)
+		response.AddExtension(ntpcore.ExtTypeUniqueID, nts.UniqueID)
+		return "NTS NAK"
+	case "malform":
+		// Structurally broken: the authenticator claims more bytes than it holds
+		response.AddExtension(ntpcore.ExtTypeUniqueID, nts.UniqueID)
+		response.AddExtension(ntpcore.ExtTypeNTSCookie, randomBytes(ntsFakeCookieSize))
+		nonce, cipher := randomBytes(ntsFakeNonceSize), randomBytes(ntsFakeCipherSize)
+		response.AddExtension(ntpcore.ExtTypeNTSAuthenticator,
+			ntpcore.NTSAuthenticator(nonce, cipher, ntsFakeNonceSize, 0xFFFF))
+		return "malformed NTS fields"
+	default:
+		return "unauthenticated response"
+	}
+}
+
+// randomBytes returns n random bytes
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b)
+	return b
+}
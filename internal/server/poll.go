@@ -0,0 +1,23 @@
+package server
+
+import "github.com/neutrinoguy/timehammer/internal/config"
+
+// responsePoll returns the poll value to send for a client's poll under the
+// configured policy
+func responsePoll(policy config.PollPolicyConfig, clientPoll int8) int8 {
+	switch policy.Mode {
+	case "fixed":
+		return int8(policy.Value)
+	case "clamp":
+		poll := int(clientPoll)
+		if poll < policy.Min {
+			poll = policy.Min
+		}
+		if poll > policy.Max {
+			poll = policy.Max
+		}
+		return int8(poll)
+	default:
+		return clientPoll
+	}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"time"
+
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// proxyRequest relays a client's request to the proxy upstream and returns
+// the real answer, with the time it carries for the attacks to shift. The
+// answer is nil when upstream could not be reached; the client then gets
+// nothing, as behind a broken path.
+func (s *Server) proxyRequest(st *runState, data []byte, clientStr string) (*ntpcore.NTPPacket, time.Time) {
+	reply, from, rtt, err := s.upstream.Forward(data, st.server.Proxy.Upstream)
+	if err == nil {
+		var response *ntpcore.NTPPacket
+		if response, err = ntpcore.ParsePacket(reply); err == nil {
+			s.stats.Proxied.Add(1)
+			s.stats.proxyRTT.Add(int64(rtt))
+			s.log.Debugf("SERVER", "Relayed request from %s to %s (rtt %v)", clientStr, from, rtt.Round(time.Microsecond))
+			return response, response.TransmitTimeNear(s.now(st))
+		}
+	}
+
+	s.stats.ProxyErrors.Add(1)
+	s.log.Warnf("SERVER", "Failed to relay request from %s: %v", clientStr, err)
+	return nil, time.Time{}
+}
+
+// meanProxyRTT returns the mean round trip of relayed requests
+func (st *ServerStats) meanProxyRTT() time.Duration {
+	n := st.Proxied.Load()
+	if n == 0 {
+		return 0
+	}
+	return time.Duration(st.proxyRTT.Load() / int64(n))
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// applyQuirks gives a response the configured habits of a real server
+func applyQuirks(q config.QuirksConfig, p *ntpcore.NTPPacket) {
+	if q.OmitReferenceTime {
+		p.RefTimeSec, p.RefTimeFrac = 0, 0
+	}
+	if q.ForcePoll != nil {
+		p.Poll = int8(*q.ForcePoll)
+	}
+	if q.ZeroPrecision {
+		p.Precision = 0
+	}
+}
+
+// describeQuirks lists the quirks in use, e.g. "omit_reference_time,
+// force_poll=0" ("" = none)
+func describeQuirks(q config.QuirksConfig) string {
+	var names []string
+	if q.OmitReferenceTime {
+		names = append(names, "omit_reference_time")
+	}
+	if q.ForcePoll != nil {
+		names = append(names, fmt.Sprintf("force_poll=%d", *q.ForcePoll))
+	}
+	if q.ZeroPrecision {
+		names = append(names, "zero_precision")
+	}
+	return strings.Join(names, ", ")
+}
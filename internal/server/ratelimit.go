@@ -0,0 +1,180 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenBucket is a simple token bucket rate limiter
+type tokenBucket struct {
+	rate   float64 // Tokens added per second
+	burst  float64 // Maximum tokens
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket creates a full token bucket
+func newTokenBucket(rate, burst float64, now time.Time) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   now,
+	}
+}
+
+// allow consumes a token if one is available
+func (b *tokenBucket) allow(now time.Time) bool {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens += elapsed * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// refund returns a token taken by allow for a request that was not served
+func (b *tokenBucket) refund() {
+	b.tokens = min(b.tokens+1, b.burst)
+}
+
+// clientLimiter rate limits incoming requests per client IP
+type clientLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// newClientLimiter creates an empty client limiter
+func newClientLimiter() *clientLimiter {
+	return &clientLimiter{
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// allow reports whether client may send another request
+func (l *clientLimiter) allow(client string, rate float64, burst int, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[client]
+	if !ok || b.rate != rate || b.burst != float64(burst) {
+		b = newTokenBucket(rate, float64(burst), now)
+		l.buckets[client] = b
+	}
+	return b.allow(now)
+}
+
+// prune removes buckets that have been idle longer than maxIdle
+func (l *clientLimiter) prune(now time.Time, maxIdle time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for client, b := range l.buckets {
+		if now.Sub(b.last) > maxIdle {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// responseCap enforces global and per-source response rate ceilings so the
+// server cannot be abused as a traffic amplifier
+type responseCap struct {
+	mu           sync.Mutex
+	global       *tokenBucket
+	perSource    map[string]*tokenBucket
+	globalCapped bool
+	capDropped   uint64 // Drops since the global cap engaged
+}
+
+// newResponseCap creates an empty response cap
+func newResponseCap() *responseCap {
+	return &responseCap{
+		perSource: make(map[string]*tokenBucket),
+	}
+}
+
+// capResult describes the outcome of a response cap check
+type capResult int
+
+const (
+	capAllowed capResult = iota
+	capGlobal
+	capSource
+)
+
+// check decides whether a response to source may be sent.
+// globalRate and sourceRate are responses per second (0 = unlimited).
+// A source over its own cap does not use up the global budget, and a
+// response the global cap drops does not count against its source.
+func (c *responseCap) check(source string, globalRate, sourceRate int, now time.Time) capResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var src *tokenBucket
+	if sourceRate > 0 {
+		b, ok := c.perSource[source]
+		if !ok || b.rate != float64(sourceRate) {
+			b = newTokenBucket(float64(sourceRate), float64(sourceRate), now)
+			c.perSource[source] = b
+		}
+		if !b.allow(now) {
+			return capSource
+		}
+		src = b
+	}
+
+	if globalRate > 0 {
+		if c.global == nil || c.global.rate != float64(globalRate) {
+			c.global = newTokenBucket(float64(globalRate), float64(globalRate), now)
+		}
+		if !c.global.allow(now) {
+			if src != nil {
+				src.refund()
+			}
+			c.capDropped++
+			return capGlobal
+		}
+	}
+
+	return capAllowed
+}
+
+// setGlobalCapped records the global cap state and reports whether it changed.
+// The number of responses dropped while capped is returned on release.
+func (c *responseCap) setGlobalCapped(capped bool) (changed bool, dropped uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.globalCapped == capped {
+		return false, 0
+	}
+	c.globalCapped = capped
+	dropped = c.capDropped
+	if !capped {
+		c.capDropped = 0
+	}
+	return true, dropped
+}
+
+// prune removes per-source buckets that have been idle longer than maxIdle
+func (c *responseCap) prune(now time.Time, maxIdle time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for source, b := range c.perSource {
+		if now.Sub(b.last) > maxIdle {
+			delete(c.perSource, source)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// A response the global cap drops must not use up its source's budget
+func TestResponseCapRefundsSourceOnGlobalCap(t *testing.T) {
+	c := newResponseCap()
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 10; i++ {
+		if got := c.check(fmt.Sprintf("192.0.2.%d", i), 10, 1, now); got != capAllowed {
+			t.Fatalf("source %d: %v, want allowed", i, got)
+		}
+	}
+	if got := c.check("198.51.100.1", 10, 1, now); got != capGlobal {
+		t.Fatalf("over the global cap: %v, want capGlobal", got)
+	}
+
+	// One global token back; the source never got to use its own
+	now = now.Add(100 * time.Millisecond)
+	if got := c.check("198.51.100.1", 10, 1, now); got != capAllowed {
+		t.Errorf("after the global cap refilled: %v, want allowed", got)
+	}
+}
+
+// A source over its own cap must not use up the global budget
+func TestResponseCapSourceCapSparesGlobal(t *testing.T) {
+	c := newResponseCap()
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	c.check("192.0.2.1", 2, 1, now)
+	for i := 0; i < 5; i++ {
+		if got := c.check("192.0.2.1", 2, 1, now); got != capSource {
+			t.Fatalf("flooding source: %v, want capSource", got)
+		}
+	}
+	if got := c.check("192.0.2.2", 2, 1, now); got != capAllowed {
+		t.Errorf("other source: %v, want allowed", got)
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+)
+
+// Reload applies a new configuration to the server. The settings are copied
+// into the live config, so stats, active clients and attack state survive.
+// The listener is only rebound (a full stop/start) when the port or
+// interface changed.
+func (s *Server) Reload(cfg *config.Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	live := s.cfg
+	cur := s.state()
+	oldUpstream := live.Upstream
+
+	// The engine and the upstream client read the shared config under
+	// their own locks
+	s.attackEngine.EditConfig(func() {
+		s.upstream.EditConfig(func() {
+			live.CopyFrom(cfg)
+		})
+	})
+	logging := live.GetLogging()
+
+	if logging.Level != cur.logging.Level {
+		s.log.SetLevel(logging.Level)
+	}
+	if !reflect.DeepEqual(logging.Sinks, cur.logging.Sinks) {
+		s.log.SetSinks(logging.Sinks)
+	}
+
+	oldServer, newServer := cur.server, live.GetServer()
+	running := s.running.Load()
+	rebind := running && (newServer.Port != oldServer.Port || newServer.Interface != oldServer.Interface ||
+		!reflect.DeepEqual(newServer.Ports, oldServer.Ports))
+	if rebind {
+		s.mu.Unlock()
+		s.log.Infof("CONFIG", "Listen address changed (%s:%d%s -> %s:%d%s), rebinding",
+			oldServer.Interface, oldServer.Port, describePorts(oldServer.Ports),
+			newServer.Interface, newServer.Port, describePorts(newServer.Ports))
+		if err := s.Stop(); err != nil {
+			return fmt.Errorf("reload: %w", err)
+		}
+		if err := s.Start(); err != nil {
+			return fmt.Errorf("reload: %w", err)
+		}
+		s.log.Info("CONFIG", "Configuration reloaded")
+		return nil
+	}
+	defer s.mu.Unlock()
+
+	// Requests in flight finish with the state they started with
+	if err := s.publishState(); err != nil {
+		return fmt.Errorf("reload: %w", err)
+	}
+
+	if running && !reflect.DeepEqual(live.Upstream, oldUpstream) {
+		if live.Upstream.SyncInterval != oldUpstream.SyncInterval {
+			// The sync loop reads its interval once
+			s.upstream.Stop()
+			s.upstream.Start()
+		} else {
+			s.upstream.ForceSync()
+		}
+	}
+
+	s.log.Info("CONFIG", "Configuration reloaded")
+	return nil
+}
+
+// describePorts formats additional listen ports for the rebind message
+func describePorts(ports []int) string {
+	if len(ports) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" +%v", ports)
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/neutrinoguy/timehammer/internal/attacks"
+	"github.com/neutrinoguy/timehammer/internal/config"
+)
+
+// startReloadableServer starts a test server and rebinds it to the port it
+// was given, as Reload rejects port 0. cfg is updated to the port.
+func startReloadableServer(t *testing.T, cfg *config.Config) *Server {
+	t.Helper()
+	s := startTestServer(t, cfg)
+	cfg.Server.Port = s.conns[0].LocalAddr().(*net.UDPAddr).Port
+	if err := s.Reload(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// Requests in flight while the config is reloaded are served with either
+// the old settings or the new ones; run with -race
+func TestReloadWithRequestsInFlight(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.ResponseCap.Enabled = false
+	s := startReloadableServer(t, cfg)
+	path := replyPath{conn: s.conns[0]}
+	clientAddr := testClient(t).LocalAddr().(*net.UDPAddr)
+	started := cfg.GetServer() // Reload copies into cfg
+
+	// Alternates between the started config and one that turns on every
+	// setting with per-run state
+	variant := func(i int) *config.Config {
+		next := config.DefaultConfig()
+		next.Server = started
+		next.Upstream.Servers = nil
+		next.Logging.ClientHistory = false
+		if i%2 == 1 {
+			next.Server.Signing = config.SigningConfig{Enabled: true, Key: "74696d6568616d6d6572"}
+			next.Server.BaselineOffset = config.BaselineOffsetConfig{Enabled: true, MaxSecs: 5, Seed: int64(i)}
+			next.Server.Jitter = config.JitterConfig{Enabled: true, Ms: 0.01, Distribution: "uniform", Seed: int64(i)}
+			next.Server.FrozenTime.Base = "2030-01-01T00:00:00Z"
+			next.Server.DropRate = 0.5
+			next.Security.Enabled = true
+			next.Security.ActiveAttack = string(attacks.AttackTimeDrift)
+		}
+		return next
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				s.processRequest(path, clientRequest(), clientAddr)
+			}
+		}()
+	}
+
+	for i := 1; i <= 20; i++ {
+		if err := s.Reload(variant(i)); err != nil {
+			t.Fatal(err)
+		}
+		if got, on := s.GetBaselineOffset() != 0, i%2 == 1; got != on {
+			t.Fatalf("reload %d: baseline offset in effect = %v, want %v", i, got, on)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	if n := s.GetStats().ErrorCount; n != 0 {
+		t.Errorf("ErrorCount = %d, want 0", n)
+	}
+}
+
+// A reload that changes the broadcast settings restarts the sender with
+// them, without a rebind
+func TestReloadRestartsBroadcast(t *testing.T) {
+	cfg := config.DefaultConfig()
+	s := startReloadableServer(t, cfg)
+	if s.stopBcast != nil {
+		t.Fatal("broadcasting with broadcast disabled")
+	}
+
+	next := config.DefaultConfig()
+	next.Server = cfg.GetServer()
+	next.Upstream.Servers = nil
+	next.Logging.ClientHistory = false
+	next.Server.Broadcast = config.BroadcastConfig{Enabled: true, Address: "127.0.0.1:9", IntervalSecs: 60}
+	if err := s.Reload(next); err != nil {
+		t.Fatal(err)
+	}
+	if s.stopBcast == nil {
+		t.Fatal("broadcast not started by the reload")
+	}
+
+	next.Server.Broadcast.Enabled = false
+	if err := s.Reload(next); err != nil {
+		t.Fatal(err)
+	}
+	if s.stopBcast != nil {
+		t.Error("broadcast not stopped by the reload")
+	}
+}
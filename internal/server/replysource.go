@@ -0,0 +1,84 @@
+package server
+
+import (
+	"net"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// replyPath is where a response goes out: the socket the request arrived
+// on and, for a socket bound to all addresses, a control message that sends
+// it from the local address the request was sent to
+type replyPath struct {
+	conn *net.UDPConn
+	oob  []byte // IP_PKTINFO/IPV6_PKTINFO for the reply (nil = the routing table picks)
+}
+
+// dstReader reads requests on a wildcard socket together with the local
+// address each one was sent to. Multi-homed hosts otherwise answer from
+// the address of the default route, which strict clients drop.
+type dstReader struct {
+	v6  bool   // IPV6_PKTINFO (dual-stack and IPv6 sockets) rather than IP_PKTINFO
+	oob []byte // Receive buffer for the control messages
+}
+
+// newDstReader asks the kernel for the destination address of every
+// datagram on conn. Returns nil when conn is bound to one address, where
+// replies already leave from it, or the platform lacks the socket options.
+func newDstReader(conn *net.UDPConn) (*dstReader, error) {
+	local, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok || (local.IP != nil && !local.IP.IsUnspecified()) {
+		return nil, nil
+	}
+
+	// A dual-stack socket reports IPv4 requests as mapped IPv6 addresses
+	if err := ipv6.NewPacketConn(conn).SetControlMessage(ipv6.FlagDst|ipv6.FlagInterface, true); err == nil {
+		return &dstReader{v6: true, oob: ipv6.NewControlMessage(ipv6.FlagDst | ipv6.FlagInterface)}, nil
+	}
+	if err := ipv4.NewPacketConn(conn).SetControlMessage(ipv4.FlagDst|ipv4.FlagInterface, true); err != nil {
+		return nil, err
+	}
+	return &dstReader{oob: ipv4.NewControlMessage(ipv4.FlagDst | ipv4.FlagInterface)}, nil
+}
+
+// read reads one request and returns the control message that answers it
+// from the address it was sent to (nil if that cannot be a source)
+func (r *dstReader) read(conn *net.UDPConn, b []byte) (int, *net.UDPAddr, []byte, error) {
+	n, oobn, _, addr, err := conn.ReadMsgUDP(b, r.oob)
+	if err != nil || oobn == 0 {
+		return n, addr, nil, err
+	}
+
+	var dst net.IP
+	var ifIndex int
+	if r.v6 {
+		var cm ipv6.ControlMessage
+		if cm.Parse(r.oob[:oobn]) != nil {
+			return n, addr, nil, nil
+		}
+		dst, ifIndex = cm.Dst, cm.IfIndex
+	} else {
+		var cm ipv4.ControlMessage
+		if cm.Parse(r.oob[:oobn]) != nil {
+			return n, addr, nil, nil
+		}
+		dst, ifIndex = cm.Dst, cm.IfIndex
+	}
+	if !replySource(dst) {
+		return n, addr, nil, nil
+	}
+
+	// IPv4 requests on a dual-stack socket are answered with IP_PKTINFO
+	if dst.To4() != nil {
+		return n, addr, (&ipv4.ControlMessage{Src: dst, IfIndex: ifIndex}).Marshal(), nil
+	}
+	return n, addr, (&ipv6.ControlMessage{Src: dst, IfIndex: ifIndex}).Marshal(), nil
+}
+
+// replySource reports whether a request's destination can be the source
+// of its reply; broadcast and multicast requests are answered from the
+// interface address the routing table picks
+func replySource(dst net.IP) bool {
+	return dst != nil && !dst.IsUnspecified() && !dst.IsMulticast() && !dst.Equal(net.IPv4bcast)
+}
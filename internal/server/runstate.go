@@ -0,0 +1,117 @@
+package server
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/auth"
+	"github.com/neutrinoguy/timehammer/internal/clock"
+	"github.com/neutrinoguy/timehammer/internal/config"
+)
+
+// runState is the configuration requests are served with and the per-run
+// state derived from it. A published runState is never modified: Start,
+// Reload and UpdateConfig build a new one and swap it in whole, so a
+// request sees either the old settings or the new ones, never a mix.
+type runState struct {
+	server  config.ServerConfig
+	logging config.LoggingConfig
+
+	signKey  []byte        // HMAC key for response signing (nil = disabled)
+	keys     auth.Keys     // Trusted symmetric keys for MACs (nil = auth disabled)
+	baseline time.Duration // Per-start constant offset (baseline offset mode)
+	frozen   *clock.Frozen // Base of served time in frozen time mode (nil = off)
+	drops    *dropper      // Simulated packet loss
+	jitter   *jitterer     // Simulated path jitter
+}
+
+// state returns the run state requests are served with
+func (s *Server) state() *runState {
+	return s.run.Load()
+}
+
+// newRunState builds the run state for a start, setting up every piece of
+// per-run state afresh
+func (s *Server) newRunState() (*runState, error) {
+	st := &runState{server: s.cfg.GetServer(), logging: s.cfg.GetLogging()}
+
+	// Prepare response signing key
+	if err := s.setupSigning(st); err != nil {
+		return nil, err
+	}
+
+	// Load symmetric keys for MAC authentication
+	if err := s.setupAuth(st); err != nil {
+		return nil, err
+	}
+
+	// Pick the baseline offset for this run
+	s.setupBaselineOffset(st)
+
+	// Prepare the packet loss simulation
+	if err := s.setupDrops(st); err != nil {
+		return nil, err
+	}
+
+	// Prepare the path jitter emulation
+	s.setupJitter(st)
+
+	// Pin the served time in frozen time mode
+	if err := s.setupFrozenTime(st); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// publishState swaps in a run state for the current config. A running
+// server rebuilds only the per-run pieces whose settings changed, and
+// restarts the client history and the broadcast sender if theirs did; a
+// stopped one just takes the settings, as Start builds the rest. On error
+// the state in effect is kept. Caller must hold s.mu.
+func (s *Server) publishState() error {
+	cur := s.state()
+	next := *cur
+	next.server, next.logging = s.cfg.GetServer(), s.cfg.GetLogging()
+	if !s.running.Load() {
+		s.run.Store(&next)
+		return nil
+	}
+
+	old := cur.server
+	if next.server.Signing != old.Signing {
+		if err := s.setupSigning(&next); err != nil {
+			return err
+		}
+	}
+	if !reflect.DeepEqual(next.server.Auth, old.Auth) {
+		if err := s.setupAuth(&next); err != nil {
+			return err
+		}
+	}
+	if next.server.BaselineOffset != old.BaselineOffset {
+		s.setupBaselineOffset(&next)
+	}
+	if next.server.FrozenTime != old.FrozenTime {
+		if err := s.setupFrozenTime(&next); err != nil {
+			return err
+		}
+	}
+	if next.server.Jitter != old.Jitter {
+		s.setupJitter(&next)
+	}
+	if next.server.DropSeed != old.DropSeed || !reflect.DeepEqual(next.server.DropClients, old.DropClients) {
+		if err := s.setupDrops(&next); err != nil {
+			return err
+		}
+	}
+	s.run.Store(&next)
+
+	if next.logging.ClientHistory != cur.logging.ClientHistory {
+		s.setupClientHistory()
+	}
+	// The sender reads its settings once
+	if next.server.Broadcast != old.Broadcast {
+		s.startBroadcast(next.server.Broadcast)
+	}
+	return nil
+}
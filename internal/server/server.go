@@ -2,14 +2,22 @@
 package server
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	mrand "math/rand"
 	"net"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/neutrinoguy/timehammer/internal/attacks"
+	"github.com/neutrinoguy/timehammer/internal/auth"
+	"github.com/neutrinoguy/timehammer/internal/clock"
 	"github.com/neutrinoguy/timehammer/internal/config"
+	"github.com/neutrinoguy/timehammer/internal/kodcheck"
 	"github.com/neutrinoguy/timehammer/internal/logger"
 	"github.com/neutrinoguy/timehammer/internal/ntp"
 	"github.com/neutrinoguy/timehammer/internal/session"
@@ -21,27 +29,57 @@ type Server struct {
 	mu           sync.RWMutex
 	cfg          *config.Config
 	log          *logger.Logger
+	clock        clock.Clock
+	run          atomic.Pointer[runState] // Settings and per-run state requests are served with
 	upstream     *ntp.UpstreamClient
 	attackEngine *attacks.AttackEngine
 	recorder     *session.SessionRecorder
-	conn         *net.UDPConn
+	conns        []*net.UDPConn // Bound sockets; the first is the primary port
 	running      atomic.Bool
 	stopChan     chan struct{}
 	wg           sync.WaitGroup
+	inflight     sync.WaitGroup // Requests being processed
+	sendMu       sync.RWMutex   // Read-held while sending; Stop closes the sockets under it
+	connsClosed  bool           // Sockets closed; guarded by sendMu
+	stopBcast    chan struct{}  // Stops the broadcast sender (nil = not sending)
+	responseCap  *responseCap
+	rateLimiter  *clientLimiter
+	writeFails   *writeFailures
+	kodCheck     *kodcheck.Tracker
+	behavior     *behaviorDetector
+	interleave   *interleaveState
+	history      *clientHistory
 
 	// Stats
 	stats ServerStats
 }
 
-// ServerStats holds server statistics
+// ServerStats holds server statistics. Counters are atomics so the request
+// path never takes the lock for them; every other field is guarded by mu.
 type ServerStats struct {
 	mu              sync.RWMutex
 	StartTime       time.Time
-	TotalRequests   uint64
-	TotalResponses  uint64
+	TotalRequests   atomic.Uint64
+	TotalResponses  atomic.Uint64
 	ActiveClients   map[string]time.Time
-	ErrorCount      uint64
-	AttacksExecuted uint64
+	ErrorCount      atomic.Uint64
+	AttacksExecuted atomic.Uint64
+	CappedResponses atomic.Uint64
+	Throttled       atomic.Uint64 // Requests rejected by the per-client rate limit
+	Dropped         atomic.Uint64 // Responses dropped by the packet loss simulation
+	Skewed          atomic.Uint64 // Requests rejected by max_client_skew
+	VersionRefused  atomic.Uint64 // Requests dropped by accepted_versions
+	Proxied         atomic.Uint64 // Requests answered by relaying to upstream
+	ProxyErrors     atomic.Uint64 // Relayed requests upstream did not answer
+	proxyRTT        atomic.Int64  // Sum of relay round trips, in nanoseconds
+	RequestRate     atomic.Uint64 // Requests seen in the last second
+
+	Modes [8]atomic.Uint64 // Parsed packets per NTP mode, answered or not
+
+	MaxAmplification float64 // Largest mode 6/7 response/request size ratio
+
+	clientOffsets map[string]clientOffset  // Estimated clock offsets of active clients
+	clientDetails map[string]*clientDetail // Fingerprints and counters of active clients
 }
 
 // ClientInfo represents connected client information
@@ -51,22 +89,78 @@ type ClientInfo struct {
 	RequestCount int
 	Version      int
 	Mode         string
+
+	EstimatedOffset time.Duration // Client clock minus true time, from its last request
+	OffsetChange    time.Duration // Change in the estimate since the client was first seen
+	OffsetKnown     bool          // Whether the client sent a usable transmit timestamp
+
+	Fingerprint logger.ClientFingerprint // Header fields and identified implementation of its last request
+	Versions    []int                    // NTP versions seen, ascending
+	Polls       []int8                   // Poll exponents of its last requests, oldest first
+	Attacks     int                      // Responses modified by an attack
+
+	ObservedInterval     time.Duration // Median time between its recent requests (0 = not enough requests)
+	AdvertisedInterval   time.Duration // Poll interval its last request announced (0 = none)
+	IntervalBeforeAttack time.Duration // Observed interval up to its first attacked response (0 = not attacked or unknown)
 }
 
 // NewServer creates a new NTP server
 func NewServer(cfg *config.Config) *Server {
-	return &Server{
+	s := &Server{
 		cfg:          cfg,
 		log:          logger.GetLogger(),
+		clock:        clock.Real{},
 		upstream:     ntp.NewUpstreamClient(cfg),
 		attackEngine: attacks.NewAttackEngine(cfg),
 		recorder:     session.GetRecorder(),
+		responseCap:  newResponseCap(),
+		rateLimiter:  newClientLimiter(),
+		writeFails:   newWriteFailures(),
+		interleave:   newInterleaveState(),
+		history:      newClientHistory(),
+		behavior:     newBehaviorDetector(),
 		stopChan:     make(chan struct{}),
 		stats: ServerStats{
-			StartTime:     time.Now(),
 			ActiveClients: make(map[string]time.Time),
+			clientOffsets: make(map[string]clientOffset),
+			clientDetails: make(map[string]*clientDetail),
 		},
 	}
+	s.stats.StartTime = s.clock.Now()
+	s.run.Store(&runState{server: cfg.GetServer(), logging: cfg.GetLogging()})
+
+	s.upstream.OnSyncChange(s.handleSyncChange)
+	s.attackEngine.SetStratumSource(func() uint8 {
+		stratum, _ := s.advertisedSource(s.state())
+		return stratum
+	})
+	return s
+}
+
+// SetClock replaces the time source of the server, its attack engine and
+// its upstream client. Call it before Start.
+func (s *Server) SetClock(c clock.Clock) {
+	s.mu.Lock()
+	s.clock = c
+	s.mu.Unlock()
+	s.stats.mu.Lock()
+	s.stats.StartTime = c.Now()
+	s.stats.mu.Unlock()
+	s.attackEngine.SetClock(c)
+	s.upstream.SetClock(c)
+}
+
+// handleSyncChange reacts to upstream sync transitions
+func (s *Server) handleSyncChange(old, new ntp.SyncStatus) {
+	switch {
+	case new.Synchronized && !old.Synchronized:
+		s.log.Infof("SERVER", "Upstream sync acquired via %s", new.ActiveServer)
+	case !new.Synchronized && old.Synchronized:
+		s.log.Warnf("SERVER", "Upstream sync lost (was %s): %s", old.ActiveServer, new.LastError)
+	case new.ActiveServer != old.ActiveServer:
+		s.log.Infof("SERVER", "Upstream active server changed: %s → %s", old.ActiveServer, new.ActiveServer)
+	}
+	s.attackEngine.SetUpstreamSynced(new.Synchronized)
 }
 
 // Start starts the NTP server
@@ -78,60 +172,112 @@ func (s *Server) Start() error {
 		return fmt.Errorf("server already running")
 	}
 
-	// Determine which port to use
-	port := s.cfg.Server.Port
-	iface := s.cfg.Server.Interface
-
-	// Build address
-	addr := fmt.Sprintf("%s:%d", iface, port)
-
-	// Try to bind
-	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	// Set up this run before taking any requests
+	st, err := s.newRunState()
 	if err != nil {
-		return fmt.Errorf("failed to resolve address: %w", err)
+		return err
 	}
 
-	conn, err := net.ListenUDP("udp", udpAddr)
+	// Determine which port to use
+	port := st.server.Port
+	iface := st.server.Interface
+
+	conn, err := listenUDP(iface, port)
 	if err != nil {
 		// If standard port fails and alt port is enabled, try alt port
-		if s.cfg.Server.UseAltPortOnFail && port == s.cfg.Server.Port {
-			s.log.Warnf("SERVER", "Failed to bind to port %d, trying alt port %d", port, s.cfg.Server.AltPort)
-
-			altAddr := fmt.Sprintf("%s:%d", iface, s.cfg.Server.AltPort)
-			altUdpAddr, _ := net.ResolveUDPAddr("udp", altAddr)
+		if st.server.UseAltPortOnFail {
+			s.log.Warnf("SERVER", "Failed to bind to port %d, trying alt port %d", port, st.server.AltPort)
 
-			conn, err = net.ListenUDP("udp", altUdpAddr)
+			conn, err = listenUDP(iface, st.server.AltPort)
 			if err != nil {
 				// Provide helpful error message
-				s.log.Error("SERVER", config.GetPortConflictHelp(s.cfg.Server.AltPort))
-				return fmt.Errorf("failed to bind to port %d or %d: %w", s.cfg.Server.Port, s.cfg.Server.AltPort, err)
+				s.log.Error("SERVER", config.GetPortConflictHelp(st.server.AltPort))
+				return fmt.Errorf("failed to bind to port %d or %d: %w", st.server.Port, st.server.AltPort, err)
 			}
-			port = s.cfg.Server.AltPort
+			port = st.server.AltPort
 		} else {
 			s.log.Error("SERVER", config.GetPortConflictHelp(port))
 			return fmt.Errorf("failed to bind to port %d: %w", port, err)
 		}
 	}
 
-	s.conn = conn
+	// Additional ports are best effort: report failures and carry on
+	conns := []*net.UDPConn{conn}
+	var failed []string
+	for _, extra := range st.server.Ports {
+		if extra == port {
+			continue
+		}
+		c, err := listenUDP(iface, extra)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%d (%v)", extra, err))
+			continue
+		}
+		conns = append(conns, c)
+	}
+
+	s.conns = conns
+	s.sendMu.Lock()
+	s.connsClosed = false
+	s.sendMu.Unlock()
+	s.stopChan = make(chan struct{})
 	s.running.Store(true)
-	s.stats.StartTime = time.Now()
+	s.stats.mu.Lock()
+	s.stats.StartTime = s.clock.Now()
+	s.stats.mu.Unlock()
+	s.run.Store(st)
+
+	// Measure KoD compliance afresh for this run
+	s.kodCheck = kodcheck.NewTracker()
+	s.behavior.reset()
+
+	// Pick up the client history of earlier runs
+	s.setupClientHistory()
+
+	// Add user client signatures to the fingerprint database
+	s.loadFingerprints()
 
 	// Start upstream client
 	s.upstream.Start()
 
-	// Start request handler
-	s.wg.Add(1)
-	go s.handleRequests()
+	// Start applying attack schedules
+	s.attackEngine.StartScheduler()
+
+	// Start a request handler per socket
+	for _, c := range conns {
+		s.wg.Add(1)
+		go s.handleRequests(c)
+	}
 
 	// Start client cleanup routine
 	s.wg.Add(1)
 	go s.cleanupClients()
 
-	s.log.Infof("SERVER", "NTP server started on %s:%d", iface, port)
+	// Start request rate sampler
+	s.wg.Add(1)
+	go s.sampleRequestRate()
+
+	// Record attack periods automatically
+	s.wg.Add(1)
+	go s.autoRecordLoop()
+
+	// Report clients whose behavior changes
+	s.wg.Add(1)
+	go s.watchBehavior()
+
+	// Start broadcast sender
+	s.startBroadcast(st.server.Broadcast)
+
+	s.log.Infof("SERVER", "NTP server started on %s", s.listenAddresses())
+	if len(failed) > 0 {
+		s.log.Warnf("SERVER", "Could not bind additional port(s): %s", strings.Join(failed, ", "))
+	}
 	if iface == "" {
 		s.log.Info("SERVER", "Listening on all interfaces")
 	}
+	if quirks := describeQuirks(st.server.Quirks); quirks != "" {
+		s.log.Infof("SERVER", "Server quirks: %s", quirks)
+	}
 
 	return nil
 }
@@ -145,19 +291,31 @@ func (s *Server) Stop() error {
 		return fmt.Errorf("server not running")
 	}
 
-	// Signal stop
+	// Signal stop and wake the readers without closing their sockets, so
+	// requests in flight can still be answered
 	close(s.stopChan)
-
-	// Close connection
-	if s.conn != nil {
-		s.conn.Close()
+	for _, c := range s.conns {
+		c.SetReadDeadline(time.Now())
 	}
 
 	// Stop upstream
 	s.upstream.Stop()
 
-	// Wait for goroutines
+	// Stop attack schedules
+	s.attackEngine.StopScheduler()
+
+	// Wait for goroutines; once the readers are gone no new requests start
 	s.wg.Wait()
+	s.stopBcast = nil
+	s.drainRequests()
+
+	// Close connections
+	s.sendMu.Lock()
+	s.connsClosed = true
+	closeConns(s.conns)
+	s.sendMu.Unlock()
+
+	s.saveClientHistory()
 
 	s.running.Store(false)
 	s.log.Info("SERVER", "NTP server stopped")
@@ -165,11 +323,20 @@ func (s *Server) Stop() error {
 	return nil
 }
 
-// handleRequests handles incoming NTP requests
-func (s *Server) handleRequests() {
+// handleRequests handles incoming NTP requests on one socket
+func (s *Server) handleRequests(conn *net.UDPConn) {
 	defer s.wg.Done()
 
-	buffer := make([]byte, 1024)
+	// One byte over the parser's limit, so oversized packets are rejected
+	// instead of silently truncated
+	buffer := make([]byte, ntpcore.NTPPacketMaxSize+1)
+
+	// On a wildcard socket, learn where each request was sent so the reply
+	// can leave from that address
+	dst, err := newDstReader(conn)
+	if err != nil {
+		s.log.Warnf("SERVER", "Cannot read request destinations on %s, replies use the routed source address: %v", conn.LocalAddr(), err)
+	}
 
 	for {
 		select {
@@ -179,9 +346,20 @@ func (s *Server) handleRequests() {
 		}
 
 		// Set read deadline to allow checking for stop
-		s.conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
-
-		n, clientAddr, err := s.conn.ReadFromUDP(buffer)
+		conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+
+		path := replyPath{conn: conn}
+		var n int
+		var clientAddr *net.UDPAddr
+		if dst != nil {
+			var oob []byte
+			n, clientAddr, oob, err = dst.read(conn, buffer)
+			if s.state().server.ReplyFromRequestAddress {
+				path.oob = oob
+			}
+		} else {
+			n, clientAddr, err = conn.ReadFromUDP(buffer)
+		}
 		if err != nil {
 			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 				continue // Timeout, just retry
@@ -192,41 +370,100 @@ func (s *Server) handleRequests() {
 				return
 			default:
 				s.log.Errorf("SERVER", "Read error: %v", err)
-				atomic.AddUint64(&s.stats.ErrorCount, 1)
+				s.stats.ErrorCount.Add(1)
 				continue
 			}
 		}
 
-		// Process request in goroutine for concurrency
-		go s.processRequest(buffer[:n], clientAddr)
+		// Throttle before spawning any work for the packet
+		if !s.allowRequest(s.state(), path, buffer[:n], clientAddr) {
+			continue
+		}
+
+		// Process request in goroutine for concurrency; the buffer is reused
+		data := make([]byte, n)
+		copy(data, buffer[:n])
+		s.inflight.Add(1)
+		go func() {
+			defer s.inflight.Done()
+			s.processRequest(path, data, clientAddr)
+		}()
 	}
 }
 
-// processRequest processes a single NTP request
-func (s *Server) processRequest(data []byte, clientAddr *net.UDPAddr) {
-	startTime := time.Now()
+// processRequest processes a single NTP request, answering on the socket it
+// arrived on
+func (s *Server) processRequest(path replyPath, data []byte, clientAddr *net.UDPAddr) {
+	startTime := s.clock.Now()
 	clientStr := clientAddr.String()
 
+	// One snapshot of the settings for the whole request
+	st := s.state()
+
+	// Mode 6/7 queries do not use the 48-byte packet format
+	if st.server.AmplificationTest.Enabled && isControlQuery(data) {
+		s.handleAmplificationProbe(st, path, data, clientAddr)
+		return
+	}
+
 	// Parse incoming packet
 	packet, err := ntpcore.ParsePacket(data)
 	if err != nil {
 		s.log.Warnf("SERVER", "Invalid packet from %s: %v", clientStr, err)
-		atomic.AddUint64(&s.stats.ErrorCount, 1)
+		s.stats.ErrorCount.Add(1)
 		return
 	}
 
-	// Validate it's a client request
-	if !packet.IsValidClientRequest() {
-		s.log.Debugf("SERVER", "Non-client packet from %s (mode: %s)", clientStr, packet.GetModeString())
+	if len(packet.Unparsed) > 0 {
+		s.log.Debugf("SERVER", "Packet from %s has %d unparsed trailing bytes", clientStr, len(packet.Unparsed))
+	}
+	s.stats.Modes[packet.Mode&7].Add(1)
+
+	// Validate it's a client request, or a peer we answer as one
+	if !packet.IsValidClientRequest() && !s.acceptNonClient(st, packet, clientStr) {
 		return
 	}
 
 	// Update stats
-	atomic.AddUint64(&s.stats.TotalRequests, 1)
+	s.stats.TotalRequests.Add(1)
+
+	// Stay quiet towards clients we cannot reach
+	if s.writeFails.backedOff(clientAddr.IP.String(), s.clock.Now()) {
+		return
+	}
+
+	// Estimate how far the client's clock already is from true time
+	offset, offsetOK := estimateClientOffset(packet, s.upstream.GetCurrentTime())
+
 	s.stats.mu.Lock()
 	// Use IP mainly to track unique clients (ignoring ephemeral ports)
-	s.stats.ActiveClients[clientAddr.IP.String()] = time.Now()
+	s.stats.ActiveClients[clientAddr.IP.String()] = s.clock.Now()
+	detail := s.stats.detail(clientAddr.IP.String())
+	detail.requests++
+	interval := detail.recordArrival(s.clock.Now())
+	if offsetOK {
+		s.recordClientOffset(clientAddr.IP.String(), offset)
+	}
 	s.stats.mu.Unlock()
+	s.kodCheck.Request(clientAddr.IP.String(), s.clock.Now())
+	s.checkBehavior(st, clientAddr.IP.String(), int(packet.Version), offset, offsetOK)
+	s.history.request(clientAddr.IP.String(), s.clock.Now(), int(packet.Version), offset, offsetOK)
+	s.history.interval(clientAddr.IP.String(), interval, advertisedInterval(packet.Poll))
+
+	// A hardened server ignores replayed requests and wildly wrong clocks
+	if offsetOK && s.skewed(st, offset, clientStr) {
+		return
+	}
+
+	// Refused versions go unanswered, so clients have to fall back
+	if s.versionRefused(st, packet.Version, clientStr) {
+		return
+	}
+
+	// Enforce the response rate ceiling before doing any further work
+	if !s.allowResponse(st, clientAddr.IP.String()) {
+		return
+	}
 
 	// Create fingerprint for logging
 	fingerprint := &logger.ClientFingerprint{
@@ -239,76 +476,130 @@ func (s *Server) processRequest(data []byte, clientAddr *net.UDPAddr) {
 	}
 
 	// Identify possible client implementation
-	fingerprint.PossibleClient = identifyClient(packet)
-
-	// Get current time from upstream
-	currentTime := s.upstream.GetCurrentTime()
+	fingerprint.PossibleClient, fingerprint.Confidence, fingerprint.Candidates = identifyClient(packet, startTime)
 
-	// Apply configured timezone offset if set
-	// This shifts the UTC time to match the wall clock time of the target timezone
-	if s.cfg.Server.Timezone != "" && s.cfg.Server.Timezone != "UTC" {
-		loc, err := time.LoadLocation(s.cfg.Server.Timezone)
-		if err == nil {
-			_, offset := currentTime.In(loc).Zone()
-			currentTime = currentTime.Add(time.Duration(offset) * time.Second)
-		} else {
-			// Only log error occasionally or debug to avoid flooding
-			s.log.Debugf("SERVER", "Failed to load timezone %s: %v", s.cfg.Server.Timezone, err)
-		}
+	// Note whether the client even tries authenticated NTP
+	if packet.HasMAC {
+		fingerprint.Authenticated = true
+		fingerprint.KeyID = packet.KeyID
+		fingerprint.MACStatus = s.checkRequestMAC(st, packet)
 	}
-	receiveTime := time.Now()
 
-	// Create response packet
-	response := ntpcore.NewPacket()
-	response.Version = packet.Version // Echo client's version
-	response.Mode = ntpcore.ModeServer
-	response.Stratum = s.upstream.GetStratum()
-	response.Poll = packet.Poll
-	response.Precision = -20 // ~1 microsecond
+	s.stats.mu.Lock()
+	s.recordClientDetail(clientAddr.IP.String(), fingerprint)
+	s.stats.mu.Unlock()
 
-	// Set reference ID
-	response.ReferenceID = s.upstream.GetReferenceID()
+	// Simulated packet loss: the request is handled but never answered,
+	// so no attack is applied or counted for it
+	if st.drops.drop(clientAddr.IP, st.server.DropRate) {
+		s.stats.Dropped.Add(1)
+		if s.recorder.IsRecording() {
+			s.recorder.RecordClientRequest(clientStr, packet, "")
+		}
+		s.log.LogClientRequest(clientAddr.IP.String(), clientAddr.Port, fingerprint, "")
+		s.log.Debugf("SERVER", "Dropped response to %s (simulated loss)", clientStr)
+		return
+	}
 
-	// Set timestamps
-	// Copy client's transmit time to our origin time
-	response.SetOriginTime(packet.XmitTimeSec, packet.XmitTimeFrac)
-	response.SetReceiveTime(receiveTime)
-	response.SetReferenceTime(currentTime.Add(-time.Second))
-	response.SetTransmitTime(time.Now())
+	// Get the time we serve (upstream, timezone and baseline applied)
+	receiveTime := s.now(st)
+	currentTime := s.serverClock(st)
 
-	// Calculate root delay/dispersion
-	syncStatus := s.upstream.GetSyncStatus()
-	response.RootDelay = ntpcore.CalculateRootDelay(float64(syncStatus.RTT.Milliseconds()))
-	response.RootDisp = ntpcore.CalculateRootDispersion(10) // 10ms dispersion
+	// Relay the request to a real server, or answer from our own clock
+	var response *ntpcore.NTPPacket
+	proxied := st.server.Proxy.Enabled
+	if proxied {
+		response, currentTime = s.proxyRequest(st, data, clientStr)
+		if response == nil {
+			return
+		}
+	} else {
+		response = s.buildResponse(st, packet, clientAddr, receiveTime, currentTime)
+		applyQuirks(st.server.Quirks, response)
+	}
 
 	// Check for security mode and apply attacks
 	attackName := ""
 	if s.attackEngine.IsEnabled() {
-		response, attackName = s.attackEngine.ProcessPacket(response, clientStr, currentTime)
+		req := &attacks.RequestInfo{
+			Version: packet.Version,
+			Mode:    packet.Mode,
+			Poll:    packet.Poll,
+			Client:  fingerprint.PossibleClient,
+			HasMAC:  packet.HasMAC,
+		}
+		// Ramps compare the client's clock with the time we served it
+		req.ClientOffset, req.OffsetKnown = estimateClientOffset(packet, currentTime)
+		response, attackName = s.attackEngine.ProcessPacket(response, clientStr, currentTime, req)
 		if attackName != "" {
-			atomic.AddUint64(&s.stats.AttacksExecuted, 1)
+			s.stats.AttacksExecuted.Add(1)
+			s.recordClientAttack(clientAddr.IP.String())
+			s.history.attack(clientAddr.IP.String(), string(s.attackEngine.ClientAttack(clientStr)))
 		}
 	}
 
+	// NTS requests get a controlled answer; no NTS keys are held. Relayed
+	// answers keep the upstream's extension fields and MAC as sent.
+	if nts, ok := packet.NTS(); ok && !proxied {
+		answer := s.applyNTS(st, nts, response)
+		s.log.Infof("SERVER", "NTS request from %s (%d cookies, %d placeholders), sent %s",
+			clientStr, nts.Cookies, nts.Placeholders, answer)
+	}
+
+	// Tag the response so captures can be attributed to this run
+	if st.signKey != nil && !proxied {
+		response.Sign(st.signKey)
+	}
+
+	// Authenticate the response when the client uses symmetric keys
+	if packet.HasMAC && !response.HasMAC && !proxied {
+		s.authenticateResponse(st, packet, response, clientStr, fingerprint.MACStatus)
+	}
+
 	// Record session if enabled
 	if s.recorder.IsRecording() {
+		activeAttack, params := s.attackEngine.DescribeActiveAttack()
+		s.recorder.RecordAttackState(string(activeAttack), params)
 		s.recorder.RecordClientRequest(clientStr, packet, attackName)
-		s.recorder.RecordClientResponse(clientStr, response, time.Since(startTime))
+		s.recorder.RecordClientResponse(clientStr, response, clock.Since(s.clock, startTime))
 	}
 
 	// Log the request
 	s.log.LogClientRequest(clientAddr.IP.String(), clientAddr.Port, fingerprint, attackName)
 
+	// Hold the response to add simulated path delay (delay attack)
+	if attackName != "" {
+		if hold := s.attackEngine.ResponseHold(clientStr); hold > 0 {
+			time.Sleep(hold)
+		}
+	}
+
+	// Random path jitter, on top of any delay attack
+	if jitter := st.server.Jitter; jitter.Enabled {
+		time.Sleep(st.jitter.next(jitter))
+	}
+
 	// Send response
 	responseBytes := response.Bytes()
-	_, err = s.conn.WriteToUDP(responseBytes, clientAddr)
+	_, err = s.send(path, responseBytes, clientAddr)
+	if errors.Is(err, errStopped) {
+		s.log.Debugf("SERVER", "Server stopped before the response to %s was sent", clientStr)
+		return
+	}
 	if err != nil {
-		s.log.Errorf("SERVER", "Failed to send response to %s: %v", clientStr, err)
-		atomic.AddUint64(&s.stats.ErrorCount, 1)
+		s.stats.ErrorCount.Add(1)
+		s.handleWriteFailure(st, clientAddr, err)
 		return
 	}
+	s.writeFails.success(clientAddr.IP.String())
+	if response.GetKissOfDeathCode() == ntpcore.KoDRate {
+		s.kodCheck.KoD(clientAddr.IP.String(), s.clock.Now())
+	}
+	if st.server.InterleavedMode != "off" {
+		s.interleave.sent(clientAddr.IP.String(), response, s.clock.Now())
+	}
 
-	atomic.AddUint64(&s.stats.TotalResponses, 1)
+	s.stats.TotalResponses.Add(1)
 
 	// Log response
 	if attackName != "" {
@@ -318,6 +609,361 @@ func (s *Server) processRequest(data []byte, clientAddr *net.UDPAddr) {
 	}
 }
 
+// advertisedSource returns the stratum and reference ID responses claim.
+// With server.stratum 1 the server poses as a primary server synchronized
+// to server.ref_clock, as long as it has time to serve.
+func (s *Server) advertisedSource(st *runState) (uint8, uint32) {
+	stratum := s.upstream.GetStratum()
+	if st.server.Stratum == 1 && stratum < 16 {
+		if id, err := ntpcore.RefClockID(st.server.RefClock); err == nil {
+			return 1, id
+		}
+	}
+	return stratum, s.upstream.GetReferenceID()
+}
+
+// buildResponse answers a request from the time we serve; currentTime is
+// receiveTime with the timezone and baseline shift applied
+func (s *Server) buildResponse(st *runState, packet *ntpcore.NTPPacket, clientAddr *net.UDPAddr, receiveTime, currentTime time.Time) *ntpcore.NTPPacket {
+	shift := currentTime.Sub(receiveTime)
+
+	response := ntpcore.NewPacket()
+	response.Version = responseVersion(st.server.ResponseVersion, packet.Version)
+	response.Mode = ntpcore.ModeServer
+	if packet.Mode == ntpcore.ModeSymmetricActive {
+		response.Mode = ntpcore.ModeSymmetricPassive
+	}
+	response.Stratum, response.ReferenceID = s.advertisedSource(st)
+	response.Poll = responsePoll(st.server.PollPolicy, packet.Poll)
+	response.Precision = int8(st.server.Precision)
+
+	// Set timestamps
+	// Copy client's transmit time to our origin time
+	response.SetOriginTime(packet.XmitTimeSec, packet.XmitTimeFrac)
+	response.SetReceiveTime(receiveTime.Add(shift))
+	response.SetReferenceTime(referenceTime(st, currentTime))
+	response.SetTransmitTime(s.now(st).Add(shift))
+
+	// Calculate root delay/dispersion
+	syncStatus := s.upstream.GetSyncStatus()
+	response.RootDelay = ntpcore.CalculateRootDelay(float64(syncStatus.RTT) / float64(time.Millisecond))
+	response.RootDisp = ntpcore.CalculateRootDispersion(10) // 10ms dispersion
+
+	// Without a usable time source, tell clients not to trust us
+	if s.upstream.Refusing() {
+		response.LeapIndicator = ntpcore.LeapAlarm
+	}
+
+	// Answer interleaved requests with the previous transmit timestamp
+	if s.interleave.apply(clientAddr.IP.String(), st.server.InterleavedMode, packet, response) {
+		s.log.Debugf("SERVER", "Interleaved response to %s (%s)", clientAddr, st.server.InterleavedMode)
+	}
+
+	return response
+}
+
+// handleWriteFailure tracks a failed send, dropping and backing off the
+// client once it reaches the configured number of consecutive failures
+func (s *Server) handleWriteFailure(st *runState, clientAddr *net.UDPAddr, err error) {
+	clientStr := clientAddr.String()
+	threshold := st.server.WriteFailureThreshold
+	backoff := time.Duration(st.server.WriteFailureBackoff) * time.Second
+
+	if threshold <= 0 {
+		s.log.Errorf("SERVER", "Failed to send response to %s: %v", clientStr, err)
+		return
+	}
+
+	if !s.writeFails.failure(clientAddr.IP.String(), threshold, backoff, s.clock.Now()) {
+		s.log.Debugf("SERVER", "Failed to send response to %s: %v", clientStr, err)
+		return
+	}
+
+	s.stats.mu.Lock()
+	s.stats.forgetClient(clientAddr.IP.String())
+	s.stats.mu.Unlock()
+
+	s.log.Warnf("SERVER", "Dropping %s after %d consecutive send failures (last: %v), backing off for %v",
+		clientAddr.IP, threshold, err, backoff)
+}
+
+// allowRequest applies the per-client request rate limit. Throttled
+// requests are dropped, or answered with a KoD RATE if configured.
+func (s *Server) allowRequest(st *runState, path replyPath, data []byte, clientAddr *net.UDPAddr) bool {
+	rl := st.server.RateLimit
+	if !rl.Enabled || rl.PerSec <= 0 {
+		return true
+	}
+
+	ip := clientAddr.IP.String()
+	if s.rateLimiter.allow(ip, rl.PerSec, rl.Burst, s.clock.Now()) {
+		return true
+	}
+
+	s.stats.Throttled.Add(1)
+	if rl.SendKoD {
+		s.sendRateKoD(st, path, data, clientAddr)
+	} else {
+		s.log.Debugf("SERVER", "Rate limit exceeded by %s, dropping request", ip)
+	}
+	return false
+}
+
+// sendRateKoD answers a throttled request with a Kiss-of-Death RATE packet
+func (s *Server) sendRateKoD(st *runState, path replyPath, data []byte, clientAddr *net.UDPAddr) {
+	packet, err := ntpcore.ParsePacket(data)
+	if err != nil || !packet.IsValidClientRequest() {
+		return
+	}
+
+	// A KoD is still a response, so it must respect the amplification cap
+	if !s.allowResponse(st, clientAddr.IP.String()) {
+		return
+	}
+
+	kod, err := ntpcore.NewBuilder().
+		Version(packet.Version).
+		Mode(ntpcore.ModeServer).
+		LI(ntpcore.LeapAlarm).
+		Poll(packet.Poll).
+		KoD(ntpcore.KoDRate).
+		OriginRaw(packet.XmitTimeSec, packet.XmitTimeFrac).
+		Receive(s.now(st)).
+		Transmit(s.now(st)).
+		Bytes()
+	if err != nil {
+		s.log.Debugf("SERVER", "Failed to build KoD RATE for %s: %v", clientAddr, err)
+		return
+	}
+
+	if _, err := s.send(path, kod, clientAddr); err != nil {
+		s.log.Debugf("SERVER", "Failed to send KoD RATE to %s: %v", clientAddr, err)
+		return
+	}
+	s.log.Debugf("SERVER", "Rate limit exceeded by %s, sent KoD RATE", clientAddr)
+}
+
+// allowResponse applies the anti-amplification response cap for a source IP
+func (s *Server) allowResponse(st *runState, source string) bool {
+	capCfg := st.server.ResponseCap
+	if !capCfg.Enabled {
+		return true
+	}
+
+	result := s.responseCap.check(source, capCfg.GlobalPerSec, capCfg.PerSourcePerSec, s.clock.Now())
+	switch result {
+	case capGlobal:
+		s.stats.CappedResponses.Add(1)
+		if changed, _ := s.responseCap.setGlobalCapped(true); changed {
+			s.log.Warnf("SERVER", "Global response cap engaged (%d/s), dropping excess responses", capCfg.GlobalPerSec)
+		}
+		return false
+	case capSource:
+		s.stats.CappedResponses.Add(1)
+		s.log.Debugf("SERVER", "Per-source response cap hit for %s (%d/s), dropping response", source, capCfg.PerSourcePerSec)
+		return false
+	}
+
+	if changed, dropped := s.responseCap.setGlobalCapped(false); changed {
+		s.log.Infof("SERVER", "Global response cap released (%d responses dropped)", dropped)
+	}
+	return true
+}
+
+// setupSigning prepares the response signing key for this run
+func (s *Server) setupSigning(st *runState) error {
+	st.signKey = nil
+	if !st.server.Signing.Enabled {
+		return nil
+	}
+
+	if st.server.Signing.Key != "" {
+		key, err := hex.DecodeString(st.server.Signing.Key)
+		if err != nil || len(key) == 0 {
+			return fmt.Errorf("invalid signing key: must be non-empty hex")
+		}
+		st.signKey = key
+		s.log.Info("SERVER", "Response signing enabled (configured key)")
+		return nil
+	}
+
+	// No key configured: generate one for this run and log it so captures can be verified later
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	st.signKey = key
+	s.log.Warnf("SERVER", "Response signing enabled with run key %s (keep it to verify captures)", hex.EncodeToString(key))
+	return nil
+}
+
+// setupBaselineOffset chooses the constant offset for baseline offset mode
+func (s *Server) setupBaselineOffset(st *runState) {
+	st.baseline = 0
+	cfg := st.server.BaselineOffset
+	if !cfg.Enabled || cfg.MaxSecs <= 0 {
+		return
+	}
+
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = s.clock.Now().UnixNano()
+	}
+	rng := mrand.New(mrand.NewSource(seed))
+
+	// Uniform in [-max, +max] at millisecond resolution
+	maxMs := cfg.MaxSecs * 1000
+	st.baseline = time.Duration(rng.Int63n(2*maxMs+1)-maxMs) * time.Millisecond
+
+	s.log.Warnf("SERVER", "Baseline offset mode: serving time offset by %v (seed %d)", st.baseline, seed)
+}
+
+// setupFrozenTime starts frozen time mode if server.frozen_time.base is set
+func (s *Server) setupFrozenTime(st *runState) error {
+	st.frozen = nil
+	ft := st.server.FrozenTime
+	if ft.Base == "" {
+		return nil
+	}
+
+	base, err := time.Parse(time.RFC3339, ft.Base)
+	if err != nil {
+		return fmt.Errorf("invalid frozen_time.base: %w", err)
+	}
+	st.frozen = clock.NewFrozen(base, ft.Advance)
+	if ft.Advance {
+		s.log.Warnf("SERVER", "Frozen time mode: serving time from %s, advancing", ft.Base)
+	} else {
+		s.log.Warnf("SERVER", "Frozen time mode: serving %s in every response", ft.Base)
+	}
+	return nil
+}
+
+// now returns the local instant packet timestamps are based on: the frozen
+// clock in frozen time mode, otherwise the server clock
+func (s *Server) now(st *runState) time.Time {
+	if st.frozen != nil {
+		return st.frozen.Now()
+	}
+	return s.clock.Now()
+}
+
+// referenceTime returns the reference time to advertise for a response
+// sent at currentTime, server.ref_time_age seconds earlier
+func referenceTime(st *runState, currentTime time.Time) time.Time {
+	return currentTime.Add(-time.Duration(st.server.RefTimeAge) * time.Second)
+}
+
+// serverClock returns the time the server claims: upstream time (or the
+// frozen time) shifted by the configured timezone and the baseline offset.
+// Attacks layer on top.
+func (s *Server) serverClock(st *runState) time.Time {
+	now := s.now(st)
+	if st.frozen == nil {
+		now = s.upstream.GetCurrentTime()
+	}
+
+	// Apply configured timezone offset if set
+	// This shifts the UTC time to match the wall clock time of the target timezone
+	if tz := st.server.Timezone; tz != "" && tz != "UTC" {
+		loc, err := time.LoadLocation(tz)
+		if err == nil {
+			_, offset := now.In(loc).Zone()
+			now = now.Add(time.Duration(offset) * time.Second)
+		} else {
+			// Only log error occasionally or debug to avoid flooding
+			s.log.Debugf("SERVER", "Failed to load timezone %s: %v", tz, err)
+		}
+	}
+
+	return now.Add(st.baseline)
+}
+
+// setupDrops prepares the packet loss simulation for this run
+func (s *Server) setupDrops(st *runState) error {
+	drops, err := newDropper(st.server, s.clock.Now())
+	if err != nil {
+		return fmt.Errorf("invalid drop_clients: %w", err)
+	}
+	st.drops = drops
+	if st.server.DropRate > 0 || len(st.server.DropClients) > 0 {
+		s.log.Warnf("SERVER", "Simulating packet loss: drop rate %.2f, %d client rule(s), seed %d",
+			st.server.DropRate, len(st.server.DropClients), drops.seed)
+	}
+	return nil
+}
+
+// setupJitter prepares the path jitter emulation for this run
+func (s *Server) setupJitter(st *runState) {
+	jitter := st.server.Jitter
+	st.jitter = newJitterer(jitter.Seed, s.clock.Now())
+	if jitter.Enabled {
+		s.log.Warnf("SERVER", "Simulating path jitter: ±%gms %s, seed %d", jitter.Ms, jitter.Distribution, st.jitter.seed)
+	}
+}
+
+// GetBaselineOffset returns the baseline offset in effect (0 if disabled)
+func (s *Server) GetBaselineOffset() time.Duration {
+	return s.state().baseline
+}
+
+// setupAuth loads the symmetric keys file, if configured, keeping only the
+// trusted keys
+func (s *Server) setupAuth(st *runState) error {
+	st.keys = nil
+	cfg := st.server.Auth
+	if cfg.KeysFile == "" {
+		return nil
+	}
+
+	keys, err := auth.LoadKeys(cfg.KeysFile)
+	if err != nil {
+		return err
+	}
+	trusted, err := keys.Trusted(cfg.TrustedKeys)
+	if err != nil {
+		return fmt.Errorf("%s: %w", cfg.KeysFile, err)
+	}
+	st.keys = trusted
+	s.log.Infof("SERVER", "Loaded %d symmetric key(s) from %s, trusted: %v", len(keys), cfg.KeysFile, trusted.IDs())
+	if cfg.ForgeMAC {
+		s.log.Warn("SERVER", "MAC forgery enabled: authenticated responses carry invalid digests")
+	}
+	return nil
+}
+
+// authenticateResponse adds a MAC for the request's key ID, or a crypto-NAK
+// if the key is unknown or the request does not verify. status is the
+// result of checkRequestMAC.
+func (s *Server) authenticateResponse(st *runState, request, response *ntpcore.NTPPacket, clientStr, status string) {
+	if st.keys == nil {
+		return
+	}
+
+	switch status {
+	case macUnknownKey:
+		s.log.Debugf("SERVER", "Unknown key ID %d from %s, sending crypto-NAK", request.KeyID, clientStr)
+		response.SetCryptoNAK()
+		return
+	case macVerified:
+	default:
+		s.log.Debugf("SERVER", "MAC check failed for key ID %d from %s, sending crypto-NAK", request.KeyID, clientStr)
+		response.SetCryptoNAK()
+		return
+	}
+
+	key := st.keys[request.KeyID]
+	if err := response.SetMAC(request.KeyID, key.Secret, key.Type); err != nil {
+		s.log.Errorf("SERVER", "Failed to compute MAC for %s: %v", clientStr, err)
+		return
+	}
+
+	if st.server.Auth.ForgeMAC {
+		response.MAC[0] ^= 0xFF
+		s.log.LogAttack("forge_mac", clientStr, fmt.Sprintf("Sending forged %s MAC for key ID %d", key.Type, request.KeyID))
+	}
+}
+
 // cleanupClients removes stale clients from the active list
 func (s *Server) cleanupClients() {
 	defer s.wg.Done()
@@ -329,47 +975,27 @@ func (s *Server) cleanupClients() {
 		select {
 		case <-ticker.C:
 			s.stats.mu.Lock()
-			now := time.Now()
+			now := s.clock.Now()
 			for addr, lastSeen := range s.stats.ActiveClients {
 				if now.Sub(lastSeen) > 5*time.Minute {
-					delete(s.stats.ActiveClients, addr)
+					s.stats.forgetClient(addr)
 				}
 			}
+			s.state().drops.prune(s.stats.ActiveClients)
+			s.kodCheck.Prune(s.stats.ActiveClients)
+			s.behavior.prune(s.stats.ActiveClients)
 			s.stats.mu.Unlock()
+			s.responseCap.prune(now, 5*time.Minute)
+			s.rateLimiter.prune(now, 5*time.Minute)
+			s.writeFails.prune(now, 5*time.Minute)
+			s.interleave.prune(now, 5*time.Minute)
+			s.saveClientHistory()
 		case <-s.stopChan:
 			return
 		}
 	}
 }
 
-// identifyClient attempts to identify the NTP client implementation
-func identifyClient(packet *ntpcore.NTPPacket) string {
-	// Common patterns for client identification
-	// This is a heuristic based on typical client behaviors
-
-	if packet.Version == 3 {
-		if packet.Poll == 6 {
-			return "Windows W32Time (possible)"
-		}
-		return "NTPv3 Client"
-	}
-
-	if packet.Version == 4 {
-		switch packet.Poll {
-		case 6:
-			return "ntpd/chrony (likely)"
-		case 7:
-			return "systemd-timesyncd (possible)"
-		case 10:
-			return "macOS sntp (possible)"
-		default:
-			return "NTPv4 Client"
-		}
-	}
-
-	return "Unknown"
-}
-
 // IsRunning returns whether the server is running
 func (s *Server) IsRunning() bool {
 	return s.running.Load()
@@ -377,19 +1003,55 @@ func (s *Server) IsRunning() bool {
 
 // GetStats returns server statistics
 func (s *Server) GetStats() Stats {
+	jitter := s.jitterStats()
+
 	s.stats.mu.RLock()
 	defer s.stats.mu.RUnlock()
 
 	return Stats{
-		Uptime:          time.Since(s.stats.StartTime),
-		TotalRequests:   atomic.LoadUint64(&s.stats.TotalRequests),
-		TotalResponses:  atomic.LoadUint64(&s.stats.TotalResponses),
+		Uptime:          clock.Since(s.clock, s.stats.StartTime),
+		TotalRequests:   s.stats.TotalRequests.Load(),
+		TotalResponses:  s.stats.TotalResponses.Load(),
 		ActiveClients:   len(s.stats.ActiveClients),
-		ErrorCount:      atomic.LoadUint64(&s.stats.ErrorCount),
-		AttacksExecuted: atomic.LoadUint64(&s.stats.AttacksExecuted),
+		ErrorCount:      s.stats.ErrorCount.Load(),
+		AttacksExecuted: s.stats.AttacksExecuted.Load(),
+		CappedResponses: s.stats.CappedResponses.Load(),
+		Throttled:       s.stats.Throttled.Load(),
+		Dropped:         s.stats.Dropped.Load(),
+		Skewed:          s.stats.Skewed.Load(),
+		VersionRefused:  s.stats.VersionRefused.Load(),
+		Proxied:         s.stats.Proxied.Load(),
+		ProxyErrors:     s.stats.ProxyErrors.Load(),
+		ProxyRTT:        s.stats.meanProxyRTT(),
+		RequestRate:     s.stats.RequestRate.Load(),
+		Modes:           s.stats.modeCounts(),
+
+		MaxAmplification: s.stats.MaxAmplification,
+		Jitter:           jitter,
 	}
 }
 
+// GetKoDCompliance returns how clients reacted to the RATE KoDs sent in
+// the current run
+func (s *Server) GetKoDCompliance() []kodcheck.Result {
+	s.mu.RLock()
+	k := s.kodCheck
+	s.mu.RUnlock()
+	if k == nil {
+		return nil
+	}
+	return k.Results(s.clock.Now())
+}
+
+// jitterStats returns the jitter stats of the current run, if any
+func (s *Server) jitterStats() JitterStats {
+	j := s.state().jitter
+	if j == nil {
+		return JitterStats{}
+	}
+	return j.stats()
+}
+
 // Stats is the public stats structure
 type Stats struct {
 	Uptime          time.Duration
@@ -398,20 +1060,54 @@ type Stats struct {
 	ActiveClients   int
 	ErrorCount      uint64
 	AttacksExecuted uint64
+	CappedResponses uint64
+	Throttled       uint64
+	Dropped         uint64
+	Skewed          uint64
+	VersionRefused  uint64
+	Proxied         uint64
+	ProxyErrors     uint64
+	ProxyRTT        time.Duration // Mean relay round trip
+	RequestRate     uint64
+	Modes           [8]uint64 // Parsed packets per NTP mode (index = mode)
+
+	MaxAmplification float64
+	Jitter           JitterStats
 }
 
-// GetActiveClients returns list of active clients
+// GetActiveClients returns list of active clients, ordered as
+// logging.client_order says
 func (s *Server) GetActiveClients() []ClientInfo {
 	s.stats.mu.RLock()
 	defer s.stats.mu.RUnlock()
 
 	clients := make([]ClientInfo, 0, len(s.stats.ActiveClients))
 	for addr, lastSeen := range s.stats.ActiveClients {
-		clients = append(clients, ClientInfo{
+		info := ClientInfo{
 			Address:  addr,
 			LastSeen: lastSeen,
-		})
+		}
+		if est, ok := s.stats.clientOffsets[addr]; ok {
+			info.EstimatedOffset = est.last
+			info.OffsetChange = est.last - est.first
+			info.OffsetKnown = true
+		}
+		if d, ok := s.stats.clientDetails[addr]; ok {
+			info.RequestCount = d.requests
+			info.Version = d.fingerprint.Version
+			info.Mode = d.fingerprint.ModeString
+			info.Fingerprint = d.fingerprint
+			info.Fingerprint.Candidates = append([]string(nil), d.fingerprint.Candidates...)
+			info.Versions = append([]int(nil), d.versions...)
+			info.Polls = append([]int8(nil), d.polls...)
+			info.Attacks = d.attacks
+			info.ObservedInterval = d.observedInterval()
+			info.AdvertisedInterval = advertisedInterval(int8(d.fingerprint.Poll))
+			info.IntervalBeforeAttack = d.intervalBeforeAttack
+		}
+		clients = append(clients, info)
 	}
+	sortClients(clients, s.state().logging.ClientOrder)
 	return clients
 }
 
@@ -420,6 +1116,22 @@ func (s *Server) GetUpstreamStatus() ntp.SyncStatus {
 	return s.upstream.GetSyncStatus()
 }
 
+// GetUpstreamHealth returns the health of each enabled upstream server
+func (s *Server) GetUpstreamHealth() []ntp.ServerHealth {
+	return s.upstream.GetHealth()
+}
+
+// GetTimeSource returns what currently feeds responses ("upstream" or the
+// fallback mode in use) and a short description
+func (s *Server) GetTimeSource() (string, string) {
+	return s.upstream.TimeSource()
+}
+
+// OnUpstreamSyncChange registers a callback for upstream sync transitions
+func (s *Server) OnUpstreamSyncChange(fn func(old, new ntp.SyncStatus)) {
+	s.upstream.OnSyncChange(fn)
+}
+
 // ForceUpstreamSync triggers an immediate upstream sync
 func (s *Server) ForceUpstreamSync() {
 	s.upstream.ForceSync()
@@ -437,12 +1149,42 @@ func (s *Server) UpdateConfig(cfg *config.Config) {
 	s.cfg = cfg
 	s.upstream.UpdateConfig(cfg)
 	s.attackEngine.UpdateConfig(cfg)
+	if err := s.publishState(); err != nil {
+		s.log.Errorf("CONFIG", "Settings not applied: %v", err)
+	}
 }
 
-// GetListenAddress returns the current listen address
+// GetListenAddress returns the bound listen addresses, comma separated
 func (s *Server) GetListenAddress() string {
-	if s.conn == nil {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.listenAddresses()
+}
+
+// listenAddresses lists the bound addresses. Caller must hold s.mu.
+func (s *Server) listenAddresses() string {
+	if len(s.conns) == 0 {
 		return "not bound"
 	}
-	return s.conn.LocalAddr().String()
+	addrs := make([]string, len(s.conns))
+	for i, c := range s.conns {
+		addrs[i] = c.LocalAddr().String()
+	}
+	return strings.Join(addrs, ", ")
+}
+
+// listenUDP binds a UDP socket on an interface address and port
+func listenUDP(iface string, port int) (*net.UDPConn, error) {
+	addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", iface, port))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve address: %w", err)
+	}
+	return net.ListenUDP("udp", addr)
+}
+
+// closeConns closes every socket in the list
+func closeConns(conns []*net.UDPConn) {
+	for _, c := range conns {
+		c.Close()
+	}
 }
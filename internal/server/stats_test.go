@@ -0,0 +1,161 @@
+package server
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/attacks"
+	"github.com/neutrinoguy/timehammer/internal/clock"
+	"github.com/neutrinoguy/timehammer/internal/config"
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// startTestServer starts a server on an ephemeral loopback port with no
+// upstream servers, so nothing leaves the host
+func startTestServer(t *testing.T, cfg *config.Config) *Server {
+	t.Helper()
+	cfg.Server.Interface = "127.0.0.1"
+	cfg.Server.Port = 0
+	cfg.Server.UseAltPortOnFail = false
+	cfg.Server.Ports = nil
+	cfg.Upstream.Servers = nil
+	cfg.Logging.ClientHistory = false
+
+	s := NewServer(cfg)
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if s.IsRunning() {
+			s.Stop()
+		}
+	})
+	return s
+}
+
+// testClient opens a loopback socket for a client, so replies have
+// somewhere to go
+func testClient(t *testing.T) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// clientRequest returns a serialized mode 3 request
+func clientRequest() []byte {
+	req := ntpcore.NewPacket()
+	req.Mode = ntpcore.ModeClient
+	req.Stratum = 0
+	req.XmitTimeSec, req.XmitTimeFrac = 0xE0000000, 0x12345678
+	return req.Bytes()
+}
+
+// Request processing and the stats readers share the counters and client
+// maps; run with -race
+func TestStatsConcurrentAccess(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Security.Enabled = true
+	cfg.Security.ActiveAttack = string(attacks.AttackTimeDrift)
+	cfg.Server.ResponseCap.Enabled = false // Every request is from one IP
+	s := startTestServer(t, cfg)
+	path := replyPath{conn: s.conns[0]}
+
+	const workers, requests = 8, 50
+	var processing, reading sync.WaitGroup
+	done := make(chan struct{})
+
+	for i := 0; i < 2; i++ {
+		reading.Add(1)
+		go func() {
+			defer reading.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				s.GetStats()
+				s.StatusLine()
+				s.GetActiveClients()
+			}
+		}()
+	}
+
+	for i := 0; i < workers; i++ {
+		addr := testClient(t).LocalAddr().(*net.UDPAddr)
+		processing.Add(1)
+		go func() {
+			defer processing.Done()
+			for j := 0; j < requests; j++ {
+				s.processRequest(path, clientRequest(), addr)
+			}
+		}()
+	}
+
+	processing.Wait()
+	close(done)
+	reading.Wait()
+
+	stats := s.GetStats()
+	if stats.TotalRequests != workers*requests {
+		t.Errorf("TotalRequests = %d, want %d", stats.TotalRequests, workers*requests)
+	}
+	if stats.TotalResponses != workers*requests {
+		t.Errorf("TotalResponses = %d, want %d", stats.TotalResponses, workers*requests)
+	}
+	if stats.Modes[ntpcore.ModeClient] != workers*requests {
+		t.Errorf("mode 3 count = %d, want %d", stats.Modes[ntpcore.ModeClient], workers*requests)
+	}
+	if stats.ActiveClients != 1 {
+		t.Errorf("ActiveClients = %d, want 1 (clients are tracked by IP)", stats.ActiveClients)
+	}
+}
+
+// Uptime and the drift progress read the injected clock, not the wall clock
+func TestStatsFollowInjectedClock(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Security.Enabled = true
+	cfg.Security.ActiveAttack = string(attacks.AttackTimeDrift)
+	s := NewServer(cfg)
+	mock := clock.NewMock(time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC))
+	s.SetClock(mock)
+
+	mock.Advance(90 * time.Minute)
+	if up := s.GetStats().Uptime; up != 90*time.Minute {
+		t.Errorf("Uptime = %v, want 1h30m", up)
+	}
+	if _, elapsed := s.GetAttackEngine().GetDriftStatus(); elapsed != 90*time.Minute {
+		t.Errorf("drift running for %v, want 1h30m", elapsed)
+	}
+}
+
+// Time-based seeds of a run come from the injected clock, so a mock clock
+// reproduces the baseline offset, drops and jitter
+func TestRunSeedsFollowInjectedClock(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.BaselineOffset = config.BaselineOffsetConfig{Enabled: true, MaxSecs: 3600}
+	start := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	var baselines []time.Duration
+	for i := 0; i < 2; i++ {
+		s := NewServer(cfg)
+		s.SetClock(clock.NewMock(start))
+		st, err := s.newRunState()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.drops.seed != start.UnixNano() || st.jitter.seed != start.UnixNano() {
+			t.Errorf("drop seed %d, jitter seed %d, want %d", st.drops.seed, st.jitter.seed, start.UnixNano())
+		}
+		baselines = append(baselines, st.baseline)
+	}
+	if baselines[0] != baselines[1] {
+		t.Errorf("baseline offsets %v and %v from the same clock", baselines[0], baselines[1])
+	}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/attacks"
+)
+
+// StatusLine returns a compact single-line summary for status bars, e.g.
+//
+//	TH up 1h23m | req 4521 r/s 12 | clients 8 | upstream SYNC +2ms | attack time_drift 45%
+//
+// The field order and keywords are stable so scripts can parse it.
+func (s *Server) StatusLine() string {
+	var b strings.Builder
+
+	stats := s.GetStats()
+	if s.IsRunning() {
+		fmt.Fprintf(&b, "TH up %s", compactDuration(stats.Uptime))
+	} else {
+		b.WriteString("TH down")
+	}
+
+	fmt.Fprintf(&b, " | req %d r/s %d", stats.TotalRequests, stats.RequestRate)
+	fmt.Fprintf(&b, " | clients %d", stats.ActiveClients)
+
+	sync := s.upstream.GetSyncStatus()
+	if sync.Synchronized {
+		fmt.Fprintf(&b, " | upstream SYNC %s", signedDuration(sync.Offset))
+	} else {
+		source, _ := s.upstream.TimeSource()
+		fmt.Fprintf(&b, " | upstream UNSYNC (%s)", source)
+	}
+
+	b.WriteString(" | attack ")
+	b.WriteString(s.attackStatus())
+
+	return b.String()
+}
+
+// attackStatus describes the effective attack and its progress where known
+func (s *Server) attackStatus() string {
+	attack, _ := s.attackEngine.DescribeActiveAttack()
+	if attack == attacks.AttackNone {
+		return "off"
+	}
+	if s.attackEngine.IsPaused() {
+		return s.attackProgress(attack) + " PAUSED"
+	}
+	return s.attackProgress(attack)
+}
+
+// attackProgress describes an active attack and its progress where known
+func (s *Server) attackProgress(attack attacks.AttackType) string {
+	if attack == attacks.AttackTimeDrift && s.cfg.Security.TimeDrift.Waveform != attacks.WaveLinear {
+		drift, _ := s.attackEngine.GetDriftStatus()
+		return fmt.Sprintf("%s %s %+.3fs", attack, s.cfg.Security.TimeDrift.Waveform, drift.Seconds())
+	}
+	if attack == attacks.AttackTimeDrift {
+		if maxDrift := s.cfg.Security.TimeDrift.MaxDrift; maxDrift > 0 {
+			drift, _ := s.attackEngine.GetDriftStatus()
+			pct := math.Min(math.Abs(drift.Seconds())/maxDrift*100, 100)
+			return fmt.Sprintf("%s %d%%", attack, int(pct))
+		}
+	}
+	return string(attack)
+}
+
+// sampleRequestRate records the request rate once per second
+func (s *Server) sampleRequestRate() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	last := s.stats.TotalRequests.Load()
+	for {
+		select {
+		case <-ticker.C:
+			cur := s.stats.TotalRequests.Load()
+			s.stats.RequestRate.Store(cur - last)
+			last = cur
+		case <-s.stopChan:
+			s.stats.RequestRate.Store(0)
+			return
+		}
+	}
+}
+
+// compactDuration formats a duration as e.g. 2d3h, 1h23m, 5m12s or 42s
+func compactDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd%dh", d/(24*time.Hour), (d%(24*time.Hour))/time.Hour)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh%02dm", d/time.Hour, (d%time.Hour)/time.Minute)
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm%02ds", d/time.Minute, (d%time.Minute)/time.Second)
+	default:
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+}
+
+// signedDuration formats an offset with an explicit sign at a readable precision
+func signedDuration(d time.Duration) string {
+	sign := "+"
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+	if d < time.Millisecond {
+		return sign + d.Round(time.Microsecond).String()
+	}
+	return sign + d.Round(time.Millisecond).String()
+}
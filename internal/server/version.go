@@ -0,0 +1,32 @@
+package server
+
+import (
+	"slices"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+)
+
+// versionRefused reports whether a request's NTP version is outside
+// server.accepted_versions, counting and logging the refusal
+func (s *Server) versionRefused(st *runState, version uint8, clientStr string) bool {
+	accepted := st.server.AcceptedVersions
+	if len(accepted) == 0 || slices.Contains(accepted, int(version)) {
+		return false
+	}
+	s.stats.VersionRefused.Add(1)
+	s.log.Debugf("SERVER", "Refused NTPv%d request from %s (accepted versions %v)", version, clientStr, accepted)
+	return true
+}
+
+// responseVersion returns the version to answer a request of the given
+// version with under the configured policy
+func responseVersion(policy config.ResponseVersionPolicy, requestVersion uint8) uint8 {
+	switch policy.Mode {
+	case "fixed":
+		return uint8(policy.Version)
+	case "cap":
+		return min(requestVersion, uint8(policy.Version))
+	default:
+		return requestVersion
+	}
+}
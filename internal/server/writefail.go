@@ -0,0 +1,95 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// writeFailureState tracks consecutive send failures for one client
+type writeFailureState struct {
+	count        int
+	lastFailure  time.Time
+	backoffUntil time.Time
+}
+
+// writeFailures tracks clients whose responses keep failing to send so
+// they can be dropped and backed off instead of spamming the log. Clients
+// are keyed by IP, so a client cycling source ports is still one client.
+type writeFailures struct {
+	mu      sync.Mutex
+	clients map[string]*writeFailureState
+}
+
+// newWriteFailures creates an empty failure tracker
+func newWriteFailures() *writeFailures {
+	return &writeFailures{
+		clients: make(map[string]*writeFailureState),
+	}
+}
+
+// backedOff reports whether responses to client are currently suppressed
+func (w *writeFailures) backedOff(client string, now time.Time) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	st, ok := w.clients[client]
+	if !ok || st.backoffUntil.IsZero() {
+		return false
+	}
+	if now.Before(st.backoffUntil) {
+		return true
+	}
+
+	// Backoff expired: give the client a fresh set of attempts
+	delete(w.clients, client)
+	return false
+}
+
+// failure records a failed send and reports whether the client just
+// crossed the threshold and entered backoff
+func (w *writeFailures) failure(client string, threshold int, backoff time.Duration, now time.Time) bool {
+	if threshold <= 0 {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	st, ok := w.clients[client]
+	if !ok {
+		st = &writeFailureState{}
+		w.clients[client] = st
+	}
+	st.count++
+	st.lastFailure = now
+
+	if st.count >= threshold && st.backoffUntil.IsZero() {
+		st.backoffUntil = now.Add(backoff)
+		return true
+	}
+	return false
+}
+
+// success clears the failure count for a client
+func (w *writeFailures) success(client string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.clients, client)
+}
+
+// prune drops expired backoff entries, and failure counts below the
+// threshold whose last failure is older than maxAge
+func (w *writeFailures) prune(now time.Time, maxAge time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for client, st := range w.clients {
+		if st.backoffUntil.IsZero() {
+			if now.Sub(st.lastFailure) > maxAge {
+				delete(w.clients, client)
+			}
+		} else if now.After(st.backoffUntil) {
+			delete(w.clients, client)
+		}
+	}
+}
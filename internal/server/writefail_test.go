@@ -0,0 +1,33 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWriteFailuresPrune(t *testing.T) {
+	w := newWriteFailures()
+	start := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	w.failure("192.0.2.1", 5, time.Minute, start)                    // Below the threshold
+	w.failure("192.0.2.2", 5, time.Minute, start.Add(4*time.Minute)) // Below the threshold, recent
+	for i := 0; i < 5; i++ {
+		w.failure("192.0.2.3", 5, time.Minute, start) // Backed off until start+1m
+	}
+
+	w.prune(start.Add(30*time.Second), 5*time.Minute)
+	if len(w.clients) != 3 {
+		t.Fatalf("%d clients tracked before anything expired, want 3", len(w.clients))
+	}
+
+	w.prune(start.Add(6*time.Minute), 5*time.Minute)
+	if _, ok := w.clients["192.0.2.1"]; ok {
+		t.Error("stale failure count was kept")
+	}
+	if _, ok := w.clients["192.0.2.2"]; !ok {
+		t.Error("recent failure count was dropped")
+	}
+	if _, ok := w.clients["192.0.2.3"]; ok {
+		t.Error("expired backoff was kept")
+	}
+}
@@ -0,0 +1,244 @@
+package session
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// diffOffsetTolerance is how far a packet's transmit time (relative to when
+// it was recorded) may move between sessions before it counts as a change
+const diffOffsetTolerance = time.Second
+
+// diffSummaryLimit caps the packet differences listed by String
+const diffSummaryLimit = 20
+
+// DiffReport describes how session B differs from session A
+type DiffReport struct {
+	A           string            `json:"a"`
+	B           string            `json:"b"`
+	EventCounts map[string][2]int `json:"event_counts"` // Per event type, A then B
+	Stats       StatsDelta        `json:"stats"`
+	OnlyInA     []string          `json:"only_in_a,omitempty"` // Client IPs seen only in A
+	OnlyInB     []string          `json:"only_in_b,omitempty"`
+	Packets     []PacketDiff      `json:"packets,omitempty"`
+}
+
+// StatsDelta is B minus A for each session statistic
+type StatsDelta struct {
+	TotalRequests   int           `json:"total_requests"`
+	TotalResponses  int           `json:"total_responses"`
+	UniqueClients   int           `json:"unique_clients"`
+	UpstreamQueries int           `json:"upstream_queries"`
+	AttacksExecuted int           `json:"attacks_executed"`
+	AvgResponseTime time.Duration `json:"avg_response_time"`
+}
+
+// PacketDiff is one field that differs between aligned packets
+type PacketDiff struct {
+	Type  string `json:"type"`  // Event type ("request", "response", ...)
+	Index int    `json:"index"` // Position among events of that type
+	Field string `json:"field"`
+	A     string `json:"a"`
+	B     string `json:"b"`
+}
+
+// Diff compares two sessions. Events are aligned by type and order, so the
+// nth response of A is compared with the nth response of B. Transmit times
+// are compared as offsets from the recording time, since absolute times
+// always differ between runs.
+func Diff(a, b *Session) (*DiffReport, error) {
+	if a == nil || b == nil {
+		return nil, fmt.Errorf("two sessions are required")
+	}
+
+	r := &DiffReport{
+		A:           a.ID,
+		B:           b.ID,
+		EventCounts: make(map[string][2]int),
+		Stats: StatsDelta{
+			TotalRequests:   b.Stats.TotalRequests - a.Stats.TotalRequests,
+			TotalResponses:  b.Stats.TotalResponses - a.Stats.TotalResponses,
+			UniqueClients:   b.Stats.UniqueClients - a.Stats.UniqueClients,
+			UpstreamQueries: b.Stats.UpstreamQueries - a.Stats.UpstreamQueries,
+			AttacksExecuted: b.Stats.AttacksExecuted - a.Stats.AttacksExecuted,
+			AvgResponseTime: b.Stats.AvgResponseTime - a.Stats.AvgResponseTime,
+		},
+	}
+
+	byTypeA, byTypeB := eventsByType(a.Events), eventsByType(b.Events)
+	for typ, evs := range byTypeA {
+		c := r.EventCounts[typ]
+		c[0] = len(evs)
+		r.EventCounts[typ] = c
+	}
+	for typ, evs := range byTypeB {
+		c := r.EventCounts[typ]
+		c[1] = len(evs)
+		r.EventCounts[typ] = c
+	}
+
+	clientsA, clientsB := clientIPs(a.Events), clientIPs(b.Events)
+	r.OnlyInA = missingFrom(clientsA, clientsB)
+	r.OnlyInB = missingFrom(clientsB, clientsA)
+
+	for _, typ := range []string{"request", "response", "upstream_response"} {
+		evA, evB := byTypeA[typ], byTypeB[typ]
+		n := len(evA)
+		if len(evB) < n {
+			n = len(evB)
+		}
+		for i := 0; i < n; i++ {
+			r.Packets = append(r.Packets, diffPackets(typ, i, evA[i], evB[i])...)
+		}
+	}
+
+	return r, nil
+}
+
+// Identical reports whether no difference was found
+func (r *DiffReport) Identical() bool {
+	if len(r.OnlyInA) > 0 || len(r.OnlyInB) > 0 || len(r.Packets) > 0 {
+		return false
+	}
+	for _, c := range r.EventCounts {
+		if c[0] != c[1] {
+			return false
+		}
+	}
+	return r.Stats == StatsDelta{}
+}
+
+// String formats the report as a readable summary
+func (r *DiffReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Diff %s -> %s\n", r.A, r.B)
+	if r.Identical() {
+		b.WriteString("No behavior changes found\n")
+		return b.String()
+	}
+
+	types := make([]string, 0, len(r.EventCounts))
+	for typ := range r.EventCounts {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	b.WriteString("Events:\n")
+	for _, typ := range types {
+		c := r.EventCounts[typ]
+		mark := ""
+		if c[0] != c[1] {
+			mark = fmt.Sprintf(" (%+d)", c[1]-c[0])
+		}
+		fmt.Fprintf(&b, "  %-18s %d -> %d%s\n", typ, c[0], c[1], mark)
+	}
+
+	s := r.Stats
+	fmt.Fprintf(&b, "Stats: requests %+d, responses %+d, clients %+d, upstream %+d, attacks %+d, avg response %+v\n",
+		s.TotalRequests, s.TotalResponses, s.UniqueClients, s.UpstreamQueries, s.AttacksExecuted, s.AvgResponseTime)
+
+	if len(r.OnlyInA) > 0 {
+		fmt.Fprintf(&b, "Clients only in %s: %s\n", r.A, strings.Join(r.OnlyInA, ", "))
+	}
+	if len(r.OnlyInB) > 0 {
+		fmt.Fprintf(&b, "Clients only in %s: %s\n", r.B, strings.Join(r.OnlyInB, ", "))
+	}
+
+	if len(r.Packets) > 0 {
+		fmt.Fprintf(&b, "Packet differences (%d):\n", len(r.Packets))
+		for i, d := range r.Packets {
+			if i == diffSummaryLimit {
+				fmt.Fprintf(&b, "  ... %d more\n", len(r.Packets)-i)
+				break
+			}
+			fmt.Fprintf(&b, "  %s #%d %s: %s -> %s\n", d.Type, d.Index+1, d.Field, d.A, d.B)
+		}
+	}
+	return b.String()
+}
+
+// eventsByType groups events by type, keeping their order
+func eventsByType(events []SessionEvent) map[string][]SessionEvent {
+	out := make(map[string][]SessionEvent)
+	for _, ev := range events {
+		out[ev.Type] = append(out[ev.Type], ev)
+	}
+	return out
+}
+
+// clientIPs returns the client IPs in a session, ignoring source ports
+func clientIPs(events []SessionEvent) map[string]bool {
+	out := make(map[string]bool)
+	for _, ev := range events {
+		if ev.ClientAddr == "" {
+			continue
+		}
+		host, _, err := net.SplitHostPort(ev.ClientAddr)
+		if err != nil {
+			host = ev.ClientAddr
+		}
+		out[host] = true
+	}
+	return out
+}
+
+// missingFrom returns the sorted keys of a that are not in b
+func missingFrom(a, b map[string]bool) []string {
+	var out []string
+	for k := range a {
+		if !b[k] {
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// diffPackets compares the packets of two aligned events field by field
+func diffPackets(typ string, index int, a, b SessionEvent) []PacketDiff {
+	var diffs []PacketDiff
+	add := func(field, va, vb string) {
+		if va != vb {
+			diffs = append(diffs, PacketDiff{Type: typ, Index: index, Field: field, A: va, B: vb})
+		}
+	}
+
+	add("length", fmt.Sprint(len(a.PacketData)), fmt.Sprint(len(b.PacketData)))
+
+	pa, errA := ntpcore.ParsePacket(a.PacketData)
+	pb, errB := ntpcore.ParsePacket(b.PacketData)
+	if errA != nil || errB != nil {
+		add("parse", errString(errA), errString(errB))
+		return diffs
+	}
+
+	add("leap", fmt.Sprint(pa.LeapIndicator), fmt.Sprint(pb.LeapIndicator))
+	add("version", fmt.Sprint(pa.Version), fmt.Sprint(pb.Version))
+	add("mode", pa.GetModeString(), pb.GetModeString())
+	add("stratum", fmt.Sprint(pa.Stratum), fmt.Sprint(pb.Stratum))
+	add("poll", fmt.Sprint(pa.Poll), fmt.Sprint(pb.Poll))
+	add("precision", fmt.Sprint(pa.Precision), fmt.Sprint(pb.Precision))
+	add("kod", pa.GetKissOfDeathCode(), pb.GetKissOfDeathCode())
+	if pa.GetKissOfDeathCode() == "" && pb.GetKissOfDeathCode() == "" {
+		add("ref_id", fmt.Sprintf("%08x", pa.ReferenceID), fmt.Sprintf("%08x", pb.ReferenceID))
+	}
+
+	offA := pa.TransmitTimeNear(a.Timestamp).Sub(a.Timestamp)
+	offB := pb.TransmitTimeNear(b.Timestamp).Sub(b.Timestamp)
+	if d := offB - offA; d > diffOffsetTolerance || d < -diffOffsetTolerance {
+		add("transmit_offset", offA.Round(time.Millisecond).String(), offB.Round(time.Millisecond).String())
+	}
+	return diffs
+}
+
+// errString formats an optional error
+func errString(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	return err.Error()
+}
@@ -0,0 +1,241 @@
+package session
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	pcapMagic        = 0xa1b2c3d4
+	pcapSnapLen      = 65535
+	pcapLinkEthernet = 1
+
+	ntpPort       = 123
+	upstreamPort  = 50123 // Fabricated local port for upstream responses
+	ethHeaderLen  = 14
+	ipv4HeaderLen = 20
+	ipv6HeaderLen = 40
+	udpHeaderLen  = 8
+)
+
+// Fabricated link and network addresses for the TimeHammer side of the
+// capture (documentation ranges, locally administered MACs)
+var (
+	pcapServerMAC = net.HardwareAddr{0x02, 0x54, 0x48, 0x00, 0x00, 0x01}
+	pcapPeerMAC   = net.HardwareAddr{0x02, 0x54, 0x48, 0x00, 0x00, 0x02}
+	pcapServerV4  = net.IPv4(192, 0, 2, 1).To4()
+	pcapServerV6  = net.ParseIP("2001:db8::1")
+	pcapPeerV4    = net.IPv4(198, 51, 100, 1).To4()
+	pcapPeerV6    = net.ParseIP("2001:db8::2")
+)
+
+// ExportPCAP writes the packets of a session to a classic pcap file with
+// Ethernet/IP/UDP framing so it can be opened in Wireshark. Client
+// addresses come from the session; the server side uses a fixed
+// documentation address since it is not recorded.
+func ExportPCAP(sess *Session, path string) error {
+	if sess == nil {
+		return fmt.Errorf("no session to export")
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+
+	// Global header
+	hdr := make([]byte, 24)
+	binary.LittleEndian.PutUint32(hdr[0:4], pcapMagic)
+	binary.LittleEndian.PutUint16(hdr[4:6], 2)
+	binary.LittleEndian.PutUint16(hdr[6:8], 4)
+	binary.LittleEndian.PutUint32(hdr[16:20], pcapSnapLen)
+	binary.LittleEndian.PutUint32(hdr[20:24], pcapLinkEthernet)
+	w.Write(hdr)
+
+	for _, event := range sess.Events {
+		frame := eventFrame(event)
+		if frame == nil {
+			continue
+		}
+		if err := writePCAPRecord(w, event.Timestamp, frame); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writePCAPRecord writes one record header and frame
+func writePCAPRecord(w *bufio.Writer, ts time.Time, frame []byte) error {
+	rec := make([]byte, 16)
+	binary.LittleEndian.PutUint32(rec[0:4], uint32(ts.Unix()))
+	binary.LittleEndian.PutUint32(rec[4:8], uint32(ts.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(rec[8:12], uint32(len(frame)))
+	binary.LittleEndian.PutUint32(rec[12:16], uint32(len(frame)))
+	if _, err := w.Write(rec); err != nil {
+		return err
+	}
+	_, err := w.Write(frame)
+	return err
+}
+
+// eventFrame builds the Ethernet frame for an event, or nil if the event
+// carries no packet
+func eventFrame(event SessionEvent) []byte {
+	if len(event.PacketData) == 0 {
+		return nil
+	}
+
+	switch event.Type {
+	case "request":
+		peer, port := splitPeer(event.ClientAddr)
+		server := serverIPFor(peer)
+		return buildFrame(pcapPeerMAC, pcapServerMAC, peer, server, port, ntpPort, event.PacketData)
+	case "response":
+		peer, port := splitPeer(event.ClientAddr)
+		server := serverIPFor(peer)
+		return buildFrame(pcapServerMAC, pcapPeerMAC, server, peer, ntpPort, port, event.PacketData)
+	case "upstream_response":
+		peer, _ := splitPeer(event.UpstreamAddr)
+		server := serverIPFor(peer)
+		return buildFrame(pcapPeerMAC, pcapServerMAC, peer, server, ntpPort, upstreamPort, event.PacketData)
+	default:
+		return nil
+	}
+}
+
+// splitPeer parses "ip:port" (or a bare IP), substituting a placeholder
+// address for anything that is not an IP, such as an upstream hostname
+func splitPeer(addr string) (net.IP, uint16) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, portStr = addr, ""
+	}
+
+	port := uint16(ntpPort)
+	if p, err := strconv.ParseUint(portStr, 10, 16); err == nil {
+		port = uint16(p)
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return pcapPeerV4, port
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4, port
+	}
+	return ip, port
+}
+
+// serverIPFor returns the fabricated server address in the peer's family
+func serverIPFor(peer net.IP) net.IP {
+	if peer.To4() != nil {
+		return pcapServerV4
+	}
+	return pcapServerV6
+}
+
+// buildFrame wraps a UDP payload in UDP, IPv4/IPv6 and Ethernet headers
+func buildFrame(srcMAC, dstMAC net.HardwareAddr, src, dst net.IP, srcPort, dstPort uint16, payload []byte) []byte {
+	v4 := src.To4() != nil
+	ipLen := ipv6HeaderLen
+	etherType := uint16(0x86dd)
+	if v4 {
+		ipLen = ipv4HeaderLen
+		etherType = 0x0800
+	}
+
+	udpLen := udpHeaderLen + len(payload)
+	frame := make([]byte, ethHeaderLen+ipLen+udpLen)
+
+	// Ethernet
+	copy(frame[0:6], dstMAC)
+	copy(frame[6:12], srcMAC)
+	binary.BigEndian.PutUint16(frame[12:14], etherType)
+
+	// IP
+	ip := frame[ethHeaderLen : ethHeaderLen+ipLen]
+	if v4 {
+		ip[0] = 0x45
+		binary.BigEndian.PutUint16(ip[2:4], uint16(ipLen+udpLen))
+		ip[8] = 64 // TTL
+		ip[9] = 17 // UDP
+		copy(ip[12:16], src.To4())
+		copy(ip[16:20], dst.To4())
+		binary.BigEndian.PutUint16(ip[10:12], checksum(ip, 0))
+	} else {
+		ip[0] = 0x60
+		binary.BigEndian.PutUint16(ip[4:6], uint16(udpLen))
+		ip[6] = 17 // Next header: UDP
+		ip[7] = 64 // Hop limit
+		copy(ip[8:24], src.To16())
+		copy(ip[24:40], dst.To16())
+	}
+
+	// UDP
+	udp := frame[ethHeaderLen+ipLen:]
+	binary.BigEndian.PutUint16(udp[0:2], srcPort)
+	binary.BigEndian.PutUint16(udp[2:4], dstPort)
+	binary.BigEndian.PutUint16(udp[4:6], uint16(udpLen))
+	copy(udp[udpHeaderLen:], payload)
+
+	sum := udpChecksum(src, dst, udp)
+	binary.BigEndian.PutUint16(udp[6:8], sum)
+
+	return frame
+}
+
+// udpChecksum computes the UDP checksum including the pseudo-header
+func udpChecksum(src, dst net.IP, udp []byte) uint16 {
+	var pseudo []byte
+	if ip4 := src.To4(); ip4 != nil {
+		pseudo = make([]byte, 12)
+		copy(pseudo[0:4], ip4)
+		copy(pseudo[4:8], dst.To4())
+		pseudo[9] = 17
+		binary.BigEndian.PutUint16(pseudo[10:12], uint16(len(udp)))
+	} else {
+		pseudo = make([]byte, 40)
+		copy(pseudo[0:16], src.To16())
+		copy(pseudo[16:32], dst.To16())
+		binary.BigEndian.PutUint32(pseudo[32:36], uint32(len(udp)))
+		pseudo[39] = 17
+	}
+
+	sum := checksum(udp, sumWords(pseudo, 0))
+	if sum == 0 {
+		sum = 0xffff // Zero means "no checksum" in UDP
+	}
+	return sum
+}
+
+// sumWords adds data as big-endian 16-bit words to a running sum
+func sumWords(data []byte, sum uint32) uint32 {
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(data[i])<<8 | uint32(data[i+1])
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	return sum
+}
+
+// checksum returns the Internet checksum of data, starting from sum
+func checksum(data []byte, sum uint32) uint16 {
+	sum = sumWords(data, sum)
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,178 @@
+package session
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/logger"
+	"github.com/neutrinoguy/timehammer/internal/ops"
+)
+
+// ReplayOptions controls how a recorded session is replayed
+type ReplayOptions struct {
+	PreserveTiming bool    // Wait the recorded gap between events
+	Speed          float64 // Timing multiplier (2 = twice as fast, <= 0 means 1)
+	DryRun         bool    // Log what would be sent without sending
+	SkipUpstream   bool    // Skip upstream_query/upstream_response events
+
+	// Move every non-zero timestamp by the time since the first replayed
+	// event was recorded, so packets look current to clients that reject
+	// stale ones. Offsets between events and fields are kept; a MAC no
+	// longer matches.
+	RewriteTimestamps bool
+}
+
+// Replayer sends recorded session packets to a live UDP target
+type Replayer struct {
+	log *logger.Logger
+}
+
+// NewReplayer creates a new session replayer
+func NewReplayer() *Replayer {
+	return &Replayer{
+		log: logger.GetLogger(),
+	}
+}
+
+// Replay transmits the stored packet bytes of a session to target
+// (host:port, port defaults to 123). It blocks until done and is tracked
+// as a cancellable operation.
+func (r *Replayer) Replay(sess *Session, target string, opts ReplayOptions) error {
+	if sess == nil {
+		return fmt.Errorf("no session to replay")
+	}
+
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		target = net.JoinHostPort(target, "123")
+	}
+	addr, err := net.ResolveUDPAddr("udp", target)
+	if err != nil {
+		return fmt.Errorf("invalid replay target %q: %w", target, err)
+	}
+
+	speed := opts.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+
+	var conn *net.UDPConn
+	if !opts.DryRun {
+		conn, err = net.DialUDP("udp", nil, addr)
+		if err != nil {
+			return fmt.Errorf("failed to open replay socket: %w", err)
+		}
+		defer conn.Close()
+	}
+
+	ctx, done := ops.GetRegistry().Start(context.Background(), fmt.Sprintf("replay %s -> %s", sess.ID, addr))
+	defer done()
+
+	mode := "Replaying"
+	if opts.DryRun {
+		mode = "Dry-run replay of"
+	}
+	r.log.Infof("SESSION", "%s session %s to %s (%d events, speed %.2gx)", mode, sess.ID, addr, len(sess.Events), speed)
+
+	sent := 0
+	var last time.Time
+	var shift time.Duration // Added to recorded timestamps when rewriting
+	for i, event := range sess.Events {
+		if !replayable(event, opts) {
+			continue
+		}
+
+		// Wait out the recorded gap since the previous replayed event
+		if opts.PreserveTiming && !last.IsZero() {
+			if gap := time.Duration(float64(event.Timestamp.Sub(last)) / speed); gap > 0 {
+				select {
+				case <-time.After(gap):
+				case <-ctx.Done():
+					r.log.Warnf("SESSION", "Replay of %s cancelled after %d packets", sess.ID, sent)
+					return ctx.Err()
+				}
+			}
+		}
+		last = event.Timestamp
+
+		if ctx.Err() != nil {
+			r.log.Warnf("SESSION", "Replay of %s cancelled after %d packets", sess.ID, sent)
+			return ctx.Err()
+		}
+
+		data := event.PacketData
+		if opts.RewriteTimestamps {
+			if sent == 0 {
+				shift = time.Since(event.Timestamp)
+				r.log.Infof("SESSION", "Rewriting timestamps of %s by %+v", sess.ID, shift.Round(time.Second))
+			}
+			data = shiftTimestamps(data, shift)
+		}
+
+		if opts.DryRun {
+			r.log.Infof("SESSION", "[dry-run] event %d: would send %s (%d bytes, recorded from %s)",
+				i, event.Type, len(event.PacketData), orPeer(event))
+			sent++
+			continue
+		}
+
+		if _, err := conn.Write(data); err != nil {
+			r.log.Errorf("SESSION", "Replay of %s failed at event %d: %v", sess.ID, i, err)
+			return err
+		}
+		r.log.Debugf("SESSION", "Replayed event %d: %s (%d bytes)", i, event.Type, len(event.PacketData))
+		sent++
+	}
+
+	r.log.Infof("SESSION", "Replay of %s finished: %d packets to %s", sess.ID, sent, addr)
+	return nil
+}
+
+// shiftTimestamps returns a copy of an NTP packet with the reference,
+// origin, receive and transmit timestamps moved by shift. Zero timestamps
+// mean "unset" and stay zero; every other byte is copied unchanged.
+func shiftTimestamps(data []byte, shift time.Duration) []byte {
+	out := append([]byte(nil), data...)
+	if len(out) < 48 {
+		return out
+	}
+
+	// 32.32 fixed point; wrapping past an era boundary is what the wire does
+	secs := int64(shift / time.Second)
+	frac := int64(shift%time.Second) << 32 / int64(time.Second)
+	delta := uint64(secs<<32 + frac)
+	for off := 16; off < 48; off += 8 {
+		if ts := binary.BigEndian.Uint64(out[off:]); ts != 0 {
+			binary.BigEndian.PutUint64(out[off:], ts+delta)
+		}
+	}
+	return out
+}
+
+// replayable reports whether an event carries packet bytes the options allow
+func replayable(event SessionEvent, opts ReplayOptions) bool {
+	if len(event.PacketData) == 0 {
+		return false
+	}
+	switch event.Type {
+	case "request", "response":
+		return true
+	case "upstream_query", "upstream_response":
+		return !opts.SkipUpstream
+	default:
+		return false
+	}
+}
+
+// orPeer returns the address an event was recorded against
+func orPeer(event SessionEvent) string {
+	if event.ClientAddr != "" {
+		return event.ClientAddr
+	}
+	if event.UpstreamAddr != "" {
+		return event.UpstreamAddr
+	}
+	return "unknown"
+}
@@ -6,17 +6,21 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/neutrinoguy/timehammer/internal/config"
+	"github.com/neutrinoguy/timehammer/internal/kodcheck"
+	"github.com/neutrinoguy/timehammer/internal/netutil"
 	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
 )
 
 // SessionEvent represents a single event in a session
 type SessionEvent struct {
 	Timestamp    time.Time   `json:"timestamp"`
-	Type         string      `json:"type"` // "request", "response", "upstream_query", "upstream_response"
+	Type         string      `json:"type"` // "request", "response", "upstream_query", "upstream_response", "attack_state"
 	ClientAddr   string      `json:"client_addr,omitempty"`
 	UpstreamAddr string      `json:"upstream_addr,omitempty"`
 	PacketData   []byte      `json:"packet_data"`
@@ -37,16 +41,23 @@ type PacketInfo struct {
 	TransmitTime  string `json:"transmit_time"`
 	IsKoD         bool   `json:"is_kod,omitempty"`
 	KoDCode       string `json:"kod_code,omitempty"`
+	KeyID         uint32 `json:"key_id,omitempty"`
+	MACLen        int    `json:"mac_len,omitempty"` // Authenticator length (4 = crypto-NAK)
+
+	Extensions []string `json:"extensions,omitempty"` // Extension field names
 }
 
 // Session represents a recording session
 type Session struct {
-	ID          string         `json:"id"`
-	StartTime   time.Time      `json:"start_time"`
-	EndTime     time.Time      `json:"end_time,omitempty"`
-	Description string         `json:"description,omitempty"`
-	Events      []SessionEvent `json:"events"`
-	Stats       SessionStats   `json:"stats"`
+	ID           string          `json:"id"`
+	StartTime    time.Time       `json:"start_time"`
+	EndTime      time.Time       `json:"end_time,omitempty"`
+	Description  string          `json:"description,omitempty"`
+	ClientFilter []string        `json:"client_filter,omitempty"` // IPs/CIDRs recorded (empty = all)
+	Tags         []string        `json:"tags,omitempty"`          // e.g. the attacks of an auto-recording
+	Events       []SessionEvent  `json:"events"`
+	Stats        SessionStats    `json:"stats"`
+	Timeline     []TimelineEntry `json:"timeline,omitempty"`
 }
 
 // SessionStats contains session statistics
@@ -57,6 +68,28 @@ type SessionStats struct {
 	UpstreamQueries int           `json:"upstream_queries"`
 	AttacksExecuted int           `json:"attacks_executed"`
 	AvgResponseTime time.Duration `json:"avg_response_time"`
+
+	// AttacksExecuted broken down by attack type, and by client IP
+	AttacksByType   map[string]int            `json:"attacks_by_type,omitempty"`
+	AttacksByClient map[string]map[string]int `json:"attacks_by_client,omitempty"`
+
+	KoDCompliance []kodcheck.Result `json:"kod_compliance,omitempty"` // Reactions to RATE KoDs
+}
+
+// countAttack counts an attack applied to a request from clientAddr
+func (s *SessionStats) countAttack(attack, clientAddr string) {
+	s.AttacksExecuted++
+	if s.AttacksByType == nil {
+		s.AttacksByType = make(map[string]int)
+		s.AttacksByClient = make(map[string]map[string]int)
+	}
+	s.AttacksByType[attack]++
+
+	client := clientHost(clientAddr)
+	if s.AttacksByClient[client] == nil {
+		s.AttacksByClient[client] = make(map[string]int)
+	}
+	s.AttacksByClient[client][attack]++
 }
 
 // SessionRecorder handles session recording
@@ -66,6 +99,15 @@ type SessionRecorder struct {
 	session       *Session
 	clientMap     map[string]bool
 	responseTimes []time.Duration
+	attackState   string // Last recorded attack state marker
+	filter        *netutil.AddrSet
+}
+
+// RecordingOptions configures a new recording
+type RecordingOptions struct {
+	Description string
+	Clients     []string // Only record these IPs/CIDRs (empty = all clients)
+	Tags        []string
 }
 
 // Global recorder instance
@@ -84,6 +126,17 @@ func GetRecorder() *SessionRecorder {
 
 // StartRecording starts a new recording session
 func (r *SessionRecorder) StartRecording(description string) error {
+	return r.StartRecordingWithOptions(RecordingOptions{Description: description})
+}
+
+// StartRecordingWithOptions starts a new recording session, optionally
+// restricted to a set of target clients
+func (r *SessionRecorder) StartRecordingWithOptions(opts RecordingOptions) error {
+	filter, err := netutil.ParseAddrSet(opts.Clients)
+	if err != nil {
+		return err
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -92,14 +145,18 @@ func (r *SessionRecorder) StartRecording(description string) error {
 	}
 
 	r.session = &Session{
-		ID:          fmt.Sprintf("session_%d", time.Now().Unix()),
-		StartTime:   time.Now(),
-		Description: description,
-		Events:      make([]SessionEvent, 0),
-		Stats:       SessionStats{},
+		ID:           fmt.Sprintf("session_%d", time.Now().Unix()),
+		StartTime:    time.Now(),
+		Description:  opts.Description,
+		ClientFilter: filter.Specs(),
+		Tags:         append([]string(nil), opts.Tags...),
+		Events:       make([]SessionEvent, 0),
+		Stats:        SessionStats{},
 	}
+	r.filter = filter
 	r.clientMap = make(map[string]bool)
 	r.responseTimes = make([]time.Duration, 0)
+	r.attackState = ""
 	r.active = true
 
 	return nil
@@ -126,6 +183,10 @@ func (r *SessionRecorder) StopRecording() (*Session, error) {
 		r.session.Stats.AvgResponseTime = total / time.Duration(len(r.responseTimes))
 	}
 
+	// Derive the attack timeline and KoD compliance from the event stream
+	r.session.Timeline = BuildTimeline(r.session.Events, r.session.EndTime)
+	r.session.Stats.KoDCompliance = kodCompliance(r.session.Events, r.session.EndTime)
+
 	// Save session to file
 	if err := r.saveSession(); err != nil {
 		return nil, err
@@ -138,6 +199,17 @@ func (r *SessionRecorder) StopRecording() (*Session, error) {
 	return session, nil
 }
 
+// AddTag tags the current recording, if any, once per tag
+func (r *SessionRecorder) AddTag(tag string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.active || r.session == nil || slices.Contains(r.session.Tags, tag) {
+		return
+	}
+	r.session.Tags = append(r.session.Tags, tag)
+}
+
 // IsRecording returns whether recording is active
 func (r *SessionRecorder) IsRecording() bool {
 	r.mu.RLock()
@@ -150,7 +222,7 @@ func (r *SessionRecorder) RecordClientRequest(clientAddr string, packet *ntpcore
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if !r.active {
+	if !r.active || !r.filter.ContainsAddr(clientAddr) {
 		return
 	}
 
@@ -158,15 +230,22 @@ func (r *SessionRecorder) RecordClientRequest(clientAddr string, packet *ntpcore
 	r.session.Stats.TotalRequests++
 
 	if attackMode != "" {
-		r.session.Stats.AttacksExecuted++
+		// Attribute the request to the attack type of the current state
+		// marker; the mode is a display label such as "Time Spoofing"
+		attack, _, _ := strings.Cut(r.attackState, "|")
+		if attack == "" {
+			attack = attackMode
+		}
+		r.session.Stats.countAttack(attack, clientAddr)
 	}
 
+	now := time.Now()
 	event := SessionEvent{
-		Timestamp:    time.Now(),
+		Timestamp:    now,
 		Type:         "request",
 		ClientAddr:   clientAddr,
 		PacketData:   packet.Bytes(),
-		ParsedPacket: packetToInfo(packet),
+		ParsedPacket: packetToInfo(packet, now),
 		AttackMode:   attackMode,
 	}
 
@@ -178,19 +257,50 @@ func (r *SessionRecorder) RecordClientResponse(clientAddr string, packet *ntpcor
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if !r.active {
+	if !r.active || !r.filter.ContainsAddr(clientAddr) {
 		return
 	}
 
 	r.session.Stats.TotalResponses++
 	r.responseTimes = append(r.responseTimes, responseTime)
 
+	now := time.Now()
 	event := SessionEvent{
-		Timestamp:    time.Now(),
+		Timestamp:    now,
 		Type:         "response",
 		ClientAddr:   clientAddr,
 		PacketData:   packet.Bytes(),
-		ParsedPacket: packetToInfo(packet),
+		ParsedPacket: packetToInfo(packet, now),
+	}
+
+	r.session.Events = append(r.session.Events, event)
+}
+
+// RecordAttackState records an attack state marker when the active attack or
+// its parameters differ from the last recorded state
+func (r *SessionRecorder) RecordAttackState(attack, params string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.active {
+		return
+	}
+
+	state := attack + "|" + params
+	if state == r.attackState {
+		return
+	}
+	// Nothing to mark until an attack is first seen
+	if r.attackState == "" && attack == "" {
+		return
+	}
+	r.attackState = state
+
+	event := SessionEvent{
+		Timestamp:  time.Now(),
+		Type:       "attack_state",
+		AttackMode: attack,
+		Notes:      params,
 	}
 
 	r.session.Events = append(r.session.Events, event)
@@ -225,12 +335,13 @@ func (r *SessionRecorder) RecordUpstreamResponse(upstreamAddr string, packet *nt
 		return
 	}
 
+	now := time.Now()
 	event := SessionEvent{
-		Timestamp:    time.Now(),
+		Timestamp:    now,
 		Type:         "upstream_response",
 		UpstreamAddr: upstreamAddr,
 		PacketData:   packet.Bytes(),
-		ParsedPacket: packetToInfo(packet),
+		ParsedPacket: packetToInfo(packet, now),
 	}
 
 	r.session.Events = append(r.session.Events, event)
@@ -287,12 +398,15 @@ func ListSessions() ([]SessionSummary, error) {
 		}
 
 		sessions = append(sessions, SessionSummary{
-			ID:          session.ID,
-			StartTime:   session.StartTime,
-			EndTime:     session.EndTime,
-			Description: session.Description,
-			EventCount:  len(session.Events),
-			Stats:       session.Stats,
+			ID:           session.ID,
+			StartTime:    session.StartTime,
+			EndTime:      session.EndTime,
+			Description:  session.Description,
+			ClientFilter: session.ClientFilter,
+			Tags:         session.Tags,
+			EventCount:   len(session.Events),
+			Stats:        session.Stats,
+			Timeline:     session.Timeline,
 		})
 	}
 
@@ -301,12 +415,15 @@ func ListSessions() ([]SessionSummary, error) {
 
 // SessionSummary provides a summary of a session
 type SessionSummary struct {
-	ID          string       `json:"id"`
-	StartTime   time.Time    `json:"start_time"`
-	EndTime     time.Time    `json:"end_time"`
-	Description string       `json:"description"`
-	EventCount  int          `json:"event_count"`
-	Stats       SessionStats `json:"stats"`
+	ID           string          `json:"id"`
+	StartTime    time.Time       `json:"start_time"`
+	EndTime      time.Time       `json:"end_time"`
+	Description  string          `json:"description"`
+	ClientFilter []string        `json:"client_filter,omitempty"`
+	Tags         []string        `json:"tags,omitempty"`
+	EventCount   int             `json:"event_count"`
+	Stats        SessionStats    `json:"stats"`
+	Timeline     []TimelineEntry `json:"timeline,omitempty"`
 }
 
 // LoadSession loads a session from disk
@@ -330,6 +447,25 @@ func LoadSession(id string) (*Session, error) {
 	return &session, nil
 }
 
+// ExportSessionPCAP writes a saved session to the exports directory as
+// <id>.pcap and returns the file path
+func ExportSessionPCAP(id string) (string, error) {
+	sess, err := LoadSession(id)
+	if err != nil {
+		return "", err
+	}
+
+	dataDir, err := config.GetDataDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dataDir, config.ExportDirName, sess.ID+".pcap")
+	if err := ExportPCAP(sess, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
 // DeleteSession deletes a session file
 func DeleteSession(id string) error {
 	dataDir, err := config.GetDataDir()
@@ -341,8 +477,9 @@ func DeleteSession(id string) error {
 	return os.Remove(sessionPath)
 }
 
-// packetToInfo converts an NTP packet to human-readable info
-func packetToInfo(p *ntpcore.NTPPacket) *PacketInfo {
+// packetToInfo converts an NTP packet to human-readable info. Timestamps
+// are read in the era nearest to when the packet was seen.
+func packetToInfo(p *ntpcore.NTPPacket, seen time.Time) *PacketInfo {
 	if p == nil {
 		return nil
 	}
@@ -354,7 +491,7 @@ func packetToInfo(p *ntpcore.NTPPacket) *PacketInfo {
 		Stratum:       p.Stratum,
 		Poll:          p.Poll,
 		Precision:     p.Precision,
-		TransmitTime:  p.GetTransmitTime().Format(time.RFC3339),
+		TransmitTime:  p.TransmitTimeNear(seen).Format(time.RFC3339),
 	}
 
 	// Check for KoD
@@ -364,6 +501,16 @@ func packetToInfo(p *ntpcore.NTPPacket) *PacketInfo {
 		info.KoDCode = kod
 	}
 
+	for _, f := range p.Extensions {
+		info.Extensions = append(info.Extensions, ntpcore.ExtensionName(f.Type))
+	}
+
+	// Authenticator, if any
+	if p.HasMAC {
+		info.KeyID = p.KeyID
+		info.MACLen = p.MACLen()
+	}
+
 	// Reference ID as string (for stratum 0-1 it's ASCII, otherwise IP)
 	if p.Stratum <= 1 {
 		bytes := []byte{
@@ -384,6 +531,29 @@ func packetToInfo(p *ntpcore.NTPPacket) *PacketInfo {
 	return info
 }
 
+// RecentClientEvents returns up to n of the latest requests and responses
+// exchanged with a client IP in the current recording, oldest first
+func (r *SessionRecorder) RecentClientEvents(ip string, n int) []SessionEvent {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if !r.active || r.session == nil {
+		return nil
+	}
+
+	var events []SessionEvent
+	for i := len(r.session.Events) - 1; i >= 0 && len(events) < n; i-- {
+		e := r.session.Events[i]
+		if (e.Type == "request" || e.Type == "response") && clientHost(e.ClientAddr) == ip {
+			events = append(events, e)
+		}
+	}
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	return events
+}
+
 // GetCurrentSession returns the current session info (if recording)
 func (r *SessionRecorder) GetCurrentSession() *SessionSummary {
 	r.mu.RLock()
@@ -394,10 +564,12 @@ func (r *SessionRecorder) GetCurrentSession() *SessionSummary {
 	}
 
 	return &SessionSummary{
-		ID:          r.session.ID,
-		StartTime:   r.session.StartTime,
-		Description: r.session.Description,
-		EventCount:  len(r.session.Events),
-		Stats:       r.session.Stats,
+		ID:           r.session.ID,
+		StartTime:    r.session.StartTime,
+		Description:  r.session.Description,
+		ClientFilter: r.session.ClientFilter,
+		Tags:         append([]string(nil), r.session.Tags...),
+		EventCount:   len(r.session.Events),
+		Stats:        r.session.Stats,
 	}
 }
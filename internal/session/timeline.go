@@ -0,0 +1,195 @@
+package session
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/kodcheck"
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+)
+
+// Timeline entry kinds
+const (
+	TimelineAttackStart = "attack_start"
+	TimelineAttackStop  = "attack_stop"
+	TimelineParamChange = "param_change"
+	TimelineReaction    = "reaction"
+)
+
+// reactionGapFactor is how many typical intervals a client must stay silent
+// before the silence is reported as a reaction
+const reactionGapFactor = 4
+
+// TimelineEntry is one step in the narrative of a recorded test
+type TimelineEntry struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`
+	Attack  string    `json:"attack,omitempty"`
+	Client  string    `json:"client,omitempty"`
+	Detail  string    `json:"detail"`
+	Applied int       `json:"applied,omitempty"` // Attack-applied responses in the phase (stop entries)
+}
+
+// clientTrack holds per-client state used for reaction detection
+type clientTrack struct {
+	last      time.Time
+	version   uint8
+	intervals []time.Duration
+}
+
+// typical returns the median observed request interval
+func (c *clientTrack) typical() time.Duration {
+	if len(c.intervals) < 3 {
+		return 0
+	}
+	sorted := make([]time.Duration, len(c.intervals))
+	copy(sorted, c.intervals)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
+
+// BuildTimeline derives attack phases, parameter changes and client
+// reactions from a session's event stream
+func BuildTimeline(events []SessionEvent, end time.Time) []TimelineEntry {
+	var timeline []TimelineEntry
+
+	curAttack, curParams := "", ""
+	applied := 0
+	clients := make(map[string]*clientTrack)
+
+	stopPhase := func(at time.Time, detail string) {
+		timeline = append(timeline, TimelineEntry{
+			Time:    at,
+			Kind:    TimelineAttackStop,
+			Attack:  curAttack,
+			Detail:  detail,
+			Applied: applied,
+		})
+		curAttack, curParams, applied = "", "", 0
+	}
+	startPhase := func(at time.Time, attack, params string) {
+		curAttack, curParams, applied = attack, params, 0
+		timeline = append(timeline, TimelineEntry{
+			Time:   at,
+			Kind:   TimelineAttackStart,
+			Attack: attack,
+			Detail: params,
+		})
+	}
+
+	for _, ev := range events {
+		switch ev.Type {
+		case "attack_state":
+			switch {
+			case ev.AttackMode == curAttack && ev.Notes != curParams:
+				curParams = ev.Notes
+				timeline = append(timeline, TimelineEntry{
+					Time:   ev.Timestamp,
+					Kind:   TimelineParamChange,
+					Attack: curAttack,
+					Detail: ev.Notes,
+				})
+			case ev.AttackMode != curAttack:
+				if curAttack != "" {
+					stopPhase(ev.Timestamp, "attack changed")
+				}
+				if ev.AttackMode != "" {
+					startPhase(ev.Timestamp, ev.AttackMode, ev.Notes)
+				}
+			}
+
+		case "request":
+			if ev.AttackMode != "" {
+				// Sessions without state markers still get phases from applied attacks
+				if curAttack == "" {
+					startPhase(ev.Timestamp, ev.AttackMode, "")
+				}
+				applied++
+			}
+
+			host := clientHost(ev.ClientAddr)
+			track, ok := clients[host]
+			if !ok {
+				track = &clientTrack{}
+				clients[host] = track
+			}
+
+			if ev.ParsedPacket != nil {
+				if track.version != 0 && ev.ParsedPacket.Version != track.version {
+					timeline = append(timeline, TimelineEntry{
+						Time:   ev.Timestamp,
+						Kind:   TimelineReaction,
+						Attack: curAttack,
+						Client: host,
+						Detail: fmt.Sprintf("switched NTP version v%d → v%d", track.version, ev.ParsedPacket.Version),
+					})
+				}
+				track.version = ev.ParsedPacket.Version
+			}
+
+			if !track.last.IsZero() {
+				gap := ev.Timestamp.Sub(track.last)
+				if typical := track.typical(); typical > 0 && gap > typical*reactionGapFactor {
+					timeline = append(timeline, TimelineEntry{
+						Time:   track.last,
+						Kind:   TimelineReaction,
+						Attack: curAttack,
+						Client: host,
+						Detail: fmt.Sprintf("went quiet for %s (usual interval %s)", gap.Round(time.Second), typical.Round(time.Second)),
+					})
+				}
+				track.intervals = append(track.intervals, gap)
+			}
+			track.last = ev.Timestamp
+		}
+	}
+
+	// Clients that never came back
+	for host, track := range clients {
+		if typical := track.typical(); typical > 0 && end.Sub(track.last) > typical*reactionGapFactor {
+			timeline = append(timeline, TimelineEntry{
+				Time:   track.last,
+				Kind:   TimelineReaction,
+				Client: host,
+				Detail: fmt.Sprintf("stopped querying (usual interval %s)", typical.Round(time.Second)),
+			})
+		}
+	}
+
+	if curAttack != "" {
+		stopPhase(end, "session ended")
+	}
+
+	sort.SliceStable(timeline, func(i, j int) bool {
+		return timeline[i].Time.Before(timeline[j].Time)
+	})
+
+	return timeline
+}
+
+// clientHost strips the port from a client address
+func clientHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// kodCompliance measures how clients reacted to the RATE KoDs in the events
+func kodCompliance(events []SessionEvent, end time.Time) []kodcheck.Result {
+	tracker := kodcheck.NewTracker()
+	for _, ev := range events {
+		switch ev.Type {
+		case "request":
+			tracker.Request(clientHost(ev.ClientAddr), ev.Timestamp)
+		case "response":
+			if ev.ParsedPacket != nil && ev.ParsedPacket.IsKoD && ev.ParsedPacket.KoDCode == ntpcore.KoDRate {
+				tracker.KoD(clientHost(ev.ClientAddr), ev.Timestamp)
+			}
+		}
+	}
+	return tracker.Results(end)
+}
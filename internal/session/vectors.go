@@ -0,0 +1,247 @@
+package session
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+	"github.com/neutrinoguy/timehammer/pkg/ntpcore"
+	"gopkg.in/yaml.v3"
+)
+
+// VectorFile is a hand-editable set of test vectors. Files ending in .json
+// are JSON, anything else is YAML.
+type VectorFile struct {
+	Session     string   `yaml:"session,omitempty" json:"session,omitempty"`
+	Description string   `yaml:"description,omitempty" json:"description,omitempty"`
+	Vectors     []Vector `yaml:"vectors" json:"vectors"`
+}
+
+// Vector is one request and the response it produced (or should produce).
+// Packets are hex; whitespace and colons are ignored on import so long
+// packets can be split into readable groups.
+type Vector struct {
+	Name     string `yaml:"name,omitempty" json:"name,omitempty"`
+	AtMs     int64  `yaml:"at_ms" json:"at_ms"` // Offset from the start of the session
+	Client   string `yaml:"client,omitempty" json:"client,omitempty"`
+	Attack   string `yaml:"attack,omitempty" json:"attack,omitempty"`
+	Params   string `yaml:"params,omitempty" json:"params,omitempty"`
+	Request  string `yaml:"request" json:"request"`
+	Response string `yaml:"response,omitempty" json:"response,omitempty"`
+}
+
+// ExportVectors writes the client requests of a session, each paired with
+// the next response sent to the same client, as test vectors
+func ExportVectors(sess *Session, path string) error {
+	if sess == nil {
+		return fmt.Errorf("no session to export")
+	}
+
+	file := VectorFile{Session: sess.ID, Description: sess.Description}
+	pending := make(map[string]int) // Client -> vector awaiting a response
+	var attack, params string
+
+	for _, event := range sess.Events {
+		switch event.Type {
+		case "attack_state":
+			attack, params = event.AttackMode, event.Notes
+		case "request":
+			if len(event.PacketData) == 0 {
+				continue
+			}
+			v := Vector{
+				Name:    fmt.Sprintf("request %d", len(file.Vectors)+1),
+				AtMs:    event.Timestamp.Sub(sess.StartTime).Milliseconds(),
+				Client:  event.ClientAddr,
+				Attack:  event.AttackMode,
+				Request: hex.EncodeToString(event.PacketData),
+			}
+			if v.Attack == "" {
+				v.Attack = attack
+			}
+			if v.Attack == attack {
+				v.Params = params
+			}
+			pending[event.ClientAddr] = len(file.Vectors)
+			file.Vectors = append(file.Vectors, v)
+		case "response":
+			i, ok := pending[event.ClientAddr]
+			if !ok || len(event.PacketData) == 0 {
+				continue
+			}
+			file.Vectors[i].Response = hex.EncodeToString(event.PacketData)
+			delete(pending, event.ClientAddr)
+		}
+	}
+
+	var data []byte
+	var err error
+	if isJSONPath(path) {
+		data, err = json.MarshalIndent(file, "", "  ")
+	} else {
+		data, err = yaml.Marshal(file)
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// ImportVectors reads a vector file back into a session the replayer can
+// run. Packets that do not parse as NTP are kept as raw bytes.
+func ImportVectors(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file VectorFile
+	if isJSONPath(path) {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid vector file %s: %w", path, err)
+	}
+
+	id := file.Session
+	if id == "" {
+		id = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	start := time.Now()
+	sess := &Session{
+		ID:          id,
+		StartTime:   start,
+		Description: file.Description,
+		Events:      make([]SessionEvent, 0, 2*len(file.Vectors)),
+	}
+
+	clients := make(map[string]bool)
+	state := ""
+	for i, v := range file.Vectors {
+		name := v.Name
+		if name == "" {
+			name = fmt.Sprintf("vector %d", i+1)
+		}
+
+		req, err := decodeVectorHex(v.Request)
+		if err != nil {
+			return nil, fmt.Errorf("%s: request: %w", name, err)
+		}
+		if len(req) == 0 {
+			return nil, fmt.Errorf("%s: request is empty", name)
+		}
+		resp, err := decodeVectorHex(v.Response)
+		if err != nil {
+			return nil, fmt.Errorf("%s: response: %w", name, err)
+		}
+
+		ts := start.Add(time.Duration(v.AtMs) * time.Millisecond)
+		if s := v.Attack + "|" + v.Params; s != state && (state != "" || v.Attack != "") {
+			state = s
+			sess.Events = append(sess.Events, SessionEvent{
+				Timestamp:  ts,
+				Type:       "attack_state",
+				AttackMode: v.Attack,
+				Notes:      v.Params,
+			})
+		}
+
+		sess.Events = append(sess.Events, vectorEvent(ts, "request", v.Client, req, v.Attack, name))
+		sess.Stats.TotalRequests++
+		if v.Attack != "" {
+			sess.Stats.countAttack(v.Attack, v.Client)
+		}
+		clients[v.Client] = true
+
+		if len(resp) > 0 {
+			sess.Events = append(sess.Events, vectorEvent(ts, "response", v.Client, resp, "", name))
+			sess.Stats.TotalResponses++
+		}
+	}
+
+	sess.Stats.UniqueClients = len(clients)
+	if n := len(sess.Events); n > 0 {
+		sess.EndTime = sess.Events[n-1].Timestamp
+	} else {
+		sess.EndTime = start
+	}
+	sess.Timeline = BuildTimeline(sess.Events, sess.EndTime)
+	return sess, nil
+}
+
+// ExportSessionVectors writes a saved session to the exports directory as
+// <id>.vectors.yaml and returns the file path
+func ExportSessionVectors(id string) (string, error) {
+	sess, err := LoadSession(id)
+	if err != nil {
+		return "", err
+	}
+
+	dataDir, err := config.GetDataDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dataDir, config.ExportDirName, sess.ID+".vectors.yaml")
+	if err := ExportVectors(sess, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// IsVectorFile reports whether a replay source names a vector file rather
+// than a saved session ID
+func IsVectorFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// LoadSource loads a replay source: a vector file or a saved session ID
+func LoadSource(name string) (*Session, error) {
+	if IsVectorFile(name) {
+		return ImportVectors(name)
+	}
+	return LoadSession(name)
+}
+
+// vectorEvent builds a session event for a vector packet
+func vectorEvent(ts time.Time, typ, client string, data []byte, attack, name string) SessionEvent {
+	event := SessionEvent{
+		Timestamp:  ts,
+		Type:       typ,
+		ClientAddr: client,
+		PacketData: data,
+		AttackMode: attack,
+		Notes:      name,
+	}
+	if p, err := ntpcore.ParsePacket(data); err == nil {
+		event.ParsedPacket = packetToInfo(p, ts)
+	}
+	return event
+}
+
+// decodeVectorHex decodes hex, ignoring whitespace and colon separators
+func decodeVectorHex(s string) ([]byte, error) {
+	clean := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\n', '\r', ':':
+			return -1
+		}
+		return r
+	}, s)
+	return hex.DecodeString(clean)
+}
+
+// isJSONPath reports whether a vector file should be JSON
+func isJSONPath(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
+}
@@ -3,6 +3,7 @@ package tui
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -11,12 +12,16 @@ import (
 
 	"github.com/neutrinoguy/timehammer/internal/attacks"
 	"github.com/neutrinoguy/timehammer/internal/config"
+	"github.com/neutrinoguy/timehammer/internal/kodcheck"
 	"github.com/neutrinoguy/timehammer/internal/logger"
+	"github.com/neutrinoguy/timehammer/internal/ntp"
+	"github.com/neutrinoguy/timehammer/internal/ops"
+	"github.com/neutrinoguy/timehammer/internal/report"
 	"github.com/neutrinoguy/timehammer/internal/server"
 	"github.com/neutrinoguy/timehammer/internal/session"
 )
 
-// Colors
+// Colors, set from the configured theme (see ApplyTheme)
 var (
 	ColorPrimary    = tcell.ColorDodgerBlue
 	ColorSecondary  = tcell.ColorLightGray
@@ -25,6 +30,8 @@ var (
 	ColorDanger     = tcell.ColorRed
 	ColorAccent     = tcell.ColorMediumPurple
 	ColorBackground = tcell.ColorBlack
+	ColorText       = tcell.ColorWhite
+	ColorBar        = tcell.ColorDarkSlateGray
 )
 
 // App represents the TUI application
@@ -42,15 +49,29 @@ type App struct {
 	footer        *tview.TextView
 	statusBar     *tview.TextView
 	logView       *tview.TextView
+	logPanel      *tview.Flex
+	logFilter     *tview.InputField
 	dashboardView *tview.Flex
 	configEditor  *tview.TextArea
 	attackPanel   *tview.Flex
 	helpModal     *tview.Modal
 	sessionPanel  *tview.Flex
+	graphView     *offsetGraph
+	historyView   *tview.Table
+	clientsPanel  *tview.Flex
+	clientList    *tview.List
+	clientDetail  *tview.TextView
 
 	// State
 	currentPage string
 	logChan     chan logger.LogEntry
+	logQuery    logQuery // Log view filter
+	logFollow   bool     // Log view tails new entries (false = frozen)
+	logShown    int      // Entries in the log view
+	logTotal    int      // Entries in the logger buffer
+
+	exportDest   string // Last log export destination ("" = exports directory)
+	exportFormat int    // Last log export format choice (0 = all)
 }
 
 // NewApp creates a new TUI application
@@ -64,6 +85,10 @@ func NewApp(cfg *config.Config, srv *server.Server) *App {
 		recorder: session.GetRecorder(),
 	}
 
+	if err := ApplyTheme(cfg.TUI.Theme); err != nil {
+		a.log.Errorf("CONFIG", "Theme: %v, using the default", err)
+		ApplyTheme("default")
+	}
 	a.setupUI()
 	return a
 }
@@ -76,14 +101,14 @@ func (a *App) setupUI() {
 		SetTextAlign(tview.AlignCenter)
 	a.updateHeader()
 	a.header.SetBackgroundColor(ColorPrimary)
-	a.header.SetTextColor(tcell.ColorWhite)
+	a.header.SetTextColor(ColorText)
 
 	// Create footer with keybindings
 	a.footer = tview.NewTextView().
 		SetDynamicColors(true).
 		SetTextAlign(tview.AlignCenter)
-	a.footer.SetText(" [yellow]F1[white] Dashboard │ [yellow]F2[white] Logs │ [yellow]F3[white] Config │ [yellow]F4[white] Attacks │ [yellow]F5[white] Sessions │ [yellow]F10[white] Start/Stop │ [yellow]F12[white] Quit │ [yellow]?[white] Help ")
-	a.footer.SetBackgroundColor(tcell.ColorDarkSlateGray)
+	a.footer.SetText(" [yellow]F1[white] Dashboard │ [yellow]F2[white] Logs │ [yellow]F3[white] Config │ [yellow]F4[white] Attacks │ [yellow]F5[white] Sessions │ [yellow]F6[white] Graph │ [yellow]F7[white] History │ [yellow]F8[white] Clients │ [yellow]F10[white] Start/Stop │ [yellow]F12[white] Quit │ [yellow]?[white] Help ")
+	a.footer.SetBackgroundColor(ColorBar)
 
 	// Create status bar
 	a.statusBar = tview.NewTextView().
@@ -96,14 +121,20 @@ func (a *App) setupUI() {
 	a.createConfigEditor()
 	a.createAttackPanel()
 	a.createSessionPanel()
+	a.createGraphView()
+	a.createHistoryView()
+	a.createClientsView()
 	a.createHelpModal()
 
 	// Add pages
 	a.pages.AddPage("dashboard", a.dashboardView, true, true)
-	a.pages.AddPage("logs", a.logView, true, false)
+	a.pages.AddPage("logs", a.logPanel, true, false)
 	a.pages.AddPage("config", a.configEditor, true, false)
 	a.pages.AddPage("attacks", a.attackPanel, true, false)
 	a.pages.AddPage("sessions", a.sessionPanel, true, false)
+	a.pages.AddPage("graph", a.graphView, true, false)
+	a.pages.AddPage("history", a.historyView, true, false)
+	a.pages.AddPage("clients", a.clientsPanel, true, false)
 
 	// Create main layout
 	a.mainFlex = tview.NewFlex().SetDirection(tview.FlexRow).
@@ -121,6 +152,11 @@ func (a *App) setupUI() {
 	// Subscribe to log updates
 	a.logChan = a.log.Subscribe()
 	go a.handleLogUpdates()
+
+	// Refresh the status bar as soon as upstream sync changes
+	a.server.OnUpstreamSyncChange(func(old, new ntp.SyncStatus) {
+		a.app.QueueUpdateDraw(a.updateStatusBar)
+	})
 }
 
 // createDashboardView creates the main dashboard
@@ -155,6 +191,12 @@ func (a *App) createDashboardView() {
 	attackStatus.SetTitle(" ⚔️ Security Mode ")
 	attackStatus.SetBorderColor(ColorDanger)
 
+	// Operations panel
+	opsPanel := tview.NewTextView().SetDynamicColors(true)
+	opsPanel.SetBorder(true)
+	opsPanel.SetTitle(" ⏳ Operations ")
+	opsPanel.SetBorderColor(ColorAccent)
+
 	// Quick log panel
 	quickLog := tview.NewTextView().SetDynamicColors(true)
 	quickLog.SetBorder(true)
@@ -170,7 +212,8 @@ func (a *App) createDashboardView() {
 
 	middleRow := tview.NewFlex().
 		AddItem(clientsPanel, 0, 1, false).
-		AddItem(attackStatus, 0, 1, false)
+		AddItem(attackStatus, 0, 1, false).
+		AddItem(opsPanel, 0, 1, false)
 
 	a.dashboardView = tview.NewFlex().SetDirection(tview.FlexRow).
 		AddItem(topRow, 11, 0, false).
@@ -184,17 +227,17 @@ func (a *App) createDashboardView() {
 
 		for range ticker.C {
 			a.app.QueueUpdateDraw(func() {
-				a.updateDashboardPanel(serverStatus, upstreamStatus, statsPanel, clientsPanel, attackStatus, quickLog)
+				a.updateDashboardPanel(serverStatus, upstreamStatus, statsPanel, clientsPanel, attackStatus, opsPanel, quickLog)
 			})
 		}
 	}()
 }
 
 // updateDashboardPanel updates all dashboard panels
-func (a *App) updateDashboardPanel(serverStatus, upstreamStatus, statsPanel, clientsPanel, attackStatus, quickLog *tview.TextView) {
+func (a *App) updateDashboardPanel(serverStatus, upstreamStatus, statsPanel, clientsPanel, attackStatus, opsPanel, quickLog *tview.TextView) {
 	// Server status
 	if a.server.IsRunning() {
-		serverStatus.SetText(fmt.Sprintf(`
+		text := fmt.Sprintf(`
   [green]● RUNNING[white]
   
   Listen: [cyan]%s[white]
@@ -206,7 +249,11 @@ func (a *App) updateDashboardPanel(serverStatus, upstreamStatus, statsPanel, cli
 			a.cfg.Server.Port,
 			orDefault(a.cfg.Server.Interface, "all"),
 			orDefault(a.cfg.Server.Timezone, "UTC"),
-			a.cfg.Server.MaxClients))
+			a.cfg.Server.MaxClients)
+		if baseline := a.server.GetBaselineOffset(); baseline != 0 {
+			text += fmt.Sprintf("\n  Baseline: [yellow]%+.3fs[white]", baseline.Seconds())
+		}
+		serverStatus.SetText(text)
 	} else {
 		serverStatus.SetText(fmt.Sprintf(`
   [red]● STOPPED[white]
@@ -231,23 +278,30 @@ func (a *App) updateDashboardPanel(serverStatus, upstreamStatus, statsPanel, cli
   Stratum: [cyan]%d[white]
   Offset: [cyan]%v[white]
   RTT: [cyan]%v[white]
+  Agreed: [cyan]%d/%d[white]
+  Stability: %s
   Last Sync: [cyan]%s[white]`,
 			sync.ActiveServer,
 			sync.Stratum,
 			sync.Offset,
 			sync.RTT,
-			sync.LastSync.Format("15:04:05")))
+			len(sync.AgreedServers()), len(sync.Servers),
+			formatStability(sync.Stability),
+			sync.LastSync.Format("15:04:05")) + formatUpstreamHealth(a.server.GetUpstreamHealth()))
 	} else {
 		errMsg := sync.LastError
 		if errMsg == "" {
 			errMsg = "Not yet synced"
 		}
+		source, detail := a.server.GetTimeSource()
 		upstreamStatus.SetText(fmt.Sprintf(`
   [yellow]● UNSYNCHRONIZED[white]
   
   Status: [red]%s[white]
+  Serving: [yellow]%s[white]
+  [gray]%s[white]
   
-  Press [yellow]Ctrl+U[white] to force sync`, errMsg))
+  Press [yellow]Ctrl+U[white] to force sync`, errMsg, source, tview.Escape(detail)) + formatUpstreamHealth(a.server.GetUpstreamHealth()))
 	}
 
 	// Statistics
@@ -258,14 +312,44 @@ func (a *App) updateDashboardPanel(serverStatus, upstreamStatus, statsPanel, cli
   Requests: [green]%d[white]
   Responses: [green]%d[white]
   Errors: [red]%d[white]
-  Attacks: [yellow]%d[white]`,
+  Attacks: [yellow]%d[white]
+  Capped: [gray]%d[white]
+  Throttled: [gray]%d[white]
+  Dropped: [gray]%d[white]
+  Skewed: [gray]%d[white]
+  Version refused: [gray]%d[white]
+  Proxy: [gray]%s[white]
+  Modes: [gray]%s[white]
+  Jitter: [gray]%s[white]
+  KoD test: [gray]%s[white]`,
 		formatDuration(stats.Uptime),
 		stats.TotalRequests,
 		stats.TotalResponses,
 		stats.ErrorCount,
-		stats.AttacksExecuted))
-
-	// Active clients
+		stats.AttacksExecuted,
+		stats.CappedResponses,
+		stats.Throttled,
+		stats.Dropped,
+		stats.Skewed,
+		stats.VersionRefused,
+		formatProxy(a.cfg.Server.Proxy.Enabled, stats),
+		formatModes(stats.Modes),
+		formatJitter(stats.Jitter),
+		formatKoDSummary(a.server.GetKoDCompliance())))
+
+	// Active clients, with their KoD compliance verdicts
+	kodResults := make(map[string]kodcheck.Result)
+	for _, r := range a.server.GetKoDCompliance() {
+		kodResults[r.Client] = r
+	}
+	rampResults := make(map[string]attacks.RampResult)
+	for _, r := range a.server.GetAttackEngine().GetRampResults() {
+		rampResults[r.Client] = r
+	}
+	mixAttacks := make(map[string]attacks.AttackType)
+	for _, m := range a.server.GetAttackEngine().GetMixAssignments() {
+		mixAttacks[m.Client] = m.Attack
+	}
 	clients := a.server.GetActiveClients()
 	if len(clients) == 0 {
 		clientsPanel.SetText("\n  [gray]No active clients[white]")
@@ -279,13 +363,52 @@ func (a *App) updateDashboardPanel(serverStatus, upstreamStatus, statsPanel, cli
 				break
 			}
 			ago := time.Since(client.LastSeen)
-			sb.WriteString(fmt.Sprintf("  • %s [gray](%s ago)[white]\n", client.Address, formatDuration(ago)))
+			offset := ""
+			if client.OffsetKnown {
+				offset = fmt.Sprintf(" [yellow]%s[white]", formatOffset(client.EstimatedOffset))
+				if client.OffsetChange != 0 {
+					offset += fmt.Sprintf(" [gray](Δ %s)[white]", formatOffset(client.OffsetChange))
+				}
+			}
+			if client.ObservedInterval > 0 {
+				offset += " " + formatInterval(client)
+			}
+			if r, ok := kodResults[client.Address]; ok {
+				offset += " " + formatKoDVerdict(r)
+			}
+			if r, ok := rampResults[client.Address]; ok {
+				offset += " " + formatRampVerdict(r)
+			}
+			if attack, ok := mixAttacks[client.Address]; ok {
+				offset += fmt.Sprintf(" [red]%s[white]", attack)
+			}
+			sb.WriteString(fmt.Sprintf("  • %s [gray](%s ago)[white]%s\n", client.Address, formatDuration(ago), offset))
 		}
 		clientsPanel.SetText(sb.String())
 	}
 
 	// Attack status
-	if a.cfg.Security.Enabled {
+	scheduleLine := ""
+	if sched := a.server.GetAttackEngine().ScheduleStatus(); sched != "" {
+		scheduleLine = fmt.Sprintf("\n  Schedule: [yellow]%s[white]", sched)
+	}
+	if seq := a.server.GetAttackEngine().SequenceStatus(); seq != "" {
+		scheduleLine += fmt.Sprintf("\n  Sequence: [yellow]%s[white]", tview.Escape(seq))
+	}
+	if a.cfg.Security.Enabled && a.server.GetAttackEngine().IsPaused() {
+		drift, elapsed := a.server.GetAttackEngine().GetDriftStatus()
+		attackStatus.SetText(fmt.Sprintf(`
+  [yellow]⏸ ATTACK PAUSED[white]
+  
+  Attack: [yellow]%s[white] (held at drift %v after %s)
+  Targets: [yellow]%s[white]%s
+  
+  Responses are honest until the attack resumes
+  
+  Press [yellow]Ctrl+T[white] to resume`, a.cfg.Security.ActiveAttack, drift.Round(time.Millisecond), formatDuration(elapsed),
+			tview.Escape(a.server.GetAttackEngine().DescribeTargets()), scheduleLine))
+		attackStatus.SetBorderColor(ColorWarning)
+	} else if a.cfg.Security.Enabled {
 		activeAttack := a.cfg.Security.ActiveAttack
 		if activeAttack == "" {
 			activeAttack = "None"
@@ -294,21 +417,41 @@ func (a *App) updateDashboardPanel(serverStatus, upstreamStatus, statsPanel, cli
   [red]⚠️ SECURITY MODE ACTIVE[white]
   
   Attack: [yellow]%s[white]
+  Targets: [yellow]%s[white]%s
   
-  [red]WARNING: All responses are modified![white]
+  [red]WARNING: Targeted responses are modified![white]
   
-  Press [yellow]F4[white] for attack options`, activeAttack))
+  Press [yellow]F4[white] for attack options`, activeAttack, tview.Escape(a.server.GetAttackEngine().DescribeTargets()), scheduleLine))
 		attackStatus.SetBorderColor(ColorDanger)
 	} else {
 		attackStatus.SetText(`
   [green]● NORMAL MODE[white]
   
-  Security testing mode is [green]disabled[white]
+  Security testing mode is [green]disabled[white]` + scheduleLine + `
   
   Press [yellow]F4[white] to enable attacks`)
 		attackStatus.SetBorderColor(ColorSuccess)
 	}
 
+	// Operations
+	running := ops.GetRegistry().List()
+	if len(running) == 0 {
+		opsPanel.SetText("\n  [gray]Nothing running[white]")
+	} else {
+		var sb strings.Builder
+		sb.WriteString("\n")
+		for _, op := range running {
+			state := ""
+			if op.Cancelling {
+				state = " [red](cancelling)[white]"
+			}
+			sb.WriteString(fmt.Sprintf("  #%d %s [gray]%s[white]%s\n",
+				op.ID, truncate(op.Name, 24), formatDuration(time.Since(op.Started)), state))
+		}
+		sb.WriteString("\n  [yellow]Ctrl+X[white] cancel newest")
+		opsPanel.SetText(sb.String())
+	}
+
 	// Quick log
 	entries := a.log.GetEntries(15)
 	var logSb strings.Builder
@@ -331,15 +474,6 @@ func (a *App) updateDashboardPanel(serverStatus, upstreamStatus, statsPanel, cli
 	quickLog.ScrollToEnd()
 }
 
-// createLogView creates the log viewer
-func (a *App) createLogView() {
-	a.logView = tview.NewTextView().SetDynamicColors(true)
-	a.logView.SetScrollable(true)
-	a.logView.SetBorder(true)
-	a.logView.SetTitle(" 📜 Logs [Ctrl+C to clear, Ctrl+E to export] ")
-	a.logView.SetBorderColor(ColorPrimary)
-}
-
 // createConfigEditor creates the configuration editor
 func (a *App) createConfigEditor() {
 	a.configEditor = tview.NewTextArea().
@@ -366,7 +500,7 @@ func (a *App) createAttackPanel() {
 	for _, attack := range availableAttacks {
 		info := attack // capture
 		attackList.AddItem(info.Name, info.Description, 0, func() {
-			a.selectAttack(info)
+			a.showAttackForm(info)
 		})
 	}
 
@@ -392,10 +526,19 @@ func (a *App) createAttackPanel() {
   • Gradual Drift - Slowly drift time undetected
   • Kiss-of-Death - Disable client synchronization
   • Stratum Attack - Claim higher authority
+  • RefID Spoofing - Claim a fake refclock or upstream
+  • Root Distance - Fake root delay/dispersion
+  • Origin Corruption - Break request/response matching
   • Leap Second - Inject leap second flags
+  • Leap Smear - Spread a leap second, LI clear
   • Rollover - Test Y2K38 and NTP era bugs
   • Clock Step - Sudden large time jumps
+  • Crypto-NAK - Signal authentication failure
+  • Time Bomb - Honest until a trigger, then attack
+  • Per-Client Mix - A sticky random attack per client
+  • Asymmetric Delay - Skew offset via one-way delay
   
+  [yellow]Press Enter[white] on an attack to edit its parameters and enable it
   [yellow]Press Tab[white] to switch between Attacks and Presets
   
   [red]⚠️ Use only in controlled test environments![white]`)
@@ -417,6 +560,23 @@ func (a *App) createAttackPanel() {
 		})
 	}
 
+	// Sequences run in the background and can be stopped from the same list
+	for _, seq := range a.cfg.AttackSequences {
+		sq := seq // capture
+		presetList.AddItem("▶ "+sq.Name, sq.Description, 0, func() {
+			if err := a.server.GetAttackEngine().StartSequence(sq); err != nil {
+				a.log.Errorf("ATTACK", "Cannot start sequence: %v", err)
+			}
+		})
+	}
+	if len(a.cfg.AttackSequences) > 0 {
+		presetList.AddItem("■ Stop sequence", "Stop the running attack sequence", 0, func() {
+			if !a.server.GetAttackEngine().StopSequence() {
+				a.log.Info("ATTACK", "No attack sequence running")
+			}
+		})
+	}
+
 	// Handle Tab key to switch focus between lists
 	attackList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		if event.Key() == tcell.KeyTab {
@@ -457,7 +617,32 @@ func (a *App) createSessionPanel() {
 		SetHighlightFullLine(true).
 		SetSelectedBackgroundColor(ColorPrimary)
 	sessionList.SetBorder(true)
-	sessionList.SetTitle(" 📁 Saved Sessions ")
+	sessionList.SetTitle(" 📁 Saved Sessions [p: pcap, v: vectors, r: report] ")
+
+	// Export the highlighted session for Wireshark, as test vectors or as
+	// a test report
+	sessionList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if sessionList.GetItemCount() == 0 {
+			return event
+		}
+		export, kind := session.ExportSessionPCAP, "pcap"
+		switch event.Rune() {
+		case 'p':
+		case 'v':
+			export, kind = session.ExportSessionVectors, "vectors"
+		case 'r':
+			export, kind = a.exportReport, "report"
+		default:
+			return event
+		}
+		id, _ := sessionList.GetItemText(sessionList.GetCurrentItem())
+		if path, err := export(id); err != nil {
+			a.log.Errorf("EXPORT", "Failed to export %s: %v", kind, err)
+		} else {
+			a.log.Infof("EXPORT", "Exported session %s to %s", id, path)
+		}
+		return nil
+	})
 
 	// Session details
 	sessionDetails := tview.NewTextView().SetDynamicColors(true)
@@ -529,6 +714,8 @@ func (a *App) refreshSessionList(sessionList *tview.List, sessionDetails *tview.
 			sessionDetails.SetText(fmt.Sprintf(`
   [cyan]Session ID:[white] %s
   [cyan]Description:[white] %s
+  [cyan]Clients:[white] %s
+  [cyan]Tags:[white] %s
   [cyan]Start:[white] %s
   [cyan]End:[white] %s
   [cyan]Duration:[white] %s
@@ -542,6 +729,8 @@ func (a *App) refreshSessionList(sessionList *tview.List, sessionDetails *tview.
   • Avg Response Time: %v`,
 				s.ID,
 				orDefault(s.Description, "None"),
+				orDefault(strings.Join(s.ClientFilter, ", "), "all"),
+				orDefault(strings.Join(s.Tags, ", "), "None"),
 				s.StartTime.Format(time.RFC3339),
 				s.EndTime.Format(time.RFC3339),
 				s.EndTime.Sub(s.StartTime).String(),
@@ -550,11 +739,158 @@ func (a *App) refreshSessionList(sessionList *tview.List, sessionDetails *tview.
 				s.Stats.UniqueClients,
 				s.Stats.UpstreamQueries,
 				s.Stats.AttacksExecuted,
-				s.Stats.AvgResponseTime))
+				s.Stats.AvgResponseTime) + renderAttackCounts(s.Stats) + renderKoDCompliance(s.Stats.KoDCompliance) +
+				renderTimeline(s.Timeline, s.StartTime, s.EndTime, 48))
 		})
 	}
 }
 
+// exportReport writes a Markdown test report of a saved session
+func (a *App) exportReport(id string) (string, error) {
+	sess, err := session.LoadSession(id)
+	if err != nil {
+		return "", err
+	}
+	return report.Write(report.Options{
+		Config:  a.cfg,
+		Engine:  a.server.GetAttackEngine(),
+		Session: sess,
+		Entries: a.log.GetAllEntries(),
+	}, report.FormatMarkdown, "")
+}
+
+// renderAttackCounts lists the attacks a session delivered per type and
+// per client, most frequent first
+func renderAttackCounts(st session.SessionStats) string {
+	if len(st.AttacksByType) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n\n  [yellow]Attacks by Type:[white]")
+	for _, a := range sortedCounts(st.AttacksByType) {
+		sb.WriteString(fmt.Sprintf("\n  • %s: %d", a, st.AttacksByType[a]))
+	}
+
+	clients := make([]string, 0, len(st.AttacksByClient))
+	for c := range st.AttacksByClient {
+		clients = append(clients, c)
+	}
+	sort.Strings(clients)
+	sb.WriteString("\n\n  [yellow]Attacks by Client:[white]")
+	for _, c := range clients {
+		counts := st.AttacksByClient[c]
+		parts := make([]string, 0, len(counts))
+		for _, a := range sortedCounts(counts) {
+			parts = append(parts, fmt.Sprintf("%s %d", a, counts[a]))
+		}
+		sb.WriteString(fmt.Sprintf("\n  • %s: %s", c, strings.Join(parts, ", ")))
+	}
+	return sb.String()
+}
+
+// sortedCounts returns the keys of counts, largest count first
+func sortedCounts(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// renderKoDCompliance lists how clients reacted to RATE KoDs in a session
+func renderKoDCompliance(results []kodcheck.Result) string {
+	if len(results) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n\n  [yellow]KoD Compliance:[white]")
+	for _, r := range results {
+		baseline := "unknown"
+		if r.Baseline > 0 {
+			baseline = formatDuration(r.Baseline)
+		}
+		sb.WriteString(fmt.Sprintf("\n  • %s %s [gray](usual interval %s, %d request(s) after)[white]",
+			r.Client, formatKoDVerdict(r), baseline, r.After))
+	}
+	return sb.String()
+}
+
+// renderTimeline draws the session attack timeline as a horizontal bar
+// followed by a short legend of phases and reactions
+func renderTimeline(entries []session.TimelineEntry, start, end time.Time, width int) string {
+	if len(entries) == 0 || !end.After(start) {
+		return ""
+	}
+
+	total := end.Sub(start)
+	cellOf := func(t time.Time) int {
+		cell := int(float64(t.Sub(start)) / float64(total) * float64(width))
+		if cell < 0 {
+			cell = 0
+		}
+		if cell >= width {
+			cell = width - 1
+		}
+		return cell
+	}
+
+	bar := []rune(strings.Repeat("─", width))
+	marks := []rune(strings.Repeat(" ", width))
+	var phaseStart time.Time
+	var phaseAttack string
+	for _, e := range entries {
+		switch e.Kind {
+		case session.TimelineAttackStart:
+			phaseStart, phaseAttack = e.Time, e.Attack
+		case session.TimelineAttackStop:
+			if phaseAttack == "" {
+				continue
+			}
+			symbol := []rune(strings.ToUpper(phaseAttack))[0]
+			for i := cellOf(phaseStart); i <= cellOf(e.Time); i++ {
+				bar[i] = symbol
+			}
+			phaseAttack = ""
+		case session.TimelineReaction:
+			marks[cellOf(e.Time)] = '▲'
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n\n  [yellow]Timeline:[white]\n")
+	sb.WriteString(fmt.Sprintf("  [gray]%s[white] [red]%s[white] [gray]%s[white]\n",
+		start.Format("15:04:05"), string(bar), end.Format("15:04:05")))
+	sb.WriteString(fmt.Sprintf("           [yellow]%s[white]\n", string(marks)))
+
+	maxShow := 12
+	for i, e := range entries {
+		if i >= maxShow {
+			sb.WriteString(fmt.Sprintf("  ... and %d more\n", len(entries)-maxShow))
+			break
+		}
+		var line string
+		switch e.Kind {
+		case session.TimelineAttackStart:
+			line = fmt.Sprintf("[red]▶ %s[white] %s", e.Attack, e.Detail)
+		case session.TimelineAttackStop:
+			line = fmt.Sprintf("[green]■ %s[white] stopped (%d applied, %s)", e.Attack, e.Applied, e.Detail)
+		case session.TimelineParamChange:
+			line = fmt.Sprintf("[cyan]✎ %s[white] %s", e.Attack, e.Detail)
+		case session.TimelineReaction:
+			line = fmt.Sprintf("[yellow]▲ %s[white] %s", e.Client, e.Detail)
+		}
+		sb.WriteString(fmt.Sprintf("  %s %s\n", e.Time.Format("15:04:05"), line))
+	}
+
+	return sb.String()
+}
+
 // createHelpModal creates the help modal
 func (a *App) createHelpModal() {
 	helpText := `TimeHammer - NTP Security Testing Tool
@@ -566,14 +902,22 @@ func (a *App) createHelpModal() {
   F3         - Edit Configuration
   F4         - Attack Mode
   F5         - Session Management
+  F6         - Offset Graph
+  F7         - Client History
+  F8         - Client Details
   F10        - Start/Stop Server
   F12 / Esc  - Quit
 
   Ctrl+S     - Save Configuration
   Ctrl+E     - Export Logs
   Ctrl+C     - Clear Logs (in log view)
+  /          - Filter Logs (in log view)
+  f          - Follow / Freeze Log Tail (in log view)
   Ctrl+R     - Toggle Recording
   Ctrl+U     - Force Upstream Sync
+  Ctrl+X     - Cancel Newest Operation
+  Ctrl+P     - Switch / Save Config Profile
+  Ctrl+T     - Pause / Resume Active Attack
 
 ⚠️  WARNING: This tool is for security testing only!
     Never use on production systems.
@@ -590,28 +934,9 @@ Press any key to close this help.`
 
 // selectAttack handles attack selection
 func (a *App) selectAttack(info attacks.AttackInfo) {
-	a.cfg.Security.Enabled = true
-	a.cfg.Security.ActiveAttack = string(info.Type)
-
-	// Enable the specific attack
-	switch info.Type {
-	case attacks.AttackTimeSpoofing:
-		a.cfg.Security.TimeSpoofing.Enabled = true
-	case attacks.AttackTimeDrift:
-		a.cfg.Security.TimeDrift.Enabled = true
-		a.server.GetAttackEngine().ResetDriftState()
-	case attacks.AttackKissOfDeath:
-		a.cfg.Security.KissOfDeath.Enabled = true
-	case attacks.AttackStratumLie:
-		a.cfg.Security.StratumAttack.Enabled = true
-	case attacks.AttackLeapSecond:
-		a.cfg.Security.LeapSecond.Enabled = true
-	case attacks.AttackRollover:
-		a.cfg.Security.Rollover.Enabled = true
-	case attacks.AttackClockStep:
-		a.cfg.Security.ClockStep.Enabled = true
-	case attacks.AttackFuzzing:
-		a.cfg.Security.Fuzzing.Enabled = true
+	if _, err := a.server.GetAttackEngine().EnableAttack(info.Type); err != nil {
+		a.log.Errorf("ATTACK", "Failed to enable attack: %v", err)
+		return
 	}
 
 	a.log.Infof("ATTACK", "Enabled attack: %s - %s", info.Name, info.Description)
@@ -619,6 +944,20 @@ func (a *App) selectAttack(info attacks.AttackInfo) {
 
 // handleGlobalKeys handles global keyboard shortcuts
 func (a *App) handleGlobalKeys(event *tcell.EventKey) *tcell.EventKey {
+	if a.profileDialogOpen() {
+		return a.profileKeys(event)
+	}
+	if a.attackFormOpen() {
+		return a.attackFormKeys(event)
+	}
+	if a.exportDialogOpen() {
+		return a.exportKeys(event)
+	}
+	// Typing a log filter only leaves the function keys global
+	if a.logFilterFocused() && (event.Key() < tcell.KeyF1 || event.Key() > tcell.KeyF12) {
+		return event
+	}
+
 	switch event.Key() {
 	case tcell.KeyF1:
 		a.switchPage("dashboard")
@@ -635,6 +974,15 @@ func (a *App) handleGlobalKeys(event *tcell.EventKey) *tcell.EventKey {
 	case tcell.KeyF5:
 		a.switchPage("sessions")
 		return nil
+	case tcell.KeyF6:
+		a.switchPage("graph")
+		return nil
+	case tcell.KeyF7:
+		a.switchPage("history")
+		return nil
+	case tcell.KeyF8:
+		a.switchPage("clients")
+		return nil
 	case tcell.KeyF10:
 		a.toggleServer()
 		return nil
@@ -645,7 +993,7 @@ func (a *App) handleGlobalKeys(event *tcell.EventKey) *tcell.EventKey {
 		a.saveConfig()
 		return nil
 	case tcell.KeyCtrlE:
-		a.exportLogs()
+		a.showExportDialog()
 		return nil
 	case tcell.KeyCtrlR:
 		a.toggleRecording()
@@ -654,10 +1002,21 @@ func (a *App) handleGlobalKeys(event *tcell.EventKey) *tcell.EventKey {
 		a.server.ForceUpstreamSync()
 		a.log.Info("SERVER", "Forced upstream sync")
 		return nil
+	case tcell.KeyCtrlP:
+		a.showProfiles()
+		return nil
+	case tcell.KeyCtrlT:
+		a.togglePause()
+		return nil
+	case tcell.KeyCtrlX:
+		if op, err := ops.GetRegistry().CancelNewest(); err == nil {
+			a.log.Infof("SERVER", "Cancelling operation #%d (%s)", op.ID, op.Name)
+		}
+		return nil
 	case tcell.KeyCtrlC:
 		if a.currentPage == "logs" {
 			a.log.ClearEntries()
-			a.logView.Clear()
+			a.renderLogs()
 			return nil
 		}
 	case tcell.KeyRune:
@@ -679,6 +1038,12 @@ func (a *App) switchPage(name string) {
 	if name == "config" {
 		a.reloadConfigEditor()
 	}
+	if name == "history" {
+		a.refreshHistory()
+	}
+	if name == "clients" {
+		a.refreshClients()
+	}
 }
 
 // reloadConfigEditor reloads the current config into the editor
@@ -705,12 +1070,28 @@ func (a *App) toggleServer() {
 	a.updateStatusBar()
 }
 
+// togglePause pauses the active attack or resumes the paused one
+func (a *App) togglePause() {
+	engine := a.server.GetAttackEngine()
+	var err error
+	if engine.IsPaused() {
+		err = engine.Resume()
+	} else {
+		err = engine.Pause()
+	}
+	if err != nil {
+		a.log.Errorf("ATTACK", "Cannot pause or resume: %v", err)
+	}
+	a.updateStatusBar()
+}
+
 // saveConfig saves the configuration
 func (a *App) saveConfig() {
 	if a.currentPage == "config" {
 		yaml := a.configEditor.GetText()
 		if err := a.cfg.UpdateFromYAML(yaml); err != nil {
 			a.log.Errorf("CONFIG", "Invalid config: %v", err)
+			a.showConfigErrors(err)
 			return
 		}
 	}
@@ -723,23 +1104,17 @@ func (a *App) saveConfig() {
 	}
 }
 
-// exportLogs exports logs to file
-func (a *App) exportLogs() {
-	timestamp := time.Now().Format("20060102_150405")
-
-	jsonFile := fmt.Sprintf("logs_%s.json", timestamp)
-	if err := a.log.ExportJSON(jsonFile); err != nil {
-		a.log.Errorf("EXPORT", "Failed to export JSON: %v", err)
-	} else {
-		a.log.Infof("EXPORT", "Exported to .timehammer/exports/%s", jsonFile)
+// exportLogs writes the logs to dest in the given formats (all if none);
+// see logger.ExportLogs
+func (a *App) exportLogs(dest string, formats []string) error {
+	paths, err := a.log.ExportLogs(dest, formats)
+	for _, path := range paths {
+		a.log.Infof("EXPORT", "Exported to %s", path)
 	}
-
-	csvFile := fmt.Sprintf("logs_%s.csv", timestamp)
-	if err := a.log.ExportCSV(csvFile); err != nil {
-		a.log.Errorf("EXPORT", "Failed to export CSV: %v", err)
-	} else {
-		a.log.Infof("EXPORT", "Exported to .timehammer/exports/%s", csvFile)
+	if err != nil {
+		a.log.Errorf("EXPORT", "Failed to export: %v", err)
 	}
+	return err
 }
 
 // toggleRecording toggles session recording
@@ -752,7 +1127,11 @@ func (a *App) toggleRecording() {
 			a.log.Infof("SESSION", "Recording stopped, saved as %s", sess.ID)
 		}
 	} else {
-		if err := a.recorder.StartRecording("Manual recording"); err != nil {
+		opts := session.RecordingOptions{
+			Description: "Manual recording",
+			Clients:     a.cfg.Logging.RecordClients,
+		}
+		if err := a.recorder.StartRecordingWithOptions(opts); err != nil {
 			a.log.Errorf("SESSION", "Failed to start recording: %v", err)
 		} else {
 			a.log.Info("SESSION", "Recording started")
@@ -765,6 +1144,18 @@ func (a *App) showHelp() {
 	a.pages.AddPage("help", a.helpModal, true, true)
 }
 
+// showConfigErrors lists config validation problems over the editor
+func (a *App) showConfigErrors(err error) {
+	modal := tview.NewModal().
+		SetText("Configuration not saved:\n\n" + err.Error()).
+		AddButtons([]string{"Edit"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			a.pages.RemovePage("config_errors")
+			a.app.SetFocus(a.configEditor)
+		})
+	a.pages.AddPage("config_errors", modal, true, true)
+}
+
 // confirmQuit confirms before quitting
 func (a *App) confirmQuit() {
 	modal := tview.NewModal().
@@ -791,6 +1182,9 @@ func (a *App) updateHeader() {
 		"config":    "Configuration",
 		"attacks":   "Security Testing",
 		"sessions":  "Sessions",
+		"graph":     "Offset Graph",
+		"history":   "Client History",
+		"clients":   "Client Details",
 	}
 	pageName := pageNames[a.currentPage]
 
@@ -811,10 +1205,13 @@ func (a *App) updateStatusBar() {
 	if sync.Synchronized {
 		status += fmt.Sprintf("[green]SYNCED[white] (%s)", sync.ActiveServer)
 	} else {
-		status += "[yellow]UNSYNCED[white]"
+		source, _ := a.server.GetTimeSource()
+		status += fmt.Sprintf("[yellow]UNSYNCED[white] (serving %s)", source)
 	}
 
-	if a.cfg.Security.Enabled {
+	if a.cfg.Security.Enabled && a.server.GetAttackEngine().IsPaused() {
+		status += " │ [yellow]⏸ ATTACK PAUSED[white]"
+	} else if a.cfg.Security.Enabled {
 		status += " │ [red]⚠️ ATTACK MODE ACTIVE[white]"
 	}
 
@@ -829,24 +1226,7 @@ func (a *App) updateStatusBar() {
 func (a *App) handleLogUpdates() {
 	for entry := range a.logChan {
 		a.app.QueueUpdateDraw(func() {
-			color := "white"
-			switch entry.Level {
-			case logger.LevelDebug:
-				color = "gray"
-			case logger.LevelInfo:
-				color = "green"
-			case logger.LevelWarn:
-				color = "yellow"
-			case logger.LevelError:
-				color = "red"
-			}
-
-			line := fmt.Sprintf("[%s]%s [%s][%s]%s %s[white]\n",
-				"cyan", entry.Timestamp.Format("15:04:05"),
-				entry.LevelStr, color, entry.Category, entry.Message)
-
-			fmt.Fprint(a.logView, line)
-			a.logView.ScrollToEnd()
+			a.appendLog(entry)
 
 			// Update status bar
 			a.updateStatusBar()
@@ -884,3 +1264,139 @@ func formatDuration(d time.Duration) string {
 	}
 	return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
 }
+
+// formatKoDVerdict shows a client's reaction to its RATE KoD
+func formatKoDVerdict(r kodcheck.Result) string {
+	switch r.Verdict {
+	case kodcheck.Complied:
+		return fmt.Sprintf("[green]KoD ✓ %s[white]", formatDuration(r.Backoff))
+	case kodcheck.Ignored:
+		return fmt.Sprintf("[red]KoD ✗ %s[white]", formatDuration(r.Backoff))
+	default:
+		return fmt.Sprintf("[yellow]KoD … %s[white]", formatDuration(r.Backoff))
+	}
+}
+
+// formatRampVerdict shows where a client stands on the offset ramp
+func formatRampVerdict(r attacks.RampResult) string {
+	switch {
+	case r.Broken:
+		return fmt.Sprintf("[red]ramp ✗ %s[white]", formatOffset(r.BreakAt))
+	case r.Following:
+		return fmt.Sprintf("[green]ramp ✓ %s[white]", formatOffset(r.Offset))
+	default:
+		return fmt.Sprintf("[yellow]ramp … %s[white]", formatOffset(r.Offset))
+	}
+}
+
+// formatKoDSummary counts the KoD compliance verdicts
+func formatKoDSummary(results []kodcheck.Result) string {
+	if len(results) == 0 {
+		return "-"
+	}
+	complied, ignored, pending := kodcheck.Summary(results)
+	return fmt.Sprintf("%d complied, %d ignored, %d pending", complied, ignored, pending)
+}
+
+// formatJitter summarizes the jitter holds, e.g. "4.9ms (0.1-9.8ms)"
+func formatJitter(j server.JitterStats) string {
+	if j.Count == 0 {
+		return "-"
+	}
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	return fmt.Sprintf("%.1fms (%.1f-%.1fms)", ms(j.Mean), ms(j.Min), ms(j.Max))
+}
+
+// formatProxy summarizes proxy mode, e.g. "120 relayed, 2 failed, rtt 14ms"
+func formatProxy(enabled bool, st server.Stats) string {
+	if !enabled && st.Proxied == 0 && st.ProxyErrors == 0 {
+		return "off"
+	}
+	return fmt.Sprintf("%d relayed, %d failed, rtt %v", st.Proxied, st.ProxyErrors, st.ProxyRTT.Round(100*time.Microsecond))
+}
+
+// formatModes lists the packets received per NTP mode, e.g. "3:120 1:2 5:1"
+// (client requests first)
+func formatModes(modes [8]uint64) string {
+	parts := []string{}
+	for _, mode := range []int{3, 1, 5, 0, 2, 4, 6, 7} {
+		if modes[mode] > 0 {
+			parts = append(parts, fmt.Sprintf("%d:%d", mode, modes[mode]))
+		}
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatOffset(d time.Duration) string {
+	switch abs := d.Abs(); {
+	case abs >= 24*time.Hour:
+		return fmt.Sprintf("%+.1fd", d.Hours()/24)
+	case abs >= time.Second:
+		return fmt.Sprintf("%+.3fs", d.Seconds())
+	default:
+		return fmt.Sprintf("%+.1fms", float64(d)/float64(time.Millisecond))
+	}
+}
+
+// unsteadyUpstream is the offset standard deviation above which the
+// upstream stability is flagged
+const unsteadyUpstream = 10 * time.Millisecond
+
+// formatStability shows the spread of the upstream offset over the last
+// syncs, flagged when it is large enough to blur an attack's effect
+func formatStability(s ntp.OffsetStability) string {
+	if s.Samples < 2 {
+		return "[gray]measuring…[white]"
+	}
+	color := "cyan"
+	if s.StdDev > unsteadyUpstream {
+		color = "yellow"
+	}
+	return fmt.Sprintf("[%s]σ %s jitter %s range %s[white] [gray](%d syncs)[white]", color,
+		formatSpread(s.StdDev), formatSpread(s.Jitter), formatSpread(s.Range()), s.Samples)
+}
+
+// formatSpread formats a non-negative spread in milliseconds
+func formatSpread(d time.Duration) string {
+	return fmt.Sprintf("%.1fms", float64(d)/float64(time.Millisecond))
+}
+
+// formatUpstreamHealth lists each upstream server with its health state
+func formatUpstreamHealth(health []ntp.ServerHealth) string {
+	if len(health) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("\n\n  [::b]Servers:[::-]")
+	for _, h := range health {
+		color := "green"
+		switch h.Status() {
+		case "demoted":
+			color = "red"
+		case "failing":
+			color = "yellow"
+		case "unknown":
+			color = "gray"
+		}
+		detail := h.Status()
+		if h.RTT > 0 {
+			detail += fmt.Sprintf(" %v", h.RTT.Round(time.Millisecond))
+		}
+		if h.ConsecutiveFailures > 0 {
+			detail += fmt.Sprintf(" (%d fails)", h.ConsecutiveFailures)
+		}
+		name := h.Address
+		if h.Pool != "" {
+			name += " (" + h.Pool + ")"
+		}
+		if h.Secure != "" {
+			name += " 🔒" + h.Secure
+		}
+		fmt.Fprintf(&b, "\n  [%s]●[white] %s [gray]%s[white]", color, tview.Escape(name), detail)
+	}
+	return b.String()
+}
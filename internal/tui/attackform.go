@@ -0,0 +1,155 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/neutrinoguy/timehammer/internal/attacks"
+	"github.com/neutrinoguy/timehammer/internal/config"
+)
+
+// pageAttackForm is the page name of the attack parameter form
+const pageAttackForm = "attack_form"
+
+// attackFormOpen reports whether the attack form is in front
+func (a *App) attackFormOpen() bool {
+	front, _ := a.pages.GetFrontPage()
+	return front == pageAttackForm
+}
+
+// attackFormKeys handles keys while the attack form is open. Escape
+// cancels instead of asking to quit.
+func (a *App) attackFormKeys(event *tcell.EventKey) *tcell.EventKey {
+	if event.Key() == tcell.KeyEscape {
+		a.pages.RemovePage(pageAttackForm)
+		return nil
+	}
+	return event
+}
+
+// attackKeys returns the config keys of an attack's parameters, leaving out
+// its enabled switch, schedule and conditions
+func attackKeys(attack attacks.AttackType) []string {
+	prefix := "security." + string(attack) + "."
+	var keys []string
+	for _, key := range config.Keys() {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok || rest == "enabled" || strings.HasPrefix(rest, "schedule.") || strings.HasPrefix(rest, "conditions.") {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// showAttackForm lets the tester review and edit an attack's parameters
+// before enabling it
+func (a *App) showAttackForm(info attacks.AttackInfo) {
+	keys := attackKeys(info.Type)
+	if len(keys) == 0 {
+		a.enableAttack(info, nil)
+		return
+	}
+
+	form := tview.NewForm()
+	form.SetBorder(true)
+	form.SetTitle(fmt.Sprintf(" ⚔️ %s [Esc cancel] ", info.Name))
+	form.SetBorderColor(ColorAccent)
+	form.SetButtonsAlign(tview.AlignCenter)
+
+	// Each field reports its value as Set accepts it
+	values := make(map[string]func() string, len(keys))
+	for _, key := range keys {
+		label := strings.TrimPrefix(key, "security."+string(info.Type)+".")
+		current, err := a.cfg.Get(key)
+		if err != nil {
+			continue
+		}
+
+		switch allowed := config.AllowedValues(key); {
+		case current == "true" || current == "false":
+			form.AddCheckbox(label, current == "true", nil)
+			box := form.GetFormItem(form.GetFormItemCount() - 1).(*tview.Checkbox)
+			values[key] = func() string { return fmt.Sprint(box.IsChecked()) }
+		case len(allowed) > 0:
+			options := make([]string, len(allowed))
+			for i, v := range allowed {
+				options[i] = orDefault(v, "(none)")
+			}
+			form.AddDropDown(label, options, max(slices.Index(allowed, current), 0), nil)
+			drop := form.GetFormItem(form.GetFormItemCount() - 1).(*tview.DropDown)
+			values[key] = func() string {
+				i, _ := drop.GetCurrentOption()
+				return allowed[i]
+			}
+		default:
+			form.AddInputField(label, current, 30, nil, nil)
+			input := form.GetFormItem(form.GetFormItemCount() - 1).(*tview.InputField)
+			values[key] = input.GetText
+		}
+	}
+
+	form.AddButton("Enable", func() {
+		set := make(map[string]string, len(values))
+		for key, value := range values {
+			set[key] = value()
+		}
+		if err := a.enableAttack(info, set); err != nil {
+			a.log.Errorf("ATTACK", "Invalid %s parameters: %v", info.Name, err)
+			form.SetTitle(fmt.Sprintf(" ⚔️ %s [red]%s[white] ", info.Name, tview.Escape(firstLine(err.Error()))))
+			return
+		}
+		a.pages.RemovePage(pageAttackForm)
+	})
+	form.AddButton("Cancel", func() {
+		a.pages.RemovePage(pageAttackForm)
+	})
+
+	a.pages.AddPage(pageAttackForm, centered(form, 64, min(2*len(values)+5, 30)), true, true)
+}
+
+// enableAttack applies edited parameters, if any, and enables the attack.
+// The parameters are validated on a copy first, so a bad value changes
+// nothing.
+func (a *App) enableAttack(info attacks.AttackInfo, set map[string]string) error {
+	if len(set) > 0 {
+		probe := config.DefaultConfig()
+		probe.CopyFrom(a.cfg)
+		for key, value := range set {
+			if err := probe.Set(key, value); err != nil {
+				return err
+			}
+		}
+		if err := probe.Validate(); err != nil {
+			return err
+		}
+
+		var changed []string
+		for key, value := range set {
+			if old, _ := a.cfg.Get(key); old != value {
+				a.cfg.Set(key, value)
+				changed = append(changed, fmt.Sprintf("%s=%s", key, value))
+			}
+		}
+		if len(changed) > 0 {
+			slices.Sort(changed)
+			a.log.Infof("CONFIG", "Updated attack parameters: %s", strings.Join(changed, ", "))
+			if a.currentPage == "config" {
+				a.reloadConfigEditor()
+			}
+		}
+	}
+
+	a.selectAttack(info)
+	return nil
+}
+
+// firstLine returns the first line of a message
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -0,0 +1,240 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+
+	"github.com/neutrinoguy/timehammer/internal/server"
+	"github.com/neutrinoguy/timehammer/internal/session"
+)
+
+// Client drill-down tuning
+const (
+	clientsInterval = time.Second // Refresh period while the page is shown
+	clientPackets   = 20          // Recorded packets shown per client
+)
+
+// createClientsView creates the active client list with its detail panel
+func (a *App) createClientsView() {
+	a.clientList = tview.NewList().ShowSecondaryText(true)
+	a.clientList.SetBorder(true)
+	a.clientList.SetTitle(" 👥 Active Clients ")
+	a.clientList.SetBorderColor(ColorPrimary)
+
+	a.clientDetail = tview.NewTextView().SetDynamicColors(true)
+	a.clientDetail.SetBorder(true)
+	a.clientDetail.SetTitle(" 🔎 Client Details ")
+	a.clientDetail.SetBorderColor(ColorSecondary)
+	a.clientDetail.SetScrollable(true)
+
+	// Moving through the list shows the client under the cursor
+	a.clientList.SetChangedFunc(func(index int, mainText, secondaryText string, shortcut rune) {
+		a.showClientDetail(mainText)
+	})
+
+	a.clientsPanel = tview.NewFlex().
+		AddItem(a.clientList, 34, 0, true).
+		AddItem(a.clientDetail, 0, 1, false)
+
+	go func() {
+		ticker := time.NewTicker(clientsInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			a.app.QueueUpdateDraw(func() {
+				if a.currentPage == "clients" {
+					a.refreshClients()
+				}
+			})
+		}
+	}()
+}
+
+// refreshClients rebuilds the client list, keeping the selected client
+func (a *App) refreshClients() {
+	clients := a.server.GetActiveClients()
+	sort.Slice(clients, func(i, j int) bool { return clients[i].Address < clients[j].Address })
+
+	selected := ""
+	if a.clientList.GetItemCount() > 0 {
+		selected, _ = a.clientList.GetItemText(a.clientList.GetCurrentItem())
+	}
+
+	// Clearing fires the changed func; rebuild quietly and show once
+	a.clientList.SetChangedFunc(nil)
+	a.clientList.Clear()
+	current := 0
+	for i, c := range clients {
+		a.clientList.AddItem(c.Address, fmt.Sprintf("%d req · %s ago", c.RequestCount, formatDuration(time.Since(c.LastSeen))), 0, nil)
+		if c.Address == selected {
+			current = i
+		}
+	}
+	a.clientList.SetCurrentItem(current)
+	a.clientList.SetChangedFunc(func(index int, mainText, secondaryText string, shortcut rune) {
+		a.showClientDetail(mainText)
+	})
+
+	if len(clients) == 0 {
+		a.clientDetail.SetText("\n  [gray]No active clients[white]")
+		return
+	}
+	a.showClientDetail(clients[current].Address)
+}
+
+// showClientDetail fills the detail panel for one client
+func (a *App) showClientDetail(address string) {
+	var client *server.ClientInfo
+	for _, c := range a.server.GetActiveClients() {
+		if c.Address == address {
+			client = &c
+			break
+		}
+	}
+	if client == nil {
+		a.clientDetail.SetText("\n  [gray]Client no longer active[white]")
+		return
+	}
+
+	fp := client.Fingerprint
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n  [cyan]Client:[white] %s\n", client.Address)
+	fmt.Fprintf(&sb, "  [cyan]Last seen:[white] %s ago   [cyan]Requests:[white] %d   [cyan]Attacked:[white] %d\n",
+		formatDuration(time.Since(client.LastSeen)), client.RequestCount, client.Attacks)
+
+	sb.WriteString("\n  [yellow]Fingerprint:[white]\n")
+	impl := orDefault(fp.PossibleClient, "unknown")
+	if fp.PossibleClient != "" {
+		impl += fmt.Sprintf(" (%.0f%%)", fp.Confidence*100)
+	}
+	if len(fp.Candidates) > 0 {
+		impl += fmt.Sprintf(" [gray]also: %s[white]", tview.Escape(strings.Join(fp.Candidates, ", ")))
+	}
+	fmt.Fprintf(&sb, "  • Implementation: %s\n", impl)
+	fmt.Fprintf(&sb, "  • Version: v%d (seen %s)   Mode: %s\n", fp.Version, formatVersions(client.Versions), orDefault(fp.ModeString, "-"))
+	fmt.Fprintf(&sb, "  • Stratum: %d   Poll: %d   Precision: %d\n", fp.Stratum, fp.Poll, fp.Precision)
+	if fp.Authenticated {
+		fmt.Fprintf(&sb, "  • Authenticated: key %d (%s)\n", fp.KeyID, fp.MACStatus)
+	}
+
+	fmt.Fprintf(&sb, "\n  [yellow]Poll history:[white] %s\n", formatPolls(client.Polls))
+	fmt.Fprintf(&sb, "  [yellow]Query interval:[white] %s\n", formatIntervalDetail(*client))
+
+	offset := "[gray]unknown (no transmit timestamp)[white]"
+	if client.OffsetKnown {
+		offset = formatOffset(client.EstimatedOffset)
+		if client.OffsetChange != 0 {
+			offset += fmt.Sprintf(" [gray](Δ %s since first seen)[white]", formatOffset(client.OffsetChange))
+		}
+	}
+	fmt.Fprintf(&sb, "  [yellow]Clock offset:[white] %s\n", offset)
+
+	for _, r := range a.server.GetKoDCompliance() {
+		if r.Client == client.Address {
+			fmt.Fprintf(&sb, "  [yellow]KoD test:[white] %s\n", formatKoDVerdict(r))
+		}
+	}
+	for _, r := range a.server.GetAttackEngine().GetRampResults() {
+		if r.Client == client.Address {
+			fmt.Fprintf(&sb, "  [yellow]Ramp:[white] %s\n", formatRampVerdict(r))
+		}
+	}
+	for _, m := range a.server.GetAttackEngine().GetMixAssignments() {
+		if m.Client == client.Address {
+			fmt.Fprintf(&sb, "  [yellow]Mix attack:[white] [red]%s[white] (%d requests)\n", m.Attack, m.Requests)
+		}
+	}
+
+	sb.WriteString(fmt.Sprintf("\n  [yellow]Last %d packets:[white]\n", clientPackets))
+	if !a.recorder.IsRecording() {
+		sb.WriteString("  [gray]Start a recording with Ctrl+R to capture packets[white]\n")
+	} else if events := a.recorder.RecentClientEvents(client.Address, clientPackets); len(events) == 0 {
+		sb.WriteString("  [gray]None recorded yet[white]\n")
+	} else {
+		for _, e := range events {
+			sb.WriteString(formatClientPacket(e))
+		}
+	}
+
+	a.clientDetail.SetText(sb.String())
+}
+
+// formatPolls lists poll exponents, oldest first, e.g. "6 6 7 (64s-128s)"
+func formatPolls(polls []int8) string {
+	if len(polls) == 0 {
+		return "-"
+	}
+	parts := make([]string, len(polls))
+	lo, hi := polls[0], polls[0]
+	for i, p := range polls {
+		parts[i] = fmt.Sprint(p)
+		lo, hi = min(lo, p), max(hi, p)
+	}
+	interval := func(p int8) string {
+		if p < 0 || p > 17 {
+			return fmt.Sprintf("2^%d s", p)
+		}
+		return formatDuration(time.Duration(1<<p) * time.Second)
+	}
+	rng := interval(lo)
+	if hi != lo {
+		rng += "-" + interval(hi)
+	}
+	return fmt.Sprintf("%s [gray](%s)[white]", strings.Join(parts, " "), rng)
+}
+
+// formatInterval shows a client's observed query interval, flagged when it
+// polls faster than it advertises, e.g. "every 16s fast"
+func formatInterval(c server.ClientInfo) string {
+	text := "[gray]every " + formatDuration(c.ObservedInterval) + "[white]"
+	if c.PollsFast() {
+		text += " [red]fast[white]"
+	}
+	return text
+}
+
+// formatIntervalDetail compares a client's observed query interval with
+// the advertised one and with its cadence before it was first attacked
+func formatIntervalDetail(c server.ClientInfo) string {
+	observed := "[gray]measuring…[white]"
+	if c.ObservedInterval > 0 {
+		observed = formatDuration(c.ObservedInterval)
+	}
+	advertised := "none"
+	if c.AdvertisedInterval > 0 {
+		advertised = formatDuration(c.AdvertisedInterval)
+	}
+	text := fmt.Sprintf("%s observed, %s advertised", observed, advertised)
+	if c.PollsFast() {
+		text += " [red](polls faster than advertised)[white]"
+	}
+	if c.IntervalBeforeAttack > 0 {
+		text += fmt.Sprintf(" [gray](%s before first attack)[white]", formatDuration(c.IntervalBeforeAttack))
+	}
+	return text
+}
+
+// formatClientPacket formats one recorded request or response
+func formatClientPacket(e session.SessionEvent) string {
+	arrow := "[green]→[white]"
+	if e.Type == "response" {
+		arrow = "[cyan]←[white]"
+	}
+	line := fmt.Sprintf("  %s %s %-8s", e.Timestamp.Format("15:04:05.000"), arrow, e.Type)
+	if p := e.ParsedPacket; p != nil {
+		line += fmt.Sprintf(" v%d %s stratum %d poll %d", p.Version, p.Mode, p.Stratum, p.Poll)
+		if p.IsKoD {
+			line += fmt.Sprintf(" [red]KoD %s[white]", tview.Escape(p.KoDCode))
+		} else if e.Type == "response" {
+			line += " tx " + p.TransmitTime
+		}
+	}
+	if e.AttackMode != "" {
+		line += fmt.Sprintf(" [red]%s[white]", tview.Escape(e.AttackMode))
+	}
+	return line + "\n"
+}
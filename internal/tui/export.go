@@ -0,0 +1,75 @@
+package tui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/neutrinoguy/timehammer/internal/logger"
+)
+
+// pageExport is the page name of the log export dialog
+const pageExport = "export"
+
+// exportDialogOpen reports whether the export dialog is in front
+func (a *App) exportDialogOpen() bool {
+	front, _ := a.pages.GetFrontPage()
+	return front == pageExport
+}
+
+// exportKeys handles keys while the export dialog is open. Escape cancels
+// instead of asking to quit.
+func (a *App) exportKeys(event *tcell.EventKey) *tcell.EventKey {
+	if event.Key() == tcell.KeyEscape {
+		a.pages.RemovePage(pageExport)
+		return nil
+	}
+	return event
+}
+
+// showExportDialog asks where to export the logs and in which format,
+// starting from the last destination used
+func (a *App) showExportDialog() {
+	dest := a.exportDest
+	if dest == "" {
+		dir, err := logger.ExportDir()
+		if err != nil {
+			a.log.Errorf("EXPORT", "Failed to export: %v", err)
+			return
+		}
+		dest = dir + string(filepath.Separator)
+	}
+
+	formats := append([]string{"all"}, logger.ExportFormats...)
+
+	form := tview.NewForm()
+	form.SetBorder(true)
+	form.SetTitle(" 💾 Export Logs [Esc cancel] ")
+	form.SetBorderColor(ColorAccent)
+	form.SetButtonsAlign(tview.AlignCenter)
+	form.AddInputField("Path", dest, 56, nil, nil)
+	form.AddDropDown("Format", formats, a.exportFormat, nil)
+
+	form.AddButton("Export", func() {
+		path := strings.TrimSpace(form.GetFormItem(0).(*tview.InputField).GetText())
+		choice, format := form.GetFormItem(1).(*tview.DropDown).GetCurrentOption()
+		var chosen []string
+		if choice > 0 {
+			chosen = []string{format}
+		}
+		if err := a.exportLogs(path, chosen); err != nil {
+			form.SetTitle(fmt.Sprintf(" 💾 Export Logs [red]%s[white] ", tview.Escape(firstLine(err.Error()))))
+			return
+		}
+		a.exportDest, a.exportFormat = path, choice
+		a.pages.RemovePage(pageExport)
+	})
+	form.AddButton("Cancel", func() {
+		a.pages.RemovePage(pageExport)
+	})
+
+	a.pages.AddPage(pageExport, centered(form, 72, 9), true, true)
+}
@@ -0,0 +1,265 @@
+package tui
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/neutrinoguy/timehammer/internal/attacks"
+	"github.com/neutrinoguy/timehammer/internal/server"
+)
+
+// Offset graph tuning
+const (
+	graphWindow   = 600         // Samples kept per series
+	graphInterval = time.Second // Time between samples
+	graphAxisW    = 10          // Columns reserved for the y-axis labels
+)
+
+// offsetHistory keeps a rolling window of client offset estimates and the
+// applied attack drift, one sample per graphInterval. Missing samples are
+// NaN so every series stays aligned.
+type offsetHistory struct {
+	mu       sync.Mutex
+	drift    []float64            // Seconds
+	clients  map[string][]float64 // Seconds, per client address
+	selected string               // Client being plotted
+}
+
+// newOffsetHistory creates an empty history
+func newOffsetHistory() *offsetHistory {
+	return &offsetHistory{clients: make(map[string][]float64)}
+}
+
+// sample records the current offsets of every client and the drift
+func (h *offsetHistory) sample(srv *server.Server) {
+	engine := srv.GetAttackEngine()
+	drift := math.NaN()
+	if engine.IsEnabled() && engine.GetActiveAttack() == attacks.AttackTimeDrift {
+		d, _ := engine.GetDriftStatus()
+		drift = d.Seconds()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.drift = appendSample(h.drift, drift)
+	seen := make(map[string]bool)
+	for _, c := range srv.GetActiveClients() {
+		if !c.OffsetKnown {
+			continue
+		}
+		seen[c.Address] = true
+		series, ok := h.clients[c.Address]
+		if !ok {
+			// Pad so the new series lines up with the drift
+			series = make([]float64, len(h.drift)-1, graphWindow)
+			for i := range series {
+				series[i] = math.NaN()
+			}
+		}
+		h.clients[c.Address] = appendSample(series, c.EstimatedOffset.Seconds())
+	}
+
+	// Clients that left keep their history until it scrolls out
+	for addr, series := range h.clients {
+		if seen[addr] {
+			continue
+		}
+		series = appendSample(series, math.NaN())
+		if allNaN(series) {
+			delete(h.clients, addr)
+			continue
+		}
+		h.clients[addr] = series
+	}
+
+	if _, ok := h.clients[h.selected]; !ok {
+		h.selected = ""
+		if addrs := h.addresses(); len(addrs) > 0 {
+			h.selected = addrs[0]
+		}
+	}
+}
+
+// cycle selects the next (dir > 0) or previous client
+func (h *offsetHistory) cycle(dir int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	addrs := h.addresses()
+	if len(addrs) == 0 {
+		return
+	}
+	i := sort.SearchStrings(addrs, h.selected)
+	h.selected = addrs[((i+dir)%len(addrs)+len(addrs))%len(addrs)]
+}
+
+// addresses returns the tracked clients in sorted order. Callers hold h.mu.
+func (h *offsetHistory) addresses() []string {
+	addrs := make([]string, 0, len(h.clients))
+	for addr := range h.clients {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// snapshot returns the selected client, its series and the drift series
+func (h *offsetHistory) snapshot() (string, int, []float64, []float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	client := append([]float64(nil), h.clients[h.selected]...)
+	return h.selected, len(h.clients), client, append([]float64(nil), h.drift...)
+}
+
+// appendSample adds a sample, dropping the oldest beyond the window
+func appendSample(series []float64, v float64) []float64 {
+	series = append(series, v)
+	if len(series) > graphWindow {
+		series = series[len(series)-graphWindow:]
+	}
+	return series
+}
+
+// allNaN reports whether a series has no samples left
+func allNaN(series []float64) bool {
+	for _, v := range series {
+		if !math.IsNaN(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// offsetGraph plots the selected client's offset and the attack drift
+type offsetGraph struct {
+	*tview.Box
+	hist *offsetHistory
+}
+
+// newOffsetGraph creates the graph primitive
+func newOffsetGraph(hist *offsetHistory) *offsetGraph {
+	g := &offsetGraph{Box: tview.NewBox(), hist: hist}
+	g.SetBorder(true)
+	g.SetTitle(" 📈 Client Offset vs Attack Drift [←/→ select client] ")
+	g.SetBorderColor(ColorAccent)
+	return g
+}
+
+// Draw renders the axis labels, both series and a legend
+func (g *offsetGraph) Draw(screen tcell.Screen) {
+	g.Box.DrawForSubclass(screen, g)
+	x, y, width, height := g.GetInnerRect()
+	plotW, plotH := width-graphAxisW, height-2
+	if plotW < 10 || plotH < 3 {
+		return
+	}
+
+	client, count, clientSeries, driftSeries := g.hist.snapshot()
+	clientSeries = lastN(clientSeries, plotW)
+	driftSeries = lastN(driftSeries, plotW)
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, series := range [][]float64{clientSeries, driftSeries} {
+		for _, v := range series {
+			if !math.IsNaN(v) {
+				lo, hi = math.Min(lo, v), math.Max(hi, v)
+			}
+		}
+	}
+	if math.IsInf(lo, 1) {
+		tview.Print(screen, "[gray]Waiting for client requests with transmit timestamps...", x+1, y+height/2, width-2, tview.AlignCenter, tcell.ColorGray)
+		return
+	}
+	if hi-lo < 0.001 {
+		lo, hi = lo-0.0005, hi+0.0005
+	}
+
+	// Y axis labels at the top, middle and bottom rows
+	for _, row := range []int{0, plotH / 2, plotH - 1} {
+		v := hi - (hi-lo)*float64(row)/float64(plotH-1)
+		tview.Print(screen, formatOffset(secondsToDuration(v)), x, y+row, graphAxisW-1, tview.AlignRight, tcell.ColorGray)
+		screen.SetContent(x+graphAxisW-1, y+row, '┤', nil, tcell.StyleDefault.Foreground(tcell.ColorGray))
+	}
+
+	plot := func(series []float64, color tcell.Color) {
+		offset := plotW - len(series) // Right-align so the newest sample is last
+		for i, v := range series {
+			if math.IsNaN(v) {
+				continue
+			}
+			row := int(math.Round((hi - v) / (hi - lo) * float64(plotH-1)))
+			screen.SetContent(x+graphAxisW+offset+i, y+row, '•', nil, tcell.StyleDefault.Foreground(color))
+		}
+	}
+	plot(driftSeries, tcell.ColorYellow)
+	plot(clientSeries, tcell.ColorAqua)
+
+	legend := fmt.Sprintf("[aqua]• client %s", tview.Escape(orDefault(client, "none")))
+	if v := lastValue(clientSeries); !math.IsNaN(v) {
+		legend += " " + formatOffset(secondsToDuration(v))
+	}
+	legend += fmt.Sprintf(" [gray](%d tracked)  [yellow]• attack drift", count)
+	if v := lastValue(driftSeries); !math.IsNaN(v) {
+		legend += " " + formatOffset(secondsToDuration(v))
+	} else {
+		legend += " inactive"
+	}
+	legend += fmt.Sprintf("  [gray]last %s", time.Duration(plotW)*graphInterval)
+	tview.Print(screen, legend, x+1, y+height-1, width-2, tview.AlignLeft, tcell.ColorWhite)
+}
+
+// InputHandler cycles the plotted client with the arrow keys
+func (g *offsetGraph) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return g.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		switch event.Key() {
+		case tcell.KeyLeft:
+			g.hist.cycle(-1)
+		case tcell.KeyRight:
+			g.hist.cycle(1)
+		}
+	})
+}
+
+// lastN returns the last n samples of a series
+func lastN(series []float64, n int) []float64 {
+	if len(series) > n {
+		return series[len(series)-n:]
+	}
+	return series
+}
+
+// lastValue returns the newest sample (NaN if none)
+func lastValue(series []float64) float64 {
+	if len(series) == 0 {
+		return math.NaN()
+	}
+	return series[len(series)-1]
+}
+
+// secondsToDuration converts float seconds to a duration
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// createGraphView creates the offset graph page and starts sampling
+func (a *App) createGraphView() {
+	hist := newOffsetHistory()
+	a.graphView = newOffsetGraph(hist)
+
+	go func() {
+		ticker := time.NewTicker(graphInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			hist.sample(a.server)
+			a.app.QueueUpdateDraw(func() {})
+		}
+	}()
+}
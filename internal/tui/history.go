@@ -0,0 +1,137 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/neutrinoguy/timehammer/internal/server"
+)
+
+// historyInterval is how often the client history page refreshes
+const historyInterval = 2 * time.Second
+
+// createHistoryView creates the client history table
+func (a *App) createHistoryView() {
+	a.historyView = tview.NewTable().
+		SetFixed(1, 1).
+		SetSelectable(true, false)
+	a.historyView.SetBorder(true)
+	a.historyView.SetBorderColor(ColorPrimary)
+	a.historyView.SetSelectedStyle(tcell.StyleDefault.Background(ColorPrimary))
+
+	go func() {
+		ticker := time.NewTicker(historyInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			a.app.QueueUpdateDraw(func() {
+				if a.currentPage == "history" {
+					a.refreshHistory()
+				}
+			})
+		}
+	}()
+}
+
+// refreshHistory redraws the client history table
+func (a *App) refreshHistory() {
+	records := a.server.GetClientHistory()
+
+	title := " 🗃️ Client History "
+	if a.cfg.Logging.ClientHistory {
+		title += "[gray](saved across restarts)[white] "
+	} else {
+		title += "[gray](this run only; set logging.client_history to keep)[white] "
+	}
+	a.historyView.SetTitle(title)
+
+	row, col := a.historyView.GetSelection()
+	a.historyView.Clear()
+
+	headers := []string{"Client", "First Seen", "Last Seen", "Requests", "Versions", "Offset", "Offset Range", "Interval", "Attacks"}
+	for i, h := range headers {
+		a.historyView.SetCell(0, i, tview.NewTableCell(h).
+			SetTextColor(tcell.ColorYellow).
+			SetSelectable(false))
+	}
+
+	now := time.Now()
+	for i, r := range records {
+		offset, offsetRange := "-", "-"
+		if r.OffsetKnown {
+			offset = formatOffset(r.LastOffset)
+			offsetRange = fmt.Sprintf("%s … %s", formatOffset(r.MinOffset), formatOffset(r.MaxOffset))
+		}
+		interval := "-"
+		if r.ObservedInterval > 0 {
+			interval = formatDuration(r.ObservedInterval)
+			if r.AdvertisedInterval > 0 {
+				interval += " / " + formatDuration(r.AdvertisedInterval)
+			}
+		}
+		cells := []string{
+			r.Address,
+			r.FirstSeen.Format("2006-01-02 15:04"),
+			formatDuration(now.Sub(r.LastSeen)) + " ago",
+			fmt.Sprint(r.Requests),
+			formatVersions(r.Versions),
+			offset,
+			offsetRange,
+			interval,
+			formatHistoryAttacks(r),
+		}
+		for j, text := range cells {
+			a.historyView.SetCell(i+1, j, tview.NewTableCell(tview.Escape(text)).SetExpansion(expansion(j, len(cells))))
+		}
+	}
+
+	if row < 1 {
+		row = 1
+	}
+	a.historyView.Select(min(row, max(len(records), 1)), col)
+}
+
+// expansion lets the last column take the spare width
+func expansion(col, cols int) int {
+	if col == cols-1 {
+		return 1
+	}
+	return 0
+}
+
+// formatVersions lists NTP versions, e.g. "v3,v4"
+func formatVersions(versions []int) string {
+	parts := make([]string, len(versions))
+	for i, v := range versions {
+		parts[i] = fmt.Sprintf("v%d", v)
+	}
+	return strings.Join(parts, ",")
+}
+
+// formatHistoryAttacks lists the attacks a client received, most frequent
+// first, e.g. "kiss_of_death×12 time_drift×3"
+func formatHistoryAttacks(r server.ClientRecord) string {
+	if len(r.Attacks) == 0 {
+		return "-"
+	}
+	names := make([]string, 0, len(r.Attacks))
+	for name := range r.Attacks {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if r.Attacks[names[i]] != r.Attacks[names[j]] {
+			return r.Attacks[names[i]] > r.Attacks[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s×%d", name, r.Attacks[name])
+	}
+	return strings.Join(parts, " ")
+}
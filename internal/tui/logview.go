@@ -0,0 +1,197 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/neutrinoguy/timehammer/internal/logger"
+)
+
+// logQuery is a parsed log filter. Terms of the form level:LEVEL keep
+// entries at or above LEVEL, cat:A,B keeps the listed categories and all
+// other words must appear in the entry text (case-insensitive).
+type logQuery struct {
+	minLevel   logger.LogLevel
+	categories map[string]bool
+	words      []string
+}
+
+// parseLogQuery parses the filter input
+func parseLogQuery(text string) logQuery {
+	q := logQuery{minLevel: logger.LevelDebug}
+	for _, term := range strings.Fields(strings.ToLower(text)) {
+		key, value, ok := strings.Cut(term, ":")
+		switch {
+		case ok && (key == "level" || key == "l"):
+			q.minLevel = parseQueryLevel(value)
+		case ok && (key == "cat" || key == "category" || key == "c"):
+			if q.categories == nil {
+				q.categories = make(map[string]bool)
+			}
+			for _, cat := range strings.Split(value, ",") {
+				if cat != "" {
+					q.categories[cat] = true
+				}
+			}
+		default:
+			q.words = append(q.words, term)
+		}
+	}
+	return q
+}
+
+// parseQueryLevel maps a level name or prefix (e.g. "warn", "err") to a level
+func parseQueryLevel(s string) logger.LogLevel {
+	for _, level := range []logger.LogLevel{logger.LevelDebug, logger.LevelInfo, logger.LevelWarn, logger.LevelError} {
+		if s != "" && strings.HasPrefix(strings.ToLower(level.String()), s) {
+			return level
+		}
+	}
+	return logger.LevelDebug
+}
+
+// empty reports whether the query matches everything
+func (q logQuery) empty() bool {
+	return q.minLevel == logger.LevelDebug && len(q.categories) == 0 && len(q.words) == 0
+}
+
+// matches reports whether an entry passes the filter
+func (q logQuery) matches(entry logger.LogEntry) bool {
+	if entry.Level < q.minLevel {
+		return false
+	}
+	if len(q.categories) > 0 && !q.categories[strings.ToLower(entry.Category)] {
+		return false
+	}
+	if len(q.words) == 0 {
+		return true
+	}
+	text := strings.ToLower(strings.Join([]string{entry.Category, entry.Message, entry.ClientIP, entry.UpstreamIP, entry.Attack}, " "))
+	for _, w := range q.words {
+		if !strings.Contains(text, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// createLogView creates the log viewer with its filter line
+func (a *App) createLogView() {
+	a.logFollow = true
+
+	a.logView = tview.NewTextView().SetDynamicColors(true)
+	a.logView.SetScrollable(true)
+	a.logView.SetBorder(true)
+	a.logView.SetBorderColor(ColorPrimary)
+
+	a.logFilter = tview.NewInputField().
+		SetLabel(" 🔍 Filter [/]: ").
+		SetPlaceholder("text, level:warn, cat:attack,session").
+		SetFieldBackgroundColor(ColorBar)
+
+	// Re-filter as the query is typed; Enter or Esc goes back to the log
+	a.logFilter.SetChangedFunc(func(text string) {
+		a.logQuery = parseLogQuery(text)
+		a.renderLogs()
+	})
+	a.logFilter.SetDoneFunc(func(key tcell.Key) {
+		a.app.SetFocus(a.logView)
+	})
+
+	a.logView.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Rune() {
+		case '/':
+			a.app.SetFocus(a.logFilter)
+			return nil
+		case 'f':
+			a.logFollow = !a.logFollow
+			a.renderLogs()
+			return nil
+		}
+		return event
+	})
+
+	a.logPanel = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(a.logView, 0, 1, true).
+		AddItem(a.logFilter, 1, 0, false)
+
+	a.renderLogs()
+}
+
+// logFilterFocused reports whether the filter input has the keyboard
+func (a *App) logFilterFocused() bool {
+	return a.logFilter != nil && a.app.GetFocus() == a.logFilter
+}
+
+// renderLogs redraws the log view from the in-memory entries that match
+// the filter
+func (a *App) renderLogs() {
+	entries := a.log.GetAllEntries()
+
+	var sb strings.Builder
+	shown := 0
+	for _, entry := range entries {
+		if a.logQuery.matches(entry) {
+			sb.WriteString(formatLogLine(entry))
+			shown++
+		}
+	}
+	a.logView.SetText(sb.String())
+	a.logShown, a.logTotal = shown, len(entries)
+	a.updateLogTitle()
+
+	if a.logFollow {
+		a.logView.ScrollToEnd()
+	}
+}
+
+// appendLog adds a new entry to the log view if it passes the filter.
+// While frozen the view is left alone; resuming redraws it.
+func (a *App) appendLog(entry logger.LogEntry) {
+	a.logTotal++
+	if !a.logFollow {
+		a.updateLogTitle()
+		return
+	}
+	if a.logQuery.matches(entry) {
+		fmt.Fprint(a.logView, formatLogLine(entry))
+		a.logShown++
+		a.logView.ScrollToEnd()
+	}
+	a.updateLogTitle()
+}
+
+// updateLogTitle shows the follow state and, when filtering, the match count
+func (a *App) updateLogTitle() {
+	state := "[green]FOLLOWING[white]"
+	if !a.logFollow {
+		state = "[yellow]FROZEN[white]"
+	}
+	count := ""
+	if !a.logQuery.empty() {
+		count = fmt.Sprintf(" %d/%d", a.logShown, a.logTotal)
+	}
+	a.logView.SetTitle(fmt.Sprintf(" 📜 Logs %s%s [/ filter, f follow/freeze, Ctrl+C clear, Ctrl+E export] ", state, count))
+}
+
+// formatLogLine formats an entry for the log view
+func formatLogLine(entry logger.LogEntry) string {
+	color := "white"
+	switch entry.Level {
+	case logger.LevelDebug:
+		color = "gray"
+	case logger.LevelInfo:
+		color = "green"
+	case logger.LevelWarn:
+		color = "yellow"
+	case logger.LevelError:
+		color = "red"
+	}
+
+	return fmt.Sprintf("[%s]%s [%s][%s]%s %s[white]\n",
+		"cyan", entry.Timestamp.Format("15:04:05"),
+		entry.LevelStr, color, entry.Category, entry.Message)
+}
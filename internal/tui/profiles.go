@@ -0,0 +1,130 @@
+package tui
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/neutrinoguy/timehammer/internal/config"
+)
+
+// Profile dialog page names
+const (
+	pageProfiles    = "profiles"
+	pageProfileSave = "profile_save"
+)
+
+// centered wraps a primitive in a fixed-size box in the middle of the page
+func centered(p tview.Primitive, width, height int) tview.Primitive {
+	return tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(p, height, 0, true).
+			AddItem(nil, 0, 1, false), width, 0, true).
+		AddItem(nil, 0, 1, false)
+}
+
+// profileDialogOpen reports whether a profile dialog is in front
+func (a *App) profileDialogOpen() bool {
+	front, _ := a.pages.GetFrontPage()
+	return front == pageProfiles || front == pageProfileSave
+}
+
+// closeProfileDialogs removes any open profile dialog
+func (a *App) closeProfileDialogs() {
+	a.pages.RemovePage(pageProfileSave)
+	a.pages.RemovePage(pageProfiles)
+}
+
+// showProfiles lists the saved profiles; selecting one switches to it
+func (a *App) showProfiles() {
+	names, err := config.ListProfiles()
+	if err != nil {
+		a.log.Errorf("CONFIG", "Failed to list profiles: %v", err)
+		return
+	}
+
+	list := tview.NewList().ShowSecondaryText(false)
+	list.SetBorder(true)
+	list.SetTitle(" 🗂️ Profiles [Enter load, Esc close] ")
+	list.SetBorderColor(ColorAccent)
+
+	list.AddItem("[green]+ Save current config as profile...", "", 's', func() {
+		a.showSaveProfile()
+	})
+	for _, name := range names {
+		list.AddItem(tview.Escape(name), "", 0, func() {
+			a.closeProfileDialogs()
+			a.loadProfile(name)
+		})
+	}
+
+	height := len(names) + 3
+	if height > 20 {
+		height = 20
+	}
+	a.pages.AddPage(pageProfiles, centered(list, 50, height), true, true)
+}
+
+// showSaveProfile asks for a name and saves the current config under it
+func (a *App) showSaveProfile() {
+	input := tview.NewInputField().
+		SetLabel("Name: ").
+		SetFieldWidth(30).
+		SetAcceptanceFunc(func(text string, ch rune) bool {
+			return len(text) <= 64
+		})
+	input.SetBorder(true)
+	input.SetTitle(" 💾 Save Profile [Enter save, Esc cancel] ")
+	input.SetBorderColor(ColorAccent)
+
+	input.SetDoneFunc(func(key tcell.Key) {
+		if key != tcell.KeyEnter {
+			return
+		}
+		name := input.GetText()
+		if err := a.cfg.SaveProfile(name); err != nil {
+			a.log.Errorf("CONFIG", "Failed to save profile: %v", err)
+			return
+		}
+		a.log.Infof("CONFIG", "Saved profile %s", name)
+		a.closeProfileDialogs()
+	})
+
+	a.pages.AddPage(pageProfileSave, centered(input, 50, 3), true, true)
+}
+
+// loadProfile switches the running server to a saved profile
+func (a *App) loadProfile(name string) {
+	cfg, err := config.LoadProfile(name)
+	if err != nil {
+		a.log.Errorf("CONFIG", "Failed to load profile: %v", err)
+		return
+	}
+	if err := a.server.Reload(cfg); err != nil {
+		a.log.Errorf("CONFIG", "Failed to apply profile %s: %v", name, err)
+		return
+	}
+	// Reload copied the profile into the shared config
+	a.server.UpdateConfig(a.cfg)
+	a.log.Infof("CONFIG", "Switched to profile %s", name)
+
+	if a.currentPage == "config" {
+		a.reloadConfigEditor()
+	}
+	a.updateStatusBar()
+}
+
+// profileKeys handles keys while a profile dialog is open. Escape closes
+// the dialog instead of asking to quit; everything else goes to the dialog.
+func (a *App) profileKeys(event *tcell.EventKey) *tcell.EventKey {
+	if event.Key() == tcell.KeyEscape {
+		if a.pages.HasPage(pageProfileSave) {
+			a.pages.RemovePage(pageProfileSave)
+		} else {
+			a.pages.RemovePage(pageProfiles)
+		}
+		return nil
+	}
+	return event
+}
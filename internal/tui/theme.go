@@ -0,0 +1,142 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Theme is a TUI color palette. Tags recolors the inline color tags the
+// views use for status ([green] ok, [red] bad, [yellow] pending, ...), so a
+// palette can change what the indicators look like without touching them.
+type Theme struct {
+	Name       string
+	Primary    tcell.Color
+	Secondary  tcell.Color
+	Success    tcell.Color
+	Warning    tcell.Color
+	Danger     tcell.Color
+	Accent     tcell.Color
+	Background tcell.Color
+	Text       tcell.Color // Header text
+	Bar        tcell.Color // Footer and input field background
+	Tags       map[string]tcell.Color
+}
+
+// Themes are the palettes tui.theme selects from
+var Themes = []Theme{
+	{
+		Name:       "default",
+		Primary:    tcell.ColorDodgerBlue,
+		Secondary:  tcell.ColorLightGray,
+		Success:    tcell.ColorLimeGreen,
+		Warning:    tcell.ColorOrange,
+		Danger:     tcell.ColorRed,
+		Accent:     tcell.ColorMediumPurple,
+		Background: tcell.ColorBlack,
+		Text:       tcell.ColorWhite,
+		Bar:        tcell.ColorDarkSlateGray,
+	},
+	{
+		// Saturated colors and no dim grays
+		Name:       "high_contrast",
+		Primary:    tcell.ColorWhite,
+		Secondary:  tcell.ColorWhite,
+		Success:    tcell.NewHexColor(0x00FF00),
+		Warning:    tcell.NewHexColor(0xFFFF00),
+		Danger:     tcell.NewHexColor(0xFF0000),
+		Accent:     tcell.NewHexColor(0x00FFFF),
+		Background: tcell.ColorBlack,
+		Text:       tcell.ColorBlack,
+		Bar:        tcell.NewHexColor(0x000080),
+		Tags: map[string]tcell.Color{
+			"green":  tcell.NewHexColor(0x00FF00),
+			"red":    tcell.NewHexColor(0xFF0000),
+			"yellow": tcell.NewHexColor(0xFFFF00),
+			"cyan":   tcell.NewHexColor(0x00FFFF),
+			"gray":   tcell.ColorSilver,
+			"white":  tcell.NewHexColor(0xFFFFFF),
+		},
+	},
+	{
+		// Okabe-Ito colors: blue for good and vermillion for bad stay apart
+		// for red/green color blindness
+		Name:       "colorblind",
+		Primary:    tcell.NewHexColor(0x0072B2),
+		Secondary:  tcell.ColorLightGray,
+		Success:    tcell.NewHexColor(0x56B4E9),
+		Warning:    tcell.NewHexColor(0xF0E442),
+		Danger:     tcell.NewHexColor(0xD55E00),
+		Accent:     tcell.NewHexColor(0xCC79A7),
+		Background: tcell.ColorBlack,
+		Text:       tcell.ColorWhite,
+		Bar:        tcell.ColorDarkSlateGray,
+		Tags: map[string]tcell.Color{
+			"green":  tcell.NewHexColor(0x56B4E9),
+			"red":    tcell.NewHexColor(0xD55E00),
+			"yellow": tcell.NewHexColor(0xF0E442),
+			"cyan":   tcell.NewHexColor(0xCC79A7),
+		},
+	},
+	{
+		// For terminals without color; the indicators keep their text
+		Name:       "monochrome",
+		Primary:    tcell.ColorWhite,
+		Secondary:  tcell.ColorSilver,
+		Success:    tcell.ColorWhite,
+		Warning:    tcell.ColorWhite,
+		Danger:     tcell.ColorWhite,
+		Accent:     tcell.ColorSilver,
+		Background: tcell.ColorBlack,
+		Text:       tcell.ColorBlack,
+		Bar:        tcell.ColorGray,
+		Tags: map[string]tcell.Color{
+			"green":  tcell.ColorWhite,
+			"red":    tcell.ColorWhite,
+			"yellow": tcell.ColorWhite,
+			"cyan":   tcell.ColorWhite,
+			"gray":   tcell.ColorSilver,
+		},
+	},
+}
+
+// tagDefaults holds the tag colors a theme replaced, to restore them
+var tagDefaults = map[string]tcell.Color{}
+
+// ApplyTheme makes the named theme current. It must run before the views are
+// built, which read the Color variables once.
+func ApplyTheme(name string) error {
+	var theme *Theme
+	for i := range Themes {
+		if Themes[i].Name == name {
+			theme = &Themes[i]
+		}
+	}
+	if theme == nil {
+		return fmt.Errorf("unknown theme %q", name)
+	}
+
+	ColorPrimary = theme.Primary
+	ColorSecondary = theme.Secondary
+	ColorSuccess = theme.Success
+	ColorWarning = theme.Warning
+	ColorDanger = theme.Danger
+	ColorAccent = theme.Accent
+	ColorBackground = theme.Background
+	ColorText = theme.Text
+	ColorBar = theme.Bar
+	tview.Styles.PrimitiveBackgroundColor = theme.Background
+
+	// tview looks tag names up in tcell's color table
+	for tag, c := range tagDefaults {
+		tcell.ColorNames[tag] = c
+	}
+	for tag, c := range theme.Tags {
+		if _, ok := tagDefaults[tag]; !ok {
+			tagDefaults[tag] = tcell.ColorNames[tag]
+		}
+		tcell.ColorNames[tag] = c
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package ntpcore
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Builder assembles an NTP packet field by field:
+//
+//	p, err := ntpcore.NewBuilder().LI(3).Version(4).Mode(4).KoD("RATE").Build()
+//
+// It starts from NewPacket's defaults. Build rejects values the header
+// cannot encode and, unless Unsafe is called, combinations no real server
+// or client sends, such as a kiss code with a non-zero stratum.
+type Builder struct {
+	p      NTPPacket
+	kiss   string // Kiss code set by KoD, if any
+	unsafe bool
+	errs   []error
+
+	macKey  []byte // Key the packet is signed with on Build
+	macAlgo string // "" = no MAC to compute
+}
+
+// NewBuilder starts a packet with NewPacket's defaults (NTPv4 server,
+// stratum 2, poll 6, precision -20)
+func NewBuilder() *Builder {
+	return &Builder{p: *NewPacket()}
+}
+
+// From starts a builder from a copy of an existing packet, e.g. a parsed
+// request to answer
+func From(p *NTPPacket) *Builder {
+	b := &Builder{p: *p}
+	b.p.Extensions = append([]ExtensionField(nil), p.Extensions...)
+	b.p.MAC = append([]byte(nil), p.MAC...)
+	b.p.Unparsed = append([]byte(nil), p.Unparsed...)
+	return b
+}
+
+// Unsafe turns off the consistency checks of Build, for crafting packets
+// that are deliberately wrong. Values the header cannot encode are still
+// rejected.
+func (b *Builder) Unsafe() *Builder {
+	b.unsafe = true
+	return b
+}
+
+// LI sets the leap indicator (0-3)
+func (b *Builder) LI(li uint8) *Builder {
+	b.p.LeapIndicator = li
+	return b
+}
+
+// Version sets the NTP version (0-7 on the wire, 1-4 defined)
+func (b *Builder) Version(v uint8) *Builder {
+	b.p.Version = v
+	return b
+}
+
+// Mode sets the association mode (0-7)
+func (b *Builder) Mode(m uint8) *Builder {
+	b.p.Mode = m
+	return b
+}
+
+// Stratum sets the stratum
+func (b *Builder) Stratum(s uint8) *Builder {
+	b.p.Stratum = s
+	return b
+}
+
+// Poll sets the poll exponent (log2 seconds)
+func (b *Builder) Poll(poll int8) *Builder {
+	b.p.Poll = poll
+	return b
+}
+
+// Precision sets the precision exponent (log2 seconds)
+func (b *Builder) Precision(precision int8) *Builder {
+	b.p.Precision = precision
+	return b
+}
+
+// RootDelay sets the root delay, saturating at the short format maximum
+func (b *Builder) RootDelay(d time.Duration) *Builder {
+	b.p.RootDelay = CalculateRootDelay(float64(d) / float64(time.Millisecond))
+	return b
+}
+
+// RootDispersion sets the root dispersion, saturating at the short format
+// maximum
+func (b *Builder) RootDispersion(d time.Duration) *Builder {
+	b.p.RootDisp = CalculateRootDispersion(float64(d) / float64(time.Millisecond))
+	return b
+}
+
+// RefID sets the reference ID from an IP address or refclock code, as
+// ParseReferenceID reads it
+func (b *Builder) RefID(s string) *Builder {
+	id, _, err := ParseReferenceID(s)
+	if err != nil {
+		b.errs = append(b.errs, err)
+		return b
+	}
+	b.p.ReferenceID = id
+	b.kiss = ""
+	return b
+}
+
+// RefIDRaw sets the reference ID to a raw value
+func (b *Builder) RefIDRaw(id uint32) *Builder {
+	b.p.ReferenceID = id
+	b.kiss = ""
+	return b
+}
+
+// KoD makes the packet a Kiss-o'-Death with the given kiss code and
+// stratum 0. The leap indicator is left alone; servers usually send 3.
+func (b *Builder) KoD(code string) *Builder {
+	if err := b.p.SetKissOfDeathCode(code); err != nil {
+		b.errs = append(b.errs, fmt.Errorf("kiss code %q: %w", code, err))
+		return b
+	}
+	b.kiss = code
+	return b
+}
+
+// RefTime sets the reference timestamp
+func (b *Builder) RefTime(t time.Time) *Builder {
+	b.p.SetReferenceTime(t)
+	return b
+}
+
+// Origin sets the origin timestamp
+func (b *Builder) Origin(t time.Time) *Builder {
+	ts := TimeToNTPTimestamp(t)
+	b.p.SetOriginTime(ts.Seconds, ts.Fraction)
+	return b
+}
+
+// OriginRaw sets the origin timestamp to raw values, e.g. a request's
+// transmit timestamp
+func (b *Builder) OriginRaw(sec, frac uint32) *Builder {
+	b.p.SetOriginTime(sec, frac)
+	return b
+}
+
+// Receive sets the receive timestamp
+func (b *Builder) Receive(t time.Time) *Builder {
+	b.p.SetReceiveTime(t)
+	return b
+}
+
+// Transmit sets the transmit timestamp
+func (b *Builder) Transmit(t time.Time) *Builder {
+	b.p.SetTransmitTime(t)
+	return b
+}
+
+// Extension appends an extension field
+func (b *Builder) Extension(fieldType uint16, value []byte) *Builder {
+	b.p.AddExtension(fieldType, value)
+	return b
+}
+
+// MAC authenticates the packet when it is built, with the fields set by
+// then; see NTPPacket.SetMAC
+func (b *Builder) MAC(keyID uint32, key []byte, algo string) *Builder {
+	b.p.HasMAC = true
+	b.p.KeyID = keyID
+	b.p.MAC = nil
+	b.macKey, b.macAlgo = key, algo
+	return b
+}
+
+// CryptoNAK appends a crypto-NAK authenticator
+func (b *Builder) CryptoNAK() *Builder {
+	b.p.SetCryptoNAK()
+	b.macKey, b.macAlgo = nil, ""
+	return b
+}
+
+// Build checks the packet and returns it
+func (b *Builder) Build() (*NTPPacket, error) {
+	errs := append([]error(nil), b.errs...)
+	errs = append(errs, b.encodable()...)
+	if !b.unsafe {
+		errs = append(errs, b.consistent()...)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	p := From(&b.p).p
+	if b.macAlgo != "" {
+		if err := p.SetMAC(b.p.KeyID, b.macKey, b.macAlgo); err != nil {
+			return nil, err
+		}
+	}
+	return &p, nil
+}
+
+// Bytes builds the packet and serializes it
+func (b *Builder) Bytes() ([]byte, error) {
+	p, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	return p.Bytes(), nil
+}
+
+// encodable reports header values that do not fit their bits
+func (b *Builder) encodable() []error {
+	var errs []error
+	if b.p.LeapIndicator > LeapAlarm {
+		errs = append(errs, fmt.Errorf("leap indicator %d does not fit in 2 bits", b.p.LeapIndicator))
+	}
+	if b.p.Version > 7 {
+		errs = append(errs, fmt.Errorf("version %d does not fit in 3 bits", b.p.Version))
+	}
+	if b.p.Mode > ModePrivate {
+		errs = append(errs, fmt.Errorf("mode %d does not fit in 3 bits", b.p.Mode))
+	}
+	return errs
+}
+
+// consistent reports combinations that no conforming implementation sends
+func (b *Builder) consistent() []error {
+	var errs []error
+	p := &b.p
+	if p.Version < 1 || p.Version > VersionNTPv4 {
+		errs = append(errs, fmt.Errorf("version %d is not an NTP version (1-4)", p.Version))
+	}
+	if p.Stratum > 16 {
+		errs = append(errs, fmt.Errorf("stratum %d is reserved (0-16)", p.Stratum))
+	}
+	if b.kiss != "" {
+		if p.Stratum != 0 {
+			errs = append(errs, fmt.Errorf("kiss code %s needs stratum 0, not %d", b.kiss, p.Stratum))
+		}
+		if p.Mode == ModeClient {
+			errs = append(errs, fmt.Errorf("kiss code %s in a client request", b.kiss))
+		}
+		for i := 0; i < len(b.kiss); i++ {
+			if b.kiss[i] < 0x20 || b.kiss[i] > 0x7e {
+				errs = append(errs, fmt.Errorf("kiss code %q is not printable ASCII", b.kiss))
+				break
+			}
+		}
+	}
+	if p.HasMAC && len(p.MAC) == 0 && p.KeyID != 0 && b.macAlgo == "" {
+		errs = append(errs, fmt.Errorf("key ID %d without a digest", p.KeyID))
+	}
+	return errs
+}
@@ -0,0 +1,59 @@
+package ntpcore
+
+import "time"
+
+// NTPEraSeconds is the length of an NTP era: the 32-bit seconds field wraps
+// every 2^32 seconds, first on 2036-02-07 06:28:16 UTC (the start of era 1)
+const NTPEraSeconds = 1 << 32
+
+// NTPEra returns the era a time falls in (0 = 1900-2036, negative before 1900)
+func NTPEra(t time.Time) int {
+	secs := t.Unix() + NTPEpochOffset
+	era := secs / NTPEraSeconds
+	if secs < 0 && secs%NTPEraSeconds != 0 {
+		era-- // Floor, not truncation
+	}
+	return int(era)
+}
+
+// TimeToNTPTimestampEra converts a time to an NTP timestamp and returns the
+// era its seconds field counts from. The timestamp alone is the same as
+// TimeToNTPTimestamp; the era is what a wrapped timestamp loses on the wire.
+func TimeToNTPTimestampEra(t time.Time) (NTPTimestamp, int) {
+	return TimeToNTPTimestamp(t), NTPEra(t)
+}
+
+// NTPTimestampToTimeEra converts an NTP timestamp counted from the start of
+// the given era. Era 0 is the same as NTPTimestampToTime.
+func NTPTimestampToTimeEra(ts NTPTimestamp, era int) time.Time {
+	t := NTPTimestampToTime(ts)
+	return time.Unix(t.Unix()+int64(era)*NTPEraSeconds, int64(t.Nanosecond()))
+}
+
+// NTPTimestampToTimeNear converts an NTP timestamp to the time closest to
+// pivot, so the result is within 68 years of it. This is how RFC 5905
+// clients resolve the era: with the current time as pivot, a timestamp just
+// past the 2036 wrap reads as 2036 rather than 1900.
+func NTPTimestampToTimeNear(ts NTPTimestamp, pivot time.Time) time.Time {
+	era := NTPEra(pivot)
+	t := NTPTimestampToTimeEra(ts, era)
+	switch d := t.Unix() - pivot.Unix(); {
+	case d > NTPEraSeconds/2:
+		t = NTPTimestampToTimeEra(ts, era-1)
+	case d < -NTPEraSeconds/2:
+		t = NTPTimestampToTimeEra(ts, era+1)
+	}
+	return t
+}
+
+// TransmitTimeNear returns the transmit time, resolving its era relative to
+// pivot (see NTPTimestampToTimeNear)
+func (p *NTPPacket) TransmitTimeNear(pivot time.Time) time.Time {
+	return NTPTimestampToTimeNear(NTPTimestamp{Seconds: p.XmitTimeSec, Fraction: p.XmitTimeFrac}, pivot)
+}
+
+// ReceiveTimeNear returns the receive time, resolving its era relative to
+// pivot (see NTPTimestampToTimeNear)
+func (p *NTPPacket) ReceiveTimeNear(pivot time.Time) time.Time {
+	return NTPTimestampToTimeNear(NTPTimestamp{Seconds: p.RecvTimeSec, Fraction: p.RecvTimeFrac}, pivot)
+}
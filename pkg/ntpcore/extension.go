@@ -0,0 +1,136 @@
+package ntpcore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+// Extension field constants (RFC 7822)
+const (
+	ExtFieldHeaderSize = 4  // Field type (16 bits) + length (16 bits)
+	ExtFieldMinSize    = 16 // Minimum size of an extension field in bytes
+
+	// ExtTypeSignature is an unassigned field type used to tag TimeHammer
+	// responses with an HMAC so captured packets can be attributed to a run
+	ExtTypeSignature uint16 = 0xF1A5
+
+	// SignatureSize is the length of the HMAC-SHA256 carried in the signature field
+	SignatureSize = sha256.Size
+)
+
+// ErrNoSignature is returned when a packet carries no TimeHammer signature field
+var ErrNoSignature = errors.New("packet carries no signature field")
+
+// ExtensionField represents a single NTPv4 extension field
+type ExtensionField struct {
+	Type  uint16 // Field type
+	Value []byte // Field value (padding included when parsed from the wire)
+}
+
+// Len returns the on-wire length of the field including header and padding
+func (f ExtensionField) Len() int {
+	n := ExtFieldHeaderSize + len(f.Value)
+	if rem := n % 4; rem != 0 {
+		n += 4 - rem
+	}
+	if n < ExtFieldMinSize {
+		n = ExtFieldMinSize
+	}
+	return n
+}
+
+// Bytes serializes the extension field, zero-padding the value to a 4-byte boundary
+func (f ExtensionField) Bytes() []byte {
+	data := make([]byte, f.Len())
+	binary.BigEndian.PutUint16(data[0:2], f.Type)
+	binary.BigEndian.PutUint16(data[2:4], uint16(len(data)))
+	copy(data[ExtFieldHeaderSize:], f.Value)
+	return data
+}
+
+// AddExtension appends an extension field to the packet
+func (p *NTPPacket) AddExtension(fieldType uint16, value []byte) {
+	p.Extensions = append(p.Extensions, ExtensionField{Type: fieldType, Value: value})
+}
+
+// GetExtension returns the first extension field of the given type
+func (p *NTPPacket) GetExtension(fieldType uint16) (ExtensionField, bool) {
+	for _, f := range p.Extensions {
+		if f.Type == fieldType {
+			return f, true
+		}
+	}
+	return ExtensionField{}, false
+}
+
+// parseExtensions parses extension fields following the 48-byte header.
+// Parsing stops at the first trailer that is not a well-formed field (e.g. a
+// MAC), which is returned as the remainder.
+func parseExtensions(data []byte) ([]ExtensionField, []byte) {
+	var fields []ExtensionField
+	for len(data) >= ExtFieldMinSize {
+		// Trailers of exactly MAC size are an authenticator, not a field,
+		// unless they are a field followed by a crypto-NAK
+		if isMACLength(len(data)) && !fieldThenCryptoNAK(data) {
+			break
+		}
+
+		length := int(binary.BigEndian.Uint16(data[2:4]))
+		if length < ExtFieldMinSize || length%4 != 0 || length > len(data) {
+			break
+		}
+
+		value := make([]byte, length-ExtFieldHeaderSize)
+		copy(value, data[ExtFieldHeaderSize:length])
+		fields = append(fields, ExtensionField{
+			Type:  binary.BigEndian.Uint16(data[0:2]),
+			Value: value,
+		})
+		data = data[length:]
+	}
+	return fields, data
+}
+
+// fieldThenCryptoNAK reports whether a trailer of MAC size is one extension
+// field followed by a crypto-NAK rather than a MAC. The MAC reading wins
+// unless the field length is legal and fills the trailer up to a zero key
+// ID, since the low half of a MAC's key ID can look like a field length.
+func fieldThenCryptoNAK(data []byte) bool {
+	length := int(binary.BigEndian.Uint16(data[2:4]))
+	if length < ExtFieldMinSize || length%4 != 0 || length != len(data)-MACLenCryptoNAK {
+		return false
+	}
+	return binary.BigEndian.Uint32(data[length:]) == 0
+}
+
+// Sign appends an HMAC-SHA256 signature field computed over the header and
+// any extension fields already present. It changes the wire format of the
+// packet, so strict clients may reject signed responses.
+func (p *NTPPacket) Sign(key []byte) {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(p.bytesWithoutMAC())
+	p.AddExtension(ExtTypeSignature, mac.Sum(nil))
+}
+
+// VerifySignature checks the TimeHammer signature field of a raw packet
+// against the given run key
+func VerifySignature(data []byte, key []byte) (bool, error) {
+	if len(data) < NTPPacketMinSize {
+		return false, errors.New("packet too short")
+	}
+
+	offset := NTPPacketSize
+	fields, _ := parseExtensions(data[NTPPacketSize:])
+	for _, f := range fields {
+		if f.Type == ExtTypeSignature && len(f.Value) >= SignatureSize {
+			mac := hmac.New(sha256.New, key)
+			mac.Write(data[:offset])
+			return hmac.Equal(mac.Sum(nil), f.Value[:SignatureSize]), nil
+		}
+		offset += f.Len()
+	}
+
+	return false, ErrNoSignature
+}
@@ -0,0 +1,135 @@
+package ntpcore
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// Authenticator (MAC trailer) lengths, RFC 5905 section 7.3 and Appendix A
+const (
+	KeyIDSize = 4 // Key identifier preceding the digest
+
+	MACLenCryptoNAK = KeyIDSize      // Key ID only, no digest: authentication failure
+	MACLenMD5       = KeyIDSize + 16 // Key ID + MD5 digest
+	MACLenSHA1      = KeyIDSize + 20 // Key ID + SHA1 digest
+)
+
+// isMACLength reports whether a trailer of n bytes is a valid authenticator
+func isMACLength(n int) bool {
+	return n == MACLenCryptoNAK || n == MACLenMD5 || n == MACLenSHA1
+}
+
+// parseMAC reads the authenticator from the trailer left after extension
+// fields. Trailers of any other length are ignored.
+func (p *NTPPacket) parseMAC(trailer []byte) {
+	if !isMACLength(len(trailer)) {
+		return
+	}
+	p.HasMAC = true
+	p.KeyID = binary.BigEndian.Uint32(trailer[0:KeyIDSize])
+	p.MAC = nil
+	if len(trailer) > KeyIDSize {
+		p.MAC = make([]byte, len(trailer)-KeyIDSize)
+		copy(p.MAC, trailer[KeyIDSize:])
+	}
+}
+
+// macBytes serializes the authenticator, or returns nil if there is none
+func (p *NTPPacket) macBytes() []byte {
+	if !p.HasMAC {
+		return nil
+	}
+	data := make([]byte, KeyIDSize+len(p.MAC))
+	binary.BigEndian.PutUint32(data[0:KeyIDSize], p.KeyID)
+	copy(data[KeyIDSize:], p.MAC)
+	return data
+}
+
+// MACLen returns the on-wire length of the authenticator (0 if absent)
+func (p *NTPPacket) MACLen() int {
+	if !p.HasMAC {
+		return 0
+	}
+	return KeyIDSize + len(p.MAC)
+}
+
+// SetCryptoNAK turns the packet into a crypto-NAK: an authenticator with
+// key ID 0 and no digest, which tells the client authentication failed
+func (p *NTPPacket) SetCryptoNAK() {
+	p.HasMAC = true
+	p.KeyID = 0
+	p.MAC = nil
+}
+
+// IsCryptoNAK reports whether the packet carries a crypto-NAK authenticator
+func (p *NTPPacket) IsCryptoNAK() bool {
+	return p.HasMAC && len(p.MAC) == 0
+}
+
+// ClearMAC removes any authenticator from the packet
+func (p *NTPPacket) ClearMAC() {
+	p.HasMAC = false
+	p.KeyID = 0
+	p.MAC = nil
+}
+
+// MAC digest algorithms for symmetric key authentication
+const (
+	MACAlgoMD5  = "MD5"
+	MACAlgoSHA1 = "SHA1"
+)
+
+// macHash returns the hash constructor for a digest algorithm name
+func macHash(algo string) (func() hash.Hash, error) {
+	switch strings.ToUpper(algo) {
+	case MACAlgoMD5:
+		return md5.New, nil
+	case MACAlgoSHA1, "SHA":
+		return sha1.New, nil
+	default:
+		return nil, fmt.Errorf("unsupported MAC algorithm: %s", algo)
+	}
+}
+
+// computeMAC returns digest(key || header and extension fields) as defined
+// for NTP symmetric key authentication (RFC 5905 Appendix A)
+func (p *NTPPacket) computeMAC(key []byte, algo string) ([]byte, error) {
+	newHash, err := macHash(algo)
+	if err != nil {
+		return nil, err
+	}
+	h := newHash()
+	h.Write(key)
+	h.Write(p.bytesWithoutMAC())
+	return h.Sum(nil), nil
+}
+
+// SetMAC authenticates the packet with a symmetric key, replacing any
+// existing authenticator. algo is "MD5" or "SHA1".
+func (p *NTPPacket) SetMAC(keyID uint32, key []byte, algo string) error {
+	digest, err := p.computeMAC(key, algo)
+	if err != nil {
+		return err
+	}
+	p.HasMAC = true
+	p.KeyID = keyID
+	p.MAC = digest
+	return nil
+}
+
+// VerifyMAC checks the packet's digest against a symmetric key
+func (p *NTPPacket) VerifyMAC(key []byte, algo string) (bool, error) {
+	if !p.HasMAC || len(p.MAC) == 0 {
+		return false, nil
+	}
+	digest, err := p.computeMAC(key, algo)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(digest, p.MAC) == 1, nil
+}
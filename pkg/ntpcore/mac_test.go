@@ -0,0 +1,140 @@
+package ntpcore
+
+import "testing"
+
+// Key IDs whose low 16 bits equal the MAC trailer length minus 4 look like
+// an extension field length followed by a crypto-NAK
+func TestMACRoundTripFieldLikeKeyIDs(t *testing.T) {
+	key := []byte("timehammer-test-key")
+	tests := []struct {
+		algo  string
+		keyID uint32
+	}{
+		{MACAlgoMD5, 16},
+		{MACAlgoSHA1, 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algo, func(t *testing.T) {
+			p := NewPacket()
+			if err := p.SetMAC(tt.keyID, key, tt.algo); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ParsePacket(p.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got.Extensions) != 0 {
+				t.Errorf("parsed %d extension fields, want none", len(got.Extensions))
+			}
+			if !got.HasMAC || got.KeyID != tt.keyID || got.IsCryptoNAK() {
+				t.Fatalf("HasMAC=%v KeyID=%d NAK=%v, want MAC with key ID %d",
+					got.HasMAC, got.KeyID, got.IsCryptoNAK(), tt.keyID)
+			}
+			ok, err := got.VerifyMAC(key, tt.algo)
+			if err != nil || !ok {
+				t.Errorf("VerifyMAC = %v, %v; want true", ok, err)
+			}
+		})
+	}
+}
+
+// Every documented trailer after the header parses back to what was
+// serialized
+func TestTrailerLengths(t *testing.T) {
+	key := []byte("timehammer-test-key")
+	tests := []struct {
+		name    string
+		build   func(p *NTPPacket) error
+		size    int
+		fields  int
+		macLen  int // 0 = no MAC
+		nak     bool
+		algo    string // Digest to verify ("" = none)
+		fieldSz []int
+	}{
+		{
+			name:  "crypto-NAK",
+			build: func(p *NTPPacket) error { p.SetCryptoNAK(); return nil },
+			size:  NTPPacketSize + MACLenCryptoNAK, macLen: MACLenCryptoNAK, nak: true,
+		},
+		{
+			name:  "MD5",
+			build: func(p *NTPPacket) error { return p.SetMAC(7, key, MACAlgoMD5) },
+			size:  NTPPacketSize + MACLenMD5, macLen: MACLenMD5, algo: MACAlgoMD5,
+		},
+		{
+			name:  "SHA1",
+			build: func(p *NTPPacket) error { return p.SetMAC(7, key, MACAlgoSHA1) },
+			size:  NTPPacketSize + MACLenSHA1, macLen: MACLenSHA1, algo: MACAlgoSHA1,
+		},
+		{
+			name: "padded extension fields",
+			build: func(p *NTPPacket) error {
+				p.AddExtension(0x0104, []byte{1})              // Padded to the 16-byte minimum
+				p.AddExtension(0x0204, make([]byte, 17))       // Padded to 24 bytes
+				p.AddExtension(0x0304, []byte("twelve bytes")) // Exactly 16 bytes
+				return nil
+			},
+			size: NTPPacketSize + 16 + 24 + 16, fields: 3, fieldSz: []int{16, 24, 16},
+		},
+		{
+			name: "extension field and MAC",
+			build: func(p *NTPPacket) error {
+				p.AddExtension(0x0104, make([]byte, 28))
+				return p.SetMAC(7, key, MACAlgoSHA1)
+			},
+			size: NTPPacketSize + 32 + MACLenSHA1, fields: 1, fieldSz: []int{32}, macLen: MACLenSHA1, algo: MACAlgoSHA1,
+		},
+		{
+			name: "extension field and crypto-NAK",
+			build: func(p *NTPPacket) error {
+				p.AddExtension(0x0104, make([]byte, 12))
+				p.SetCryptoNAK()
+				return nil
+			},
+			size: NTPPacketSize + 16 + MACLenCryptoNAK, fields: 1, fieldSz: []int{16}, macLen: MACLenCryptoNAK, nak: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPacket()
+			if err := tt.build(p); err != nil {
+				t.Fatal(err)
+			}
+			data := p.Bytes()
+			if len(data) != tt.size {
+				t.Fatalf("serialized %d bytes, want %d", len(data), tt.size)
+			}
+
+			got, err := ParsePacket(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got.Extensions) != tt.fields {
+				t.Fatalf("parsed %d extension fields, want %d", len(got.Extensions), tt.fields)
+			}
+			for i, f := range got.Extensions {
+				if f.Len() != tt.fieldSz[i] {
+					t.Errorf("field %d is %d bytes, want %d", i, f.Len(), tt.fieldSz[i])
+				}
+			}
+			if got.MACLen() != tt.macLen {
+				t.Errorf("MAC length %d, want %d", got.MACLen(), tt.macLen)
+			}
+			if got.IsCryptoNAK() != tt.nak {
+				t.Errorf("IsCryptoNAK = %v, want %v", got.IsCryptoNAK(), tt.nak)
+			}
+			if len(got.Unparsed) != 0 {
+				t.Errorf("%d unparsed trailer bytes", len(got.Unparsed))
+			}
+			if tt.algo != "" {
+				if ok, err := got.VerifyMAC(key, tt.algo); err != nil || !ok {
+					t.Errorf("VerifyMAC = %v, %v; want true", ok, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package ntpcore
+
+import "encoding/binary"
+
+// NTS extension field types (RFC 8915 section 5.7)
+const (
+	ExtTypeUniqueID         uint16 = 0x0104 // Unique Identifier, echoed by the server
+	ExtTypeNTSCookie        uint16 = 0x0204 // NTS Cookie
+	ExtTypeNTSPlaceholder   uint16 = 0x0304 // NTS Cookie Placeholder
+	ExtTypeNTSAuthenticator uint16 = 0x0404 // NTS Authenticator and Encrypted Extension Fields
+
+	// KoDNTSNak is the kiss code of an NTS negative acknowledgment, sent
+	// when the server cannot use the client's cookie
+	KoDNTSNak = "NTSN"
+
+	ntsAuthHeaderSize = 4 // Nonce length + ciphertext length
+)
+
+// NTSRequest summarizes the NTS extension fields of a request
+type NTSRequest struct {
+	UniqueID      []byte // Unique Identifier value (echoed in any response)
+	Cookies       int    // NTS Cookie fields
+	Placeholders  int    // NTS Cookie Placeholder fields
+	Authenticated bool   // Carries an NTS Authenticator field
+}
+
+// NTS reports whether the packet uses NTS and summarizes its NTS fields.
+// A packet counts as NTS when it carries a Unique Identifier together with
+// a cookie or an authenticator.
+func (p *NTPPacket) NTS() (NTSRequest, bool) {
+	var req NTSRequest
+	hasUID := false
+	for _, f := range p.Extensions {
+		switch f.Type {
+		case ExtTypeUniqueID:
+			if !hasUID {
+				req.UniqueID = f.Value
+				hasUID = true
+			}
+		case ExtTypeNTSCookie:
+			req.Cookies++
+		case ExtTypeNTSPlaceholder:
+			req.Placeholders++
+		case ExtTypeNTSAuthenticator:
+			req.Authenticated = true
+		}
+	}
+	return req, hasUID && (req.Cookies > 0 || req.Authenticated)
+}
+
+// NTSAuthenticator builds the value of an NTS Authenticator field from a
+// nonce and ciphertext. The declared lengths can be overridden (negative =
+// actual length) to produce malformed fields.
+func NTSAuthenticator(nonce, ciphertext []byte, nonceLen, cipherLen int) []byte {
+	if nonceLen < 0 {
+		nonceLen = len(nonce)
+	}
+	if cipherLen < 0 {
+		cipherLen = len(ciphertext)
+	}
+	value := make([]byte, ntsAuthHeaderSize, ntsAuthHeaderSize+len(nonce)+len(ciphertext))
+	binary.BigEndian.PutUint16(value[0:2], uint16(nonceLen))
+	binary.BigEndian.PutUint16(value[2:4], uint16(cipherLen))
+	value = append(value, nonce...)
+	return append(value, ciphertext...)
+}
+
+// ExtensionName returns a short name for known extension field types
+func ExtensionName(fieldType uint16) string {
+	switch fieldType {
+	case ExtTypeUniqueID:
+		return "unique-id"
+	case ExtTypeNTSCookie:
+		return "nts-cookie"
+	case ExtTypeNTSPlaceholder:
+		return "nts-cookie-placeholder"
+	case ExtTypeNTSAuthenticator:
+		return "nts-authenticator"
+	case ExtTypeSignature:
+		return "timehammer-signature"
+	default:
+		return "unknown"
+	}
+}
@@ -3,9 +3,12 @@
 package ntpcore
 
 import (
+	"crypto/md5"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
+	"net"
 	"time"
 )
 
@@ -18,7 +21,7 @@ const (
 	// NTP packet size
 	NTPPacketSize    = 48
 	NTPPacketMinSize = 48
-	NTPPacketMaxSize = 68 // With optional authentication
+	NTPPacketMaxSize = 1024 // Largest packet ParsePacket accepts, extension fields included
 
 	// Leap Indicator values
 	LeapNoWarning     = 0 // No warning
@@ -78,6 +81,16 @@ type NTPPacket struct {
 	RecvTimeFrac uint32 // Receive timestamp (fraction)
 	XmitTimeSec  uint32 // Transmit timestamp (seconds)
 	XmitTimeFrac uint32 // Transmit timestamp (fraction)
+
+	Extensions []ExtensionField // Extension fields (RFC 7822), if any
+
+	// Optional authenticator trailer (RFC 5905 section 7.3)
+	HasMAC bool   // Whether a key ID (and possibly digest) is present
+	KeyID  uint32 // Key identifier
+	MAC    []byte // Message digest (empty for a crypto-NAK)
+
+	// Trailing bytes that are neither extension fields nor an authenticator
+	Unparsed []byte
 }
 
 // NTPTimestamp represents an NTP timestamp (64 bits)
@@ -92,9 +105,10 @@ func TimeToNTPTimestamp(t time.Time) NTPTimestamp {
 	secs := t.Unix() + NTPEpochOffset
 	
 	// Calculate fraction (nanoseconds to NTP fraction)
-	// NTP fraction is 2^32 / 10^9 of nanosecond
-	nanos := t.Nanosecond()
-	frac := uint32((float64(nanos) / 1e9) * float64(1<<32))
+	// One nanosecond is 2^32 / 10^9 fraction units; integer math rounded to
+	// the nearest unit keeps the conversion exact and unbiased
+	nanos := uint64(t.Nanosecond())
+	frac := uint32((nanos<<32 + 5e8) / 1e9)
 	
 	return NTPTimestamp{
 		Seconds:  uint32(secs),
@@ -102,10 +116,14 @@ func TimeToNTPTimestamp(t time.Time) NTPTimestamp {
 	}
 }
 
-// NTPTimestampToTime converts an NTP timestamp to Go time.Time
+// NTPTimestampToTime converts an NTP timestamp to Go time.Time, assuming
+// era 0 (1900-2036). See NTPTimestampToTimeEra and NTPTimestampToTimeNear
+// for timestamps past the 2036 wrap.
 func NTPTimestampToTime(ts NTPTimestamp) time.Time {
 	secs := int64(ts.Seconds) - NTPEpochOffset
-	nanos := int64((float64(ts.Fraction) / float64(1<<32)) * 1e9)
+	// Round to the nearest nanosecond so that converting a time.Time there
+	// and back is lossless
+	nanos := int64((uint64(ts.Fraction)*1e9 + 1<<31) >> 32)
 	return time.Unix(secs, nanos)
 }
 
@@ -124,10 +142,26 @@ func NewPacket() *NTPPacket {
 	}
 }
 
-// ParsePacket parses a byte slice into an NTPPacket
+// Errors returned by ParsePacket; the truncated and oversized errors are
+// wrapped with the packet length
+var (
+	ErrEmptyPacket     = errors.New("empty packet")
+	ErrTruncatedPacket = errors.New("packet truncated")
+	ErrOversizedPacket = errors.New("packet too large")
+)
+
+// ParsePacket parses a byte slice into an NTPPacket. The 48-byte header
+// may be followed by extension fields and an authenticator; any other
+// trailing bytes are kept in Unparsed rather than rejected, so malformed
+// packets can still be inspected.
 func ParsePacket(data []byte) (*NTPPacket, error) {
-	if len(data) < NTPPacketMinSize {
-		return nil, errors.New("packet too short")
+	switch {
+	case len(data) == 0:
+		return nil, ErrEmptyPacket
+	case len(data) < NTPPacketMinSize:
+		return nil, fmt.Errorf("%w: %d of %d header bytes", ErrTruncatedPacket, len(data), NTPPacketMinSize)
+	case len(data) > NTPPacketMaxSize:
+		return nil, fmt.Errorf("%w: %d bytes (max %d)", ErrOversizedPacket, len(data), NTPPacketMaxSize)
 	}
 
 	p := &NTPPacket{}
@@ -153,15 +187,33 @@ func ParsePacket(data []byte) (*NTPPacket, error) {
 	p.XmitTimeSec = binary.BigEndian.Uint32(data[40:44])
 	p.XmitTimeFrac = binary.BigEndian.Uint32(data[44:48])
 
+	// Parse any extension fields following the header, then the MAC
+	var trailer []byte
+	p.Extensions, trailer = parseExtensions(data[NTPPacketSize:])
+	p.parseMAC(trailer)
+	if !p.HasMAC && len(trailer) > 0 {
+		p.Unparsed = append([]byte(nil), trailer...)
+	}
+
 	return p, nil
 }
 
-// Bytes serializes the NTPPacket to bytes
+// Bytes serializes the NTPPacket to bytes, including any authenticator and
+// unparsed trailing bytes
 func (p *NTPPacket) Bytes() []byte {
+	data := append(p.bytesWithoutMAC(), p.Unparsed...)
+	return append(data, p.macBytes()...)
+}
+
+// bytesWithoutMAC serializes the header and extension fields, which is the
+// part of the packet covered by a MAC
+func (p *NTPPacket) bytesWithoutMAC() []byte {
 	data := make([]byte, NTPPacketSize)
 
 	// First byte: LI | VN | Mode
-	data[0] = (p.LeapIndicator << 6) | (p.Version << 3) | p.Mode
+	// Each field is masked to its width so an out-of-range value cannot
+	// spill into its neighbour
+	data[0] = (p.LeapIndicator&0x3)<<6 | (p.Version&0x7)<<3 | p.Mode&0x7
 	data[1] = p.Stratum
 	data[2] = byte(p.Poll)
 	data[3] = byte(p.Precision)
@@ -177,9 +229,54 @@ func (p *NTPPacket) Bytes() []byte {
 	binary.BigEndian.PutUint32(data[40:44], p.XmitTimeSec)
 	binary.BigEndian.PutUint32(data[44:48], p.XmitTimeFrac)
 
+	// Append extension fields
+	for _, f := range p.Extensions {
+		data = append(data, f.Bytes()...)
+	}
+
 	return data
 }
 
+// SetLeapIndicator sets the leap indicator (0-3). A value that does not
+// fit in its 2 bits is rejected and leaves the packet unchanged.
+func (p *NTPPacket) SetLeapIndicator(li int) error {
+	if li < LeapNoWarning || li > LeapAlarm {
+		return fmt.Errorf("leap indicator %d does not fit in 2 bits", li)
+	}
+	p.LeapIndicator = uint8(li)
+	return nil
+}
+
+// SetVersion sets the version number (0-7 on the wire, 1-4 defined). A
+// value that does not fit in its 3 bits is rejected.
+func (p *NTPPacket) SetVersion(v int) error {
+	if v < 0 || v > 7 {
+		return fmt.Errorf("version %d does not fit in 3 bits", v)
+	}
+	p.Version = uint8(v)
+	return nil
+}
+
+// SetMode sets the association mode (0-7). A value that does not fit in
+// its 3 bits is rejected.
+func (p *NTPPacket) SetMode(m int) error {
+	if m < ModeReserved || m > ModePrivate {
+		return fmt.Errorf("mode %d does not fit in 3 bits", m)
+	}
+	p.Mode = uint8(m)
+	return nil
+}
+
+// SetStratum sets the stratum. Reserved values (17-255) are allowed for
+// crafting bad packets; anything outside a byte is rejected.
+func (p *NTPPacket) SetStratum(s int) error {
+	if s < 0 || s > 255 {
+		return fmt.Errorf("stratum %d does not fit in 8 bits", s)
+	}
+	p.Stratum = uint8(s)
+	return nil
+}
+
 // SetReferenceTime sets the reference timestamp
 func (p *NTPPacket) SetReferenceTime(t time.Time) {
 	ts := TimeToNTPTimestamp(t)
@@ -207,7 +304,15 @@ func (p *NTPPacket) SetTransmitTime(t time.Time) {
 	p.XmitTimeFrac = ts.Fraction
 }
 
-// GetTransmitTime returns the transmit time as time.Time
+// GetReceiveTime returns the receive time as time.Time in era 0
+func (p *NTPPacket) GetReceiveTime() time.Time {
+	return NTPTimestampToTime(NTPTimestamp{
+		Seconds:  p.RecvTimeSec,
+		Fraction: p.RecvTimeFrac,
+	})
+}
+
+// GetTransmitTime returns the transmit time as time.Time in era 0
 func (p *NTPPacket) GetTransmitTime() time.Time {
 	return NTPTimestampToTime(NTPTimestamp{
 		Seconds:  p.XmitTimeSec,
@@ -225,6 +330,22 @@ func (p *NTPPacket) SetKissOfDeathCode(code string) error {
 	return nil
 }
 
+// KissCodes lists the kiss codes defined by RFC 5905
+var KissCodes = []string{
+	KoDACSTDeny, KoDAuthFail, KoDAuto, KoDBcst, KoDCryp, KoDDeny, KoDDrop,
+	KoDRstr, KoDInit, KoDMcst, KoDNkey, KoDRate, KoDRmot, KoDStep,
+}
+
+// IsKissCode reports whether code is a kiss code defined by RFC 5905
+func IsKissCode(code string) bool {
+	for _, c := range KissCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
 // GetKissOfDeathCode returns the kiss code if stratum is 0
 func (p *NTPPacket) GetKissOfDeathCode() string {
 	if p.Stratum != 0 {
@@ -243,9 +364,53 @@ func (p *NTPPacket) SetReferenceIDFromIP(ip string) {
 	p.ReferenceID = binary.BigEndian.Uint32(parts[:])
 }
 
+// ParseReferenceID converts a reference identifier to its wire value. IPv4
+// addresses are used as-is and IPv6 addresses are hashed as in RFC 5905
+// (first 4 bytes of the MD5 digest); isAddr is true for both. Anything else
+// must be a refclock code of 1-4 printable ASCII characters, NUL padded.
+func ParseReferenceID(s string) (id uint32, isAddr bool, err error) {
+	if ip := net.ParseIP(s); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			return binary.BigEndian.Uint32(ip4), true, nil
+		}
+		sum := md5.Sum(ip.To16())
+		return binary.BigEndian.Uint32(sum[:4]), true, nil
+	}
+
+	if len(s) == 0 || len(s) > 4 {
+		return 0, false, fmt.Errorf("reference ID %q must be an IP address or 1-4 characters", s)
+	}
+	var code [4]byte
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7e {
+			return 0, false, fmt.Errorf("reference ID %q contains non-printable characters", s)
+		}
+		code[i] = s[i]
+	}
+	return binary.BigEndian.Uint32(code[:]), false, nil
+}
+
+// RefClockID converts a refclock code of 1-4 printable ASCII characters
+// (GPS, PPS, DCFa...) to the reference ID a stratum 1 server advertises
+func RefClockID(code string) (uint32, error) {
+	id, isAddr, err := ParseReferenceID(code)
+	if err != nil {
+		return 0, err
+	}
+	if isAddr {
+		return 0, fmt.Errorf("reference clock %q is an address, not a refclock code", code)
+	}
+	return id, nil
+}
+
 // GetModeString returns a human-readable mode string
 func (p *NTPPacket) GetModeString() string {
-	switch p.Mode {
+	return ModeString(p.Mode)
+}
+
+// ModeString returns the name of an association mode
+func ModeString(mode uint8) string {
+	switch mode {
 	case ModeReserved:
 		return "Reserved"
 	case ModeSymmetricActive:
@@ -272,20 +437,44 @@ func (p *NTPPacket) IsValidClientRequest() bool {
 	return p.Mode == ModeClient && (p.Version == VersionNTPv3 || p.Version == VersionNTPv4)
 }
 
+// IsValidSymmetricActive checks if the packet is a valid symmetric active
+// (mode 1) packet, as sent by a host configured to peer with the server
+func (p *NTPPacket) IsValidSymmetricActive() bool {
+	return p.Mode == ModeSymmetricActive && (p.Version == VersionNTPv3 || p.Version == VersionNTPv4)
+}
+
 // String returns a human-readable representation of the packet
 func (p *NTPPacket) String() string {
 	return fmt.Sprintf("NTP{LI:%d VN:%d Mode:%s Stratum:%d Poll:%d Prec:%d}",
 		p.LeapIndicator, p.Version, p.GetModeString(), p.Stratum, p.Poll, p.Precision)
 }
 
-// CalculateRootDelay converts milliseconds to NTP short format
+// MaxShortFormat is the largest value representable in NTP short format
+// (16.16 fixed point, just under 65536 seconds)
+const MaxShortFormat uint32 = 0xFFFFFFFF
+
+// CalculateRootDelay converts a round-trip delay in milliseconds to NTP short
+// format. Negative or NaN delays yield 0 and oversized ones saturate.
 func CalculateRootDelay(ms float64) uint32 {
-	// NTP short format: 16 bits seconds, 16 bits fraction
-	secs := ms / 1000.0
-	return uint32(secs * 65536)
+	return msToShortFormat(ms)
 }
 
-// CalculateRootDispersion converts milliseconds to NTP short format
+// CalculateRootDispersion converts a dispersion in milliseconds to NTP short
+// format. Dispersion is an unsigned error bound, so negative inputs yield 0
+// and oversized ones saturate to the maximum.
 func CalculateRootDispersion(ms float64) uint32 {
-	return CalculateRootDelay(ms)
+	return msToShortFormat(ms)
+}
+
+// msToShortFormat converts milliseconds to clamped 16.16 fixed point
+func msToShortFormat(ms float64) uint32 {
+	// NTP short format: 16 bits seconds, 16 bits fraction
+	units := ms / 1000.0 * 65536
+	switch {
+	case math.IsNaN(units) || units <= 0:
+		return 0
+	case units >= float64(MaxShortFormat):
+		return MaxShortFormat
+	}
+	return uint32(units)
 }
@@ -0,0 +1,146 @@
+package ntpcore
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParsePacket(t *testing.T) {
+	header := NewPacket().Bytes()
+
+	authenticated := NewPacket()
+	if err := authenticated.SetMAC(1, []byte("timehammer-test-key"), MACAlgoMD5); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		err      error // Typed error ParsePacket wraps (nil = accepted)
+		hasMAC   bool
+		unparsed int
+	}{
+		{name: "header only", data: header},
+		{name: "header and MD5 MAC", data: authenticated.Bytes(), hasMAC: true},
+		{name: "two stray trailing bytes", data: append(append([]byte(nil), header...), 0xDE, 0xAD), unparsed: 2},
+		{name: "empty", data: nil, err: ErrEmptyPacket},
+		{name: "truncated header", data: header[:NTPPacketMinSize-1], err: ErrTruncatedPacket},
+		{name: "oversized", data: make([]byte, NTPPacketMaxSize+1), err: ErrOversizedPacket},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ParsePacket(tt.data)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err = %v, want %v", err, tt.err)
+				}
+				if p != nil {
+					t.Error("returned a packet along with the error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.HasMAC != tt.hasMAC {
+				t.Errorf("HasMAC = %v, want %v", p.HasMAC, tt.hasMAC)
+			}
+			if len(p.Unparsed) != tt.unparsed {
+				t.Errorf("%d unparsed bytes, want %d", len(p.Unparsed), tt.unparsed)
+			}
+			if !bytes.Equal(p.Bytes(), tt.data) {
+				t.Error("packet does not serialize back to the parsed bytes")
+			}
+		})
+	}
+}
+
+func TestHeaderSetters(t *testing.T) {
+	tests := []struct {
+		name  string
+		set   func(p *NTPPacket, v int) error
+		field func(p *NTPPacket) uint8
+		max   int
+	}{
+		{"leap indicator", (*NTPPacket).SetLeapIndicator, func(p *NTPPacket) uint8 { return p.LeapIndicator }, LeapAlarm},
+		{"version", (*NTPPacket).SetVersion, func(p *NTPPacket) uint8 { return p.Version }, 7},
+		{"mode", (*NTPPacket).SetMode, func(p *NTPPacket) uint8 { return p.Mode }, ModePrivate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for v := 0; v <= tt.max; v++ {
+				p := NewPacket()
+				if err := tt.set(p, v); err != nil {
+					t.Errorf("%d: %v", v, err)
+				} else if int(tt.field(p)) != v {
+					t.Errorf("%d: field is %d", v, tt.field(p))
+				}
+			}
+			for _, v := range []int{-1, tt.max + 1, 255} {
+				p := NewPacket()
+				before := tt.field(p)
+				if err := tt.set(p, v); err == nil {
+					t.Errorf("%d: accepted", v)
+				}
+				if tt.field(p) != before {
+					t.Errorf("%d: rejected value changed the field to %d", v, tt.field(p))
+				}
+			}
+		})
+	}
+}
+
+// Out-of-range values assigned to the fields directly are masked to their
+// bits, so they cannot spill into the neighbouring field
+func TestFirstByteMasking(t *testing.T) {
+	tests := []struct {
+		li, vn, mode uint8
+		want         byte
+	}{
+		{LeapAlarm, VersionNTPv4, ModeServer, 0xE4},
+		{7, 0, 0, 0xC0},    // LI bit 2 would land in VN
+		{0, 0x0F, 0, 0x38}, // VN bit 3 would land in LI
+		{0, 0, 0x0F, 0x07}, // Mode bit 3 would land in VN
+		{0xFF, 0xFF, 0xFF, 0xFF},
+	}
+
+	for _, tt := range tests {
+		p := NewPacket()
+		p.LeapIndicator, p.Version, p.Mode = tt.li, tt.vn, tt.mode
+		if got := p.Bytes()[0]; got != tt.want {
+			t.Errorf("LI=%d VN=%d mode=%d: first byte %#02x, want %#02x", tt.li, tt.vn, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestMsToShortFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		ms   float64
+		want uint32
+	}{
+		{"zero", 0, 0},
+		{"one second", 1000, 0x00010000},
+		{"half a second", 500, 0x00008000},
+		{"below one unit", 0.01, 0},
+		{"largest value", 65535999.99, 0xFFFFFFFF},
+		{"one short of the top", (65536 - 1.0/65536*2) * 1000, 0xFFFFFFFE},
+		{"at the top", 65536000, MaxShortFormat},
+		{"large", 1e12, MaxShortFormat},
+		{"negative", -5, 0},
+		{"tiny negative", -1e-9, 0},
+		{"NaN", math.NaN(), 0},
+		{"+Inf", math.Inf(1), MaxShortFormat},
+		{"-Inf", math.Inf(-1), 0},
+	}
+
+	for _, tt := range tests {
+		if got := msToShortFormat(tt.ms); got != tt.want {
+			t.Errorf("%s (%v ms): got %#08x, want %#08x", tt.name, tt.ms, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package ntpcore
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// Classic pcap magic numbers, as read in little-endian order
+const (
+	pcapMagicMicro        = 0xa1b2c3d4
+	pcapMagicMicroSwapped = 0xd4c3b2a1
+	pcapMagicNano         = 0xa1b23c4d
+	pcapMagicNanoSwapped  = 0x4d3cb2a1
+
+	pcapLinkEthernet = 1
+	pcapMaxRecord    = 256 * 1024 // Larger records are treated as corruption
+
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86dd
+	etherTypeVLAN = 0x8100
+	ipProtoUDP    = 17
+)
+
+// CapturedPacket is a UDP datagram read from a capture file
+type CapturedPacket struct {
+	Time    time.Time
+	SrcIP   net.IP
+	DstIP   net.IP
+	SrcPort uint16
+	DstPort uint16
+	Payload []byte
+}
+
+// ReadPCAP reads the UDP datagrams to or from port 123 in a classic pcap
+// file with Ethernet framing. Frames that are not IPv4/IPv6 UDP, or are
+// truncated, are skipped.
+func ReadPCAP(path string) ([]CapturedPacket, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hdr := make([]byte, 24)
+	if _, err := io.ReadFull(f, hdr); err != nil {
+		return nil, fmt.Errorf("%s: not a pcap file: %w", path, err)
+	}
+
+	var order binary.ByteOrder
+	nano := false
+	switch binary.LittleEndian.Uint32(hdr[0:4]) {
+	case pcapMagicMicro:
+		order = binary.LittleEndian
+	case pcapMagicMicroSwapped:
+		order = binary.BigEndian
+	case pcapMagicNano:
+		order, nano = binary.LittleEndian, true
+	case pcapMagicNanoSwapped:
+		order, nano = binary.BigEndian, true
+	default:
+		return nil, fmt.Errorf("%s: not a classic pcap file (pcapng is not supported)", path)
+	}
+	if link := order.Uint32(hdr[20:24]); link != pcapLinkEthernet {
+		return nil, fmt.Errorf("%s: unsupported link type %d (only Ethernet)", path, link)
+	}
+
+	var packets []CapturedPacket
+	rec := make([]byte, 16)
+	for {
+		if _, err := io.ReadFull(f, rec); err != nil {
+			if errors.Is(err, io.EOF) {
+				return packets, nil
+			}
+			return packets, fmt.Errorf("%s: truncated record header", path)
+		}
+
+		capLen := order.Uint32(rec[8:12])
+		if capLen > pcapMaxRecord {
+			return packets, fmt.Errorf("%s: record of %d bytes is too large", path, capLen)
+		}
+		frame := make([]byte, capLen)
+		if _, err := io.ReadFull(f, frame); err != nil {
+			return packets, fmt.Errorf("%s: truncated record", path)
+		}
+
+		frac := time.Duration(order.Uint32(rec[4:8]))
+		if !nano {
+			frac *= time.Microsecond
+		}
+		ts := time.Unix(int64(order.Uint32(rec[0:4])), int64(frac))
+
+		if pkt, ok := decodeFrame(frame); ok && (pkt.SrcPort == 123 || pkt.DstPort == 123) {
+			pkt.Time = ts
+			packets = append(packets, pkt)
+		}
+	}
+}
+
+// decodeFrame extracts the UDP datagram from an Ethernet frame
+func decodeFrame(frame []byte) (CapturedPacket, bool) {
+	var pkt CapturedPacket
+	if len(frame) < 14 {
+		return pkt, false
+	}
+	etherType := binary.BigEndian.Uint16(frame[12:14])
+	payload := frame[14:]
+	if etherType == etherTypeVLAN && len(payload) >= 4 {
+		etherType = binary.BigEndian.Uint16(payload[2:4])
+		payload = payload[4:]
+	}
+
+	var udp []byte
+	switch etherType {
+	case etherTypeIPv4:
+		if len(payload) < 20 || payload[0]>>4 != 4 {
+			return pkt, false
+		}
+		ihl := int(payload[0]&0x0f) * 4
+		if ihl < 20 || len(payload) < ihl || payload[9] != ipProtoUDP {
+			return pkt, false
+		}
+		// Later fragments carry no UDP header
+		if binary.BigEndian.Uint16(payload[6:8])&0x1fff != 0 {
+			return pkt, false
+		}
+		pkt.SrcIP = net.IP(append([]byte(nil), payload[12:16]...))
+		pkt.DstIP = net.IP(append([]byte(nil), payload[16:20]...))
+		udp = payload[ihl:]
+	case etherTypeIPv6:
+		if len(payload) < 40 || payload[6] != ipProtoUDP {
+			return pkt, false
+		}
+		pkt.SrcIP = net.IP(append([]byte(nil), payload[8:24]...))
+		pkt.DstIP = net.IP(append([]byte(nil), payload[24:40]...))
+		udp = payload[40:]
+	default:
+		return pkt, false
+	}
+
+	if len(udp) < 8 {
+		return pkt, false
+	}
+	pkt.SrcPort = binary.BigEndian.Uint16(udp[0:2])
+	pkt.DstPort = binary.BigEndian.Uint16(udp[2:4])
+	end := int(binary.BigEndian.Uint16(udp[4:6]))
+	if end < 8 || end > len(udp) {
+		end = len(udp) // Trust the capture over a bad length field
+	}
+	pkt.Payload = append([]byte(nil), udp[8:end]...)
+	return pkt, true
+}
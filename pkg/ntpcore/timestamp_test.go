@@ -0,0 +1,88 @@
+package ntpcore
+
+import (
+	"testing"
+	"time"
+)
+
+// eraWrap is when the 32-bit NTP seconds field wraps to era 1
+var eraWrap = time.Date(2036, time.February, 7, 6, 28, 16, 0, time.UTC)
+
+func TestTimestampRoundTrip(t *testing.T) {
+	base := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	for _, ns := range []int{0, 1, 2, 3, 499999999, 500000000, 500000001, 999999998, 999999999} {
+		in := base.Add(time.Duration(ns))
+		ts := TimeToNTPTimestamp(in)
+		if got := NTPTimestampToTime(ts); !got.Equal(in) {
+			t.Errorf("%d ns: round trip gave %v, want %v", ns, got.Format(time.RFC3339Nano), in.Format(time.RFC3339Nano))
+		}
+	}
+}
+
+// Fractions finer than a nanosecond (one unit is about 0.23 ns) convert to
+// the nearest nanosecond
+func TestTimestampSubNanosecondFractions(t *testing.T) {
+	secs := uint32(time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC).Unix() + NTPEpochOffset)
+	tests := []struct {
+		frac uint32
+		want time.Duration // Past the whole second
+	}{
+		{0, 0},
+		{1, 0},               // 0.23 ns
+		{2, 0},               // 0.47 ns
+		{3, time.Nanosecond}, // 0.70 ns
+		{1 << 31, 500 * time.Millisecond},
+		{0xFFFFFFFC, time.Second - time.Nanosecond}, // 999999999.07 ns
+		{0xFFFFFFFE, time.Second},                   // 999999999.53 ns rounds into the next second
+		{0xFFFFFFFF, time.Second},                   // 999999999.77 ns
+	}
+
+	for _, tt := range tests {
+		got := NTPTimestampToTime(NTPTimestamp{Seconds: secs, Fraction: tt.frac})
+		want := time.Unix(int64(secs)-NTPEpochOffset, 0).Add(tt.want)
+		if !got.Equal(want) {
+			t.Errorf("fraction %#x: got %v, want %v", tt.frac, got.Format(time.RFC3339Nano), want.Format(time.RFC3339Nano))
+		}
+
+		// Back to a fraction within half a nanosecond of where it started
+		back := TimeToNTPTimestamp(got)
+		diff := int64(back.Seconds-secs)<<32 + int64(back.Fraction) - int64(tt.frac)
+		if diff < -3 || diff > 3 {
+			t.Errorf("fraction %#x: came back as %d.%#x", tt.frac, back.Seconds-secs, back.Fraction)
+		}
+	}
+}
+
+func TestTimestampEraBoundary(t *testing.T) {
+	tests := []struct {
+		name string
+		in   time.Time
+		secs uint32
+		era  int
+	}{
+		{"last nanosecond of era 0", eraWrap.Add(-time.Nanosecond), 0xFFFFFFFF, 0},
+		{"start of era 1", eraWrap, 0, 1},
+		{"just past the wrap", eraWrap.Add(1500 * time.Millisecond), 1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, era := TimeToNTPTimestampEra(tt.in)
+			if ts.Seconds != tt.secs || era != tt.era {
+				t.Fatalf("got seconds %#x era %d, want %#x era %d", ts.Seconds, era, tt.secs, tt.era)
+			}
+			if got := NTPTimestampToTimeEra(ts, era); !got.Equal(tt.in) {
+				t.Errorf("NTPTimestampToTimeEra = %v, want %v", got.Format(time.RFC3339Nano), tt.in.Format(time.RFC3339Nano))
+			}
+			if got := NTPTimestampToTimeNear(ts, eraWrap); !got.Equal(tt.in) {
+				t.Errorf("NTPTimestampToTimeNear = %v, want %v", got.Format(time.RFC3339Nano), tt.in.Format(time.RFC3339Nano))
+			}
+
+			// Without the era, era 1 timestamps read as 1900
+			got := NTPTimestampToTime(ts)
+			if want := tt.in.Add(-time.Duration(tt.era) * NTPEraSeconds * time.Second); !got.Equal(want) {
+				t.Errorf("NTPTimestampToTime = %v, want %v", got.Format(time.RFC3339Nano), want.Format(time.RFC3339Nano))
+			}
+		})
+	}
+}